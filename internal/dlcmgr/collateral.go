@@ -0,0 +1,80 @@
+package dlcmgr
+
+import (
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/rpc"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+	"github.com/p2pderivatives/dlc/pkg/utils"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+const fundTxOutAt = 0 // fund txout is always at 0 in fund tx
+
+// LockedInContracts returns total collateral of the wallet in fund outputs
+// of open contracts. A contract is open if its fund tx is in given wallet txs
+// and the fund output is unspent on chain. The wallet's party is the one
+// whose utxos are spent by the fund tx.
+func (m *Manager) LockedInContracts(
+	txs []wallet.Transaction, rpcc rpc.Client,
+) (btcutil.Amount, error) {
+	contracts, err := m.Contracts()
+	if err != nil {
+		return 0, err
+	}
+
+	txids := make(map[string]bool)
+	credits := make(map[wire.OutPoint]bool)
+	for _, tx := range txs {
+		txid := tx.MsgTx.TxHash()
+		txids[txid.String()] = true
+		for _, c := range tx.Credits {
+			credits[wire.OutPoint{Hash: txid, Index: c.Index}] = true
+		}
+	}
+
+	total := btcutil.Amount(0)
+	for _, d := range contracts {
+		fundtx, err := d.FundTx()
+		if err != nil {
+			return 0, err
+		}
+		txid := fundtx.TxHash()
+		if !txids[txid.String()] {
+			continue
+		}
+
+		p, ok := ownParty(d, credits)
+		if !ok {
+			continue
+		}
+
+		// outputs spent in mempool are still locked until confirmed
+		res, err := rpcc.GetTxOut(&txid, fundTxOutAt, false)
+		if err != nil {
+			return 0, err
+		}
+		if res == nil {
+			continue
+		}
+		total += d.Conds.FundAmts[p]
+	}
+	return total, nil
+}
+
+// ownParty returns the party whose fund utxos are credits of the wallet
+func ownParty(
+	d *dlc.DLC, credits map[wire.OutPoint]bool) (dlc.Contractor, bool) {
+	for _, p := range d.Conds.Parties() {
+		for _, utxo := range d.Utxos[p] {
+			txin, err := utils.UtxoToTxIn(utxo)
+			if err != nil {
+				continue
+			}
+			if credits[txin.PreviousOutPoint] {
+				return p, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,71 @@
+package dlcmgr
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/rpcmock"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newFundedDLC returns a DLC whose first party's utxo is
+// an output of a wallet tx, and the wallet txs including the fund tx
+func newFundedDLC(t *testing.T, seed byte) (*dlc.DLC, []wallet.Transaction) {
+	prev := wire.NewMsgTx(2)
+	prev.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{seed}}, nil, nil))
+	prev.AddTxOut(wire.NewTxOut(2*btcutil.SatoshiPerBitcoin, []byte{txscript.OP_TRUE}))
+
+	d := newDLC()
+	d.Utxos[dlc.FirstParty][0].TxID = prev.TxHash().String()
+	d.Utxos[dlc.FirstParty][0].Vout = 0
+	for _, p := range d.Conds.Parties() {
+		d.Utxos[p][0].Amount = 2
+	}
+	d.Utxos[dlc.SecondParty][0].TxID = chainhash.Hash{seed, 1}.String()
+
+	fundtx, err := d.FundTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := []wallet.Transaction{
+		{MsgTx: prev, Credits: []wallet.TxCredit{{Index: 0}}},
+		{MsgTx: fundtx, Debit: 2 * btcutil.SatoshiPerBitcoin},
+	}
+	return d, txs
+}
+
+func TestLockedInContracts(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	// open, settled and not funded contracts
+	dOpen, txsOpen := newFundedDLC(t, 1)
+	dSettled, txsSettled := newFundedDLC(t, 2)
+	dNotFunded, _ := newFundedDLC(t, 3)
+	for i, d := range []*dlc.DLC{dOpen, dSettled, dNotFunded} {
+		err := manager.StoreContract([]byte{byte(i)}, d)
+		assert.NoError(err)
+	}
+	txs := append(txsOpen, txsSettled...)
+
+	openFundTx, _ := dOpen.FundTx()
+	openTxID := openFundTx.TxHash()
+	rpcc := &rpcmock.Client{}
+	rpcc.On("GetTxOut", &openTxID, uint32(0), false).Return(
+		&btcjson.GetTxOutResult{Confirmations: 1}, nil)
+	rpcc.On("GetTxOut", mock.Anything, uint32(0), false).Return(nil, nil)
+
+	amt, err := manager.LockedInContracts(txs, rpcc)
+	assert.NoError(err)
+	assert.Equal(dOpen.Conds.FundAmts[dlc.FirstParty], amt)
+}
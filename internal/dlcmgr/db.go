@@ -5,11 +5,16 @@ import (
 	"fmt"
 
 	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
 )
 
 var (
-	nsTop         = []byte("dlcmgr")
-	nsContracts   = []byte("contracts")
+	nsTop       = []byte("dlcmgr")
+	nsVersion   = []byte("version")
+	nsContracts = []byte("contracts")
+	nsDLC       = []byte("dlc")
+
+	// keys of the JSON encoded fields of version 1 and older
 	nsOracle      = []byte("oracle")
 	nsConditions  = []byte("conds")
 	nsPubkeys     = []byte("pubkeys")
@@ -19,6 +24,9 @@ var (
 	nsFundWits    = []byte("fundwits")
 	nsRefundSigs  = []byte("refundsigs")
 	nsExecSigs    = []byte("execsigs")
+	nsBuffer      = []byte("buffer")
+	nsNonces      = []byte("nonces")
+	nsFundKeyIdx  = []byte("fundkeyidx")
 )
 
 func createManager(db walletdb.DB) error {
@@ -39,15 +47,129 @@ func createBucketsIfNotExist(tx walletdb.ReadWriteTx) (
 		if err != nil {
 			return nil, nil, err
 		}
+		// a new database is of the latest version
+		if err = putVersion(top, latestVersion()); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	contracts, err := top.CreateBucketIfNotExists(nsContracts)
 	return top, contracts, err
 }
 
-func openManager(db walletdb.DB) *Manager {
-	mgr := &Manager{db: db}
-	return mgr
+// WalletDBStore is ContractStore on a wallet database
+type WalletDBStore struct {
+	db walletdb.DB
+}
+
+var _ ContractStore = (*WalletDBStore)(nil)
+
+// NewWalletDBStore creates WalletDBStore on a database
+// already created by Create
+func NewWalletDBStore(db walletdb.DB) *WalletDBStore {
+	return &WalletDBStore{db: db}
+}
+
+// Put stores a contract in the binary encoding
+func (s *WalletDBStore) Put(k []byte, d *dlc.DLC) error {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	storeFunc := func(b walletdb.ReadWriteBucket) error {
+		return b.Put(nsDLC, data)
+	}
+	return s.updateContractBucket(k, storeFunc)
+}
+
+// Insert stores a contract if no contract is stored with the key
+func (s *WalletDBStore) Insert(k []byte, d *dlc.DLC) error {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return walletdb.Update(s.db, func(tx walletdb.ReadWriteTx) error {
+		_, contracts, e := createBucketsIfNotExist(tx)
+		if e != nil {
+			return e
+		}
+		if contracts.NestedReadWriteBucket(k) != nil {
+			return newContractExistsError(k)
+		}
+		b, e := contracts.CreateBucket(k)
+		if e != nil {
+			return e
+		}
+		return b.Put(nsDLC, data)
+	})
+}
+
+// Get retrieves a stored contract
+func (s *WalletDBStore) Get(k []byte) (*dlc.DLC, error) {
+	d := &dlc.DLC{}
+	retrieveFunc := func(b walletdb.ReadBucket) error {
+		return d.UnmarshalBinary(b.Get(nsDLC))
+	}
+	if err := s.viewContractBucket(k, retrieveFunc); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Has returns true if a contract is stored
+func (s *WalletDBStore) Has(k []byte) (bool, error) {
+	exists := false
+	err := walletdb.View(s.db, func(tx walletdb.ReadTx) error {
+		contracts := contractsBucket(tx)
+		exists = contracts != nil && contracts.NestedReadBucket(k) != nil
+		return nil
+	})
+	return exists, err
+}
+
+// Query retrieves contracts matching a query.
+// All contracts are decoded to be filtered since the database has no index.
+func (s *WalletDBStore) Query(q *Query) ([]*dlc.DLC, error) {
+	ds := []*dlc.DLC{}
+	err := walletdb.View(s.db, func(tx walletdb.ReadTx) error {
+		contracts := contractsBucket(tx)
+		if contracts == nil {
+			return nil
+		}
+		return contracts.ForEach(func(k, v []byte) error {
+			// contracts are nested buckets
+			if v != nil {
+				return nil
+			}
+			d := &dlc.DLC{}
+			data := contracts.NestedReadBucket(k).Get(nsDLC)
+			if err := d.UnmarshalBinary(data); err != nil {
+				return err
+			}
+			if q.match(d) {
+				ds = append(ds, d)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// Close closes the database
+func (s *WalletDBStore) Close() error {
+	return s.db.Close()
+}
+
+// contractsBucket returns the bucket of contracts (nil if not created)
+func contractsBucket(tx walletdb.ReadTx) walletdb.ReadBucket {
+	top := tx.ReadBucket(nsTop)
+	if top == nil {
+		return nil
+	}
+	return top.NestedReadBucket(nsContracts)
 }
 
 // BucketNotExistsError is error raised when bucket doesn't exist
@@ -55,7 +177,7 @@ type BucketNotExistsError struct {
 	error
 }
 
-func (m *Manager) updateContractBucket(
+func (s *WalletDBStore) updateContractBucket(
 	k []byte, f func(walletdb.ReadWriteBucket) error) error {
 	updateFunc := func(tx walletdb.ReadWriteTx) (e error) {
 		// TODO: workaround for panicking inside callback function
@@ -74,7 +196,7 @@ func (m *Manager) updateContractBucket(
 		}
 		return f(bucket)
 	}
-	err := walletdb.Update(m.db, updateFunc)
+	err := walletdb.Update(s.db, updateFunc)
 	return err
 }
 
@@ -89,7 +211,7 @@ func newContractNotExistsError(
 	return &ContractNotExistsError{error: errors.New(msg)}
 }
 
-func (m *Manager) viewContractBucket(
+func (s *WalletDBStore) viewContractBucket(
 	k []byte, f func(walletdb.ReadBucket) error) error {
 	viewFunc := func(tx walletdb.ReadTx) error {
 		top := tx.ReadBucket(nsTop)
@@ -108,5 +230,5 @@ func (m *Manager) viewContractBucket(
 		}
 		return f(bucket)
 	}
-	return walletdb.View(m.db, viewFunc)
+	return walletdb.View(s.db, viewFunc)
 }
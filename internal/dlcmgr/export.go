@@ -0,0 +1,235 @@
+package dlcmgr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/snacl"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+)
+
+const (
+	// contractFileVersion is the version of exported contract files
+	contractFileVersion = byte(1)
+
+	// size of marshaled scrypt parameters (salt, digest, N, R and P)
+	scryptParamsSize = snacl.KeySize + sha256.Size + 24
+
+	// upper bounds of scrypt parameters accepted from a file
+	maxScryptN = 1 << 20
+	maxScryptR = 32
+	maxScryptP = 16
+)
+
+// contractFileMagic is the prefix of exported contract files
+var contractFileMagic = []byte("DLCX")
+
+// scryptOptions are parameters of scrypt deriving encryption keys
+// of exported contracts from passphrases
+type scryptOptions struct {
+	N, R, P int
+}
+
+// exportScryptOptions are the same as the ones of the wallet's master keys
+// (overwritten by tests)
+var exportScryptOptions = scryptOptions{N: 262144, R: 8, P: 1}
+
+// InvalidContractFileError is raised when an exported contract can't be read
+type InvalidContractFileError struct{ error }
+
+// contractFile is the plaintext of an exported contract
+type contractFile struct {
+	Key             []byte                              `json:"key"`
+	Conditions      *dlc.Conditions                     `json:"conditions"`
+	Oracle          json.RawMessage                     `json:"oracle"`
+	PublicKeys      dlc.PublicKeys                      `json:"pubkeys"`
+	Addresses       dlc.Addresses                       `json:"addresses"`
+	ChangeAddresses dlc.Addresses                       `json:"change_addresses"`
+	Utxos           map[dlc.Contractor][]*dlc.Utxo      `json:"utxos"`
+	FundWits        map[dlc.Contractor][]wire.TxWitness `json:"fund_witnesses"`
+	RefundSigs      map[dlc.Contractor][]byte           `json:"refund_sigs"`
+	ExecSigs        map[dlc.Contractor][][]byte         `json:"exec_sigs"`
+	Buffer          *dlc.Buffer                         `json:"buffer,omitempty"`
+	Nonces          *dlc.MuSigNonces                    `json:"nonces,omitempty"`
+	FundKey         *FundKeyDerivation                  `json:"fund_key,omitempty"`
+}
+
+// FundKeyDerivation is derivation info of the wallet's own fund pubkey
+type FundKeyDerivation struct {
+	// Index is the index of the pubkey on the wallet's fund account
+	Index uint32 `json:"index"`
+}
+
+// ExportContract exports a stored contract into a file encrypted with a passphrase.
+// The file holds conditions, oracle data, all signatures and
+// the derivation info of the fund pubkey, so that the contract can be
+// imported by another wallet of the same seed.
+// Secret MuSig2 nonces aren't exported since signing with them on two
+// installations would reuse the nonces and leak the fund key, so an imported
+// taproot contract can't sign CETxs that aren't signed yet.
+func (m *Manager) ExportContract(k []byte, passphrase []byte) ([]byte, error) {
+	d, err := m.RetrieveContract(k)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := json.Marshal(d.Oracle)
+	if err != nil {
+		return nil, err
+	}
+	f := &contractFile{
+		Key:             k,
+		Conditions:      d.Conds,
+		Oracle:          o,
+		PublicKeys:      d.PublicKeys(),
+		Addresses:       d.Addresses(),
+		ChangeAddresses: d.ChangeAddresses(),
+		Utxos:           d.Utxos,
+		FundWits:        d.FundWits,
+		RefundSigs:      d.RefundSigs,
+		ExecSigs:        d.ExecSigs,
+		Buffer:          d.Buffer,
+		Nonces:          publicNonces(d.Nonces),
+	}
+	if d.FundKeyIdx != nil {
+		f.FundKey = &FundKeyDerivation{Index: *d.FundKeyIdx}
+	}
+
+	plain, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return encryptContractFile(plain, passphrase)
+}
+
+// publicNonces returns a copy of MuSig2 nonces without the secret nonces
+func publicNonces(nonces *dlc.MuSigNonces) *dlc.MuSigNonces {
+	if nonces == nil {
+		return nil
+	}
+	return &dlc.MuSigNonces{Pub: nonces.Pub}
+}
+
+// ImportContract decrypts an exported contract and stores it.
+// It returns the key of the contract and the derivation info of
+// the fund pubkey (nil if unknown), which the wallet has to recover to sign.
+func (m *Manager) ImportContract(
+	data []byte, passphrase []byte) ([]byte, *FundKeyDerivation, error) {
+	plain, err := decryptContractFile(data, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := &contractFile{}
+	if err = json.Unmarshal(plain, f); err != nil {
+		return nil, nil, &InvalidContractFileError{error: err}
+	}
+	d, err := f.contract()
+	if err != nil {
+		return nil, nil, &InvalidContractFileError{error: err}
+	}
+
+	// a stored contract isn't overwritten
+	if err = m.store.Insert(f.Key, d); err != nil {
+		return nil, nil, err
+	}
+	return f.Key, f.FundKey, nil
+}
+
+// contract constructs the DLC of an exported contract
+func (f *contractFile) contract() (*dlc.DLC, error) {
+	if len(f.Key) == 0 || f.Conditions == nil {
+		return nil, errors.New("contract key and conditions are required")
+	}
+
+	d := dlc.NewDLC(f.Conditions)
+	if len(f.Oracle) != 0 && !bytes.Equal(f.Oracle, []byte("null")) {
+		o := dlc.NewOracle(len(f.Conditions.Deals))
+		if err := json.Unmarshal(f.Oracle, o); err != nil {
+			return nil, err
+		}
+		d.Oracle = o
+	}
+	if err := d.ParsePublicKeys(f.PublicKeys); err != nil {
+		return nil, err
+	}
+	if err := d.ParseAddresses(f.Addresses); err != nil {
+		return nil, err
+	}
+	if err := d.ParseChangeAddresses(f.ChangeAddresses); err != nil {
+		return nil, err
+	}
+	d.Utxos = f.Utxos
+	d.FundWits = f.FundWits
+	d.RefundSigs = f.RefundSigs
+	d.ExecSigs = f.ExecSigs
+	d.Buffer = f.Buffer
+	d.Nonces = f.Nonces
+	if f.FundKey != nil {
+		idx := f.FundKey.Index
+		d.FundKeyIdx = &idx
+	}
+	return d, nil
+}
+
+// encryptContractFile encrypts a contract with a key derived by scrypt.
+// The format is <magic><version><scrypt parameters><encrypted contract>.
+func encryptContractFile(plain, passphrase []byte) ([]byte, error) {
+	opts := exportScryptOptions
+	sk, err := snacl.NewSecretKey(&passphrase, opts.N, opts.R, opts.P)
+	if err != nil {
+		return nil, err
+	}
+	defer sk.Zero()
+
+	enc, err := sk.Encrypt(plain)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(contractFileMagic)
+	buf.WriteByte(contractFileVersion)
+	buf.Write(sk.Marshal())
+	buf.Write(enc)
+	return buf.Bytes(), nil
+}
+
+func decryptContractFile(data, passphrase []byte) ([]byte, error) {
+	headerSize := len(contractFileMagic) + 1 + scryptParamsSize
+	if len(data) < headerSize || !bytes.HasPrefix(data, contractFileMagic) {
+		msg := "not an exported contract"
+		return nil, &InvalidContractFileError{error: errors.New(msg)}
+	}
+	data = data[len(contractFileMagic):]
+
+	if v := data[0]; v != contractFileVersion {
+		msg := fmt.Sprintf("unsupported contract file version. %d", v)
+		return nil, &InvalidContractFileError{error: errors.New(msg)}
+	}
+	data = data[1:]
+
+	sk := &snacl.SecretKey{}
+	if err := sk.Unmarshal(data[:scryptParamsSize]); err != nil {
+		return nil, &InvalidContractFileError{error: err}
+	}
+	if p := sk.Parameters; p.N > maxScryptN || p.R > maxScryptR || p.P > maxScryptP {
+		msg := fmt.Sprintf("scrypt parameters too large. N: %d, R: %d, P: %d",
+			p.N, p.R, p.P)
+		return nil, &InvalidContractFileError{error: errors.New(msg)}
+	}
+	if err := sk.DeriveKey(&passphrase); err != nil {
+		return nil, err
+	}
+	defer sk.Zero()
+
+	plain, err := sk.Decrypt(data[scryptParamsSize:])
+	if err != nil {
+		return nil, &InvalidContractFileError{error: err}
+	}
+	return plain, nil
+}
@@ -0,0 +1,156 @@
+package dlcmgr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcwallet/snacl"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// fast scrypt for tests
+	exportScryptOptions = scryptOptions{N: 16, R: 8, P: 1}
+}
+
+func TestExportAndImportContract(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	key := []byte("testdlc")
+	dOrig := newDLC()
+	dOrig.Buffer = testBuffer()
+	dOrig.Nonces = testNonces()
+	idx := uint32(5)
+	dOrig.FundKeyIdx = &idx
+	err := manager.StoreContract(key, dOrig)
+	assert.NoError(err)
+
+	pass := []byte("passphrase")
+	data, err := manager.ExportContract(key, pass)
+	if !assert.NoError(err) {
+		return
+	}
+
+	// import into another installation
+	db2, closeFunc2 := newWalletDB()
+	defer closeFunc2()
+	manager2, _ := Create(db2)
+
+	_, _, err = manager2.ImportContract(data, []byte("wrong"))
+	assert.Equal(snacl.ErrInvalidPassword, err)
+
+	k, fundKey, err := manager2.ImportContract(data, pass)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(key, k)
+	assert.Equal(&FundKeyDerivation{Index: idx}, fundKey)
+	d, err := manager2.RetrieveContract(key)
+	assert.NoError(err)
+	assert.Empty(d.Nonces.Sec)
+	assert.Equal(dOrig.Nonces.Pub, d.Nonces.Pub)
+	d.Nonces = dOrig.Nonces
+	assert.Equal(dOrig, d)
+
+	// stored contract isn't overwritten
+	_, _, err = manager2.ImportContract(data, pass)
+	assert.IsType(&ContractExistsError{}, err)
+}
+
+// secret nonces must not be used by two installations
+func TestExportContractWithoutSecretNonces(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	key := []byte("testdlc")
+	d := newDLC()
+	d.Nonces = testNonces()
+	err := manager.StoreContract(key, d)
+	assert.NoError(err)
+
+	pass := []byte("passphrase")
+	data, err := manager.ExportContract(key, pass)
+	if !assert.NoError(err) {
+		return
+	}
+	plain, err := decryptContractFile(data, pass)
+	assert.NoError(err)
+	f := &contractFile{}
+	assert.NoError(json.Unmarshal(plain, f))
+	if assert.NotNil(f.Nonces) {
+		assert.Nil(f.Nonces.Sec)
+		assert.Equal(d.Nonces.Pub, f.Nonces.Pub)
+	}
+}
+
+// only one of concurrent imports of a contract stores it
+func TestImportContractConcurrently(t *testing.T) {
+	assert := assert.New(t)
+
+	manager := NewManager(NewMemoryStore())
+	key := []byte("testdlc")
+	err := manager.StoreContract(key, newDLC())
+	assert.NoError(err)
+	pass := []byte("passphrase")
+	data, err := manager.ExportContract(key, pass)
+	if !assert.NoError(err) {
+		return
+	}
+
+	manager2 := NewManager(NewMemoryStore())
+	n := 8
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, _, e := manager2.ImportContract(data, pass)
+			errs <- e
+		}()
+	}
+	imported := 0
+	for i := 0; i < n; i++ {
+		if e := <-errs; e == nil {
+			imported++
+		} else {
+			assert.IsType(&ContractExistsError{}, e)
+		}
+	}
+	assert.Equal(1, imported)
+}
+
+func TestImportContractInvalidFile(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	key := []byte("testdlc")
+	err := manager.StoreContract(key, newDLC())
+	assert.NoError(err)
+	pass := []byte("passphrase")
+	data, err := manager.ExportContract(key, pass)
+	assert.NoError(err)
+
+	// unknown version
+	invalid := append([]byte{}, data...)
+	invalid[len(contractFileMagic)] = contractFileVersion + 1
+	_, _, err = manager.ImportContract(invalid, pass)
+	assert.IsType(&InvalidContractFileError{}, err)
+
+	// tampered ciphertext
+	invalid = append([]byte{}, data...)
+	invalid[len(invalid)-1] ^= 0x01
+	_, _, err = manager.ImportContract(invalid, pass)
+	assert.IsType(&InvalidContractFileError{}, err)
+
+	// truncated
+	_, _, err = manager.ImportContract(data[:10], pass)
+	assert.IsType(&InvalidContractFileError{}, err)
+}
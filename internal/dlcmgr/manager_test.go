@@ -58,6 +58,76 @@ func TestStoreContract(t *testing.T) {
 	}
 }
 
+func TestStoreContractWithBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	// create new manager
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	key := []byte("testdlc")
+	dOrig := newDLC()
+	dOrig.Buffer = testBuffer()
+
+	err := manager.StoreContract(key, dOrig)
+	if assert.NoError(err) {
+		d, err := manager.RetrieveContract(key)
+		assert.NoError(err)
+		assert.Equal(dOrig, d)
+	}
+
+	// the buffer is removed when the contract leaves the channel
+	dOrig.Buffer = nil
+	err = manager.StoreContract(key, dOrig)
+	if assert.NoError(err) {
+		d, err := manager.RetrieveContract(key)
+		assert.NoError(err)
+		assert.Nil(d.Buffer)
+	}
+}
+
+func TestStoreContractInTaprootMode(t *testing.T) {
+	assert := assert.New(t)
+
+	// create new manager
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	key := []byte("testdlc")
+	dOrig := newDLC()
+	dOrig.Conds.FundMode = dlc.FundModeTaproot
+	dOrig.Nonces = testNonces()
+
+	err := manager.StoreContract(key, dOrig)
+	if assert.NoError(err) {
+		d, err := manager.RetrieveContract(key)
+		assert.NoError(err)
+		assert.Equal(dOrig, d)
+	}
+}
+
+func TestStoreContractWithFundKeyIdx(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	key := []byte("testdlc")
+	dOrig := newDLC()
+	idx := uint32(5)
+	dOrig.FundKeyIdx = &idx
+
+	err := manager.StoreContract(key, dOrig)
+	if assert.NoError(err) {
+		d, err := manager.RetrieveContract(key)
+		assert.NoError(err)
+		assert.Equal(dOrig, d)
+	}
+}
+
 func TestRetrieveContractNotExists(t *testing.T) {
 	assert := assert.New(t)
 
@@ -73,6 +143,30 @@ func TestRetrieveContractNotExists(t *testing.T) {
 	assert.IsType(err, &ContractNotExistsError{})
 }
 
+func TestContracts(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	manager, _ := Create(db)
+
+	ds, err := manager.Contracts()
+	assert.NoError(err)
+	assert.Empty(ds)
+
+	dOrig := newDLC()
+	for _, key := range []string{"testdlc1", "testdlc2"} {
+		err = manager.StoreContract([]byte(key), dOrig)
+		assert.NoError(err)
+	}
+
+	ds, err = manager.Contracts()
+	assert.NoError(err)
+	if assert.Len(ds, 2) {
+		assert.Equal(dOrig, ds[0])
+	}
+}
+
 func newWalletDB() (walletdb.DB, func()) {
 	path := testDBPath()
 	db, _ := walletdb.Create("bdb", path)
@@ -222,9 +316,32 @@ func testRefundSigs() map[dlc.Contractor][]byte {
 	return sigs
 }
 
-func testExecSigs() [][]byte {
-	sigs := [][]byte{}
-	sigs = append(sigs, []byte{1})
-	sigs = append(sigs, []byte{2})
+func testExecSigs() map[dlc.Contractor][][]byte {
+	sigs := make(map[dlc.Contractor][][]byte)
+	sigs[dlc.SecondParty] = [][]byte{{1}, {2}}
 	return sigs
 }
+
+func testBuffer() *dlc.Buffer {
+	buf, _ := dlc.NewBuffer(1, 144)
+	buf.Points = testPubkeys()
+	_, buf.PendingPoint = test.RandKeys()
+	buf.Sig = []byte{1}
+	buf.RevokedSecrets = [][]byte{{2}}
+	return buf
+}
+
+func testNonces() *dlc.MuSigNonces {
+	return &dlc.MuSigNonces{
+		Pub: map[dlc.Contractor]map[dlc.Contractor][][]byte{
+			dlc.FirstParty: {
+				dlc.FirstParty:  [][]byte{{1}, {2}},
+				dlc.SecondParty: [][]byte{{3}, {4}},
+			},
+		},
+		Sec: map[dlc.Contractor][][]byte{
+			dlc.FirstParty:  {{5}, {6}},
+			dlc.SecondParty: {nil, {7}},
+		},
+	}
+}
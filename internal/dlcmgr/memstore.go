@@ -0,0 +1,102 @@
+package dlcmgr
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+)
+
+// MemoryStore is ContractStore keeping contracts in memory.
+// Contracts are kept in the binary encoding, so that retrieved contracts
+// are copies independent of the stored ones as from other stores.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	contracts map[string][]byte
+}
+
+var _ ContractStore = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{contracts: make(map[string][]byte)}
+}
+
+// Put stores a contract
+func (s *MemoryStore) Put(k []byte, d *dlc.DLC) error {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contracts[string(k)] = data
+	return nil
+}
+
+// Insert stores a contract if no contract is stored with the key
+func (s *MemoryStore) Insert(k []byte, d *dlc.DLC) error {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.contracts[string(k)]; ok {
+		return newContractExistsError(k)
+	}
+	s.contracts[string(k)] = data
+	return nil
+}
+
+// Get retrieves a stored contract
+func (s *MemoryStore) Get(k []byte) (*dlc.DLC, error) {
+	s.mu.RLock()
+	data, ok := s.contracts[string(k)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, newContractNotExistsError(k)
+	}
+	d := &dlc.DLC{}
+	if err := d.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Has returns true if a contract is stored
+func (s *MemoryStore) Has(k []byte) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.contracts[string(k)]
+	return ok, nil
+}
+
+// Query retrieves contracts matching a query
+func (s *MemoryStore) Query(q *Query) ([]*dlc.DLC, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.contracts))
+	for k := range s.contracts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ds := []*dlc.DLC{}
+	for _, k := range keys {
+		d := &dlc.DLC{}
+		if err := d.UnmarshalBinary(s.contracts[k]); err != nil {
+			return nil, err
+		}
+		if q.match(d) {
+			ds = append(ds, d)
+		}
+	}
+	return ds, nil
+}
+
+// Close does nothing since memory has nothing to release
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,437 @@
+package dlcmgr
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+)
+
+// versions are schema versions of the dlcmgr database and the migrations
+// upgrading the previous versions. A database created before versioning
+// is version 0. New versions must be appended when the layout of buckets
+// or the encoding of stored values is changed.
+var versions = []migration.Version{
+	// version 1 stores CETx signatures of version 0 as a map by signer
+	{Number: 1, Migration: migrateExecSigsToMap},
+	// version 2 stores a contract as a single value of the binary encoding
+	{Number: 2, Migration: migrateToBinary},
+}
+
+// latestVersion returns the latest schema version
+func latestVersion() uint32 {
+	return migration.GetLatestVersion(versions)
+}
+
+// migrationManager upgrades the dlcmgr top bucket.
+// It implements migration.Manager.
+type migrationManager struct {
+	ns walletdb.ReadWriteBucket
+}
+
+var _ migration.Manager = (*migrationManager)(nil)
+
+// Name returns the name of the service
+func (m *migrationManager) Name() string {
+	return "dlc manager"
+}
+
+// Namespace returns the dlcmgr top bucket
+func (m *migrationManager) Namespace() walletdb.ReadWriteBucket {
+	return m.ns
+}
+
+// CurrentVersion returns the schema version of the database
+func (m *migrationManager) CurrentVersion(ns walletdb.ReadBucket) (uint32, error) {
+	return fetchVersion(ns)
+}
+
+// SetVersion sets the schema version of the database
+func (m *migrationManager) SetVersion(ns walletdb.ReadWriteBucket, version uint32) error {
+	return putVersion(ns, version)
+}
+
+// Versions returns all schema versions
+func (m *migrationManager) Versions() []migration.Version {
+	return versions
+}
+
+// upgradeManager upgrades the database to the latest schema version.
+// A database newer than this package is refused.
+func upgradeManager(db walletdb.DB) error {
+	return walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		top := tx.ReadWriteBucket(nsTop)
+		if top == nil {
+			// nothing to migrate
+			return nil
+		}
+		err := migration.Upgrade(&migrationManager{ns: top})
+		if err == migration.ErrReversion {
+			v, _ := fetchVersion(top)
+			msg := fmt.Sprintf(
+				"dlcmgr database version %d is newer than supported version %d",
+				v, latestVersion())
+			return &UnsupportedVersionError{error: errors.New(msg)}
+		}
+		return err
+	})
+}
+
+// UnsupportedVersionError is raised when a database is of a newer version
+type UnsupportedVersionError struct{ error }
+
+// fetchVersion returns the schema version stored in the top bucket
+// (0 if the database was created before versioning)
+func fetchVersion(top walletdb.ReadBucket) (uint32, error) {
+	data := top.Get(nsVersion)
+	if data == nil {
+		return 0, nil
+	}
+	if len(data) != 4 {
+		return 0, errors.New("invalid dlcmgr database version")
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func putVersion(top walletdb.ReadWriteBucket, version uint32) error {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, version)
+	return top.Put(nsVersion, data)
+}
+
+// legacyKeys are keys of the JSON encoded fields of a contract bucket
+var legacyKeys = [][]byte{
+	nsOracle, nsConditions, nsPubkeys, nsAddrs, nsChangeAddrs, nsUtxos,
+	nsFundWits, nsRefundSigs, nsExecSigs, nsBuffer, nsNonces, nsFundKeyIdx,
+}
+
+// contractKeys returns keys of the contract buckets
+func contractKeys(contracts walletdb.ReadBucket) ([][]byte, error) {
+	keys := [][]byte{}
+	err := contracts.ForEach(func(k, v []byte) error {
+		if v == nil {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// migrateExecSigsToMap converts CETx signatures stored as an array of
+// the counterparty's signatures for own CETxs, which is the format before
+// contracts of more than two parties are supported, to a map by signer
+func migrateExecSigsToMap(ns walletdb.ReadWriteBucket) error {
+	contracts := ns.NestedReadWriteBucket(nsContracts)
+	if contracts == nil {
+		return nil
+	}
+
+	// buckets can't be modified while iterating
+	keys, err := contractKeys(contracts)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		b := contracts.NestedReadWriteBucket(k)
+		data := b.Get(nsExecSigs)
+		if len(data) == 0 || data[0] != '[' || b.Get(nsConditions) == nil {
+			continue
+		}
+		sigs := [][]byte{}
+		if err = json.Unmarshal(data, &sigs); err != nil {
+			return err
+		}
+
+		d, err := retrieveJSONFields(b)
+		if err != nil {
+			return err
+		}
+		if err = acceptLegacyExecSigs(d, sigs); err != nil {
+			return err
+		}
+		data, err = json.Marshal(d.ExecSigs)
+		if err != nil {
+			return err
+		}
+		if err = b.Put(nsExecSigs, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateToBinary replaces the JSON encoded fields of each contract
+// with the binary encoding of the contract
+func migrateToBinary(ns walletdb.ReadWriteBucket) error {
+	contracts := ns.NestedReadWriteBucket(nsContracts)
+	if contracts == nil {
+		return nil
+	}
+
+	// buckets can't be modified while iterating
+	keys, err := contractKeys(contracts)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		b := contracts.NestedReadWriteBucket(k)
+		if b.Get(nsConditions) == nil {
+			continue
+		}
+		d, err := retrieveJSONContract(b)
+		if err != nil {
+			return err
+		}
+		data, err := d.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if err = b.Put(nsDLC, data); err != nil {
+			return err
+		}
+		for _, lk := range legacyKeys {
+			if err = b.Delete(lk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// retrieveJSONContract retrieves DLC stored as JSON encoded fields
+func retrieveJSONContract(b walletdb.ReadBucket) (*dlc.DLC, error) {
+	d, e := retrieveJSONFields(b)
+	if e != nil {
+		return nil, e
+	}
+	if d.ExecSigs, e = retrieveExecSigs(b); e != nil {
+		return nil, e
+	}
+	return d, nil
+}
+
+// retrieveJSONFields retrieves DLC stored as JSON encoded fields
+// except CETx signatures
+func retrieveJSONFields(b walletdb.ReadBucket) (*dlc.DLC, error) {
+	conds, e := retrieveConditions(b)
+	if e != nil {
+		return nil, e
+	}
+
+	d := dlc.NewDLC(conds)
+
+	n := len(conds.Deals)
+	o, e := retrieveOracle(b, n)
+	if e != nil {
+		return nil, e
+	}
+	d.Oracle = o
+
+	pubs, e := retrievePublicKeys(b)
+	if e != nil {
+		return nil, e
+	}
+	if e = d.ParsePublicKeys(pubs); e != nil {
+		return nil, e
+	}
+
+	addrs, e := retrieveAddrs(b)
+	if e != nil {
+		return nil, e
+	}
+	if e = d.ParseAddresses(addrs); e != nil {
+		return nil, e
+	}
+
+	chaddrs, e := retrieveChangeAddrs(b)
+	if e != nil {
+		return nil, e
+	}
+	if e = d.ParseChangeAddresses(chaddrs); e != nil {
+		return nil, e
+	}
+
+	if d.Utxos, e = retrieveUtxos(b); e != nil {
+		return nil, e
+	}
+	if d.FundWits, e = retrieveFundWits(b); e != nil {
+		return nil, e
+	}
+	if d.RefundSigs, e = retrieveRefundSigs(b); e != nil {
+		return nil, e
+	}
+	if d.Buffer, e = retrieveBuffer(b); e != nil {
+		return nil, e
+	}
+	if d.Nonces, e = retrieveNonces(b); e != nil {
+		return nil, e
+	}
+	if d.FundKeyIdx, e = retrieveFundKeyIdx(b); e != nil {
+		return nil, e
+	}
+	return d, nil
+}
+
+func retrieveOracle(b walletdb.ReadBucket, n int) (*dlc.Oracle, error) {
+	data := b.Get(nsOracle)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	o := dlc.NewOracle(n)
+	e := json.Unmarshal(data, o)
+	return o, e
+}
+
+func retrieveConditions(b walletdb.ReadBucket) (*dlc.Conditions, error) {
+	data := b.Get(nsConditions)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	conds := &dlc.Conditions{}
+	e := json.Unmarshal(data, conds)
+	return conds, e
+}
+
+func retrievePublicKeys(b walletdb.ReadBucket) (dlc.PublicKeys, error) {
+	data := b.Get(nsPubkeys)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	pubs := make(dlc.PublicKeys)
+	e := json.Unmarshal(data, &pubs)
+	if e != nil {
+		return nil, e
+	}
+	return pubs, e
+}
+
+func retrieveAddrs(b walletdb.ReadBucket) (dlc.Addresses, error) {
+	data := b.Get(nsAddrs)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	addrs := make(dlc.Addresses)
+	e := json.Unmarshal(data, &addrs)
+	return addrs, e
+}
+
+func retrieveChangeAddrs(b walletdb.ReadBucket) (dlc.Addresses, error) {
+	data := b.Get(nsChangeAddrs)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	addrs := make(dlc.Addresses)
+	e := json.Unmarshal(data, &addrs)
+	return addrs, e
+}
+
+func retrieveUtxos(b walletdb.ReadBucket) (map[dlc.Contractor][]*dlc.Utxo, error) {
+	data := b.Get(nsUtxos)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	utxos := make(map[dlc.Contractor][]*dlc.Utxo)
+	e := json.Unmarshal(data, &utxos)
+	return utxos, e
+}
+
+func retrieveFundWits(b walletdb.ReadBucket) (map[dlc.Contractor][]wire.TxWitness, error) {
+	data := b.Get(nsFundWits)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	wits := make(map[dlc.Contractor][]wire.TxWitness)
+	e := json.Unmarshal(data, &wits)
+	return wits, e
+}
+
+func retrieveRefundSigs(b walletdb.ReadBucket) (map[dlc.Contractor][]byte, error) {
+	data := b.Get(nsRefundSigs)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	sigs := make(map[dlc.Contractor][]byte)
+	e := json.Unmarshal(data, &sigs)
+	return sigs, e
+}
+
+func retrieveExecSigs(b walletdb.ReadBucket) (map[dlc.Contractor][][]byte, error) {
+	data := b.Get(nsExecSigs)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	sigs := make(map[dlc.Contractor][][]byte)
+	e := json.Unmarshal(data, &sigs)
+	return sigs, e
+}
+
+// acceptLegacyExecSigs sets the counterparty's signatures for own CETxs
+// of a two-party contract. Own party isn't stored in the legacy format,
+// so it's the party whose CETxs the signatures are valid for.
+func acceptLegacyExecSigs(d *dlc.DLC, sigs [][]byte) error {
+	empty := true
+	for _, sig := range sigs {
+		if sig != nil {
+			empty = false
+		}
+	}
+	if empty {
+		return nil
+	}
+
+	for _, p := range []dlc.Contractor{dlc.FirstParty, dlc.SecondParty} {
+		var err error
+		for idx, sig := range sigs {
+			if sig == nil {
+				continue
+			}
+			if err = d.AcceptCETxSignature(p, idx, sig); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		d.ExecSigs = make(map[dlc.Contractor][][]byte)
+	}
+	return errors.New("legacy CETx signatures are valid for neither party")
+}
+
+func retrieveBuffer(b walletdb.ReadBucket) (*dlc.Buffer, error) {
+	data := b.Get(nsBuffer)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	buf := &dlc.Buffer{}
+	e := json.Unmarshal(data, buf)
+	return buf, e
+}
+
+func retrieveNonces(b walletdb.ReadBucket) (*dlc.MuSigNonces, error) {
+	data := b.Get(nsNonces)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	nonces := &dlc.MuSigNonces{}
+	e := json.Unmarshal(data, nonces)
+	return nonces, e
+}
+
+func retrieveFundKeyIdx(b walletdb.ReadBucket) (*uint32, error) {
+	data := b.Get(nsFundKeyIdx)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data) != 4 {
+		return nil, errors.New("invalid fund key index")
+	}
+	idx := binary.BigEndian.Uint32(data)
+	return &idx, nil
+}
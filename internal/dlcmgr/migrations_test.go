@@ -0,0 +1,314 @@
+package dlcmgr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+	"github.com/stretchr/testify/assert"
+)
+
+// unversionedFixture is a fresh contract stored by dlcmgr before schema
+// versioning. CETx signatures are stored as an array and not signed yet.
+var unversionedFixture = map[string]string{
+	"addrs":      `{"0":"bcrt1qgar7sarvmkenkrmljk5slz0cn7ec0jak76zrj7","1":"bcrt1q0ldfeupqc9k2eaffep7cm6yml3ct3jwtxdz4jv"}`,
+	"chaddrs":    `{"0":"bcrt1qthklh702txwafc72d2qtxv7ywt7sk0mfv7esk7","1":"bcrt1qjefds6ld7sadyepk9ehxawnwkaj9pqf8wnz54j"}`,
+	"conds":      `{"network":"regtest","fixing_time":1567166400,"fund_amts":{"0":600,"1":400},"fund_feerate":10,"redeem_feerate":10,"refund_locktime":100,"deals":[{"amts":{"0":1000,"1":0},"msgs":["AA=="]},{"amts":{"0":0,"1":1000},"msgs":["AQ=="]}],"premium_info":null}`,
+	"execsigs":   `[null,null]`,
+	"fundwits":   `{}`,
+	"oracle":     `{"pubkey":null,"commitments":["0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798","02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"],"sig":null,"signed_msgs":null}`,
+	"pubkeys":    `{"0":"02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9","1":"02e493dbf1c10d80f3581e4904930b1404cc6c13900ee0758474fa94abe8c4cd13"}`,
+	"refundsigs": `{}`,
+	"utxos":      `{"0":[{"txid":"4b2b7e1f0c3d2a6f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f","vout":0,"address":"","account":"","scriptPubKey":"","amount":0.00001,"confirmations":6,"spendable":true}],"1":[{"txid":"4b2b7e1f0c3d2a6f8e9d0c1b2a3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f","vout":1,"address":"","account":"","scriptPubKey":"","amount":0.00001,"confirmations":6,"spendable":true}]}`,
+}
+
+// legacyFixture is a two-party contract signed by both parties and stored
+// before contracts of more than two parties are supported.
+// CETx signatures are stored as an array of the counterparty's signatures.
+var legacyFixture = map[string]string{
+	"oracle":     `{"pubkey":null,"commitments":["03f6b0506333819845ecbad202bc4cff0370f7f72d2ef25b687f2a49348f24e01c","02385ee4a891adb41ba7336298d6107e770c094a5fb2f440827d5b7869b2d94318"],"sig":null,"signed_msgs":null}`,
+	"conds":      `{"network":"regtest","fixing_time":1792397505,"fund_amts":{"0":1,"1":1},"fund_feerate":1,"redeem_feerate":1,"refund_locktime":1,"deals":[{"amts":{"0":2,"1":0},"msgs":["AQ=="]},{"amts":{"0":0,"1":2},"msgs":["Ag=="]}],"premium_info":null}`,
+	"pubkeys":    `{"0":"0373506364563393c2fd65635e33546fb4a247920ede23e162b731cbbb4d1ab988","1":"032aba9300f7fc9179d983b7669b4852bf18156bd09322f9abc8af6e9b37e8f222"}`,
+	"addrs":      `{"0":"bcrt1qvwt0tnfjv9x5n52z5rvjtwjy5a3ht52a2ynzxk","1":"bcrt1qu0070z07ln9xm7j9dv48uc7pnyl2dla7wgsam0"}`,
+	"chaddrs":    `{"0":"bcrt1qv8f629t4gdzrltd2n7yfqy67ft94e96frwwm6e","1":"bcrt1q24dkl34wlh2vupkh997wd5lhu4mkwvy3arr9dv"}`,
+	"utxos":      `{"0":[{"txid":"14a0810ac680a3eb3f82edc878cea25ec41d6b790744e5daeef","vout":0,"address":"","account":"","scriptPubKey":"","amount":0.00001,"confirmations":0,"spendable":false}],"1":[{"txid":"14a0810ac680a3eb3f82edc878cea25ec41d6b790744e5daeef","vout":0,"address":"","account":"","scriptPubKey":"","amount":0.00001,"confirmations":0,"spendable":false}]}`,
+	"fundwits":   `{}`,
+	"refundsigs": `{"0":"MEQCIEQ9GFQ8bYNpeBi5z57z98Zj0q5QeUKpjYYbK6J41Xh5AiBq1308Hdywe6+lenIby6REyQZWoDxma/NX94K1nHbbUAE=","1":"MEUCIQDQlIgSyE1g/1RG12Vt8EVcov57krXlpvmPux2bbUTwvQIgEDqLZK60fFwPb+qqrP+ZxTqNECwdrkrwBSca5OZXrugB"}`,
+}
+
+// legacyExecSigs are CETx signatures of legacyFixture stored by each party
+var legacyExecSigs = map[dlc.Contractor]string{
+	dlc.FirstParty:  `["MEQCIBuUgWnSW2lxYyh4+SIUOAyglcmF5G4C0g/YhRUYAo+PAiB0jzxReSz0s0ioVNwovYZtJGZLKJz6ePd7A52HFo3SxQE=","MEUCIQDehMvAVBKx2TTPi7CYKmRfMq8oHvP6C8fuqcwTp8v/9AIgW6EXkvWDq/yHB4xM/FLyS6N5MA/uAKILZNjcLixysicB"]`,
+	dlc.SecondParty: `["MEUCIQDaDDTzh2KlA/xEQnXGiqmuKVmDNRr3DeJ2NJYoV8PapAIgIWTwbGLz9bMOmUu581WFJDzK9hvDm8h+SsR2c1AQcSIB","MEUCIQDK3knvR9UGCXc2cVTqX7FGWJygsx5Wh+MAfDZwN9J7UgIgIbGDkc3TVfAHdLtqtz3HQyJhIgZ+vY+xuif81Xh8RHsB"]`,
+}
+
+// legacyFixtureWith returns legacyFixture with given CETx signatures
+func legacyFixtureWith(execsigs string) map[string]string {
+	fixture := map[string]string{"execsigs": execsigs}
+	for k, v := range legacyFixture {
+		fixture[k] = v
+	}
+	return fixture
+}
+
+// newUnversionedDB creates a database of the layout before schema versioning
+func newUnversionedDB(
+	t *testing.T, key []byte, fixture map[string]string) (walletdb.DB, func()) {
+	db, closeFunc := newWalletDB()
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		top, e := tx.CreateTopLevelBucket(nsTop)
+		if e != nil {
+			return e
+		}
+		contracts, e := top.CreateBucket(nsContracts)
+		if e != nil {
+			return e
+		}
+		b, e := contracts.CreateBucket(key)
+		if e != nil {
+			return e
+		}
+		for k, v := range fixture {
+			if e = b.Put([]byte(k), []byte(v)); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, closeFunc
+}
+
+func dbVersion(t *testing.T, db walletdb.DB) uint32 {
+	var v uint32
+	err := walletdb.View(db, func(tx walletdb.ReadTx) (e error) {
+		v, e = fetchVersion(tx.ReadBucket(nsTop))
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestCreateLatestVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	_, err := Create(db)
+	assert.NoError(err)
+	assert.Equal(latestVersion(), dbVersion(t, db))
+
+	_, err = Open(db)
+	assert.NoError(err)
+	assert.Equal(latestVersion(), dbVersion(t, db))
+}
+
+func TestUpgradeUnversionedDB(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("fixture")
+	db, closeFunc := newUnversionedDB(t, key, unversionedFixture)
+	defer closeFunc()
+	assert.Equal(uint32(0), dbVersion(t, db))
+
+	manager, err := Open(db)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(latestVersion(), dbVersion(t, db))
+
+	d, err := manager.RetrieveContract(key)
+	if !assert.NoError(err) {
+		return
+	}
+	conds := d.Conds
+	assert.Equal(time.Date(2019, 8, 30, 12, 0, 0, 0, time.UTC), conds.FixingTime)
+	assert.Equal(btcutil.Amount(600), conds.FundAmts[dlc.FirstParty])
+	assert.Equal(btcutil.Amount(400), conds.FundAmts[dlc.SecondParty])
+	assert.Len(conds.Deals, 2)
+	assert.Len(d.Oracle.Commitments, 2)
+	assert.Equal("bcrt1qgar7sarvmkenkrmljk5slz0cn7ec0jak76zrj7",
+		d.Addrs[dlc.FirstParty].EncodeAddress())
+	assert.Equal("bcrt1qjefds6ld7sadyepk9ehxawnwkaj9pqf8wnz54j",
+		d.ChangeAddrs[dlc.SecondParty].EncodeAddress())
+	if assert.Len(d.Utxos[dlc.SecondParty], 1) {
+		assert.Equal(uint32(1), d.Utxos[dlc.SecondParty][0].Vout)
+	}
+	assert.Empty(d.FundWits)
+	assert.Empty(d.RefundSigs)
+	assert.Empty(d.ExecSigs)
+	assert.Nil(d.FundKeyIdx)
+
+	ds, err := manager.Contracts()
+	assert.NoError(err)
+	assert.Len(ds, 1)
+
+	// JSON encoded fields are replaced with the binary encoding
+	err = walletdb.View(db, func(tx walletdb.ReadTx) error {
+		contracts := tx.ReadBucket(nsTop).NestedReadBucket(nsContracts)
+		b := contracts.NestedReadBucket(key)
+		assert.NotNil(b.Get(nsDLC))
+		for _, k := range legacyKeys {
+			assert.Nil(b.Get(k))
+		}
+		return nil
+	})
+	assert.NoError(err)
+}
+
+func TestMigrateExecSigsToMap(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("fixture")
+	db, closeFunc := newUnversionedDB(
+		t, key, legacyFixtureWith(legacyExecSigs[dlc.FirstParty]))
+	defer closeFunc()
+
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		return migrateExecSigsToMap(tx.ReadWriteBucket(nsTop))
+	})
+	if !assert.NoError(err) {
+		return
+	}
+
+	// signed by the counterparty for first party's CETxs
+	err = walletdb.View(db, func(tx walletdb.ReadTx) error {
+		contracts := tx.ReadBucket(nsTop).NestedReadBucket(nsContracts)
+		sigs, e := retrieveExecSigs(contracts.NestedReadBucket(key))
+		if e != nil {
+			return e
+		}
+		assert.Len(sigs, 1)
+		assert.Len(sigs[dlc.SecondParty], 2)
+		return nil
+	})
+	assert.NoError(err)
+}
+
+func TestUpgradeLegacyExecSigs(t *testing.T) {
+	assert := assert.New(t)
+
+	cparties := map[dlc.Contractor]dlc.Contractor{
+		dlc.FirstParty: dlc.SecondParty, dlc.SecondParty: dlc.FirstParty}
+	for p, cp := range cparties {
+		key := []byte("fixture")
+		db, closeFunc := newUnversionedDB(
+			t, key, legacyFixtureWith(legacyExecSigs[p]))
+		defer closeFunc()
+
+		manager, err := Open(db)
+		if !assert.NoError(err) {
+			return
+		}
+		d, err := manager.RetrieveContract(key)
+		if !assert.NoError(err) {
+			return
+		}
+
+		// stored under the counterparty who signed them
+		assert.Len(d.ExecSigs, 1)
+		sigs := d.ExecSigs[cp]
+		if assert.Len(sigs, 2) {
+			for idx := range sigs {
+				assert.NoError(d.AcceptCETxSignature(p, idx, sigs[idx]))
+			}
+		}
+	}
+}
+
+func TestUpgradeLegacyExecSigsNotSigned(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("fixture")
+	db, closeFunc := newUnversionedDB(t, key, legacyFixtureWith(`[null,null]`))
+	defer closeFunc()
+
+	manager, err := Open(db)
+	if !assert.NoError(err) {
+		return
+	}
+	d, err := manager.RetrieveContract(key)
+	assert.NoError(err)
+	assert.Empty(d.ExecSigs)
+}
+
+func TestUpgradeLegacyExecSigsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	// signatures of a party and of the counterparty are mixed up
+	sigs := `["MEQCIBuUgWnSW2lxYyh4+SIUOAyglcmF5G4C0g/YhRUYAo+PAiB0jzxReSz0s0ioVNwovYZtJGZLKJz6ePd7A52HFo3SxQE=",` +
+		`"MEUCIQDK3knvR9UGCXc2cVTqX7FGWJygsx5Wh+MAfDZwN9J7UgIgIbGDkc3TVfAHdLtqtz3HQyJhIgZ+vY+xuif81Xh8RHsB"]`
+	key := []byte("fixture")
+	db, closeFunc := newUnversionedDB(t, key, legacyFixtureWith(sigs))
+	defer closeFunc()
+
+	_, err := Open(db)
+	assert.Error(err)
+	assert.Equal(uint32(0), dbVersion(t, db))
+}
+
+func TestUpgradeAppliesMigrationsInOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("fixture")
+	db, closeFunc := newUnversionedDB(t, key, unversionedFixture)
+	defer closeFunc()
+
+	// migrations added by a future version
+	orig := versions
+	defer func() { versions = orig }()
+	applied := []uint32{}
+	versions = append(append([]migration.Version{}, orig...),
+		migration.Version{Number: latestVersion() + 2,
+			Migration: func(ns walletdb.ReadWriteBucket) error {
+				applied = append(applied, 2)
+				return nil
+			}},
+		migration.Version{Number: latestVersion() + 1,
+			Migration: func(ns walletdb.ReadWriteBucket) error {
+				applied = append(applied, 1)
+				// applied after the migrations of this version
+				contracts := ns.NestedReadWriteBucket(nsContracts)
+				if contracts.NestedReadWriteBucket(key).Get(nsDLC) == nil {
+					return errors.New("contract not migrated")
+				}
+				return nil
+			}},
+	)
+	latest := latestVersion()
+
+	_, err := Open(db)
+	assert.NoError(err)
+	assert.Equal([]uint32{1, 2}, applied)
+	assert.Equal(latest, dbVersion(t, db))
+
+	// migrations are applied only once
+	_, err = Open(db)
+	assert.NoError(err)
+	assert.Len(applied, 2)
+}
+
+func TestOpenNewerVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	_, err := Create(db)
+	assert.NoError(err)
+
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		return putVersion(tx.ReadWriteBucket(nsTop), latestVersion()+1)
+	})
+	assert.NoError(err)
+
+	_, err = Open(db)
+	assert.IsType(&UnsupportedVersionError{}, err)
+}
@@ -0,0 +1,249 @@
+package dlcmgr
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+)
+
+// SQLDialect is a flavor of SQL spoken by a database
+type SQLDialect int
+
+const (
+	// SQLite is the dialect of SQLite 3.24 or later
+	SQLite SQLDialect = iota
+	// Postgres is the dialect of PostgreSQL 9.5 or later
+	Postgres
+)
+
+// placeholder returns the placeholder of the i-th (from 1) argument
+func (dialect SQLDialect) placeholder(i int) string {
+	if dialect == Postgres {
+		return fmt.Sprintf("$%d", i)
+	}
+	return "?"
+}
+
+// blobType returns the column type of binary data
+func (dialect SQLDialect) blobType() string {
+	if dialect == Postgres {
+		return "BYTEA"
+	}
+	return "BLOB"
+}
+
+// sqlTable is the table of contracts
+const sqlTable = "dlc_contracts"
+
+// SQLStore is ContractStore on a SQL database.
+// A contract is stored in the binary encoding with indexed columns of
+// its state, fixing time and oracle's pubkey, so that a query is
+// answered without decoding contracts not matching it.
+type SQLStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+}
+
+var _ ContractStore = (*SQLStore)(nil)
+
+// NewSQLStore creates SQLStore on a database,
+// creating the table and indexes if they don't exist
+func NewSQLStore(db *sql.DB, dialect SQLDialect) (*SQLStore, error) {
+	s := &SQLStore{db: db, dialect: dialect}
+	for _, stmt := range s.schema() {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// schema returns statements creating the table and indexes
+func (s *SQLStore) schema() []string {
+	blob := s.dialect.blobType()
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	contract_key %s PRIMARY KEY,
+	state INTEGER NOT NULL,
+	fixing_time BIGINT NOT NULL,
+	oracle_pubkey %s,
+	data %s NOT NULL
+)`, sqlTable, blob, blob, blob),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_state ON %s (state)",
+			sqlTable, sqlTable),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_fixing_time ON %s (fixing_time)",
+			sqlTable, sqlTable),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_oracle_pubkey ON %s (oracle_pubkey)",
+			sqlTable, sqlTable),
+	}
+}
+
+// Put stores a contract
+func (s *SQLStore) Put(k []byte, d *dlc.DLC) error {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.upsertStmt(), sqlRowArgs(k, d, data)...)
+	return err
+}
+
+// Insert stores a contract if no contract is stored with the key
+func (s *SQLStore) Insert(k []byte, d *dlc.DLC) error {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(s.insertStmt(), sqlRowArgs(k, d, data)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return newContractExistsError(k)
+	}
+	return nil
+}
+
+// upsertStmt returns a statement inserting or replacing a contract
+func (s *SQLStore) upsertStmt() string {
+	return s.insertStmtWith("DO UPDATE SET " +
+		"state = excluded.state, fixing_time = excluded.fixing_time, " +
+		"oracle_pubkey = excluded.oracle_pubkey, data = excluded.data")
+}
+
+// insertStmt returns a statement inserting a contract
+// unless a contract of the same key exists
+func (s *SQLStore) insertStmt() string {
+	return s.insertStmtWith("DO NOTHING")
+}
+
+// insertStmtWith returns a statement inserting a contract
+// with a given action on conflict of the key
+func (s *SQLStore) insertStmtWith(action string) string {
+	ph := make([]string, 5)
+	for i := range ph {
+		ph[i] = s.dialect.placeholder(i + 1)
+	}
+	return fmt.Sprintf(
+		"INSERT INTO %s (contract_key, state, fixing_time, oracle_pubkey, data) "+
+			"VALUES (%s) ON CONFLICT (contract_key) %s",
+		sqlTable, strings.Join(ph, ", "), action)
+}
+
+// sqlRowArgs returns column values of a contract
+func sqlRowArgs(k []byte, d *dlc.DLC, data []byte) []interface{} {
+	var ftime int64
+	if d.Conds != nil {
+		ftime = d.Conds.FixingTime.Unix()
+	}
+	var opub []byte
+	if pub := oraclePubkey(d); pub != nil {
+		opub = pub.SerializeCompressed()
+	}
+	return []interface{}{k, int(ContractStateOf(d)), ftime, opub, data}
+}
+
+// Get retrieves a stored contract
+func (s *SQLStore) Get(k []byte) (*dlc.DLC, error) {
+	stmt := fmt.Sprintf("SELECT data FROM %s WHERE contract_key = %s",
+		sqlTable, s.dialect.placeholder(1))
+	var data []byte
+	err := s.db.QueryRow(stmt, k).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, newContractNotExistsError(k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &dlc.DLC{}
+	if err = d.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Has returns true if a contract is stored
+func (s *SQLStore) Has(k []byte) (bool, error) {
+	stmt := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE contract_key = %s",
+		sqlTable, s.dialect.placeholder(1))
+	var n int
+	if err := s.db.QueryRow(stmt, k).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Query retrieves contracts matching a query using the indexed columns
+func (s *SQLStore) Query(q *Query) ([]*dlc.DLC, error) {
+	stmt, args := s.selectStmt(q)
+	rows, err := s.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ds := []*dlc.DLC{}
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		d := &dlc.DLC{}
+		if err = d.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		ds = append(ds, d)
+	}
+	return ds, rows.Err()
+}
+
+// selectStmt returns a statement selecting contracts matching a query
+func (s *SQLStore) selectStmt(q *Query) (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return s.dialect.placeholder(len(args))
+	}
+
+	if q != nil {
+		if len(q.States) > 0 {
+			ph := []string{}
+			for _, state := range q.States {
+				ph = append(ph, arg(int(state)))
+			}
+			conds = append(conds,
+				fmt.Sprintf("state IN (%s)", strings.Join(ph, ", ")))
+		}
+		if !q.FixingFrom.IsZero() {
+			conds = append(conds, "fixing_time >= "+arg(q.FixingFrom.Unix()))
+		}
+		if !q.FixingTo.IsZero() {
+			conds = append(conds, "fixing_time < "+arg(q.FixingTo.Unix()))
+		}
+		if q.OraclePubkey != nil {
+			pub := q.OraclePubkey.SerializeCompressed()
+			conds = append(conds, "oracle_pubkey = "+arg(pub))
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "SELECT data FROM %s", sqlTable)
+	if len(conds) > 0 {
+		fmt.Fprintf(&buf, " WHERE %s", strings.Join(conds, " AND "))
+	}
+	buf.WriteString(" ORDER BY contract_key")
+	return buf.String(), args
+}
+
+// Close closes the database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,93 @@
+package dlcmgr
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // blank import for sqlite driver
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLStore(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+	s, err := NewSQLStore(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	testContractStore(t, s)
+
+	// the table is created only once
+	_, err = NewSQLStore(db, SQLite)
+	assert.NoError(t, err)
+}
+
+func TestSQLStoreSelectStmt(t *testing.T) {
+	assert := assert.New(t)
+
+	_, pub := test.RandKeys()
+	from := time.Unix(1000, 0)
+	to := time.Unix(2000, 0)
+	q := &Query{
+		States:       []ContractState{StateSigned, StateFixed},
+		FixingFrom:   from,
+		FixingTo:     to,
+		OraclePubkey: pub,
+	}
+	expArgs := []interface{}{
+		int(StateSigned), int(StateFixed), int64(1000), int64(2000),
+		pub.SerializeCompressed()}
+
+	s := &SQLStore{dialect: SQLite}
+	stmt, args := s.selectStmt(q)
+	assert.Equal("SELECT data FROM dlc_contracts WHERE state IN (?, ?) AND "+
+		"fixing_time >= ? AND fixing_time < ? AND oracle_pubkey = ? "+
+		"ORDER BY contract_key", stmt)
+	assert.Equal(expArgs, args)
+
+	s = &SQLStore{dialect: Postgres}
+	stmt, args = s.selectStmt(q)
+	assert.Equal("SELECT data FROM dlc_contracts WHERE state IN ($1, $2) AND "+
+		"fixing_time >= $3 AND fixing_time < $4 AND oracle_pubkey = $5 "+
+		"ORDER BY contract_key", stmt)
+	assert.Equal(expArgs, args)
+
+	stmt, args = s.selectStmt(&Query{})
+	assert.Equal("SELECT data FROM dlc_contracts ORDER BY contract_key", stmt)
+	assert.Empty(args)
+}
+
+func TestSQLStoreUpsertStmt(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &SQLStore{dialect: Postgres}
+	assert.Contains(s.upsertStmt(), "VALUES ($1, $2, $3, $4, $5)")
+	assert.Contains(s.insertStmt(), "VALUES ($1, $2, $3, $4, $5)")
+	s = &SQLStore{dialect: SQLite}
+	assert.Contains(s.upsertStmt(), "VALUES (?, ?, ?, ?, ?)")
+	assert.Contains(s.insertStmt(), "ON CONFLICT (contract_key) DO NOTHING")
+}
+
+func TestSQLRowArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	_, pub := test.RandKeys()
+	d := newDLC()
+	setOraclePubkey(d, pub)
+
+	args := sqlRowArgs([]byte("key"), d, []byte{1})
+	assert.Equal([]interface{}{
+		[]byte("key"), int(StateFixed), d.Conds.FixingTime.Unix(),
+		pub.SerializeCompressed(), []byte{1}}, args)
+
+	// oracle pubkey is NULL if unknown
+	args = sqlRowArgs([]byte("key"), newDLC(), []byte{1})
+	assert.Nil(args[3])
+}
@@ -0,0 +1,136 @@
+package dlcmgr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+)
+
+// ContractStore persists contracts of a manager
+type ContractStore interface {
+	// Put stores a contract, replacing the one stored with the same key
+	Put(k []byte, d *dlc.DLC) error
+	// Insert stores a contract only if no contract is stored with the key
+	// in a single transaction.
+	// ContractExistsError is returned if a contract is already stored.
+	Insert(k []byte, d *dlc.DLC) error
+	// Get retrieves a contract.
+	// ContractNotExistsError is returned if no contract is stored with the key.
+	Get(k []byte) (*dlc.DLC, error)
+	// Has returns true if a contract is stored with the key
+	Has(k []byte) (bool, error)
+	// Query retrieves contracts matching a query in ascending order of keys
+	Query(q *Query) ([]*dlc.DLC, error)
+	// Close closes the underlying storage
+	Close() error
+}
+
+// ContractExistsError is raised when a contract is already stored
+type ContractExistsError struct{ error }
+
+func newContractExistsError(k []byte) *ContractExistsError {
+	msg := fmt.Sprintf("Contract already exists. key: %x", k)
+	return &ContractExistsError{error: errors.New(msg)}
+}
+
+// ContractState is the progress of a contract derived from its data
+type ContractState int
+
+const (
+	// StateOffered is a contract whose fund tx isn't signed by all parties yet
+	StateOffered ContractState = iota
+	// StateSigned is a contract signed by all parties
+	StateSigned
+	// StateFixed is a contract whose deal is fixed by the oracle
+	StateFixed
+)
+
+func (s ContractState) String() string {
+	switch s {
+	case StateOffered:
+		return "offered"
+	case StateSigned:
+		return "signed"
+	case StateFixed:
+		return "fixed"
+	}
+	return "unknown"
+}
+
+// ContractStateOf returns the state of a contract
+func ContractStateOf(d *dlc.DLC) ContractState {
+	if d.Oracle != nil && d.HasDealFixed() {
+		return StateFixed
+	}
+	if d.Conds == nil {
+		return StateOffered
+	}
+	for _, p := range d.Conds.Parties() {
+		if len(d.FundWits[p]) == 0 || len(d.RefundSigs[p]) == 0 {
+			return StateOffered
+		}
+	}
+	return StateSigned
+}
+
+// Query is a condition to filter contracts.
+// Zero values of the fields match any contract.
+type Query struct {
+	States       []ContractState  // contract is in one of the states
+	FixingFrom   time.Time        // fixing time is at or after this time
+	FixingTo     time.Time        // fixing time is before this time
+	OraclePubkey *btcec.PublicKey // oracle's pubkey
+}
+
+// match returns true if a contract satisfies the query
+func (q *Query) match(d *dlc.DLC) bool {
+	if q == nil {
+		return true
+	}
+
+	if len(q.States) > 0 {
+		state := ContractStateOf(d)
+		found := false
+		for _, s := range q.States {
+			if s == state {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !q.FixingFrom.IsZero() || !q.FixingTo.IsZero() {
+		if d.Conds == nil {
+			return false
+		}
+		ftime := d.Conds.FixingTime
+		if !q.FixingFrom.IsZero() && ftime.Before(q.FixingFrom) {
+			return false
+		}
+		if !q.FixingTo.IsZero() && !ftime.Before(q.FixingTo) {
+			return false
+		}
+	}
+
+	if q.OraclePubkey != nil {
+		pub := oraclePubkey(d)
+		if pub == nil || !pub.IsEqual(q.OraclePubkey) {
+			return false
+		}
+	}
+	return true
+}
+
+// oraclePubkey returns the oracle's pubkey of a contract (nil if unknown)
+func oraclePubkey(d *dlc.DLC) *btcec.PublicKey {
+	if d.Oracle == nil || d.Oracle.PubkeySet == nil {
+		return nil
+	}
+	return d.Oracle.PubkeySet.Pubkey
+}
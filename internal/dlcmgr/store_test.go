@@ -0,0 +1,164 @@
+package dlcmgr
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+	"github.com/p2pderivatives/dlc/pkg/oracle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletDBStore(t *testing.T) {
+	db, closeFunc := newWalletDB()
+	defer closeFunc()
+	err := createManager(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testContractStore(t, NewWalletDBStore(db))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testContractStore(t, NewMemoryStore())
+}
+
+func TestManagerOnMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	manager := NewManager(NewMemoryStore())
+	key := []byte("testdlc")
+	dOrig := newDLC()
+	err := manager.StoreContract(key, dOrig)
+	assert.NoError(err)
+
+	d, err := manager.RetrieveContract(key)
+	assert.NoError(err)
+	assert.Equal(dOrig, d)
+
+	ds, err := manager.Contracts()
+	assert.NoError(err)
+	assert.Len(ds, 1)
+}
+
+// testContractStore tests behaviors common to all stores
+func testContractStore(t *testing.T, s ContractStore) {
+	assert := assert.New(t)
+
+	ds, err := s.Query(&Query{})
+	assert.NoError(err)
+	assert.Empty(ds)
+
+	_, err = s.Get([]byte("not_exists"))
+	assert.IsType(&ContractNotExistsError{}, err)
+	ok, err := s.Has([]byte("not_exists"))
+	assert.NoError(err)
+	assert.False(ok)
+
+	_, opub := test.RandKeys()
+	ftime := testFixingTime()
+
+	// offered contract of another oracle
+	d1 := newDLC()
+	d1.FundWits = nil
+	d1.Oracle.Sig, d1.Oracle.SignedMsgs = nil, nil
+	// signed contract fixing a day later
+	d2 := newDLC()
+	d2.Conds.FixingTime = ftime.AddDate(0, 0, 1)
+	d2.Oracle.Sig, d2.Oracle.SignedMsgs = nil, nil
+	setOraclePubkey(d2, opub)
+	// fixed contract
+	d3 := newDLC()
+	setOraclePubkey(d3, opub)
+
+	for k, d := range map[string]*dlc.DLC{"c1": d1, "c2": d2, "c3": d3} {
+		assert.NoError(s.Put([]byte(k), d))
+	}
+
+	ok, err = s.Has([]byte("c2"))
+	assert.NoError(err)
+	assert.True(ok)
+	d, err := s.Get([]byte("c2"))
+	assert.NoError(err)
+	assert.Equal(d2, d)
+
+	tests := []struct {
+		q    *Query
+		exps []string
+	}{
+		{&Query{}, []string{"c1", "c2", "c3"}},
+		{nil, []string{"c1", "c2", "c3"}},
+		{&Query{States: []ContractState{StateOffered}}, []string{"c1"}},
+		{&Query{States: []ContractState{StateSigned, StateFixed}},
+			[]string{"c2", "c3"}},
+		{&Query{FixingFrom: ftime.Add(time.Second)}, []string{"c2"}},
+		{&Query{FixingTo: ftime.Add(time.Second)}, []string{"c1", "c3"}},
+		{&Query{FixingFrom: ftime, FixingTo: ftime}, []string{}},
+		{&Query{OraclePubkey: opub}, []string{"c2", "c3"}},
+		{&Query{OraclePubkey: opub, States: []ContractState{StateFixed}},
+			[]string{"c3"}},
+	}
+	for i, test := range tests {
+		ds, err = s.Query(test.q)
+		assert.NoError(err)
+		assert.Equal(test.exps, keysOf(t, s, ds), "query %d", i)
+	}
+
+	// replaces the stored contract
+	d1.FundWits = testFundWits()
+	assert.NoError(s.Put([]byte("c1"), d1))
+	ds, err = s.Query(&Query{States: []ContractState{StateOffered}})
+	assert.NoError(err)
+	assert.Empty(ds)
+
+	// inserts only a new contract
+	err = s.Insert([]byte("c1"), d2)
+	assert.IsType(&ContractExistsError{}, err)
+	d, err = s.Get([]byte("c1"))
+	assert.NoError(err)
+	assert.Equal(d1, d)
+	assert.NoError(s.Insert([]byte("c4"), d2))
+	d, err = s.Get([]byte("c4"))
+	assert.NoError(err)
+	assert.Equal(d2, d)
+}
+
+func TestContractStateOf(t *testing.T) {
+	assert := assert.New(t)
+
+	d := newDLC()
+	assert.Equal(StateFixed, ContractStateOf(d))
+
+	d.Oracle.Sig, d.Oracle.SignedMsgs = nil, nil
+	assert.Equal(StateSigned, ContractStateOf(d))
+
+	delete(d.RefundSigs, dlc.SecondParty)
+	assert.Equal(StateOffered, ContractStateOf(d))
+}
+
+func setOraclePubkey(d *dlc.DLC, pub *btcec.PublicKey) {
+	d.Oracle.PubkeySet = &oracle.PubkeySet{
+		Pubkey: pub, CommittedRpoints: []*btcec.PublicKey{pub}}
+}
+
+// keysOf returns keys of contracts retrieved from a store
+func keysOf(t *testing.T, s ContractStore, ds []*dlc.DLC) []string {
+	keys := []string{}
+	for _, d := range ds {
+		data, _ := d.MarshalBinary()
+		for _, k := range []string{"c1", "c2", "c3"} {
+			stored, err := s.Get([]byte(k))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sdata, _ := stored.MarshalBinary()
+			if bytes.Equal(data, sdata) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,119 @@
+// Package fee provides fee rate policies for DLC transactions
+package fee
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/rpc"
+)
+
+// Default confirmation targets and feerate clamps (satoshi per byte)
+const (
+	DefaultFundConfTarget   = 6
+	DefaultRedeemConfTarget = 2
+	DefaultMinFeerate       = btcutil.Amount(1)
+	DefaultMaxFeerate       = btcutil.Amount(500)
+)
+
+// Policy decides fund and redeem feerates from confirmation targets.
+// Estimated feerates are clamped into [MinFeerate, MaxFeerate]
+type Policy struct {
+	FundConfTarget   int64
+	RedeemConfTarget int64
+	Mode             rpc.EstimateMode
+	MinFeerate       btcutil.Amount // satoshi per byte
+	MaxFeerate       btcutil.Amount // satoshi per byte
+
+	// FallbackFeerate is used when bitcoind can't estimate a feerate
+	// (e.g. not enough data in regtest). Estimation errors are returned if 0.
+	FallbackFeerate btcutil.Amount
+}
+
+// NewPolicy creates a policy with default conf targets and clamps
+func NewPolicy() *Policy {
+	return &Policy{
+		FundConfTarget:   DefaultFundConfTarget,
+		RedeemConfTarget: DefaultRedeemConfTarget,
+		Mode:             rpc.EstimateModeConservative,
+		MinFeerate:       DefaultMinFeerate,
+		MaxFeerate:       DefaultMaxFeerate,
+	}
+}
+
+// EstimationError is raised when bitcoind fails to estimate feerate
+type EstimationError struct{ error }
+
+func newEstimationError(target int64, errs []string) *EstimationError {
+	msg := fmt.Sprintf(
+		"failed to estimate feerate. conf target: %d, errors: %s",
+		target, strings.Join(errs, ", "))
+	return &EstimationError{error: errors.New(msg)}
+}
+
+// Feerates returns feerates for fund tx and redeem txs (CETx, closing tx and refund tx)
+func (p *Policy) Feerates(c rpc.Client) (fund, redeem btcutil.Amount, err error) {
+	fund, err = p.FundFeerate(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	redeem, err = p.RedeemFeerate(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fund, redeem, nil
+}
+
+// FundFeerate returns feerate for fund tx
+func (p *Policy) FundFeerate(c rpc.Client) (btcutil.Amount, error) {
+	return p.feerate(c, p.FundConfTarget)
+}
+
+// RedeemFeerate returns feerate for redeem txs
+func (p *Policy) RedeemFeerate(c rpc.Client) (btcutil.Amount, error) {
+	return p.feerate(c, p.RedeemConfTarget)
+}
+
+func (p *Policy) feerate(c rpc.Client, target int64) (btcutil.Amount, error) {
+	res, err := c.EstimateSmartFee(target, p.Mode)
+	if err != nil {
+		return 0, err
+	}
+
+	if res.FeeRate == nil {
+		if p.FallbackFeerate > 0 {
+			return p.clamp(p.FallbackFeerate), nil
+		}
+		return 0, newEstimationError(target, res.Errors)
+	}
+
+	feerate, err := btcPerKBToSatPerByte(*res.FeeRate)
+	if err != nil {
+		return 0, err
+	}
+
+	return p.clamp(feerate), nil
+}
+
+func (p *Policy) clamp(feerate btcutil.Amount) btcutil.Amount {
+	if p.MinFeerate > 0 && feerate < p.MinFeerate {
+		return p.MinFeerate
+	}
+	if p.MaxFeerate > 0 && feerate > p.MaxFeerate {
+		return p.MaxFeerate
+	}
+	return feerate
+}
+
+// btcPerKBToSatPerByte converts BTC/kB to satoshi/byte rounding up
+func btcPerKBToSatPerByte(feerate float64) (btcutil.Amount, error) {
+	satPerKB, err := btcutil.NewAmount(feerate)
+	if err != nil {
+		return 0, err
+	}
+	satPerByte := math.Ceil(float64(satPerKB) / 1000)
+	return btcutil.Amount(satPerByte), nil
+}
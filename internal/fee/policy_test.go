@@ -0,0 +1,79 @@
+package fee
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/rpcmock"
+	"github.com/p2pderivatives/dlc/internal/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockEstimateSmartFee(
+	c *rpcmock.Client, target int64, feerate *float64, err error) *rpcmock.Client {
+	res := &rpc.EstimateSmartFeeResult{FeeRate: feerate, Blocks: target}
+	if feerate == nil {
+		res.Errors = []string{"Insufficient data or no feerate found"}
+	}
+	c.On("EstimateSmartFee", target, mock.Anything).Return(res, err)
+	return c
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func TestFeerates(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &rpcmock.Client{}
+	c = mockEstimateSmartFee(c, DefaultFundConfTarget, float64Ptr(0.0002), nil)
+	c = mockEstimateSmartFee(c, DefaultRedeemConfTarget, float64Ptr(0.00040001), nil)
+
+	fund, redeem, err := NewPolicy().Feerates(c)
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(20), fund)
+	assert.Equal(btcutil.Amount(41), redeem) // rounded up
+}
+
+func TestFeeratesClamped(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &rpcmock.Client{}
+	c = mockEstimateSmartFee(c, DefaultFundConfTarget, float64Ptr(0.00000001), nil)
+	c = mockEstimateSmartFee(c, DefaultRedeemConfTarget, float64Ptr(0.1), nil)
+
+	fund, redeem, err := NewPolicy().Feerates(c)
+	assert.NoError(err)
+	assert.Equal(DefaultMinFeerate, fund)
+	assert.Equal(DefaultMaxFeerate, redeem)
+}
+
+func TestFeerateNotEstimated(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &rpcmock.Client{}
+	c = mockEstimateSmartFee(c, DefaultFundConfTarget, nil, nil)
+
+	p := NewPolicy()
+	_, err := p.FundFeerate(c)
+	assert.Error(err)
+	assert.IsType(&EstimationError{}, err)
+
+	// fallback
+	p.FallbackFeerate = 10
+	feerate, err := p.FundFeerate(c)
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(10), feerate)
+}
+
+func TestFeerateRPCError(t *testing.T) {
+	c := &rpcmock.Client{}
+	c = mockEstimateSmartFee(
+		c, DefaultFundConfTarget, nil, errors.New("rpc error"))
+
+	_, _, err := NewPolicy().Feerates(c)
+	assert.Error(t, err)
+}
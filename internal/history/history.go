@@ -0,0 +1,410 @@
+// Package history labels wallet transactions by their roles in DLCs
+// and accounts realised profit and loss per contract.
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// Role is a role of a wallet movement
+type Role string
+
+// Roles of wallet movements
+const (
+	// RoleFunding is a contribution to a fund tx
+	RoleFunding Role = "funding"
+	// RoleChange is a change from a fund tx
+	RoleChange Role = "change"
+	// RolePremium is a premium received in a fund tx
+	RolePremium Role = "premium"
+	// RoleCETPayout is a payout by a contract execution tx
+	// that the counterparty broadcast
+	RoleCETPayout Role = "cet_payout"
+	// RoleClosingPayout is a payout by a closing tx
+	// or a mutual closing tx
+	RoleClosingPayout Role = "closing_payout"
+	// RoleRefund is a payout by a refund tx
+	RoleRefund Role = "refund"
+	// RoleBuffer is a buffer tx of a channel, which doesn't settle it
+	RoleBuffer Role = "buffer"
+	// RolePenalty is a payout by a penalty tx
+	// that takes a revoked buffer tx of the counterparty
+	RolePenalty Role = "penalty"
+	// RoleReceive is a receipt not related to contracts
+	RoleReceive Role = "receive"
+	// RoleSend is a payment not related to contracts
+	RoleSend Role = "send"
+)
+
+// Entry is a wallet movement. Amount is negative if it's spent.
+type Entry struct {
+	Time       time.Time      `json:"time"`
+	Height     int32          `json:"height"`
+	TxID       string         `json:"txid"`
+	Role       Role           `json:"role"`
+	Amount     btcutil.Amount `json:"amount"` // satoshi
+	ContractID string         `json:"contract_id,omitempty"`
+}
+
+// ContractPnL is realised profit and loss of a contract.
+// PnL is zero until the contract is settled.
+type ContractPnL struct {
+	ContractID string         `json:"contract_id"`
+	Invested   btcutil.Amount `json:"invested"` // funding contribution less change
+	Payout     btcutil.Amount `json:"payout"`   // payouts including premium
+	PnL        btcutil.Amount `json:"pnl"`
+	Settled    bool           `json:"settled"`
+}
+
+// History is wallet movements and accounting per contract
+type History struct {
+	Entries   []*Entry       `json:"entries"`
+	Contracts []*ContractPnL `json:"contracts"`
+}
+
+// fund txout, settlement txout of CETx and buffer txout are always at 0
+const (
+	fundTxOutAt       = 0
+	settlementTxOutAt = 0
+	bufferTxOutAt     = 0
+)
+
+// contractTxs is txids of a contract
+type contractTxs struct {
+	id            string
+	fundTxID      chainhash.Hash
+	refundTxID    *chainhash.Hash
+	cetxIDs       map[chainhash.Hash]bool
+	premiumScript []byte
+	pnl           *ContractPnL
+
+	// channel is set if the contract is in a channel
+	channel *channelTxs
+}
+
+// channelTxs is txids and scripts of a contract in a channel
+type channelTxs struct {
+	bufferTxIDs   map[chainhash.Hash]bool // buffer txs of the current state
+	payoutScripts [][]byte
+	pub1, pub2    *btcec.PublicKey
+	delay         uint16
+}
+
+// Build labels wallet txs by roles in given contracts.
+// Txs are expected in block order.
+func Build(txs []wallet.Transaction, contracts []*dlc.DLC) (*History, error) {
+	h := &History{Entries: []*Entry{}, Contracts: []*ContractPnL{}}
+
+	ctxs := []*contractTxs{}
+	for _, d := range contracts {
+		c, err := newContractTxs(d)
+		if err != nil {
+			return nil, err
+		}
+		ctxs = append(ctxs, c)
+		h.Contracts = append(h.Contracts, c.pnl)
+	}
+
+	for i := range txs {
+		h.Entries = append(h.Entries, entries(&txs[i], ctxs)...)
+	}
+
+	for _, c := range ctxs {
+		if c.pnl.Settled {
+			c.pnl.PnL = c.pnl.Payout - c.pnl.Invested
+		}
+	}
+	return h, nil
+}
+
+func newContractTxs(d *dlc.DLC) (*contractTxs, error) {
+	fundtx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+
+	// same as DLC.ContractID. fund tx spends segwit outputs,
+	// so txid doesn't change by signing
+	c := &contractTxs{
+		id:       fundtx.TxHash().String(),
+		fundTxID: fundtx.TxHash(),
+		cetxIDs:  make(map[chainhash.Hash]bool),
+	}
+	c.pnl = &ContractPnL{ContractID: c.id}
+
+	if refundtx, err := d.RefundTx(); err == nil {
+		txid := refundtx.TxHash()
+		c.refundTxID = &txid
+	}
+
+	// txs that can't be built (e.g. missing commitments) are ignored
+	for _, p := range d.Conds.Parties() {
+		for idx, deal := range d.Conds.Deals {
+			cetx, err := d.ContractExecutionTx(p, deal, idx)
+			if err != nil {
+				continue
+			}
+			c.cetxIDs[cetx.TxHash()] = true
+		}
+	}
+
+	if info := d.Conds.PremiumInfo; info != nil {
+		c.premiumScript, err = script.PkScriptFromAddress(info.PremiumDestAddress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Buffer != nil {
+		c.channel, err = newChannelTxs(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func newChannelTxs(d *dlc.DLC) (*channelTxs, error) {
+	ch := &channelTxs{
+		bufferTxIDs: make(map[chainhash.Hash]bool),
+		pub1:        d.Pubs[dlc.FirstParty],
+		pub2:        d.Pubs[dlc.SecondParty],
+		delay:       d.Buffer.Delay,
+	}
+	for _, p := range d.Conds.Parties() {
+		if buftx, err := d.BufferTx(p); err == nil {
+			ch.bufferTxIDs[buftx.TxHash()] = true
+		}
+		if addr := d.Addrs[p]; addr != nil {
+			sc, err := script.PkScriptFromAddress(addr)
+			if err != nil {
+				return nil, err
+			}
+			ch.payoutScripts = append(ch.payoutScripts, sc)
+		}
+	}
+	return ch, nil
+}
+
+// entries returns wallet movements of a tx
+func entries(tx *wallet.Transaction, ctxs []*contractTxs) []*Entry {
+	txid := tx.MsgTx.TxHash()
+	newEntry := func(role Role, amt btcutil.Amount, c *contractTxs) *Entry {
+		e := &Entry{
+			Time:   tx.BlockTime,
+			Height: tx.Height,
+			TxID:   txid.String(),
+			Role:   role,
+			Amount: amt,
+		}
+		if c != nil {
+			e.ContractID = c.id
+		}
+		return e
+	}
+
+	credit := btcutil.Amount(0)
+	for _, cr := range tx.Credits {
+		credit += cr.Amount
+	}
+
+	for _, c := range ctxs {
+		if txid == c.fundTxID {
+			return fundTxEntries(tx, c, newEntry)
+		}
+
+		role, ok := c.payoutRole(tx.MsgTx)
+		if !ok {
+			continue
+		}
+		if role == RoleBuffer {
+			return []*Entry{newEntry(role, credit-tx.Debit, c)}
+		}
+		// a CET of a revoked channel state is followed by its closing tx
+		if role == RoleCETPayout {
+			c.cetxIDs[txid] = true
+		}
+		c.pnl.Payout += credit
+		c.pnl.Settled = true
+		return []*Entry{newEntry(role, credit, c)}
+	}
+
+	if credit >= tx.Debit {
+		return []*Entry{newEntry(RoleReceive, credit-tx.Debit, nil)}
+	}
+	return []*Entry{newEntry(RoleSend, credit-tx.Debit, nil)}
+}
+
+// fundTxEntries returns funding contribution, change and premium in a fund tx
+func fundTxEntries(
+	tx *wallet.Transaction, c *contractTxs,
+	newEntry func(Role, btcutil.Amount, *contractTxs) *Entry,
+) []*Entry {
+	es := []*Entry{}
+	if tx.Debit > 0 {
+		es = append(es, newEntry(RoleFunding, -tx.Debit, c))
+		c.pnl.Invested += tx.Debit
+	}
+	for _, cr := range tx.Credits {
+		pkScript := tx.MsgTx.TxOut[cr.Index].PkScript
+		if c.premiumScript != nil && bytes.Equal(pkScript, c.premiumScript) {
+			es = append(es, newEntry(RolePremium, cr.Amount, c))
+			c.pnl.Payout += cr.Amount
+			continue
+		}
+		es = append(es, newEntry(RoleChange, cr.Amount, c))
+		c.pnl.Invested -= cr.Amount
+	}
+	return es
+}
+
+// payoutRole returns a role of a tx paying out the contract
+func (c *contractTxs) payoutRole(tx *wire.MsgTx) (Role, bool) {
+	txid := tx.TxHash()
+	if c.refundTxID != nil && txid == *c.refundTxID {
+		return RoleRefund, true
+	}
+	if c.cetxIDs[txid] {
+		return RoleCETPayout, true
+	}
+	if c.channel != nil && c.channel.bufferTxIDs[txid] {
+		return RoleBuffer, true
+	}
+	for _, txin := range tx.TxIn {
+		prev := txin.PreviousOutPoint
+		// closing tx spending CETx
+		if c.cetxIDs[prev.Hash] && prev.Index == settlementTxOutAt {
+			return RoleClosingPayout, true
+		}
+		// mutual closing tx, or buffer tx of a revoked state in channel
+		if prev.Hash == c.fundTxID && prev.Index == fundTxOutAt {
+			if c.channel != nil && !c.channel.paysOut(tx) {
+				return RoleBuffer, true
+			}
+			return RoleClosingPayout, true
+		}
+		// penalty tx or CET of a revoked state spending buffer tx
+		if c.channel != nil && prev.Index == bufferTxOutAt {
+			if role, ok := c.channel.bufferSpendRole(txin); ok {
+				return role, true
+			}
+		}
+	}
+	return "", false
+}
+
+// paysOut returns true if a tx pays to a payout address of the contract
+func (ch *channelTxs) paysOut(tx *wire.MsgTx) bool {
+	for _, txout := range tx.TxOut {
+		for _, sc := range ch.payoutScripts {
+			if bytes.Equal(txout.PkScript, sc) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bufferSpendRole returns a role of a tx spending a buffer txout.
+// Buffer txs of revoked states pay the wallet nothing, so the wallet
+// doesn't have them and a spend is recognised by its witness script.
+func (ch *channelTxs) bufferSpendRole(txin *wire.TxIn) (Role, bool) {
+	wit := txin.Witness
+	if len(wit) == 0 || !ch.isBufferScript(wit[len(wit)-1]) {
+		return "", false
+	}
+	// revocation path of script.WitnessForBufferScriptRevoked
+	if len(wit) == 3 && bytes.Equal(wit[1], []byte{1}) {
+		return RolePenalty, true
+	}
+	return RoleCETPayout, true
+}
+
+// isBufferScript returns true if a script is a buffer script of the channel
+// with any revocation pubkey
+func (ch *channelTxs) isBufferScript(sc []byte) bool {
+	// OP_IF <revocation pubkey>
+	const revpubAt = 2
+	if ch.pub1 == nil || ch.pub2 == nil || len(sc) < revpubAt+33 {
+		return false
+	}
+	revpub, err := btcec.ParsePubKey(sc[revpubAt:revpubAt+33], btcec.S256())
+	if err != nil {
+		return false
+	}
+	bsc, err := script.BufferScript(revpub, ch.pub1, ch.pub2, ch.delay)
+	return err == nil && bytes.Equal(sc, bsc)
+}
+
+// csvHeader is a header of entries in CSV
+var csvHeader = []string{"time", "height", "txid", "role", "amount", "contract_id"}
+
+// WriteCSV writes entries in CSV. Amount is in satoshi.
+func (h *History) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, e := range h.Entries {
+		rec := []string{
+			e.Time.UTC().Format(time.RFC3339),
+			strconv.FormatInt(int64(e.Height), 10),
+			e.TxID,
+			string(e.Role),
+			strconv.FormatInt(int64(e.Amount), 10),
+			e.ContractID,
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// contractsCSVHeader is a header of contract accounting in CSV
+var contractsCSVHeader = []string{"contract_id", "invested", "payout", "pnl", "settled"}
+
+// WriteContractsCSV writes accounting per contract in CSV.
+// Amounts are in satoshi.
+func (h *History) WriteContractsCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(contractsCSVHeader); err != nil {
+		return err
+	}
+	for _, c := range h.Contracts {
+		rec := []string{
+			c.ContractID,
+			strconv.FormatInt(int64(c.Invested), 10),
+			strconv.FormatInt(int64(c.Payout), 10),
+			strconv.FormatInt(int64(c.PnL), 10),
+			strconv.FormatBool(c.Settled),
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes entries and accounting per contract in JSON.
+// Amounts are in satoshi.
+func (h *History) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h)
+}
@@ -0,0 +1,375 @@
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/dlc"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testFundAmt = btcutil.Amount(10000000)
+	testUtxoAmt = btcutil.Amount(15000000)
+)
+
+func newTestDLC() *dlc.DLC {
+	net := &chaincfg.RegressionNetParams
+	deals := []*dlc.Deal{
+		dlc.NewDeal(15000000, 5000000, [][]byte{{0}}),
+		dlc.NewDeal(5000000, 15000000, [][]byte{{1}}),
+	}
+	conds, _ := dlc.NewConditions(
+		net, time.Now().Add(time.Hour), testFundAmt, testFundAmt, 10, 10, 1, deals, nil)
+
+	d := dlc.NewDLC(conds)
+	for i := range deals {
+		_, d.Oracle.Commitments[i] = test.RandKeys()
+	}
+	for _, p := range conds.Parties() {
+		_, d.Pubs[p] = test.RandKeys()
+		d.Addrs[p] = test.RandAddress()
+		d.ChangeAddrs[p] = test.RandAddress()
+		d.Utxos[p] = []*dlc.Utxo{{
+			TxID:   randTxID(),
+			Vout:   0,
+			Amount: testUtxoAmt.ToBTC(),
+		}}
+	}
+	return d
+}
+
+func randTxID() string {
+	_, pub := test.RandKeys()
+	return chainhash.HashH(pub.SerializeCompressed()).String()
+}
+
+// fundTx returns a wallet tx of the fund tx spending the first party's utxo
+func fundTx(t *testing.T, d *dlc.DLC, height int32) wallet.Transaction {
+	tx, err := d.FundTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// txouts: fund, change of the first party, change of the second party
+	return wallet.Transaction{
+		MsgTx:     tx,
+		Height:    height,
+		BlockTime: time.Unix(int64(height), 0),
+		Credits: []wallet.TxCredit{
+			{Index: 1, Amount: btcutil.Amount(tx.TxOut[1].Value), Change: true}},
+		Debit: testUtxoAmt,
+	}
+}
+
+// payoutTx returns a wallet tx crediting a txout at a given index
+func payoutTx(tx *wire.MsgTx, idx uint32, height int32) wallet.Transaction {
+	return wallet.Transaction{
+		MsgTx:     tx,
+		Height:    height,
+		BlockTime: time.Unix(int64(height), 0),
+		Credits: []wallet.TxCredit{
+			{Index: idx, Amount: btcutil.Amount(tx.TxOut[idx].Value)}},
+	}
+}
+
+func otherTx(credit, debit btcutil.Amount, height int32) wallet.Transaction {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{byte(height)}}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(int64(credit), []byte{txscript.OP_TRUE}))
+	wtx := wallet.Transaction{
+		MsgTx:     tx,
+		Height:    height,
+		BlockTime: time.Unix(int64(height), 0),
+		Credits:   []wallet.TxCredit{},
+		Debit:     debit,
+	}
+	if credit > 0 {
+		wtx.Credits = append(wtx.Credits, wallet.TxCredit{Index: 0, Amount: credit})
+	}
+	return wtx
+}
+
+func TestBuild(t *testing.T) {
+	assert := assert.New(t)
+
+	// d1 is settled by a CET of the counterparty, d2 by a refund tx
+	// and d3 is open
+	d1, d2, d3 := newTestDLC(), newTestDLC(), newTestDLC()
+	id := func(d *dlc.DLC) string {
+		tx, _ := d.FundTx()
+		return tx.TxHash().String()
+	}
+
+	cetx, err := d1.ContractExecutionTx(dlc.SecondParty, d1.Conds.Deals[0], 0)
+	assert.NoError(err)
+	refundtx, err := d2.RefundTx()
+	assert.NoError(err)
+
+	fund1 := fundTx(t, d1, 2)
+	fund2 := fundTx(t, d2, 3)
+	txs := []wallet.Transaction{
+		otherTx(30000000, 0, 1),
+		fund1,
+		fund2,
+		fundTx(t, d3, 4),
+		payoutTx(cetx, 1, 5),     // the first party's txout
+		payoutTx(refundtx, 0, 6), // the first party's txout
+		otherTx(0, 1000000, 7),
+	}
+
+	h, err := Build(txs, []*dlc.DLC{d1, d2, d3})
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	roles := []Role{}
+	for _, e := range h.Entries {
+		roles = append(roles, e.Role)
+	}
+	assert.Equal([]Role{
+		RoleReceive,
+		RoleFunding, RoleChange,
+		RoleFunding, RoleChange,
+		RoleFunding, RoleChange,
+		RoleCETPayout,
+		RoleRefund,
+		RoleSend,
+	}, roles)
+
+	assert.Equal(btcutil.Amount(30000000), h.Entries[0].Amount)
+	assert.Empty(h.Entries[0].ContractID)
+	assert.Equal(-testUtxoAmt, h.Entries[1].Amount)
+	assert.Equal(id(d1), h.Entries[1].ContractID)
+	assert.Equal(id(d1), h.Entries[7].ContractID)
+	assert.Equal(btcutil.Amount(cetx.TxOut[1].Value), h.Entries[7].Amount)
+	assert.Equal(id(d2), h.Entries[8].ContractID)
+	assert.Equal(btcutil.Amount(-1000000), h.Entries[9].Amount)
+	assert.Equal(int32(7), h.Entries[9].Height)
+
+	if !assert.Len(h.Contracts, 3) {
+		assert.FailNow("contracts aren't accounted")
+	}
+	invested := testUtxoAmt - fund1.Credits[0].Amount
+	c1 := h.Contracts[0]
+	assert.Equal(id(d1), c1.ContractID)
+	assert.True(c1.Settled)
+	assert.Equal(invested, c1.Invested)
+	assert.Equal(btcutil.Amount(cetx.TxOut[1].Value), c1.Payout)
+	assert.Equal(c1.Payout-c1.Invested, c1.PnL)
+
+	c2 := h.Contracts[1]
+	assert.True(c2.Settled)
+	assert.Equal(btcutil.Amount(refundtx.TxOut[0].Value)-c2.Invested, c2.PnL)
+
+	c3 := h.Contracts[2]
+	assert.False(c3.Settled)
+	assert.Equal(btcutil.Amount(0), c3.PnL)
+}
+
+func TestBuildMutualClosing(t *testing.T) {
+	assert := assert.New(t)
+	d := newTestDLC()
+
+	fund := fundTx(t, d, 1)
+	fundTxID := fund.MsgTx.TxHash()
+
+	// a tx spending the fund output is a mutual closing tx
+	closing := wire.NewMsgTx(2)
+	closing.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundTxID, 0), nil, nil))
+	closing.AddTxOut(wire.NewTxOut(12000000, []byte{txscript.OP_TRUE}))
+
+	// a tx spending the change isn't
+	spend := wire.NewMsgTx(2)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundTxID, 1), nil, nil))
+	spend.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+	spendTx := payoutTx(spend, 0, 3)
+	spendTx.Debit = fund.Credits[0].Amount
+
+	h, err := Build([]wallet.Transaction{
+		fund, payoutTx(closing, 0, 2), spendTx}, []*dlc.DLC{d})
+	assert.NoError(err)
+	assert.Len(h.Entries, 4)
+	assert.Equal(RoleClosingPayout, h.Entries[2].Role)
+	assert.Equal(RoleSend, h.Entries[3].Role)
+	assert.Empty(h.Entries[3].ContractID)
+	assert.True(h.Contracts[0].Settled)
+	assert.Equal(btcutil.Amount(12000000), h.Contracts[0].Payout)
+}
+
+// newTestChannel returns a contract in a channel state
+func newTestChannel(t *testing.T) *dlc.DLC {
+	d := newTestDLC()
+	buf, err := dlc.NewBuffer(1, script.BufferDelay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range d.Conds.Parties() {
+		_, buf.Points[p] = test.RandKeys()
+	}
+	d.Buffer = buf
+	return d
+}
+
+// revokedBufferTx returns a buffer tx of a revoked state
+// and its buffer script
+func revokedBufferTx(t *testing.T, d *dlc.DLC) (*wire.MsgTx, []byte) {
+	_, revpub := test.RandKeys()
+	sc, err := script.BufferScript(
+		revpub, d.Pubs[dlc.FirstParty], d.Pubs[dlc.SecondParty], d.Buffer.Delay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, _ := script.P2WSHpkScript(sc)
+
+	fundtx, _ := d.FundTx()
+	fundTxID := fundtx.TxHash()
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundTxID, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(fundtx.TxOut[0].Value-1000, pkScript))
+	return tx, sc
+}
+
+// spendBufferTx returns a tx paying to the first party from a buffer tx
+func spendBufferTx(
+	d *dlc.DLC, buftx *wire.MsgTx, wit wire.TxWitness) *wire.MsgTx {
+	txid := buftx.TxHash()
+	tx := wire.NewMsgTx(2)
+	txin := wire.NewTxIn(wire.NewOutPoint(&txid, 0), nil, wit)
+	tx.AddTxIn(txin)
+	pkScript, _ := script.PkScriptFromAddress(d.Addrs[dlc.FirstParty])
+	tx.AddTxOut(wire.NewTxOut(buftx.TxOut[0].Value-1000, pkScript))
+	return tx
+}
+
+func TestBuildChannel(t *testing.T) {
+	assert := assert.New(t)
+
+	// d1 is settled by a CET of the current state after a buffer tx,
+	// d2 is still open after a buffer tx,
+	// d3 is settled by a penalty tx taking a revoked buffer tx and
+	// d4 is settled by a CET of a revoked state
+	d1, d2 := newTestChannel(t), newTestChannel(t)
+	d3, d4 := newTestChannel(t), newTestChannel(t)
+
+	buftx1, err := d1.BufferTx(dlc.SecondParty)
+	assert.NoError(err)
+	cetx, err := d1.ContractExecutionTx(dlc.SecondParty, d1.Conds.Deals[0], 0)
+	assert.NoError(err)
+	buftx2, err := d2.BufferTx(dlc.FirstParty)
+	assert.NoError(err)
+	revoked3, sc3 := revokedBufferTx(t, d3)
+	penaltytx := spendBufferTx(
+		d3, revoked3, script.WitnessForBufferScriptRevoked([]byte{1}, sc3))
+	revoked4, sc4 := revokedBufferTx(t, d4)
+	oldcetx := spendBufferTx(
+		d4, revoked4, script.WitnessForBufferScript([]byte{1}, []byte{2}, sc4))
+
+	nocredit := func(tx *wire.MsgTx, height int32) wallet.Transaction {
+		return wallet.Transaction{
+			MsgTx: tx, Height: height, BlockTime: time.Unix(int64(height), 0),
+			Credits: []wallet.TxCredit{}}
+	}
+	txs := []wallet.Transaction{
+		fundTx(t, d1, 1),
+		fundTx(t, d2, 2),
+		fundTx(t, d3, 3),
+		fundTx(t, d4, 4),
+		nocredit(buftx1, 5),
+		nocredit(buftx2, 6),
+		nocredit(revoked3, 7),
+		payoutTx(cetx, 1, 8), // the first party's txout
+		payoutTx(penaltytx, 0, 9),
+		payoutTx(oldcetx, 0, 10), // revoked buffer tx isn't in the wallet
+	}
+
+	h, err := Build(txs, []*dlc.DLC{d1, d2, d3, d4})
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	roles := []Role{}
+	for _, e := range h.Entries[8:] {
+		roles = append(roles, e.Role)
+	}
+	assert.Equal([]Role{
+		RoleBuffer, RoleBuffer, RoleBuffer,
+		RoleCETPayout, RolePenalty, RoleCETPayout,
+	}, roles)
+	assert.Equal(h.Contracts[0].ContractID, h.Entries[8].ContractID)
+	assert.Equal(h.Contracts[2].ContractID, h.Entries[12].ContractID)
+	assert.Equal(h.Contracts[3].ContractID, h.Entries[13].ContractID)
+
+	c1, c2, c3, c4 := h.Contracts[0], h.Contracts[1], h.Contracts[2], h.Contracts[3]
+	assert.True(c1.Settled)
+	assert.Equal(btcutil.Amount(cetx.TxOut[1].Value), c1.Payout)
+	assert.False(c2.Settled)
+	assert.Equal(btcutil.Amount(0), c2.Payout)
+	assert.Equal(btcutil.Amount(0), c2.PnL)
+	assert.True(c3.Settled)
+	assert.Equal(btcutil.Amount(penaltytx.TxOut[0].Value), c3.Payout)
+	assert.True(c4.Settled)
+	assert.Equal(btcutil.Amount(oldcetx.TxOut[0].Value), c4.Payout)
+}
+
+func TestWriteCSV(t *testing.T) {
+	assert := assert.New(t)
+	h := &History{
+		Entries: []*Entry{{
+			Time:       time.Unix(0, 0),
+			Height:     1,
+			TxID:       "txid",
+			Role:       RoleFunding,
+			Amount:     -100,
+			ContractID: "cid",
+		}},
+		Contracts: []*ContractPnL{{
+			ContractID: "cid", Invested: 100, Payout: 150, PnL: 50, Settled: true}},
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(h.WriteCSV(buf))
+	recs, err := csv.NewReader(buf).ReadAll()
+	assert.NoError(err)
+	assert.Equal([][]string{
+		csvHeader,
+		{"1970-01-01T00:00:00Z", "1", "txid", "funding", "-100", "cid"},
+	}, recs)
+
+	buf.Reset()
+	assert.NoError(h.WriteContractsCSV(buf))
+	recs, err = csv.NewReader(buf).ReadAll()
+	assert.NoError(err)
+	assert.Equal([][]string{
+		contractsCSVHeader,
+		{"cid", "100", "150", "50", "true"},
+	}, recs)
+}
+
+func TestWriteJSON(t *testing.T) {
+	assert := assert.New(t)
+	h := &History{
+		Entries: []*Entry{{
+			Time: time.Unix(0, 0).UTC(), Height: 1, TxID: "txid",
+			Role: RoleReceive, Amount: 100}},
+		Contracts: []*ContractPnL{},
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(h.WriteJSON(buf))
+
+	var decoded History
+	assert.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(h.Entries, decoded.Entries)
+	assert.NotContains(buf.String(), "contract_id")
+}
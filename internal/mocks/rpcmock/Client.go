@@ -7,6 +7,7 @@ import btcutil "github.com/btcsuite/btcutil"
 import chainhash "github.com/btcsuite/btcd/chaincfg/chainhash"
 import json "encoding/json"
 import mock "github.com/stretchr/testify/mock"
+import rpc "github.com/p2pderivatives/dlc/internal/rpc"
 
 import wire "github.com/btcsuite/btcd/wire"
 
@@ -15,6 +16,29 @@ type Client struct {
 	mock.Mock
 }
 
+// EstimateSmartFee provides a mock function with given fields: confTarget, mode
+func (_m *Client) EstimateSmartFee(confTarget int64, mode rpc.EstimateMode) (*rpc.EstimateSmartFeeResult, error) {
+	ret := _m.Called(confTarget, mode)
+
+	var r0 *rpc.EstimateSmartFeeResult
+	if rf, ok := ret.Get(0).(func(int64, rpc.EstimateMode) *rpc.EstimateSmartFeeResult); ok {
+		r0 = rf(confTarget, mode)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*rpc.EstimateSmartFeeResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int64, rpc.EstimateMode) error); ok {
+		r1 = rf(confTarget, mode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Generate provides a mock function with given fields: numBlocks
 func (_m *Client) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
 	ret := _m.Called(numBlocks)
@@ -38,6 +62,29 @@ func (_m *Client) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
 	return r0, r1
 }
 
+// GetBlock provides a mock function with given fields: blockHash
+func (_m *Client) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	ret := _m.Called(blockHash)
+
+	var r0 *wire.MsgBlock
+	if rf, ok := ret.Get(0).(func(*chainhash.Hash) *wire.MsgBlock); ok {
+		r0 = rf(blockHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*wire.MsgBlock)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*chainhash.Hash) error); ok {
+		r1 = rf(blockHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetBlockCount provides a mock function with given fields:
 func (_m *Client) GetBlockCount() (int64, error) {
 	ret := _m.Called()
@@ -59,36 +106,68 @@ func (_m *Client) GetBlockCount() (int64, error) {
 	return r0, r1
 }
 
-// ImportAddressRescan provides a mock function with given fields: address, account, rescan
-func (_m *Client) ImportAddressRescan(address string, account string, rescan bool) error {
-	ret := _m.Called(address, account, rescan)
+// GetBlockHash provides a mock function with given fields: blockHeight
+func (_m *Client) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	ret := _m.Called(blockHeight)
+
+	var r0 *chainhash.Hash
+	if rf, ok := ret.Get(0).(func(int64) *chainhash.Hash); ok {
+		r0 = rf(blockHeight)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*chainhash.Hash)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(blockHeight)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRawMempool provides a mock function with given fields:
+func (_m *Client) GetRawMempool() ([]*chainhash.Hash, error) {
+	ret := _m.Called()
+
+	var r0 []*chainhash.Hash
+	if rf, ok := ret.Get(0).(func() []*chainhash.Hash); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*chainhash.Hash)
+		}
+	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string, bool) error); ok {
-		r0 = rf(address, account, rescan)
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
 	} else {
-		r0 = ret.Error(0)
+		r1 = ret.Error(1)
 	}
 
-	return r0
+	return r0, r1
 }
 
-// ListUnspentMinMaxAddresses provides a mock function with given fields: minConf, maxConf, addrs
-func (_m *Client) ListUnspentMinMaxAddresses(minConf int, maxConf int, addrs []btcutil.Address) ([]btcjson.ListUnspentResult, error) {
-	ret := _m.Called(minConf, maxConf, addrs)
+// GetTxOut provides a mock function with given fields: txHash, index, mempool
+func (_m *Client) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error) {
+	ret := _m.Called(txHash, index, mempool)
 
-	var r0 []btcjson.ListUnspentResult
-	if rf, ok := ret.Get(0).(func(int, int, []btcutil.Address) []btcjson.ListUnspentResult); ok {
-		r0 = rf(minConf, maxConf, addrs)
+	var r0 *btcjson.GetTxOutResult
+	if rf, ok := ret.Get(0).(func(*chainhash.Hash, uint32, bool) *btcjson.GetTxOutResult); ok {
+		r0 = rf(txHash, index, mempool)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]btcjson.ListUnspentResult)
+			r0 = ret.Get(0).(*btcjson.GetTxOutResult)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, int, []btcutil.Address) error); ok {
-		r1 = rf(minConf, maxConf, addrs)
+	if rf, ok := ret.Get(1).(func(*chainhash.Hash, uint32, bool) error); ok {
+		r1 = rf(txHash, index, mempool)
 	} else {
 		r1 = ret.Error(1)
 	}
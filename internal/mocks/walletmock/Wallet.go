@@ -2,11 +2,13 @@
 
 package walletmock
 
+import time "time"
 import btcec "github.com/btcsuite/btcd/btcec"
 import btcjson "github.com/btcsuite/btcd/btcjson"
 import btcutil "github.com/btcsuite/btcutil"
 import chainhash "github.com/btcsuite/btcd/chaincfg/chainhash"
 import mock "github.com/stretchr/testify/mock"
+import musig2 "github.com/p2pderivatives/dlc/pkg/musig2"
 import rpc "github.com/p2pderivatives/dlc/internal/rpc"
 import wallet "github.com/p2pderivatives/dlc/pkg/wallet"
 import wire "github.com/btcsuite/btcd/wire"
@@ -16,6 +18,29 @@ type Wallet struct {
 	mock.Mock
 }
 
+// Balance provides a mock function with given fields:
+func (_m *Wallet) Balance() (*wallet.Balance, error) {
+	ret := _m.Called()
+
+	var r0 *wallet.Balance
+	if rf, ok := ret.Get(0).(func() *wallet.Balance); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*wallet.Balance)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Close provides a mock function with given fields:
 func (_m *Wallet) Close() error {
 	ret := _m.Called()
@@ -67,6 +92,66 @@ func (_m *Wallet) ListUnspent() ([]btcjson.ListUnspentResult, error) {
 	return r0, r1
 }
 
+// LockUtxos provides a mock function with given fields: utxos, expiry
+func (_m *Wallet) LockUtxos(utxos []btcjson.ListUnspentResult, expiry time.Time) error {
+	ret := _m.Called(utxos, expiry)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]btcjson.ListUnspentResult, time.Time) error); ok {
+		r0 = rf(utxos, expiry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// LockedUtxos provides a mock function with given fields:
+func (_m *Wallet) LockedUtxos() (map[wire.OutPoint]time.Time, error) {
+	ret := _m.Called()
+
+	var r0 map[wire.OutPoint]time.Time
+	if rf, ok := ret.Get(0).(func() map[wire.OutPoint]time.Time); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[wire.OutPoint]time.Time)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MuSig2PartialSign provides a mock function with given fields: secnonce, session, pub
+func (_m *Wallet) MuSig2PartialSign(secnonce []byte, session *musig2.Session, pub *btcec.PublicKey) ([]byte, error) {
+	ret := _m.Called(secnonce, session, pub)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func([]byte, *musig2.Session, *btcec.PublicKey) []byte); ok {
+		r0 = rf(secnonce, session, pub)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]byte, *musig2.Session, *btcec.PublicKey) error); ok {
+		r1 = rf(secnonce, session, pub)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewAddress provides a mock function with given fields:
 func (_m *Wallet) NewAddress() (btcutil.Address, error) {
 	ret := _m.Called()
@@ -90,6 +175,36 @@ func (_m *Wallet) NewAddress() (btcutil.Address, error) {
 	return r0, r1
 }
 
+// NewFundPubkey provides a mock function with given fields:
+func (_m *Wallet) NewFundPubkey() (*btcec.PublicKey, uint32, error) {
+	ret := _m.Called()
+
+	var r0 *btcec.PublicKey
+	if rf, ok := ret.Get(0).(func() *btcec.PublicKey); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*btcec.PublicKey)
+		}
+	}
+
+	var r1 uint32
+	if rf, ok := ret.Get(1).(func() uint32); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(uint32)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func() error); ok {
+		r2 = rf()
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // NewPubkey provides a mock function with given fields:
 func (_m *Wallet) NewPubkey() (*btcec.PublicKey, error) {
 	ret := _m.Called()
@@ -113,6 +228,110 @@ func (_m *Wallet) NewPubkey() (*btcec.PublicKey, error) {
 	return r0, r1
 }
 
+// Recover provides a mock function with given fields: gapLimit
+func (_m *Wallet) Recover(gapLimit uint32) error {
+	ret := _m.Called(gapLimit)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uint32) error); ok {
+		r0 = rf(gapLimit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecoverFundPubkeys provides a mock function with given fields: lastIdx
+func (_m *Wallet) RecoverFundPubkeys(lastIdx uint32) error {
+	ret := _m.Called(lastIdx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uint32) error); ok {
+		r0 = rf(lastIdx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecoverPubkeys provides a mock function with given fields: pubs, gapLimit
+func (_m *Wallet) RecoverPubkeys(pubs []*btcec.PublicKey, gapLimit uint32) ([]*btcec.PublicKey, error) {
+	ret := _m.Called(pubs, gapLimit)
+
+	var r0 []*btcec.PublicKey
+	if rf, ok := ret.Get(0).(func([]*btcec.PublicKey, uint32) []*btcec.PublicKey); ok {
+		r0 = rf(pubs, gapLimit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*btcec.PublicKey)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]*btcec.PublicKey, uint32) error); ok {
+		r1 = rf(pubs, gapLimit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SchnorrSignature provides a mock function with given fields: hash, pub
+func (_m *Wallet) SchnorrSignature(hash []byte, pub *btcec.PublicKey) ([]byte, error) {
+	ret := _m.Called(hash, pub)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func([]byte, *btcec.PublicKey) []byte); ok {
+		r0 = rf(hash, pub)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]byte, *btcec.PublicKey) error); ok {
+		r1 = rf(hash, pub)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SelectAndLockUnspent provides a mock function with given fields: s, amt, feePerTxIn, feePerTxOut, expiry
+func (_m *Wallet) SelectAndLockUnspent(s wallet.CoinSelector, amt btcutil.Amount, feePerTxIn btcutil.Amount, feePerTxOut btcutil.Amount, expiry time.Time) ([]btcjson.ListUnspentResult, btcutil.Amount, error) {
+	ret := _m.Called(s, amt, feePerTxIn, feePerTxOut, expiry)
+
+	var r0 []btcjson.ListUnspentResult
+	if rf, ok := ret.Get(0).(func(wallet.CoinSelector, btcutil.Amount, btcutil.Amount, btcutil.Amount, time.Time) []btcjson.ListUnspentResult); ok {
+		r0 = rf(s, amt, feePerTxIn, feePerTxOut, expiry)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]btcjson.ListUnspentResult)
+		}
+	}
+
+	var r1 btcutil.Amount
+	if rf, ok := ret.Get(1).(func(wallet.CoinSelector, btcutil.Amount, btcutil.Amount, btcutil.Amount, time.Time) btcutil.Amount); ok {
+		r1 = rf(s, amt, feePerTxIn, feePerTxOut, expiry)
+	} else {
+		r1 = ret.Get(1).(btcutil.Amount)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(wallet.CoinSelector, btcutil.Amount, btcutil.Amount, btcutil.Amount, time.Time) error); ok {
+		r2 = rf(s, amt, feePerTxIn, feePerTxOut, expiry)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // SelectUnspent provides a mock function with given fields: amt, feePerTxIn, feePerTxOut
 func (_m *Wallet) SelectUnspent(amt btcutil.Amount, feePerTxIn btcutil.Amount, feePerTxOut btcutil.Amount) ([]btcjson.ListUnspentResult, btcutil.Amount, error) {
 	ret := _m.Called(amt, feePerTxIn, feePerTxOut)
@@ -143,6 +362,59 @@ func (_m *Wallet) SelectUnspent(amt btcutil.Amount, feePerTxIn btcutil.Amount, f
 	return r0, r1, r2
 }
 
+// SelectUnspentWith provides a mock function with given fields: s, amt, feePerTxIn, feePerTxOut
+func (_m *Wallet) SelectUnspentWith(s wallet.CoinSelector, amt btcutil.Amount, feePerTxIn btcutil.Amount, feePerTxOut btcutil.Amount) ([]btcjson.ListUnspentResult, btcutil.Amount, error) {
+	ret := _m.Called(s, amt, feePerTxIn, feePerTxOut)
+
+	var r0 []btcjson.ListUnspentResult
+	if rf, ok := ret.Get(0).(func(wallet.CoinSelector, btcutil.Amount, btcutil.Amount, btcutil.Amount) []btcjson.ListUnspentResult); ok {
+		r0 = rf(s, amt, feePerTxIn, feePerTxOut)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]btcjson.ListUnspentResult)
+		}
+	}
+
+	var r1 btcutil.Amount
+	if rf, ok := ret.Get(1).(func(wallet.CoinSelector, btcutil.Amount, btcutil.Amount, btcutil.Amount) btcutil.Amount); ok {
+		r1 = rf(s, amt, feePerTxIn, feePerTxOut)
+	} else {
+		r1 = ret.Get(1).(btcutil.Amount)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(wallet.CoinSelector, btcutil.Amount, btcutil.Amount, btcutil.Amount) error); ok {
+		r2 = rf(s, amt, feePerTxIn, feePerTxOut)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// Send provides a mock function with given fields: outputs, feerate
+func (_m *Wallet) Send(outputs []*wire.TxOut, feerate btcutil.Amount) (*wire.MsgTx, error) {
+	ret := _m.Called(outputs, feerate)
+
+	var r0 *wire.MsgTx
+	if rf, ok := ret.Get(0).(func([]*wire.TxOut, btcutil.Amount) *wire.MsgTx); ok {
+		r0 = rf(outputs, feerate)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*wire.MsgTx)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]*wire.TxOut, btcutil.Amount) error); ok {
+		r1 = rf(outputs, feerate)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SendRawTransaction provides a mock function with given fields: tx
 func (_m *Wallet) SendRawTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
 	ret := _m.Called(tx)
@@ -171,6 +443,57 @@ func (_m *Wallet) SetRPCClient(_a0 rpc.Client) {
 	_m.Called(_a0)
 }
 
+// SetSigner provides a mock function with given fields: _a0
+func (_m *Wallet) SetSigner(_a0 wallet.Signer) {
+	_m.Called(_a0)
+}
+
+// Sweep provides a mock function with given fields: addr, feerate
+func (_m *Wallet) Sweep(addr btcutil.Address, feerate btcutil.Amount) (*wire.MsgTx, error) {
+	ret := _m.Called(addr, feerate)
+
+	var r0 *wire.MsgTx
+	if rf, ok := ret.Get(0).(func(btcutil.Address, btcutil.Amount) *wire.MsgTx); ok {
+		r0 = rf(addr, feerate)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*wire.MsgTx)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(btcutil.Address, btcutil.Amount) error); ok {
+		r1 = rf(addr, feerate)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transactions provides a mock function with given fields:
+func (_m *Wallet) Transactions() ([]wallet.Transaction, error) {
+	ret := _m.Called()
+
+	var r0 []wallet.Transaction
+	if rf, ok := ret.Get(0).(func() []wallet.Transaction); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]wallet.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Unlock provides a mock function with given fields: privPass
 func (_m *Wallet) Unlock(privPass []byte) error {
 	ret := _m.Called(privPass)
@@ -185,6 +508,34 @@ func (_m *Wallet) Unlock(privPass []byte) error {
 	return r0
 }
 
+// UnlockUtxos provides a mock function with given fields: utxos
+func (_m *Wallet) UnlockUtxos(utxos []btcjson.ListUnspentResult) error {
+	ret := _m.Called(utxos)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]btcjson.ListUnspentResult) error); ok {
+		r0 = rf(utxos)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WatchOnly provides a mock function with given fields:
+func (_m *Wallet) WatchOnly() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // WitnessSignTxByIdxs provides a mock function with given fields: tx, idxs
 func (_m *Wallet) WitnessSignTxByIdxs(tx *wire.MsgTx, idxs []int) ([]wire.TxWitness, error) {
 	ret := _m.Called(tx, idxs)
@@ -208,6 +559,29 @@ func (_m *Wallet) WitnessSignTxByIdxs(tx *wire.MsgTx, idxs []int) ([]wire.TxWitn
 	return r0, r1
 }
 
+// WitnessSignTxByUtxos provides a mock function with given fields: tx, idxs, utxos
+func (_m *Wallet) WitnessSignTxByUtxos(tx *wire.MsgTx, idxs []int, utxos []btcjson.ListUnspentResult) ([]wire.TxWitness, error) {
+	ret := _m.Called(tx, idxs, utxos)
+
+	var r0 []wire.TxWitness
+	if rf, ok := ret.Get(0).(func(*wire.MsgTx, []int, []btcjson.ListUnspentResult) []wire.TxWitness); ok {
+		r0 = rf(tx, idxs, utxos)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]wire.TxWitness)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*wire.MsgTx, []int, []btcjson.ListUnspentResult) error); ok {
+		r1 = rf(tx, idxs, utxos)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // WitnessSignature provides a mock function with given fields: tx, idx, amt, sc, pub
 func (_m *Wallet) WitnessSignature(tx *wire.MsgTx, idx int, amt btcutil.Amount, sc []byte, pub *btcec.PublicKey) ([]byte, error) {
 	ret := _m.Called(tx, idx, amt, sc, pub)
@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"encoding/json"
+)
+
+// EstimateMode is a fee estimate mode of estimatesmartfee
+type EstimateMode string
+
+const (
+	// EstimateModeUnset lets bitcoind decide the mode
+	EstimateModeUnset EstimateMode = "UNSET"
+	// EstimateModeEconomical may return a lower fee rate
+	// that is potentially less responsive to short-term drops
+	EstimateModeEconomical EstimateMode = "ECONOMICAL"
+	// EstimateModeConservative potentially returns a higher fee rate
+	// that is more likely to be sufficient for the target
+	EstimateModeConservative EstimateMode = "CONSERVATIVE"
+)
+
+// EstimateSmartFeeResult models the data returned from estimatesmartfee
+type EstimateSmartFeeResult struct {
+	FeeRate *float64 `json:"feerate,omitempty"` // fee rate in BTC/kB
+	Errors  []string `json:"errors,omitempty"`
+	Blocks  int64    `json:"blocks"`
+}
+
+// EstimateSmartFee estimates the fee rate needed for a transaction
+// to begin confirmation within confTarget blocks
+func (c *client) EstimateSmartFee(
+	confTarget int64, mode EstimateMode) (*EstimateSmartFeeResult, error) {
+	target, err := json.Marshal(confTarget)
+	if err != nil {
+		return nil, err
+	}
+	m, err := json.Marshal(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.RawRequest(
+		"estimatesmartfee", []json.RawMessage{target, m})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EstimateSmartFeeResult{}
+	err = json.Unmarshal(res, result)
+	return result, err
+}
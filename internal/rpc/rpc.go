@@ -20,15 +20,16 @@ import (
 
 // Client is an interface that provides access to certain methods of type rpcclient.Client
 type Client interface {
-	ListUnspentMinMaxAddresses(
-		minConf, maxConf int, addrs []btcutil.Address,
-	) ([]btcjson.ListUnspentResult, error)
-	ImportAddressRescan(address string, account string, rescan bool) error
 	SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error)
 	SendToAddress(address btcutil.Address, amount btcutil.Amount) (*chainhash.Hash, error)
 	Generate(numBlocks uint32) ([]*chainhash.Hash, error)
 	GetBlockCount() (int64, error)
+	GetBlockHash(blockHeight int64) (*chainhash.Hash, error)
+	GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error)
+	GetRawMempool() ([]*chainhash.Hash, error)
+	GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error)
 	RawRequest(method string, params []json.RawMessage) (json.RawMessage, error)
+	EstimateSmartFee(confTarget int64, mode EstimateMode) (*EstimateSmartFeeResult, error)
 	// TODO: add Shutdown func
 }
 
@@ -47,8 +48,17 @@ func NewClient(cfgPath string) (Client, error) {
 	return newClient(cfg)
 }
 
-func newClient(cfg *rpcclient.ConnConfig) (*rpcclient.Client, error) {
-	return rpcclient.New(cfg, nil)
+// client extends rpcclient.Client with RPCs that it doesn't support
+type client struct {
+	*rpcclient.Client
+}
+
+func newClient(cfg *rpcclient.ConnConfig) (*client, error) {
+	c, err := rpcclient.New(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &client{Client: c}, nil
 }
 
 func loadConfig(cfgPath string) (*rpcclient.ConnConfig, error) {
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
@@ -20,6 +21,57 @@ func (w *Wallet) NewPubkey() (pub *btcec.PublicKey, err error) {
 	return pub, err
 }
 
+// NewFundPubkey returns a new pubkey for a fund tx with its index on the
+// fund account. The fund account is created if the wallet doesn't have it,
+// which requires the wallet to be unlocked.
+func (w *Wallet) NewFundPubkey() (*btcec.PublicKey, uint32, error) {
+	var addrs []waddrmgr.ManagedAddress
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(w.addrmgrNsKey)
+		account, e := w.fundAccount(ns)
+		if e != nil {
+			return e
+		}
+		addrs, e = w.manager.NextExternalAddresses(ns, account, 1)
+		return e
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	mpka := addrs[0].(waddrmgr.ManagedPubKeyAddress)
+	_, path, _ := mpka.DerivationInfo()
+	return mpka.PubKey(), path.Index, nil
+}
+
+// RecoverFundPubkeys derives and stores fund pubkeys up to a given index
+// (e.g. the largest index found in stored contracts) so that the wallet
+// can sign with them
+func (w *Wallet) RecoverFundPubkeys(lastIdx uint32) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(w.addrmgrNsKey)
+		account, e := w.fundAccount(ns)
+		if e != nil {
+			return e
+		}
+		return w.manager.ExtendExternalAddresses(ns, account, lastIdx)
+	})
+}
+
+// fundAccount returns the fund account, creating it if the wallet was
+// created before fund keys had their own account
+func (w *Wallet) fundAccount(ns walletdb.ReadWriteBucket) (uint32, error) {
+	account, err := w.manager.LookupAccount(ns, fundAccountName)
+	if !waddrmgr.IsError(err, waddrmgr.ErrAccountNotFound) {
+		return account, err
+	}
+	account, err = w.manager.NewAccount(ns, fundAccountName)
+	if waddrmgr.IsError(err, waddrmgr.ErrLocked) {
+		return 0, errors.New("wallet must be unlocked to create the fund account")
+	}
+	return account, err
+}
+
 // NewAddress creates a new address managed by wallet
 func (w *Wallet) NewAddress() (btcutil.Address, error) {
 	maddr, err := w.newAddress()
@@ -30,12 +82,13 @@ func (w *Wallet) NewAddress() (btcutil.Address, error) {
 	return maddr.Address(), nil
 }
 
-// ImportAddress imports an address by creating addresses until reaching to it
-// It stops after creating 100 addresses to avoid infinite loop
+// ImportAddress imports an address of the wallet by deriving addresses of
+// its branch up to it. The address must be within importSearchLimit beyond
+// the last derived address of external or internal branch.
 func (w *Wallet) ImportAddress(addr btcutil.Address) error {
 	var maddr waddrmgr.ManagedAddress
 	err := walletdb.View(w.db, func(tx walletdb.ReadTx) (e error) {
-		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		ns := tx.ReadBucket(w.addrmgrNsKey)
 		maddr, e = w.manager.Address(ns, addr)
 		return e
 	})
@@ -43,77 +96,67 @@ func (w *Wallet) ImportAddress(addr btcutil.Address) error {
 		return nil
 	}
 
-	// generate new addresses until reaching to the target address
-	for i := 0; i < 100; i++ {
-		newAddr, err := w.NewAddress()
-		if err != nil {
-			return err
-		}
-
-		if newAddr.EncodeAddress() == addr.EncodeAddress() {
-			return nil
-		}
+	sc, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return err
+	}
+	horizon, err := w.horizon(importSearchLimit, scriptKey)
+	if err != nil {
+		return err
+	}
+	path, ok := horizon[string(sc)]
+	if !ok {
+		return errors.New("failed to import address")
 	}
 
-	return errors.New("failed to import address")
+	return w.extendAddresses(map[uint32]uint32{path.Branch: path.Index})
 }
 
+// importSearchLimit is the number of addresses searched by ImportAddress
+const importSearchLimit = uint32(100)
+
 // newAddress returns a new ManagedAddress
 // NOTE: this function calls NextExternalAddresses to generate a ManagadAdddress.
 func (w *Wallet) newAddress() (waddrmgr.ManagedAddress, error) {
-	scopedMgr, err := w.manager.FetchScopedKeyManager(waddrmgrKeyScope)
-	if err != nil {
-		return nil, err
-	}
-
 	var numAddresses uint32 = 1
 	var addrs []waddrmgr.ManagedAddress
-	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
-		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(w.addrmgrNsKey)
 		var e error
-		addrs, e = scopedMgr.NextExternalAddresses(ns, w.account, numAddresses)
+		addrs, e = w.manager.NextExternalAddresses(ns, w.account, numAddresses)
 		return e
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	addr := addrs[0]
+	return addrs[0], nil
+}
 
-	// register address to bitcoind
-	err = w.rpc.ImportAddressRescan(addr.Address().EncodeAddress(), "", false)
+// managedPubKeyAddressFromPubkey looks up an address of a given pubkey
+// in any account by its key hash
+func (w *Wallet) managedPubKeyAddressFromPubkey(
+	pub *btcec.PublicKey,
+) (waddrmgr.ManagedPubKeyAddress, error) {
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pub.SerializeCompressed()), w.params)
 	if err != nil {
 		return nil, err
 	}
 
-	return addr, nil
-}
-
-func (w *Wallet) managedPubKeyAddressFromPubkey(
-	pub *btcec.PublicKey,
-) (rmpaddr waddrmgr.ManagedPubKeyAddress, err error) {
-	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
-		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+	var maddr waddrmgr.ManagedAddress
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) (e error) {
+		ns := tx.ReadBucket(w.addrmgrNsKey)
 		if ns == nil {
 			return errors.New("missing address manager namespace")
 		}
-		e := w.manager.ForEachActiveAccountAddress(ns, w.account,
-			func(maddr waddrmgr.ManagedAddress) error {
-				mpaddr, ok := maddr.(waddrmgr.ManagedPubKeyAddress)
-				if !ok {
-					return nil
-				}
-				if !mpaddr.PubKey().IsEqual(pub) {
-					return nil
-				}
-				rmpaddr = mpaddr
-				return nil
-			})
+		maddr, e = w.manager.Address(ns, addr)
 		return e
 	})
-	if rmpaddr == nil {
+	mpaddr, ok := maddr.(waddrmgr.ManagedPubKeyAddress)
+	if err != nil || !ok {
 		msg := "No pubkey address is found associated with the given pubkey"
 		return nil, errors.New(msg)
 	}
-	return rmpaddr, err
+	return mpaddr, nil
 }
@@ -3,10 +3,12 @@ package wallet
 import (
 	"testing"
 
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
-	"github.com/p2pderivatives/dlc/internal/mocks/rpcmock"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 )
 
 // TestNewPubkey tests generating a new public key
@@ -14,33 +16,109 @@ func TestNewPubkey(t *testing.T) {
 	w, tearDownFunc := setupWallet(t)
 	defer tearDownFunc()
 
-	rpcc := &rpcmock.Client{}
-	rpcc = mockImportAddress(rpcc, nil)
-	w.SetRPCClient(rpcc)
-
 	pub, err := w.NewPubkey()
 
 	assert.Nil(t, err)
 	assert.NotNil(t, pub)
 }
 
-func TestNewAddress(t *testing.T) {
+func TestNewFundPubkey(t *testing.T) {
+	assert := assert.New(t)
 	w, tearDownFunc := setupWallet(t)
 	defer tearDownFunc()
 
-	rpcc := &rpcmock.Client{}
-	rpcc = mockImportAddress(rpcc, nil)
-	w.SetRPCClient(rpcc)
+	pub0, idx0, err := w.NewFundPubkey()
+	assert.NoError(err)
+	assert.Equal(uint32(0), idx0)
+	pub1, idx1, err := w.NewFundPubkey()
+	assert.NoError(err)
+	assert.Equal(uint32(1), idx1)
+	assert.False(pub0.IsEqual(pub1))
+
+	// fund keys don't share indexes with receive addresses
+	recvPub, err := w.NewPubkey()
+	assert.NoError(err)
+	assert.False(recvPub.IsEqual(pub0))
+	scripts, err := w.watchedScripts()
+	assert.NoError(err)
+	assert.Len(scripts, 1)
+
+	// fund keys are looked up to sign
+	mpaddr, err := w.managedPubKeyAddressFromPubkey(pub1)
+	if assert.NoError(err) {
+		_, path, _ := mpaddr.DerivationInfo()
+		assert.Equal(uint32(1), path.Index)
+	}
+}
+
+func TestNewFundPubkeyWithoutFundAccount(t *testing.T) {
+	assert := assert.New(t)
+	db, tearDownFunc := setupDB(t)
+	defer tearDownFunc()
+
+	// a wallet created before fund keys had their own account
+	seed, _ := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+	err := createManagers(db, seed, testPubPass, testPrivPass, testNetParams)
+	assert.NoError(err)
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		addrMgr, e := waddrmgr.Open(ns, testPubPass, testNetParams)
+		if e != nil {
+			return e
+		}
+		if e = addrMgr.Unlock(ns, testPrivPass); e != nil {
+			return e
+		}
+		scopedMgr, e := addrMgr.FetchScopedKeyManager(waddrmgrKeyScope)
+		if e != nil {
+			return e
+		}
+		_, e = scopedMgr.NewAccount(ns, accountName)
+		return e
+	})
+	assert.NoError(err)
+	w, err := open(db, testPubPass, testNetParams, nil)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	// the fund account can't be created while the wallet is locked
+	_, _, err = w.NewFundPubkey()
+	assert.Error(err)
+
+	assert.NoError(w.Unlock(testPrivPass))
+	pub, idx, err := w.NewFundPubkey()
+	assert.NoError(err)
+	assert.Equal(uint32(0), idx)
+	_, err = w.managedPubKeyAddressFromPubkey(pub)
+	assert.NoError(err)
+}
+
+func TestNewAddress(t *testing.T) {
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
 
 	addr, err := w.NewAddress()
 	assert.NoError(t, err)
 	assert.Implements(t, (*btcutil.Address)(nil), addr)
 }
 
-func mockImportAddress(c *rpcmock.Client, err error) *rpcmock.Client {
-	c.On("ImportAddressRescan",
-		mock.AnythingOfType("string"), "", false,
-	).Return(err)
+func TestImportAddress(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	addr := deriveTestAddress(t, w, waddrmgr.InternalBranch, 7).Address()
+	err := w.ImportAddress(addr)
+	assert.NoError(err)
+	scripts, err := w.watchedScripts()
+	assert.NoError(err)
+	sc, _ := txscript.PayToAddrScript(addr)
+	assert.Contains(scripts, string(sc))
+
+	// already imported
+	assert.NoError(w.ImportAddress(addr))
 
-	return c
+	far := deriveTestAddress(t, w, waddrmgr.ExternalBranch, 200).Address()
+	assert.Error(w.ImportAddress(far))
 }
@@ -0,0 +1,41 @@
+package wallet
+
+import (
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// Balance returns a breakdown of the wallet balance.
+// It syncs the wallet with the chain first if rpc client is set.
+// Collateral locked in contracts isn't included since the wallet
+// doesn't know contracts.
+func (w *Wallet) Balance() (*wallet.Balance, error) {
+	if w.rpc != nil {
+		if err := w.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	credits, err := w.unspentCredits()
+	if err != nil {
+		return nil, err
+	}
+	locks, err := w.LockedUtxos()
+	if err != nil {
+		return nil, err
+	}
+
+	bal := &wallet.Balance{}
+	syncHeight := w.manager.SyncedTo().Height
+	for _, c := range credits {
+		if _, ok := w.spendableConfirmations(&c, syncHeight); !ok {
+			bal.Unconfirmed += c.Amount
+			continue
+		}
+		if _, ok := locks[c.OutPoint]; ok {
+			bal.Reserved += c.Amount
+			continue
+		}
+		bal.Confirmed += c.Amount
+	}
+	return bal, nil
+}
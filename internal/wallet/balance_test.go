@@ -0,0 +1,68 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalance(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	chain := &testChain{}
+	w.SetRPCClient(chain.rpcClient())
+
+	addr, _ := w.NewAddress()
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	otherScript := []byte{txscript.OP_TRUE}
+
+	// immature coinbase and two confirmed utxos
+	coinbase := newTestTx([]wire.OutPoint{
+		{Hash: chainhash.Hash{}, Index: wire.MaxPrevOutIndex}}, pkScript, 1000)
+	tx1 := newTestTx([]wire.OutPoint{{Hash: chainhash.Hash{1}}}, pkScript, 2000)
+	tx2 := newTestTx([]wire.OutPoint{{Hash: chainhash.Hash{2}}}, pkScript, 4000)
+	chain.addBlock(coinbase, tx1, tx2)
+
+	// one utxo is reserved for a contract
+	utxos, err := w.ListUnspent()
+	assert.NoError(err)
+	for _, utxo := range utxos {
+		if utxo.TxID == tx1.TxHash().String() {
+			err = w.LockUtxos([]wallet.Utxo{utxo}, time.Now().Add(time.Hour))
+			assert.NoError(err)
+		}
+	}
+
+	bal, err := w.Balance()
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(4000), bal.Confirmed)
+	assert.Equal(btcutil.Amount(1000), bal.Unconfirmed)
+	assert.Equal(btcutil.Amount(2000), bal.Reserved)
+	assert.Equal(btcutil.Amount(0), bal.LockedInContracts)
+	assert.Equal(btcutil.Amount(7000), bal.Total())
+
+	// a sent tx is unmined until it's connected
+	op := wire.OutPoint{Hash: tx2.TxHash(), Index: 0}
+	sent := newTestTx([]wire.OutPoint{op}, otherScript, 3000)
+	chain.mempool = append(chain.mempool, sent)
+	assert.NoError(w.addUnminedTx(sent))
+	bal, err = w.Balance()
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(0), bal.Confirmed)
+
+	chain.addBlock(sent)
+	txs, err := w.Transactions()
+	assert.NoError(err)
+	if assert.Len(txs, 4) {
+		assert.Equal(sent.TxHash(), txs[3].MsgTx.TxHash())
+		assert.Equal(int32(2), txs[3].Height)
+	}
+}
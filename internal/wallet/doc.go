@@ -8,9 +8,12 @@ It is heavily based on btcsuite's
 Assumptions
 
 Right now, this library assumes only one cointype will be used
-(`waddrmgr.KeyScopeBIP0084`)and there will be only one account associated with
-that cointype. If this is to change, this library will need to be refactored a
-little (actually a lot).
+(`waddrmgr.KeyScopeBIP0084`) with two accounts associated with that cointype.
+The `dlc` account holds receive and change addresses, and the `dlcfund` account
+holds fund pubkeys of contracts, whose indexes are stored in the contracts.
+Wallets created before the `dlcfund` account existed get it on the first fund
+pubkey, which requires the wallet to be unlocked. If more cointypes are to be
+supported, this library will need to be refactored a little (actually a lot).
 
 How address management works
 
@@ -27,12 +30,34 @@ Under each `ScopedKeyManager` are `Account` types associated with that
 For more information on address management, please consult the original
 [godoc](https://godoc.org/github.com/btcsuite/btcwallet/waddrmgr).
 
+Watch-only wallets
+
+A wallet created by `CreateWatchOnlyWallet` holds only xpubs of the `dlc` and
+`dlcfund` accounts in its own namespace instead of `waddrmgr`, and derives the
+same addresses. It has no private keys, so witness signatures are delegated to
+a `Signer` as PSBTs (BIP174) with BIP32 derivations of the signing pubkeys.
+Schnorr and MuSig2 signatures aren't supported by watch-only wallets.
+Neither is `WitnessSignatureWithCallback`, since the converted privkey can't be
+passed to a `Signer`. So a watch-only wallet can't claim the output of own CETs
+by closing txs or punish revoked channel states, and `dlc.Builder` refuses to
+sign own CETs and to open channels with it.
+
 How UTXO management works
 
-Right now, to ask about UTXOS, the wallet will query the running `bitcoind`
-instance by using the rpc command `ListUnspent()`.
-When a public address is generated, it is also registered to `bitcoind`, so
-`bitcoind` knows to keep track of transactions associated with that addresss.
+The wallet tracks its own transactions with btcsuite's
+[wtxmgr](http://godoc.org/github.com/btcsuite/btcwallet/wtxmgr) instead of
+registering addresses to `bitcoind` as watch-only.
+`Sync()` fetches blocks from the last synced block to the chain tip by the rpc
+commands `getblockhash` and `getblock`, and stores transactions paying to or
+spending from the wallet. The synced block is recorded in the address manager,
+and blocks that are no longer in the main chain are rolled back.
+`ListUnspent()` syncs the wallet and computes UTXOs from the stored
+transactions, so any `bitcoind` node can serve the wallet.
+Transactions broadcast by the wallet are stored as unmined until they are
+found in a block, so their inputs aren't selected again and their change is
+reported as unconfirmed by `Balance()`. Unmined transactions that are no longer
+in the mempool of the node (`getrawmempool`) are removed by `Sync()`, so the
+inputs of a dropped transaction become spendable again.
 
 Connecting to `bitcoind`
 
@@ -0,0 +1,50 @@
+package wallet
+
+import (
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// Transactions returns confirmed txs relevant to the wallet in block order.
+// It syncs the wallet with the chain first if rpc client is set.
+func (w *Wallet) Transactions() ([]wallet.Transaction, error) {
+	if w.rpc != nil {
+		if err := w.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	var details []wtxmgr.TxDetails
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		return w.txStore.RangeTransactions(ns, 0, w.manager.SyncedTo().Height,
+			func(ds []wtxmgr.TxDetails) (bool, error) {
+				details = append(details, ds...)
+				return false, nil
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	txs := []wallet.Transaction{}
+	for i := range details {
+		d := &details[i]
+		tx := wallet.Transaction{
+			MsgTx:     &d.MsgTx,
+			Height:    d.Block.Height,
+			BlockTime: d.Block.Time,
+			Credits:   []wallet.TxCredit{},
+		}
+		for _, c := range d.Credits {
+			tx.Credits = append(tx.Credits, wallet.TxCredit{
+				Index: c.Index, Amount: c.Amount, Change: c.Change})
+		}
+		for _, debit := range d.Debits {
+			tx.Debit += debit.Amount
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
@@ -0,0 +1,53 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactions(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	chain := &testChain{}
+	w.SetRPCClient(chain.rpcClient())
+
+	addr, _ := w.NewAddress()
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	otherScript := []byte{txscript.OP_TRUE}
+
+	// irrelevant, receive and spend
+	chain.addBlock(newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{1}}}, otherScript, 1000))
+	recvTx := newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{2}}}, pkScript, 5000)
+	chain.addBlock(recvTx)
+	op := wire.OutPoint{Hash: recvTx.TxHash(), Index: 0}
+	spendTx := newTestTx([]wire.OutPoint{op}, otherScript, 4000)
+	chain.addBlock(spendTx)
+
+	txs, err := w.Transactions()
+	assert.NoError(err)
+	if !assert.Len(txs, 2) {
+		assert.FailNow("txs aren't found")
+	}
+
+	assert.Equal(recvTx.TxHash(), txs[0].MsgTx.TxHash())
+	assert.Equal(int32(2), txs[0].Height)
+	assert.Equal(chain.blocks[1].Header.Timestamp, txs[0].BlockTime)
+	assert.Len(txs[0].Credits, 1)
+	assert.Equal(btcutil.Amount(5000), txs[0].Credits[0].Amount)
+	assert.False(txs[0].Credits[0].Change)
+	assert.Equal(btcutil.Amount(0), txs[0].Debit)
+
+	assert.Equal(spendTx.TxHash(), txs[1].MsgTx.TxHash())
+	assert.Equal(int32(3), txs[1].Height)
+	assert.Empty(txs[1].Credits)
+	assert.Equal(btcutil.Amount(5000), txs[1].Debit)
+}
@@ -0,0 +1,95 @@
+package wallet
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// keyStore derives and stores addresses of the wallet accounts and
+// records the synced block. It's backed by waddrmgr, or by account xpubs
+// in a watch-only wallet. Methods take the bucket of the address manager
+// namespace.
+type keyStore interface {
+	SyncedTo() waddrmgr.BlockStamp
+	SetSyncedTo(ns walletdb.ReadWriteBucket, bs *waddrmgr.BlockStamp) error
+	Birthday() time.Time
+	BlockHash(ns walletdb.ReadBucket, height int32) (*chainhash.Hash, error)
+
+	LookupAccount(ns walletdb.ReadBucket, name string) (uint32, error)
+	NewAccount(ns walletdb.ReadWriteBucket, name string) (uint32, error)
+	AccountProperties(
+		ns walletdb.ReadBucket, account uint32) (*waddrmgr.AccountProperties, error)
+
+	Address(ns walletdb.ReadBucket, addr btcutil.Address) (waddrmgr.ManagedAddress, error)
+	ForEachActiveAccountAddress(ns walletdb.ReadBucket, account uint32,
+		fn func(maddr waddrmgr.ManagedAddress) error) error
+	DeriveFromKeyPath(
+		ns walletdb.ReadBucket, path waddrmgr.DerivationPath) (waddrmgr.ManagedAddress, error)
+	NextExternalAddresses(
+		ns walletdb.ReadWriteBucket, account, num uint32) ([]waddrmgr.ManagedAddress, error)
+	NextInternalAddresses(
+		ns walletdb.ReadWriteBucket, account, num uint32) ([]waddrmgr.ManagedAddress, error)
+	ExtendExternalAddresses(ns walletdb.ReadWriteBucket, account, lastIdx uint32) error
+	ExtendInternalAddresses(ns walletdb.ReadWriteBucket, account, lastIdx uint32) error
+
+	Unlock(ns walletdb.ReadBucket, privPass []byte) error
+	Close()
+}
+
+// scopedManager is a keyStore of waddrmgr with the key scope of the wallet
+type scopedManager struct {
+	*waddrmgr.Manager
+	scoped *waddrmgr.ScopedKeyManager
+}
+
+func newScopedManager(mgr *waddrmgr.Manager) (*scopedManager, error) {
+	scoped, err := mgr.FetchScopedKeyManager(waddrmgrKeyScope)
+	if err != nil {
+		return nil, err
+	}
+	return &scopedManager{Manager: mgr, scoped: scoped}, nil
+}
+
+func (m *scopedManager) LookupAccount(
+	ns walletdb.ReadBucket, name string) (uint32, error) {
+	return m.scoped.LookupAccount(ns, name)
+}
+
+func (m *scopedManager) NewAccount(
+	ns walletdb.ReadWriteBucket, name string) (uint32, error) {
+	return m.scoped.NewAccount(ns, name)
+}
+
+func (m *scopedManager) AccountProperties(
+	ns walletdb.ReadBucket, account uint32) (*waddrmgr.AccountProperties, error) {
+	return m.scoped.AccountProperties(ns, account)
+}
+
+func (m *scopedManager) DeriveFromKeyPath(
+	ns walletdb.ReadBucket, path waddrmgr.DerivationPath) (waddrmgr.ManagedAddress, error) {
+	return m.scoped.DeriveFromKeyPath(ns, path)
+}
+
+func (m *scopedManager) NextExternalAddresses(
+	ns walletdb.ReadWriteBucket, account, num uint32) ([]waddrmgr.ManagedAddress, error) {
+	return m.scoped.NextExternalAddresses(ns, account, num)
+}
+
+func (m *scopedManager) NextInternalAddresses(
+	ns walletdb.ReadWriteBucket, account, num uint32) ([]waddrmgr.ManagedAddress, error) {
+	return m.scoped.NextInternalAddresses(ns, account, num)
+}
+
+func (m *scopedManager) ExtendExternalAddresses(
+	ns walletdb.ReadWriteBucket, account, lastIdx uint32) error {
+	return m.scoped.ExtendExternalAddresses(ns, account, lastIdx)
+}
+
+func (m *scopedManager) ExtendInternalAddresses(
+	ns walletdb.ReadWriteBucket, account, lastIdx uint32) error {
+	return m.scoped.ExtendInternalAddresses(ns, account, lastIdx)
+}
@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// utxoLocksNamespaceKey is a bucket key of locked utxos.
+// The bucket is created on demand so that existing wallets work without upgrade.
+var utxoLocksNamespaceKey = []byte("utxolocks")
+
+// LockUtxos locks utxos until expiry so that they aren't selected
+// for the other contracts being negotiated
+func (w *Wallet) LockUtxos(utxos []wallet.Utxo, expiry time.Time) error {
+	ops := []wire.OutPoint{}
+	for _, utxo := range utxos {
+		op, err := utxoOutPoint(utxo)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, *op)
+	}
+
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns, e := locksBucket(tx)
+		if e != nil {
+			return e
+		}
+		return putLocks(ns, ops, expiry)
+	})
+}
+
+// SelectAndLockUnspent is an implementation of Wallet.SelectAndLockUnspent.
+// Utxos are selected from unlocked ones and locked in a single db transaction
+// so that concurrent negotiations never select the same utxos.
+func (w *Wallet) SelectAndLockUnspent(
+	s wallet.CoinSelector, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+	expiry time.Time,
+) (utxos []wallet.Utxo, change btcutil.Amount, err error) {
+	if s == nil {
+		s = wallet.FirstFit{}
+	}
+
+	utxosAll, err := w.ListUnspent()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns, e := locksBucket(tx)
+		if e != nil {
+			return e
+		}
+
+		unlocked := []wallet.Utxo{}
+		for _, utxo := range utxosAll {
+			op, e := utxoOutPoint(utxo)
+			if e != nil {
+				return e
+			}
+			if ns.Get(outPointKey(op)) == nil {
+				unlocked = append(unlocked, utxo)
+			}
+		}
+
+		utxos, change, e = s.Select(unlocked, amt, feePerTxIn, feePerTxOut)
+		if e != nil {
+			return e
+		}
+
+		ops := []wire.OutPoint{}
+		for _, utxo := range utxos {
+			op, e := utxoOutPoint(utxo)
+			if e != nil {
+				return e
+			}
+			ops = append(ops, *op)
+		}
+		return putLocks(ns, ops, expiry)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return utxos, change, nil
+}
+
+// UnlockUtxos releases locks of utxos
+func (w *Wallet) UnlockUtxos(utxos []wallet.Utxo) error {
+	ops := []wire.OutPoint{}
+	for _, utxo := range utxos {
+		op, err := utxoOutPoint(utxo)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, *op)
+	}
+
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(utxoLocksNamespaceKey)
+		if ns == nil {
+			return nil
+		}
+		for _, op := range ops {
+			if e := ns.Delete(outPointKey(&op)); e != nil {
+				return e
+			}
+		}
+		return pruneExpiredLocks(ns)
+	})
+}
+
+// LockedUtxos returns outpoints of locked utxos with their expiries.
+// Expired locks aren't included.
+func (w *Wallet) LockedUtxos() (map[wire.OutPoint]time.Time, error) {
+	locks := make(map[wire.OutPoint]time.Time)
+	now := time.Now()
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(utxoLocksNamespaceKey)
+		if ns == nil {
+			return nil
+		}
+		return ns.ForEach(func(k, v []byte) error {
+			expiry := lockExpiry(v)
+			if !expiry.After(now) {
+				return nil
+			}
+			locks[parseOutPointKey(k)] = expiry
+			return nil
+		})
+	})
+	return locks, err
+}
+
+// unlockedUtxos filters out locked utxos
+func (w *Wallet) unlockedUtxos(utxos []wallet.Utxo) ([]wallet.Utxo, error) {
+	locks, err := w.LockedUtxos()
+	if err != nil {
+		return nil, err
+	}
+
+	unlocked := []wallet.Utxo{}
+	for _, utxo := range utxos {
+		op, err := utxoOutPoint(utxo)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := locks[*op]; !ok {
+			unlocked = append(unlocked, utxo)
+		}
+	}
+	return unlocked, nil
+}
+
+// locksBucket returns the bucket of locked utxos without expired locks
+func locksBucket(tx walletdb.ReadWriteTx) (walletdb.ReadWriteBucket, error) {
+	ns, err := tx.CreateTopLevelBucket(utxoLocksNamespaceKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = pruneExpiredLocks(ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// putLocks locks outpoints until expiry
+func putLocks(
+	ns walletdb.ReadWriteBucket, ops []wire.OutPoint, expiry time.Time) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(expiry.Unix()))
+	for _, op := range ops {
+		if err := ns.Put(outPointKey(&op), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneExpiredLocks deletes expired locks
+func pruneExpiredLocks(ns walletdb.ReadWriteBucket) error {
+	now := time.Now()
+	expired := [][]byte{}
+	err := ns.ForEach(func(k, v []byte) error {
+		if !lockExpiry(v).After(now) {
+			expired = append(expired, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err = ns.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lockExpiry(v []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint64(v)), 0)
+}
+
+// outPointKey serializes an outpoint (txid + vout)
+func outPointKey(op *wire.OutPoint) []byte {
+	k := make([]byte, chainhash.HashSize+4)
+	copy(k, op.Hash[:])
+	binary.BigEndian.PutUint32(k[chainhash.HashSize:], op.Index)
+	return k
+}
+
+func parseOutPointKey(k []byte) wire.OutPoint {
+	var hash chainhash.Hash
+	copy(hash[:], k[:chainhash.HashSize])
+	return *wire.NewOutPoint(&hash, binary.BigEndian.Uint32(k[chainhash.HashSize:]))
+}
+
+// utxoOutPoint returns an outpoint of a utxo
+func utxoOutPoint(utxo wallet.Utxo) (*wire.OutPoint, error) {
+	txid, err := chainhash.NewHashFromStr(utxo.TxID)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewOutPoint(txid, utxo.Vout), nil
+}
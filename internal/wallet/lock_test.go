@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLockUtxos() []wallet.Utxo {
+	return []wallet.Utxo{
+		{TxID: chainhash.Hash{1}.String(), Vout: 0},
+		{TxID: chainhash.Hash{1}.String(), Vout: 1},
+		{TxID: chainhash.Hash{2}.String(), Vout: 0},
+	}
+}
+
+func TestLockUtxos(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	// no locks at beginning
+	locks, err := w.LockedUtxos()
+	assert.NoError(err)
+	assert.Empty(locks)
+
+	utxos := testLockUtxos()
+	expiry := time.Now().Add(time.Hour)
+	err = w.LockUtxos(utxos[:2], expiry)
+	assert.NoError(err)
+
+	locks, err = w.LockedUtxos()
+	assert.NoError(err)
+	assert.Len(locks, 2)
+
+	unlocked, err := w.unlockedUtxos(utxos)
+	assert.NoError(err)
+	assert.Equal(utxos[2:], unlocked)
+
+	// locks are persisted
+	w2, err := open(w.db, w.publicPassphrase, w.params, nil)
+	assert.NoError(err)
+	locks, err = w2.LockedUtxos()
+	assert.NoError(err)
+	assert.Len(locks, 2)
+
+	// release
+	err = w.UnlockUtxos(utxos[:1])
+	assert.NoError(err)
+	unlocked, err = w.unlockedUtxos(utxos)
+	assert.NoError(err)
+	assert.Equal([]wallet.Utxo{utxos[0], utxos[2]}, unlocked)
+}
+
+func TestLockUtxosExpired(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	utxos := testLockUtxos()
+	err := w.LockUtxos(utxos, time.Now().Add(-time.Second))
+	assert.NoError(err)
+
+	// expired locks are ignored
+	unlocked, err := w.unlockedUtxos(utxos)
+	assert.NoError(err)
+	assert.Equal(utxos, unlocked)
+
+	// and pruned on the next update
+	err = w.LockUtxos(utxos[:1], time.Now().Add(time.Hour))
+	assert.NoError(err)
+	locks, err := w.LockedUtxos()
+	assert.NoError(err)
+	assert.Len(locks, 1)
+}
+
+// concurrent selections should never select the same utxos
+func TestSelectAndLockUnspentConcurrently(t *testing.T) {
+	assert := assert.New(t)
+	n := 4
+	amts := []btcutil.Amount{}
+	for i := 0; i < n; i++ {
+		amts = append(amts, 5000)
+	}
+	w, _, _, tearDownFunc := setupSendWallet(t, amts...)
+	defer tearDownFunc()
+
+	// sync before selections
+	_, err := w.ListUnspent()
+	assert.NoError(err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	selected := map[string]int{}
+	expiry := time.Now().Add(time.Hour)
+	for i := 0; i < n+1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			utxos, _, err := w.SelectAndLockUnspent(nil, 4000, 0, 0, expiry)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, utxo := range utxos {
+				op, _ := utxoOutPoint(utxo)
+				selected[op.String()]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every utxo is selected exactly once and the extra selection fails
+	assert.Len(selected, n)
+	for op, count := range selected {
+		assert.Equal(1, count, op)
+	}
+	locks, err := w.LockedUtxos()
+	assert.NoError(err)
+	assert.Len(locks, n)
+
+	_, _, err = w.SelectAndLockUnspent(
+		wallet.LargestFirst{}, 4000, 0, 0, expiry)
+	assert.IsType(&wallet.NotEnoughUtxosError{}, err)
+}
@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
+	"github.com/p2pderivatives/dlc/pkg/script"
+)
+
+// psbtInput is an input of a tx to be signed by the signer
+type psbtInput struct {
+	idx    int
+	amt    btcutil.Amount
+	sc     []byte // witness script, or pkScript of p2wpkh
+	mpaddr waddrmgr.ManagedPubKeyAddress
+}
+
+// signByPSBT asks the signer to sign inputs of a tx with the pubkeys of
+// given addresses, and returns their signatures in the same order
+func (w *Wallet) signByPSBT(tx *wire.MsgTx, inputs []psbtInput) ([][]byte, error) {
+	if w.signer == nil {
+		return nil, errors.New("watch-only wallet requires a signer")
+	}
+
+	p, err := w.newPSBT(tx, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := w.signer.SignPSBT(p)
+	if err != nil {
+		return nil, err
+	}
+	if signed.UnsignedTx.TxHash() != p.UnsignedTx.TxHash() ||
+		len(signed.Inputs) != len(p.Inputs) {
+		return nil, errors.New("signer returned PSBT of another tx")
+	}
+
+	signs := [][]byte{}
+	for _, in := range inputs {
+		pub := in.mpaddr.PubKey().SerializeCompressed()
+		sign, ok := signed.Inputs[in.idx].PartialSigOf(pub)
+		if !ok {
+			return nil, fmt.Errorf("signer didn't sign input %d", in.idx)
+		}
+		signs = append(signs, sign)
+	}
+	return signs, nil
+}
+
+// newPSBT creates a PSBT of a tx having utxos and BIP32 derivations of
+// given inputs. Signatures and witnesses of the tx are stripped.
+func (w *Wallet) newPSBT(tx *wire.MsgTx, inputs []psbtInput) (*psbt.Packet, error) {
+	unsigned := tx.Copy()
+	for _, txin := range unsigned.TxIn {
+		txin.SignatureScript = nil
+		txin.Witness = nil
+	}
+	p, err := psbt.New(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, in := range inputs {
+		if in.idx < 0 || in.idx >= len(p.Inputs) {
+			return nil, fmt.Errorf("input index out of range. %d", in.idx)
+		}
+		pi := &p.Inputs[in.idx]
+
+		pkScript := in.sc
+		if !txscript.IsPayToWitnessPubKeyHash(in.sc) {
+			pi.WitnessScript = in.sc
+			if pkScript, err = script.P2WSHpkScript(in.sc); err != nil {
+				return nil, err
+			}
+		}
+		pi.WitnessUtxo = wire.NewTxOut(int64(in.amt), pkScript)
+		pi.Bip32Derivation = append(
+			pi.Bip32Derivation, w.watchOnly.bip32Derivation(in.mpaddr))
+	}
+	return p, nil
+}
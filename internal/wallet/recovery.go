@@ -0,0 +1,208 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// DefaultGapLimit is the default number of consecutive addresses
+// scanned beyond the last derived address of each branch in recovery
+const DefaultGapLimit = uint32(20)
+
+// branches of the account scanned in recovery
+var branches = []uint32{waddrmgr.ExternalBranch, waddrmgr.InternalBranch}
+
+// Recover rescans the chain from the genesis block to restore funds of
+// a wallet created from an existing seed. Addresses up to gapLimit beyond
+// the last derived address of external and internal branches are watched,
+// and the ones found used are derived and stored in the wallet.
+func (w *Wallet) Recover(gapLimit uint32) error {
+	if gapLimit == 0 {
+		return errors.New("gap limit must be positive")
+	}
+	if w.rpc == nil {
+		return errors.New("rpc client isn't set")
+	}
+
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		txmgrNs := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if e := w.txStore.Rollback(txmgrNs, 1); e != nil {
+			return e
+		}
+		addrmgrNs := tx.ReadWriteBucket(w.addrmgrNsKey)
+		return w.manager.SetSyncedTo(addrmgrNs, &waddrmgr.BlockStamp{
+			Height: 0,
+			Hash:   *w.params.GenesisHash,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.sync(gapLimit)
+}
+
+// RecoverPubkeys derives and stores addresses of given pubkeys
+// (e.g. fund pubkeys found in stored contracts) so that the wallet can
+// sign with them. Pubkeys are searched up to gapLimit beyond the last
+// derived address of each branch, and the ones found are returned.
+func (w *Wallet) RecoverPubkeys(
+	pubs []*btcec.PublicKey, gapLimit uint32,
+) ([]*btcec.PublicKey, error) {
+	recovered := []*btcec.PublicKey{}
+	remaining := []*btcec.PublicKey{}
+	for _, pub := range pubs {
+		if _, err := w.managedPubKeyAddressFromPubkey(pub); err == nil {
+			recovered = append(recovered, pub)
+			continue
+		}
+		remaining = append(remaining, pub)
+	}
+
+	for len(remaining) > 0 {
+		horizon, err := w.horizon(gapLimit, pubkeyKey)
+		if err != nil {
+			return nil, err
+		}
+
+		lastIdxs := make(map[uint32]uint32)
+		notFound := []*btcec.PublicKey{}
+		for _, pub := range remaining {
+			path, ok := horizon[string(pub.SerializeCompressed())]
+			if !ok {
+				notFound = append(notFound, pub)
+				continue
+			}
+			updateLastIndex(lastIdxs, path)
+			recovered = append(recovered, pub)
+		}
+		if len(lastIdxs) == 0 {
+			break
+		}
+
+		if err = w.extendAddresses(lastIdxs); err != nil {
+			return nil, err
+		}
+		remaining = notFound
+	}
+	return recovered, nil
+}
+
+// extendToUsedAddresses stores addresses in a horizon paid by a block
+// with all addresses before them, and returns the updated horizon.
+// It returns true if any address is stored.
+func (w *Wallet) extendToUsedAddresses(
+	block *wire.MsgBlock, gapLimit uint32,
+	horizon map[string]waddrmgr.DerivationPath,
+) (map[string]waddrmgr.DerivationPath, bool, error) {
+	extended := false
+	for {
+		lastIdxs := make(map[uint32]uint32)
+		for _, tx := range block.Transactions {
+			for _, txout := range tx.TxOut {
+				if path, ok := horizon[string(txout.PkScript)]; ok {
+					updateLastIndex(lastIdxs, path)
+				}
+			}
+		}
+		if len(lastIdxs) == 0 {
+			return horizon, extended, nil
+		}
+
+		err := w.extendAddresses(lastIdxs)
+		if err != nil {
+			return nil, false, err
+		}
+		extended = true
+
+		// addresses beyond the stored ones may be paid in the same block
+		horizon, err = w.horizon(gapLimit, scriptKey)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// horizon derives addresses up to gapLimit beyond the last derived address
+// of each branch without storing them. It returns their derivation paths
+// keyed by a given func.
+func (w *Wallet) horizon(
+	gapLimit uint32, key func(waddrmgr.ManagedAddress) (string, error),
+) (map[string]waddrmgr.DerivationPath, error) {
+	paths := make(map[string]waddrmgr.DerivationPath)
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(w.addrmgrNsKey)
+		props, e := w.manager.AccountProperties(ns, w.account)
+		if e != nil {
+			return e
+		}
+
+		for _, branch := range branches {
+			next := props.ExternalKeyCount
+			if branch == waddrmgr.InternalBranch {
+				next = props.InternalKeyCount
+			}
+			for idx := next; idx < next+gapLimit; idx++ {
+				path := waddrmgr.DerivationPath{
+					Account: w.account, Branch: branch, Index: idx}
+				maddr, e := w.manager.DeriveFromKeyPath(ns, path)
+				if e != nil {
+					return e
+				}
+				k, e := key(maddr)
+				if e != nil {
+					return e
+				}
+				paths[k] = path
+			}
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// extendAddresses derives and stores addresses of branches
+// up to given indexes
+func (w *Wallet) extendAddresses(lastIdxs map[uint32]uint32) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(w.addrmgrNsKey)
+		for branch, idx := range lastIdxs {
+			var e error
+			if branch == waddrmgr.InternalBranch {
+				e = w.manager.ExtendInternalAddresses(ns, w.account, idx)
+			} else {
+				e = w.manager.ExtendExternalAddresses(ns, w.account, idx)
+			}
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+func updateLastIndex(lastIdxs map[uint32]uint32, path waddrmgr.DerivationPath) {
+	if idx, ok := lastIdxs[path.Branch]; !ok || path.Index > idx {
+		lastIdxs[path.Branch] = path.Index
+	}
+}
+
+// scriptKey is a horizon key of pkScript
+func scriptKey(maddr waddrmgr.ManagedAddress) (string, error) {
+	sc, err := txscript.PayToAddrScript(maddr.Address())
+	return string(sc), err
+}
+
+// pubkeyKey is a horizon key of compressed pubkey
+func pubkeyKey(maddr waddrmgr.ManagedAddress) (string, error) {
+	mpka, ok := maddr.(waddrmgr.ManagedPubKeyAddress)
+	if !ok {
+		return "", errors.New("not a pubkey address")
+	}
+	return string(mpka.PubKey().SerializeCompressed()), nil
+}
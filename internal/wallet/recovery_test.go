@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// deriveTestAddress derives an address of the wallet without storing it
+func deriveTestAddress(
+	t *testing.T, w *Wallet, branch, idx uint32) waddrmgr.ManagedPubKeyAddress {
+	var maddr waddrmgr.ManagedAddress
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) (e error) {
+		ns := tx.ReadBucket(w.addrmgrNsKey)
+		maddr, e = w.manager.DeriveFromKeyPath(ns, waddrmgr.DerivationPath{
+			Account: w.account, Branch: branch, Index: idx})
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return maddr.(waddrmgr.ManagedPubKeyAddress)
+}
+
+func testPayment(
+	t *testing.T, w *Wallet, branch, idx uint32, amt btcutil.Amount) *wire.MsgTx {
+	sc, _ := txscript.PayToAddrScript(
+		deriveTestAddress(t, w, branch, idx).Address())
+	prev := wire.OutPoint{Hash: chainhash.Hash{byte(branch), byte(idx)}}
+	return newTestTx([]wire.OutPoint{prev}, sc, amt)
+}
+
+func TestRecover(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	ext, in := waddrmgr.ExternalBranch, waddrmgr.InternalBranch
+	chain := &testChain{}
+	chain.addBlock(testPayment(t, w, ext, 5, 1000))
+	// 28 is beyond the gap from 5, but within the gap from 10
+	chain.addBlock(
+		testPayment(t, w, ext, 10, 2000),
+		testPayment(t, w, ext, 28, 3000),
+		testPayment(t, w, in, 3, 4000))
+	// beyond the gap
+	chain.addBlock(testPayment(t, w, ext, 60, 5000))
+	w.SetRPCClient(chain.rpcClient())
+
+	err := w.Recover(DefaultGapLimit)
+	assert.NoError(err)
+
+	bal, err := w.Balance()
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(10000), bal.Confirmed)
+
+	// new addresses follow the last used ones
+	addr, err := w.NewAddress()
+	assert.NoError(err)
+	assert.Equal(deriveTestAddress(t, w, ext, 29).Address(), addr)
+	change, err := w.newChangeAddress()
+	assert.NoError(err)
+	assert.Equal(deriveTestAddress(t, w, in, 4).Address(), change)
+
+	// recovery is repeatable
+	err = w.Recover(DefaultGapLimit)
+	assert.NoError(err)
+	bal, err = w.Balance()
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(10000), bal.Confirmed)
+
+	// a larger gap limit finds more
+	err = w.Recover(40)
+	assert.NoError(err)
+	bal, err = w.Balance()
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(15000), bal.Confirmed)
+}
+
+func TestRecoverWithoutRPC(t *testing.T) {
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	err := w.Recover(DefaultGapLimit)
+	assert.Error(t, err)
+}
+
+func TestRecoverPubkeys(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	stored, err := w.NewPubkey()
+	assert.NoError(err)
+	pub15 := deriveTestAddress(t, w, waddrmgr.ExternalBranch, 15).PubKey()
+	pub30 := deriveTestAddress(t, w, waddrmgr.ExternalBranch, 30).PubKey()
+	_, other := btcec.PrivKeyFromBytes(btcec.S256(), []byte{1})
+
+	recovered, err := w.RecoverPubkeys(
+		[]*btcec.PublicKey{stored, pub30, other, pub15}, DefaultGapLimit)
+	assert.NoError(err)
+	assert.Len(recovered, 3)
+	assert.Contains(recovered, stored)
+	assert.Contains(recovered, pub15)
+	assert.Contains(recovered, pub30)
+
+	_, err = w.managedPubKeyAddressFromPubkey(pub30)
+	assert.NoError(err)
+	_, err = w.managedPubKeyAddressFromPubkey(other)
+	assert.Error(err)
+}
+
+func TestRecoverFundPubkeys(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	// derive a fund key without storing it
+	var maddr waddrmgr.ManagedAddress
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(w.addrmgrNsKey)
+		account, e := w.manager.LookupAccount(ns, fundAccountName)
+		if e != nil {
+			return e
+		}
+		maddr, e = w.manager.DeriveFromKeyPath(ns, waddrmgr.DerivationPath{
+			Account: account, Branch: waddrmgr.ExternalBranch, Index: 3})
+		return e
+	})
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	pub := maddr.(waddrmgr.ManagedPubKeyAddress).PubKey()
+
+	_, err = w.managedPubKeyAddressFromPubkey(pub)
+	assert.Error(err)
+	assert.NoError(w.RecoverFundPubkeys(3))
+	_, err = w.managedPubKeyAddressFromPubkey(pub)
+	assert.NoError(err)
+
+	// the next fund key follows the recovered ones
+	_, idx, err := w.NewFundPubkey()
+	assert.NoError(err)
+	assert.Equal(uint32(4), idx)
+}
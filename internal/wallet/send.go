@@ -0,0 +1,160 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// sendTxBaseSize is size (vbytes) of a tx spending p2wpkh utxos
+// without txins and txouts (version, locktime, txin/txout counts, segwit marker)
+const sendTxBaseSize = int64(11)
+
+// Send builds a tx paying to given outputs, signs and broadcasts it.
+// Utxos are selected in the order of ListUnspent, and the change is sent
+// to a new internal address. Feerate is in satoshi/byte.
+func (w *Wallet) Send(
+	outputs []*wire.TxOut, feerate btcutil.Amount,
+) (*wire.MsgTx, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("no outputs are given")
+	}
+
+	tx := wire.NewMsgTx(2)
+	amt := btcutil.Amount(0)
+	size := sendTxBaseSize
+	for _, txout := range outputs {
+		if btcutil.Amount(txout.Value) < wallet.DustThreshold {
+			msg := fmt.Sprintf(
+				"output amount must be at least %d satoshi. amount: %d",
+				wallet.DustThreshold, txout.Value)
+			return nil, errors.New(msg)
+		}
+		tx.AddTxOut(txout)
+		amt += btcutil.Amount(txout.Value)
+		size += script.TxOutSize(txout.PkScript)
+	}
+	amt += feerate.MulF64(float64(size))
+
+	feePerTxIn := feerate.MulF64(float64(script.P2WPKHTxInSize))
+	feePerTxOut := feerate.MulF64(float64(script.P2WPKHTxOutSize))
+	utxos, change, err := w.SelectUnspent(amt, feePerTxIn, feePerTxOut)
+	if err != nil {
+		return nil, err
+	}
+
+	if change >= wallet.DustThreshold {
+		addr, err := w.newChangeAddress()
+		if err != nil {
+			return nil, err
+		}
+		sc, err := script.PkScriptFromAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(change), sc))
+	}
+
+	return w.signAndSend(tx, utxos)
+}
+
+// Sweep sends all unlocked utxos to a given address.
+// Feerate is in satoshi/byte.
+func (w *Wallet) Sweep(
+	addr btcutil.Address, feerate btcutil.Amount,
+) (*wire.MsgTx, error) {
+	utxosAll, err := w.ListUnspent()
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := w.unlockedUtxos(utxosAll)
+	if err != nil {
+		return nil, err
+	}
+	if len(utxos) == 0 {
+		return nil, errors.New("no utxos to sweep")
+	}
+
+	sc, err := script.PkScriptFromAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	total := btcutil.Amount(0)
+	for _, utxo := range utxos {
+		amt, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return nil, err
+		}
+		total += amt
+	}
+
+	size := sendTxBaseSize +
+		script.P2WPKHTxInSize*int64(len(utxos)) + script.TxOutSize(sc)
+	amt := total - feerate.MulF64(float64(size))
+	if amt < wallet.DustThreshold {
+		msg := fmt.Sprintf(
+			"utxos don't cover the fee. total: %d, fee: %d", total, total-amt)
+		return nil, errors.New(msg)
+	}
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxOut(wire.NewTxOut(int64(amt), sc))
+	return w.signAndSend(tx, utxos)
+}
+
+// signAndSend adds txins spending given utxos to tx, signs and broadcasts it.
+// The tx is recorded as unmined until it's confirmed.
+func (w *Wallet) signAndSend(
+	tx *wire.MsgTx, utxos []wallet.Utxo,
+) (*wire.MsgTx, error) {
+	idxs := []int{}
+	for _, utxo := range utxos {
+		txid, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, err
+		}
+		txin := wire.NewTxIn(wire.NewOutPoint(txid, utxo.Vout), nil, nil)
+		idxs = append(idxs, len(tx.TxIn))
+		tx.AddTxIn(txin)
+	}
+
+	wits, err := w.WitnessSignTxByIdxs(tx, idxs)
+	if err != nil {
+		return nil, err
+	}
+	for i, idx := range idxs {
+		tx.TxIn[idx].Witness = wits[i]
+	}
+
+	_, err = w.SendRawTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.addUnminedTx(tx)
+	return tx, err
+}
+
+// newChangeAddress returns a new address of the internal branch
+func (w *Wallet) newChangeAddress() (btcutil.Address, error) {
+	var addrs []waddrmgr.ManagedAddress
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(w.addrmgrNsKey)
+		var e error
+		addrs, e = w.manager.NextInternalAddresses(ns, w.account, 1)
+		return e
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return addrs[0].Address(), nil
+}
@@ -0,0 +1,148 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/rpcmock"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupSendWallet creates an unlocked wallet having utxos of given amounts
+func setupSendWallet(
+	t *testing.T, amts ...btcutil.Amount,
+) (*Wallet, *rpcmock.Client, []byte, func()) {
+	w, tearDownFunc := setupWallet(t)
+	chain := &testChain{}
+	rpcc := chain.rpcClient()
+	w.SetRPCClient(rpcc)
+	w.Unlock(testPrivPass)
+
+	addr, _ := w.NewAddress()
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	for i, amt := range amts {
+		prev := wire.OutPoint{Hash: chainhash.Hash{byte(i + 1)}}
+		chain.addBlock(newTestTx([]wire.OutPoint{prev}, pkScript, amt))
+	}
+	return w, rpcc, pkScript, tearDownFunc
+}
+
+// isChangeScript returns true if a pkScript pays to an internal address
+func isChangeScript(w *Wallet, pkScript []byte) bool {
+	scripts, err := w.watchedScripts()
+	if err != nil {
+		return false
+	}
+	return scripts[string(pkScript)]
+}
+
+// assertTxSigned executes scripts of all txins spending the wallet utxos
+func assertTxSigned(
+	assert *assert.Assertions, tx *wire.MsgTx, pkScript []byte, amt btcutil.Amount) {
+	sighashes := txscript.NewTxSigHashes(tx)
+	for idx := range tx.TxIn {
+		vm, err := txscript.NewEngine(pkScript, tx, idx,
+			txscript.StandardVerifyFlags, nil, sighashes, int64(amt))
+		if assert.NoError(err) {
+			assert.NoError(vm.Execute())
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	assert := assert.New(t)
+	w, rpcc, pkScript, tearDownFunc := setupSendWallet(t, 5000, 5000)
+	defer tearDownFunc()
+
+	dest := []byte{txscript.OP_TRUE}
+	feerate := btcutil.Amount(1)
+	tx, err := w.Send([]*wire.TxOut{wire.NewTxOut(3000, dest)}, feerate)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	rpcc.AssertCalled(t, "SendRawTransaction", tx, false)
+
+	assert.Len(tx.TxIn, 1)
+	assertTxSigned(assert, tx, pkScript, 5000)
+
+	// destination and change to an internal address
+	assert.Len(tx.TxOut, 2)
+	assert.Equal(dest, tx.TxOut[0].PkScript)
+	size := sendTxBaseSize + script.P2WPKHTxInSize + script.TxOutSize(dest) + script.P2WPKHTxOutSize
+	assert.Equal(int64(5000-3000-size), tx.TxOut[1].Value)
+	assert.True(isChangeScript(w, tx.TxOut[1].PkScript))
+
+	// the tx is recorded as unmined
+	utxos, err := w.ListUnspent()
+	assert.NoError(err)
+	assert.Len(utxos, 1)
+	assert.NotEqual(tx.TxIn[0].PreviousOutPoint.Hash.String(), utxos[0].TxID)
+	bal, err := w.Balance()
+	assert.NoError(err)
+	assert.Equal(btcutil.Amount(tx.TxOut[1].Value), bal.Unconfirmed)
+
+	// the other utxo is used next
+	tx2, err := w.Send([]*wire.TxOut{wire.NewTxOut(3000, dest)}, feerate)
+	assert.NoError(err)
+	assert.NotEqual(tx.TxIn[0].PreviousOutPoint, tx2.TxIn[0].PreviousOutPoint)
+
+	_, err = w.Send([]*wire.TxOut{wire.NewTxOut(3000, dest)}, feerate)
+	assert.IsType(&wallet.NotEnoughUtxosError{}, err)
+}
+
+func TestSendWithoutChange(t *testing.T) {
+	assert := assert.New(t)
+	w, _, _, tearDownFunc := setupSendWallet(t, 5000)
+	defer tearDownFunc()
+
+	// change less than dust is paid as fee
+	dest := []byte{txscript.OP_TRUE}
+	tx, err := w.Send([]*wire.TxOut{wire.NewTxOut(4500, dest)}, 1)
+	assert.NoError(err)
+	assert.Len(tx.TxOut, 1)
+}
+
+func TestSendDust(t *testing.T) {
+	w, _, _, tearDownFunc := setupSendWallet(t, 5000)
+	defer tearDownFunc()
+
+	dest := []byte{txscript.OP_TRUE}
+	_, err := w.Send([]*wire.TxOut{wire.NewTxOut(100, dest)}, 1)
+	assert.Error(t, err)
+}
+
+func TestSweep(t *testing.T) {
+	assert := assert.New(t)
+	w, _, pkScript, tearDownFunc := setupSendWallet(t, 5000, 5000, 5000)
+	defer tearDownFunc()
+
+	// one utxo is reserved for a contract
+	utxos, _ := w.ListUnspent()
+	err := w.LockUtxos(utxos[:1], time.Now().Add(time.Hour))
+	assert.NoError(err)
+
+	addr, _ := btcutil.NewAddressWitnessScriptHash(
+		make([]byte, 32), testNetParams)
+	feerate := btcutil.Amount(2)
+	tx, err := w.Sweep(addr, feerate)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	assert.Len(tx.TxIn, 2)
+	assertTxSigned(assert, tx, pkScript, 5000)
+	assert.Len(tx.TxOut, 1)
+	sc, _ := txscript.PayToAddrScript(addr)
+	size := sendTxBaseSize + 2*script.P2WPKHTxInSize + script.TxOutSize(sc)
+	assert.Equal(int64(10000)-int64(feerate)*size, tx.TxOut[0].Value)
+
+	// nothing to sweep
+	_, err = w.Sweep(addr, feerate)
+	assert.Error(err)
+}
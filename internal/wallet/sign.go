@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/p2pderivatives/dlc/pkg/musig2"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
 	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 )
@@ -23,12 +26,12 @@ func (w *Wallet) WitnessSignature(
 		return nil, err
 	}
 
-	priv, err := mpaddr.PrivKey()
+	signs, err := w.witnessSignatures(
+		tx, []psbtInput{{idx: idx, amt: amt, sc: sc, mpaddr: mpaddr}})
 	if err != nil {
 		return nil, err
 	}
-	sign, err := script.WitnessSignature(tx, idx, int64(amt), sc, priv)
-	return sign, err
+	return signs[0], nil
 }
 
 // WitnessSignatureWithCallback does the same with WitnessSignature does,
@@ -38,6 +41,11 @@ func (w *Wallet) WitnessSignatureWithCallback(
 	tx *wire.MsgTx, idx int, amt btcutil.Amount, sc []byte, pub *btcec.PublicKey,
 	privkeyConverter wallet.PrivateKeyConverter,
 ) ([]byte, error) {
+	if w.watchOnly != nil {
+		return nil, errors.New(
+			"watch-only wallet can't sign with a converted privkey")
+	}
+
 	mpaddr, err := w.managedPubKeyAddressFromPubkey(pub)
 	if err != nil {
 		return nil, err
@@ -56,56 +64,130 @@ func (w *Wallet) WitnessSignatureWithCallback(
 	return sign, err
 }
 
+// SchnorrSignature returns BIP340 schnorr signature of a hash
+// by signing with the privkey of given pubkey
+func (w *Wallet) SchnorrSignature(
+	hash []byte, pub *btcec.PublicKey) ([]byte, error) {
+	mpaddr, err := w.managedPubKeyAddressFromPubkey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := mpaddr.PrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aux := make([]byte, 32)
+	if _, err = rand.Read(aux); err != nil {
+		return nil, err
+	}
+	return schnorr.SignBIP340(priv, hash, aux)
+}
+
+// MuSig2PartialSign returns MuSig2 partial signature of a session
+// by signing with a secret nonce and the privkey of given pubkey
+func (w *Wallet) MuSig2PartialSign(
+	secnonce []byte, session *musig2.Session, pub *btcec.PublicKey,
+) ([]byte, error) {
+	mpaddr, err := w.managedPubKeyAddressFromPubkey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := mpaddr.PrivKey()
+	if err != nil {
+		return nil, err
+	}
+	return musig2.PartialSign(secnonce, priv, session)
+}
+
 // WitnessSignTxByIdxs returns witnesses associated to txins at given indices
 func (w *Wallet) WitnessSignTxByIdxs(tx *wire.MsgTx, idxs []int) ([]wire.TxWitness, error) {
-	wits := []wire.TxWitness{}
+	utxos := []wallet.Utxo{}
 	for _, idx := range idxs {
-		txin := tx.TxIn[idx]
-
 		// txin -> utxo
-		utxo, err := w.UtxoByTxIn(txin)
+		utxo, err := w.UtxoByTxIn(tx.TxIn[idx])
 		if err != nil {
 			return nil, err
 		}
+		utxos = append(utxos, utxo)
+	}
+
+	return w.WitnessSignTxByUtxos(tx, idxs, utxos)
+}
+
+// WitnessSignTxByUtxos returns witnesses associated to txins at given indices
+// that spend given utxos. The utxos don't need to be confirmed.
+func (w *Wallet) WitnessSignTxByUtxos(
+	tx *wire.MsgTx, idxs []int, utxos []wallet.Utxo) ([]wire.TxWitness, error) {
+	if len(idxs) != len(utxos) {
+		msg := fmt.Sprintf(
+			"number of utxos doesn't match. idxs: %d, utxos: %d", len(idxs), len(utxos))
+		return nil, errors.New(msg)
+	}
+
+	inputs := []psbtInput{}
+	for i, idx := range idxs {
+		utxo := utxos[i]
 
 		// utxo -> managed address
 		maddr, err := w.managedAddressByUtxo(utxo)
 		if err != nil {
 			return nil, err
 		}
-
-		// retrieve pubkey and privkey
 		mpka := maddr.(waddrmgr.ManagedPubKeyAddress)
-		pub := mpka.PubKey()
-		priv, err := mpka.PrivKey()
-		if err != nil {
-			return nil, err
-		}
 
-		// calc witness signature
 		amt, err := btcutil.NewAmount(utxo.Amount)
 		if err != nil {
 			return nil, err
 		}
 
-		sc, err := script.P2WPKHpkScript(pub)
+		sc, err := script.P2WPKHpkScript(mpka.PubKey())
 		if err != nil {
 			return nil, err
 		}
+		inputs = append(inputs, psbtInput{idx: idx, amt: amt, sc: sc, mpaddr: mpka})
+	}
 
-		sign, err := script.WitnessSignature(tx, idx, int64(amt), sc, priv)
-		if err != nil {
-			return nil, err
-		}
+	signs, err := w.witnessSignatures(tx, inputs)
+	if err != nil {
+		return nil, err
+	}
 
-		// compose witness
-		wit := wire.TxWitness{sign, pub.SerializeCompressed()}
+	// compose witnesses
+	wits := []wire.TxWitness{}
+	for i, in := range inputs {
+		wit := wire.TxWitness{signs[i], in.mpaddr.PubKey().SerializeCompressed()}
 		wits = append(wits, wit)
 	}
 
 	return wits, nil
 }
 
+// witnessSignatures returns witness signatures of inputs, which are made
+// by the signer in a watch-only wallet
+func (w *Wallet) witnessSignatures(
+	tx *wire.MsgTx, inputs []psbtInput) ([][]byte, error) {
+	if w.watchOnly != nil {
+		return w.signByPSBT(tx, inputs)
+	}
+
+	signs := [][]byte{}
+	for _, in := range inputs {
+		priv, err := in.mpaddr.PrivKey()
+		if err != nil {
+			return nil, err
+		}
+		sign, err := script.WitnessSignature(tx, in.idx, int64(in.amt), in.sc, priv)
+		if err != nil {
+			return nil, err
+		}
+		signs = append(signs, sign)
+	}
+	return signs, nil
+}
+
 // managedAddressByUtxo finds managed address by utxo
 func (w *Wallet) managedAddressByUtxo(utxo wallet.Utxo) (maddr waddrmgr.ManagedAddress, err error) {
 	onEachAddr := func(_maddr waddrmgr.ManagedAddress) error {
@@ -116,7 +198,7 @@ func (w *Wallet) managedAddressByUtxo(utxo wallet.Utxo) (maddr waddrmgr.ManagedA
 	}
 	onView := func(tx walletdb.ReadTx) error {
 		return w.manager.ForEachActiveAccountAddress(
-			tx.ReadBucket(waddrmgrNamespaceKey), w.account, onEachAddr)
+			tx.ReadBucket(w.addrmgrNsKey), w.account, onEachAddr)
 	}
 	err = walletdb.View(w.db, onView)
 	if err != nil {
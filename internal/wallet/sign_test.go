@@ -3,10 +3,13 @@ package wallet
 import (
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
-	"github.com/p2pderivatives/dlc/internal/mocks/rpcmock"
 	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/musig2"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
 	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/stretchr/testify/assert"
 )
@@ -17,10 +20,6 @@ func TestWitnessSignature(t *testing.T) {
 	w, tearDownFunc := setupWallet(t)
 	defer tearDownFunc()
 
-	rpcc := &rpcmock.Client{}
-	rpcc = mockImportAddress(rpcc, nil)
-	w.rpc = rpcc
-
 	// pubkey and pk script
 	pub, _ := w.NewPubkey()
 	pkScript, _ := script.P2WPKHpkScript(pub)
@@ -49,3 +48,46 @@ func TestWitnessSignature(t *testing.T) {
 	err = test.ExecuteScript(pkScript, redeemTx, int64(amt))
 	assert.Nil(err)
 }
+
+func TestSchnorrSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	pub, _ := w.NewPubkey()
+	hash := chainhash.HashB([]byte("message"))
+
+	// should fail if it's not unlocked
+	_, err := w.SchnorrSignature(hash, pub)
+	assert.NotNil(err)
+
+	w.Unlock(testPrivPass)
+
+	sign, err := w.SchnorrSignature(hash, pub)
+	assert.Nil(err)
+	assert.True(schnorr.VerifyBIP340(schnorr.XOnly(pub), hash, sign))
+}
+
+func TestMuSig2PartialSign(t *testing.T) {
+	assert := assert.New(t)
+
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	w.Unlock(testPrivPass)
+
+	pub, _ := w.NewPubkey()
+	_, cpub := test.RandKeys()
+	ctx, _ := musig2.KeyAgg([]*btcec.PublicKey{pub, cpub})
+
+	secnonce, pubnonce, _ := musig2.NonceGen(pub)
+	_, cpubnonce, _ := musig2.NonceGen(cpub)
+	aggnonce, _ := musig2.NonceAgg([][]byte{pubnonce, cpubnonce})
+	session := &musig2.Session{
+		KeyAgg: ctx, AggNonce: aggnonce, Msg: chainhash.HashB([]byte("message"))}
+
+	psig, err := w.MuSig2PartialSign(secnonce, session, pub)
+	assert.Nil(err)
+	assert.True(musig2.PartialSigVerify(psig, pubnonce, pub, session))
+}
@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// SoftwareSigner is a signer of PSBTs holding a master key in memory.
+// It stands in for a hardware or offline signer of a watch-only wallet.
+type SoftwareSigner struct {
+	master      *hdkeychain.ExtendedKey
+	fingerprint uint32
+}
+
+// SoftwareSigner should satisfy Signer interface
+var _ wallet.Signer = (*SoftwareSigner)(nil)
+
+// NewSoftwareSigner returns a signer of the master key of a seed
+func NewSoftwareSigner(
+	seed []byte, params *chaincfg.Params) (*SoftwareSigner, error) {
+	master, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := master.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	fp := btcutil.Hash160(pub.SerializeCompressed())[:4]
+	return &SoftwareSigner{
+		master:      master,
+		fingerprint: binary.LittleEndian.Uint32(fp),
+	}, nil
+}
+
+// Fingerprint returns the fingerprint of the master key
+// in the byte order of PSBT
+func (s *SoftwareSigner) Fingerprint() uint32 {
+	return s.fingerprint
+}
+
+// AccountXpubs returns xpubs of the receive and fund accounts
+// to create a watch-only wallet
+func (s *SoftwareSigner) AccountXpubs() (
+	xpub, fundXpub *hdkeychain.ExtendedKey, err error) {
+	if xpub, err = s.accountXpub(accountNum); err != nil {
+		return nil, nil, err
+	}
+	if fundXpub, err = s.accountXpub(fundAccountNum); err != nil {
+		return nil, nil, err
+	}
+	return xpub, fundXpub, nil
+}
+
+func (s *SoftwareSigner) accountXpub(account uint32) (*hdkeychain.ExtendedKey, error) {
+	k, err := s.derive([]uint32{
+		waddrmgrKeyScope.Purpose + hdkeychain.HardenedKeyStart,
+		waddrmgrKeyScope.Coin + hdkeychain.HardenedKeyStart,
+		account + hdkeychain.HardenedKeyStart,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return k.Neuter()
+}
+
+func (s *SoftwareSigner) derive(path []uint32) (*hdkeychain.ExtendedKey, error) {
+	k := s.master
+	for _, i := range path {
+		var err error
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// SignPSBT signs inputs having BIP32 derivations from the master key
+func (s *SoftwareSigner) SignPSBT(p *psbt.Packet) (*psbt.Packet, error) {
+	for idx, pi := range p.Inputs {
+		for _, d := range pi.Bip32Derivation {
+			if d.MasterKeyFingerprint != s.fingerprint {
+				continue
+			}
+			k, err := s.derive(d.Bip32Path)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := k.ECPrivKey()
+			if err != nil {
+				return nil, err
+			}
+			// skip a derivation of another master key with the same fingerprint
+			if !bytes.Equal(priv.PubKey().SerializeCompressed(), d.PubKey) {
+				continue
+			}
+			if err = p.SignWitnessInput(idx, priv); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
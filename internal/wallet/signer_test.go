@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftwareSignerFingerprint(t *testing.T) {
+	assert := assert.New(t)
+
+	// BIP32 test vector 1 with fingerprint 3442193e
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	signer, err := NewSoftwareSigner(seed, testNetParams)
+	assert.NoError(err)
+	assert.Equal(uint32(0x3e194234), signer.Fingerprint())
+}
+
+func TestSoftwareSignerSignPSBT(t *testing.T) {
+	assert := assert.New(t)
+	watchOnly, signer, _, tearDownFunc := setupWatchOnlyWallet(t)
+	defer tearDownFunc()
+
+	pub, _ := watchOnly.NewPubkey()
+	mpaddr, _ := watchOnly.managedPubKeyAddressFromPubkey(pub)
+	pkScript, _ := script.P2WPKHpkScript(pub)
+
+	amt := btcutil.Amount(10000)
+	sourceTx := test.NewSourceTx()
+	sourceTx.AddTxOut(wire.NewTxOut(int64(amt), pkScript))
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+
+	p, err := watchOnly.newPSBT(redeemTx, []psbtInput{
+		{idx: 0, amt: amt, sc: pkScript, mpaddr: mpaddr}})
+	assert.NoError(err)
+
+	// derivations of another master key are skipped
+	other, _ := btcec.NewPrivateKey(btcec.S256())
+	p.Inputs[0].Bip32Derivation = append(
+		[]*psbt.Bip32Derivation{{
+			PubKey:               other.PubKey().SerializeCompressed(),
+			MasterKeyFingerprint: signer.Fingerprint() + 1,
+			Bip32Path:            []uint32{0},
+		}}, p.Inputs[0].Bip32Derivation...)
+
+	signed, err := signer.SignPSBT(p)
+	assert.NoError(err)
+	assert.Len(signed.Inputs[0].PartialSigs, 1)
+	sign, ok := signed.Inputs[0].PartialSigOf(pub.SerializeCompressed())
+	assert.True(ok)
+
+	redeemTx.TxIn[0].Witness = wire.TxWitness{sign, pub.SerializeCompressed()}
+	err = test.ExecuteScript(pkScript, redeemTx, int64(amt))
+	assert.NoError(err)
+}
@@ -0,0 +1,426 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// birthdayMargin is subtracted from the wallet birthday to find
+// the first block to scan since block timestamps aren't accurate
+const birthdayMargin = 2 * time.Hour
+
+// Sync scans blocks from the last synced block to the chain tip
+// and records transactions relevant to the wallet in the tx store.
+// Blocks before the wallet birthday are skipped.
+// Blocks disconnected by a chain reorganization are rolled back first.
+func (w *Wallet) Sync() error {
+	return w.sync(0)
+}
+
+// sync is an implementation of Sync. If gapLimit is positive, addresses up
+// to gapLimit beyond the last derived address of each branch are watched too,
+// and the ones paid in a block are stored before the block is connected.
+func (w *Wallet) sync(gapLimit uint32) error {
+	if w.rpc == nil {
+		return errors.New("rpc client isn't set")
+	}
+
+	tip, err := w.rpc.GetBlockCount()
+	if err != nil {
+		return err
+	}
+
+	err = w.rollbackReorgedBlocks(int32(tip))
+	if err != nil {
+		return err
+	}
+
+	synced := w.manager.SyncedTo()
+	if synced.Height >= int32(tip) {
+		return w.removeDroppedTxs()
+	}
+
+	// recovery scans all blocks since the seed may be older than the wallet
+	if gapLimit == 0 {
+		synced, err = w.skipToBirthday(synced, int32(tip))
+		if err != nil {
+			return err
+		}
+	}
+
+	watched, err := w.watchedScripts()
+	if err != nil {
+		return err
+	}
+	unspent, err := w.unspentOutPoints()
+	if err != nil {
+		return err
+	}
+	var horizon map[string]waddrmgr.DerivationPath
+	if gapLimit > 0 {
+		horizon, err = w.horizon(gapLimit, scriptKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	for height := synced.Height + 1; height <= int32(tip); height++ {
+		hash, err := w.rpc.GetBlockHash(int64(height))
+		if err != nil {
+			return err
+		}
+		block, err := w.rpc.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+
+		if gapLimit > 0 {
+			var extended bool
+			horizon, extended, err = w.extendToUsedAddresses(
+				block, gapLimit, horizon)
+			if err != nil {
+				return err
+			}
+			if extended {
+				if watched, err = w.watchedScripts(); err != nil {
+					return err
+				}
+			}
+		}
+
+		meta := &wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{Hash: *hash, Height: height},
+			Time:  block.Header.Timestamp,
+		}
+		err = w.connectBlock(block, meta, watched, unspent)
+		if err != nil {
+			return err
+		}
+	}
+	return w.removeDroppedTxs()
+}
+
+// skipToBirthday marks blocks before the wallet birthday as synced
+// without scanning them. The last block before the birthday is found
+// by binary search so that only a few blocks are fetched.
+func (w *Wallet) skipToBirthday(
+	synced waddrmgr.BlockStamp, tip int32) (waddrmgr.BlockStamp, error) {
+	birthday := w.manager.Birthday()
+	if birthday.IsZero() {
+		return synced, nil
+	}
+	threshold := birthday.Add(-birthdayMargin)
+
+	// first block at or after the threshold
+	lo, hi := synced.Height+1, tip+1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		_, block, err := w.blockAt(mid)
+		if err != nil {
+			return synced, err
+		}
+		if block.Header.Timestamp.Before(threshold) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == synced.Height+1 {
+		return synced, nil
+	}
+
+	hash, block, err := w.blockAt(lo - 1)
+	if err != nil {
+		return synced, err
+	}
+	bs := waddrmgr.BlockStamp{
+		Height:    lo - 1,
+		Hash:      *hash,
+		Timestamp: block.Header.Timestamp,
+	}
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(w.addrmgrNsKey)
+		return w.manager.SetSyncedTo(ns, &bs)
+	})
+	if err != nil {
+		return synced, err
+	}
+	return bs, nil
+}
+
+// blockAt fetches a block at a given height
+func (w *Wallet) blockAt(height int32) (*chainhash.Hash, *wire.MsgBlock, error) {
+	hash, err := w.rpc.GetBlockHash(int64(height))
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := w.rpc.GetBlock(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hash, block, nil
+}
+
+// connectBlock records relevant transactions of a block and
+// marks the block as synced in a single db transaction
+func (w *Wallet) connectBlock(
+	block *wire.MsgBlock, meta *wtxmgr.BlockMeta,
+	watched map[string]bool, unspent map[wire.OutPoint]bool,
+) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		txmgrNs := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		for _, msgTx := range block.Transactions {
+			e := w.addRelevantTx(txmgrNs, msgTx, meta, watched, unspent)
+			if e != nil {
+				return e
+			}
+		}
+
+		addrmgrNs := tx.ReadWriteBucket(w.addrmgrNsKey)
+		return w.manager.SetSyncedTo(addrmgrNs, &waddrmgr.BlockStamp{
+			Height:    meta.Height,
+			Hash:      meta.Hash,
+			Timestamp: meta.Time,
+		})
+	})
+}
+
+// addUnminedTx records a tx broadcast by the wallet as unmined
+// so that the spent utxos aren't selected and the change is counted
+// as unconfirmed until the tx is connected by Sync
+func (w *Wallet) addUnminedTx(msgTx *wire.MsgTx) error {
+	watched, err := w.watchedScripts()
+	if err != nil {
+		return err
+	}
+	unspent, err := w.unspentOutPoints()
+	if err != nil {
+		return err
+	}
+
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.addRelevantTx(ns, msgTx, nil, watched, unspent)
+	})
+}
+
+// removeDroppedTxs removes unmined txs that are no longer in the mempool,
+// which are dropped or evicted, so that their inputs are spendable again.
+// A tx mined after the synced block is connected again by the next sync.
+func (w *Wallet) removeDroppedTxs() error {
+	var unmined []*wire.MsgTx
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		var e error
+		unmined, e = w.txStore.UnminedTxs(ns)
+		return e
+	})
+	if err != nil || len(unmined) == 0 {
+		return err
+	}
+
+	hashes, err := w.rpc.GetRawMempool()
+	if err != nil {
+		return err
+	}
+	mempool := make(map[chainhash.Hash]bool)
+	for _, hash := range hashes {
+		mempool[*hash] = true
+	}
+
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		for _, msgTx := range unmined {
+			if mempool[msgTx.TxHash()] {
+				continue
+			}
+			rec, e := wtxmgr.NewTxRecordFromMsgTx(msgTx, time.Now())
+			if e != nil {
+				return e
+			}
+			// descendants spending the tx are removed as well
+			e = w.txStore.RemoveUnminedTx(ns, rec)
+			if e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+// addRelevantTx inserts a tx to the tx store if it spends or
+// pays to the wallet, or it's already recorded as unmined.
+// The unspent outpoints are updated accordingly.
+// Meta is nil for an unmined tx.
+func (w *Wallet) addRelevantTx(
+	ns walletdb.ReadWriteBucket,
+	msgTx *wire.MsgTx, meta *wtxmgr.BlockMeta,
+	watched map[string]bool, unspent map[wire.OutPoint]bool,
+) error {
+	txHash := msgTx.TxHash()
+	recorded, err := w.txStore.TxDetails(ns, &txHash)
+	if err != nil {
+		return err
+	}
+
+	relevant := recorded != nil
+	for _, txin := range msgTx.TxIn {
+		if unspent[txin.PreviousOutPoint] {
+			delete(unspent, txin.PreviousOutPoint)
+			relevant = true
+		}
+	}
+
+	credits := []uint32{}
+	for idx, txout := range msgTx.TxOut {
+		if _, ok := watched[string(txout.PkScript)]; ok {
+			credits = append(credits, uint32(idx))
+		}
+	}
+
+	if !relevant && len(credits) == 0 {
+		return nil
+	}
+
+	received := time.Now()
+	if meta != nil {
+		received = meta.Time
+	}
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(msgTx, received)
+	if err != nil {
+		return err
+	}
+	err = w.txStore.InsertTx(ns, rec, meta)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range credits {
+		change := watched[string(msgTx.TxOut[idx].PkScript)]
+		err = w.txStore.AddCredit(ns, rec, meta, idx, change)
+		if err != nil {
+			return err
+		}
+		unspent[wire.OutPoint{Hash: rec.Hash, Index: idx}] = true
+	}
+	return nil
+}
+
+// rollbackReorgedBlocks rolls back synced blocks
+// that are no longer in the main chain
+func (w *Wallet) rollbackReorgedBlocks(tip int32) error {
+	for {
+		synced := w.manager.SyncedTo()
+		if synced.Height == 0 {
+			return nil
+		}
+
+		if synced.Height <= tip {
+			hash, err := w.rpc.GetBlockHash(int64(synced.Height))
+			if err != nil {
+				return err
+			}
+			if *hash == synced.Hash {
+				return nil
+			}
+		}
+
+		err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+			addrmgrNs := tx.ReadWriteBucket(w.addrmgrNsKey)
+			prev, e := w.manager.BlockHash(addrmgrNs, synced.Height-1)
+			if e != nil {
+				return fmt.Errorf(
+					"failed to roll back block %d. %v", synced.Height, e)
+			}
+
+			txmgrNs := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+			e = w.txStore.Rollback(txmgrNs, synced.Height)
+			if e != nil {
+				return e
+			}
+
+			return w.manager.SetSyncedTo(addrmgrNs, &waddrmgr.BlockStamp{
+				Height: synced.Height - 1,
+				Hash:   *prev,
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watchedScripts returns pkScripts of the wallet addresses.
+// The value is true if the address is for change.
+func (w *Wallet) watchedScripts() (map[string]bool, error) {
+	scripts := make(map[string]bool)
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(w.addrmgrNsKey)
+		return w.manager.ForEachActiveAccountAddress(ns, w.account,
+			func(maddr waddrmgr.ManagedAddress) error {
+				sc, e := txscript.PayToAddrScript(maddr.Address())
+				if e != nil {
+					return e
+				}
+				scripts[string(sc)] = maddr.Internal()
+				return nil
+			})
+	})
+	return scripts, err
+}
+
+// unspentOutPoints returns outpoints of unspent credits in the tx store
+func (w *Wallet) unspentOutPoints() (map[wire.OutPoint]bool, error) {
+	credits, err := w.unspentCredits()
+	if err != nil {
+		return nil, err
+	}
+	ops := make(map[wire.OutPoint]bool)
+	for _, c := range credits {
+		ops[c.OutPoint] = true
+	}
+	return ops, nil
+}
+
+// unspentCredits returns unspent credits in the tx store
+func (w *Wallet) unspentCredits() (credits []wtxmgr.Credit, err error) {
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		var e error
+		credits, e = w.txStore.UnspentOutputs(ns)
+		return e
+	})
+	return credits, err
+}
+
+// openTxStore opens the tx store.
+// The namespace is created for wallets created without it.
+func openTxStore(db walletdb.DB, params *chaincfg.Params) (*wtxmgr.Store, error) {
+	var txStore *wtxmgr.Store
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) (e error) {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if ns == nil {
+			ns, e = tx.CreateTopLevelBucket(wtxmgrNamespaceKey)
+			if e != nil {
+				return e
+			}
+			e = wtxmgr.Create(ns)
+			if e != nil {
+				return e
+			}
+		}
+
+		txStore, e = wtxmgr.Open(ns, params)
+		return e
+	})
+	return txStore, err
+}
@@ -0,0 +1,265 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/rpcmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testChain is a fake chain served by a mocked rpc client
+type testChain struct {
+	blocks  []*wire.MsgBlock // blocks[0] is at height 1
+	nonce   uint32           // makes reorganized blocks differ
+	mempool []*wire.MsgTx
+}
+
+func (c *testChain) addBlock(txs ...*wire.MsgTx) *wire.MsgBlock {
+	prev := chainhash.Hash{}
+	if n := len(c.blocks); n > 0 {
+		prev = c.blocks[n-1].BlockHash()
+	}
+	header := wire.NewBlockHeader(1, &prev, &chainhash.Hash{}, 0, 0)
+	header.Timestamp = time.Unix(time.Now().Unix(), 0)
+	header.Nonce = c.nonce
+	c.nonce++
+	block := wire.NewMsgBlock(header)
+	for _, tx := range txs {
+		_ = block.AddTransaction(tx)
+		c.drop(tx)
+	}
+	c.blocks = append(c.blocks, block)
+	return block
+}
+
+// drop removes a tx from the mempool
+func (c *testChain) drop(tx *wire.MsgTx) {
+	for i, mtx := range c.mempool {
+		if mtx.TxHash() == tx.TxHash() {
+			c.mempool = append(c.mempool[:i], c.mempool[i+1:]...)
+			return
+		}
+	}
+}
+
+// disconnect removes blocks above a given height
+func (c *testChain) disconnect(height int) {
+	c.blocks = c.blocks[:height]
+}
+
+func (c *testChain) rpcClient() *rpcmock.Client {
+	rpcc := &rpcmock.Client{}
+	rpcc.On("GetBlockCount").Return(func() int64 {
+		return int64(len(c.blocks))
+	}, nil)
+	rpcc.On("GetBlockHash", mock.Anything).Return(
+		func(h int64) *chainhash.Hash {
+			hash := c.blocks[h-1].BlockHash()
+			return &hash
+		}, nil)
+	rpcc.On("GetBlock", mock.Anything).Return(
+		func(hash *chainhash.Hash) *wire.MsgBlock {
+			for _, b := range c.blocks {
+				if b.BlockHash() == *hash {
+					return b
+				}
+			}
+			return nil
+		}, nil)
+	rpcc.On("GetRawMempool").Return(func() []*chainhash.Hash {
+		hashes := []*chainhash.Hash{}
+		for _, tx := range c.mempool {
+			hash := tx.TxHash()
+			hashes = append(hashes, &hash)
+		}
+		return hashes
+	}, nil)
+	rpcc.On("SendRawTransaction", mock.Anything, false).Return(
+		func(tx *wire.MsgTx, _ bool) *chainhash.Hash {
+			c.mempool = append(c.mempool, tx)
+			hash := tx.TxHash()
+			return &hash
+		}, nil)
+	return rpcc
+}
+
+func newTestTx(prevs []wire.OutPoint, pkScript []byte, amt btcutil.Amount) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	for i := range prevs {
+		tx.AddTxIn(wire.NewTxIn(&prevs[i], nil, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(amt), pkScript))
+	return tx
+}
+
+func TestSync(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	chain := &testChain{}
+	w.SetRPCClient(chain.rpcClient())
+
+	addr, err := w.NewAddress()
+	assert.NoError(err)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	otherScript := []byte{txscript.OP_TRUE}
+
+	// irrelevant tx
+	chain.addBlock(newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{1}}}, otherScript, 1000))
+	utxos, err := w.ListUnspent()
+	assert.NoError(err)
+	assert.Empty(utxos)
+
+	// receive
+	fundTx := newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{2}}}, pkScript, 5000)
+	chain.addBlock(fundTx)
+	chain.addBlock()
+	utxos, err = w.ListUnspent()
+	assert.NoError(err)
+	if !assert.Len(utxos, 1) {
+		assert.FailNow("utxo isn't found")
+	}
+	assert.Equal(fundTx.TxHash().String(), utxos[0].TxID)
+	assert.Equal(addr.EncodeAddress(), utxos[0].Address)
+	assert.Equal(btcutil.Amount(5000).ToBTC(), utxos[0].Amount)
+	assert.Equal(int64(2), utxos[0].Confirmations)
+	assert.Equal(int32(3), w.manager.SyncedTo().Height)
+
+	// spend
+	op := wire.OutPoint{Hash: fundTx.TxHash(), Index: 0}
+	chain.addBlock(newTestTx([]wire.OutPoint{op}, otherScript, 4000))
+	utxos, err = w.ListUnspent()
+	assert.NoError(err)
+	assert.Empty(utxos)
+
+	// the spending block is reorganized out
+	chain.disconnect(3)
+	chain.addBlock()
+	chain.addBlock()
+	utxos, err = w.ListUnspent()
+	assert.NoError(err)
+	assert.Len(utxos, 1)
+	assert.Equal(int32(5), w.manager.SyncedTo().Height)
+
+	// the funding block is reorganized out
+	chain.disconnect(1)
+	utxos, err = w.ListUnspent()
+	assert.NoError(err)
+	assert.Empty(utxos)
+	assert.Equal(int32(1), w.manager.SyncedTo().Height)
+}
+
+// unmined txs that left the mempool shouldn't keep their inputs spent
+func TestSyncRemovesDroppedTxs(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	chain := &testChain{}
+	w.SetRPCClient(chain.rpcClient())
+
+	addr, err := w.NewAddress()
+	assert.NoError(err)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	otherScript := []byte{txscript.OP_TRUE}
+
+	fundTx := newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{1}}}, pkScript, 5000)
+	chain.addBlock(fundTx)
+
+	// a sent tx and its child are unmined while they're in the mempool
+	op := wire.OutPoint{Hash: fundTx.TxHash(), Index: 0}
+	sent := newTestTx([]wire.OutPoint{op}, pkScript, 4000)
+	child := newTestTx(
+		[]wire.OutPoint{{Hash: sent.TxHash(), Index: 0}}, otherScript, 3000)
+	for _, tx := range []*wire.MsgTx{sent, child} {
+		chain.mempool = append(chain.mempool, tx)
+		assert.NoError(w.addUnminedTx(tx))
+	}
+	chain.addBlock()
+	utxos, err := w.ListUnspent()
+	assert.NoError(err)
+	assert.Empty(utxos)
+
+	// evicted from the mempool
+	chain.drop(sent)
+	chain.drop(child)
+	utxos, err = w.ListUnspent()
+	assert.NoError(err)
+	if assert.Len(utxos, 1) {
+		assert.Equal(fundTx.TxHash().String(), utxos[0].TxID)
+	}
+	txs, err := w.Transactions()
+	assert.NoError(err)
+	assert.Len(txs, 1)
+}
+
+// blocks before the wallet birthday shouldn't be scanned
+func TestSyncFromBirthday(t *testing.T) {
+	assert := assert.New(t)
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	chain := &testChain{}
+	rpcc := chain.rpcClient()
+	w.SetRPCClient(rpcc)
+
+	addr, err := w.NewAddress()
+	assert.NoError(err)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+
+	// blocks mined long before the birthday
+	old := w.manager.Birthday().Add(-24 * time.Hour)
+	n := 1000
+	for i := 0; i < n; i++ {
+		var txs []*wire.MsgTx
+		if i == n/2 {
+			prev := wire.OutPoint{Hash: chainhash.Hash{1}}
+			txs = append(txs, newTestTx([]wire.OutPoint{prev}, pkScript, 1000))
+		}
+		block := chain.addBlock(txs...)
+		block.Header.Timestamp = old
+	}
+	fundTx := newTestTx(
+		[]wire.OutPoint{{Hash: chainhash.Hash{2}}}, pkScript, 5000)
+	chain.addBlock(fundTx)
+
+	utxos, err := w.ListUnspent()
+	assert.NoError(err)
+	if assert.Len(utxos, 1) {
+		assert.Equal(fundTx.TxHash().String(), utxos[0].TxID)
+	}
+	assert.Equal(int32(n+1), w.manager.SyncedTo().Height)
+
+	// only a few blocks are fetched to find the birthday block
+	calls := 0
+	for _, call := range rpcc.Calls {
+		if call.Method == "GetBlock" {
+			calls++
+		}
+	}
+	assert.True(calls < 20, calls)
+
+	// recovery scans from genesis
+	assert.NoError(w.Recover(10))
+	utxos, err = w.ListUnspent()
+	assert.NoError(err)
+	assert.Len(utxos, 2)
+}
+
+func TestSyncWithoutRPC(t *testing.T) {
+	w, tearDownFunc := setupWallet(t)
+	defer tearDownFunc()
+
+	err := w.Sync()
+	assert.Error(t, err)
+}
@@ -1,78 +1,103 @@
 package wallet
 
 import (
+	"encoding/hex"
 	"errors"
-	"fmt"
 
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
-	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 )
 
-const (
-	unspentMinConf = 1
-	unspentMaxConf = 9999999
-)
+const unspentMinConf = 1
 
-// ListUnspent returns unspent transactions.
-// It asks the running bitcoind instance to return all known utxos for addresses managed by the wallet
+// ListUnspent returns unspent transaction outputs tracked by the wallet.
+// It syncs the wallet with the chain first if rpc client is set.
+// Immature coinbase outputs aren't included.
 func (w *Wallet) ListUnspent() (utxos []wallet.Utxo, err error) {
-	var addrs []btcutil.Address
-	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
-		ns := tx.ReadBucket(waddrmgrNamespaceKey)
-		appendAddr := func(addr btcutil.Address) error {
-			addrs = append(addrs, addr)
-			return nil
+	if w.rpc != nil {
+		if err = w.Sync(); err != nil {
+			return nil, err
 		}
-		return w.manager.ForEachActiveAddress(ns, appendAddr)
-	})
+	}
+
+	credits, err := w.unspentCredits()
 	if err != nil {
-		return utxos, err
+		return nil, err
 	}
 
-	if addrs == nil {
-		return utxos, err
+	syncHeight := w.manager.SyncedTo().Height
+	utxos = []wallet.Utxo{}
+	for _, c := range credits {
+		confs, ok := w.spendableConfirmations(&c, syncHeight)
+		if !ok {
+			continue
+		}
+
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(c.PkScript, w.params)
+		if err != nil {
+			return nil, err
+		}
+		addr := ""
+		if len(addrs) > 0 {
+			addr = addrs[0].EncodeAddress()
+		}
+
+		utxos = append(utxos, wallet.Utxo{
+			TxID:          c.Hash.String(),
+			Vout:          c.Index,
+			Address:       addr,
+			ScriptPubKey:  hex.EncodeToString(c.PkScript),
+			Amount:        c.Amount.ToBTC(),
+			Confirmations: int64(confs),
+			Spendable:     true,
+		})
 	}
+	return utxos, nil
+}
 
-	return w.rpc.ListUnspentMinMaxAddresses(
-		unspentMinConf, unspentMaxConf, addrs)
+// spendableConfirmations returns confirmations of a credit and whether
+// it's spendable. Unmined credits, credits with confirmations less than
+// unspentMinConf and immature coinbase outputs aren't spendable.
+func (w *Wallet) spendableConfirmations(
+	c *wtxmgr.Credit, syncHeight int32) (int32, bool) {
+	if c.Height < 0 {
+		return 0, false
+	}
+	confs := syncHeight - c.Height + 1
+	if confs < unspentMinConf {
+		return confs, false
+	}
+	if c.FromCoinBase && confs < int32(w.params.CoinbaseMaturity) {
+		return confs, false
+	}
+	return confs, true
 }
 
-// SelectUnspent is an implementation of Wallet.SelectUnspent
+// SelectUnspent is an implementation of Wallet.SelectUnspent.
+// Utxos are selected in the order of ListUnspent.
 func (w *Wallet) SelectUnspent(
 	amt, feePerTxIn, feePerTxOut btcutil.Amount,
 ) (utxos []wallet.Utxo, change btcutil.Amount, err error) {
-	var utxosAll []wallet.Utxo
-	utxosAll, err = w.ListUnspent()
+	return w.SelectUnspentWith(wallet.FirstFit{}, amt, feePerTxIn, feePerTxOut)
+}
+
+// SelectUnspentWith is an implementation of Wallet.SelectUnspentWith.
+// Locked utxos aren't selected.
+func (w *Wallet) SelectUnspentWith(
+	s wallet.CoinSelector, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) (utxos []wallet.Utxo, change btcutil.Amount, err error) {
+	utxosAll, err := w.ListUnspent()
 	if err != nil {
-		return
+		return nil, 0, err
 	}
-
-	var total btcutil.Amount
-	var fee btcutil.Amount
-	var utxoAmt btcutil.Amount
-	for _, utxo := range utxosAll {
-		utxoAmt, err = btcutil.NewAmount(utxo.Amount)
-		if err != nil {
-			return
-		}
-		total += utxoAmt
-		fee += feePerTxIn
-		utxos = append(utxos, utxo)
-		if amt+fee == total {
-			return
-		} else if amt+fee < total {
-			change = total - (amt + fee)
-			fee += feePerTxOut
-			if amt+fee <= total {
-				return
-			}
-		}
+	unlocked, err := w.unlockedUtxos(utxosAll)
+	if err != nil {
+		return nil, 0, err
 	}
-
-	err = fmt.Errorf("Not enough utxos")
-	return utxos, change, err
+	return s.Select(unlocked, amt, feePerTxIn, feePerTxOut)
 }
 
 // UtxoByTxIn finds utxo by txin
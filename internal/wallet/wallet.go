@@ -12,6 +12,7 @@ import (
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
 	_ "github.com/btcsuite/btcwallet/walletdb/bdb" // blank import for bolt db driver
+	"github.com/btcsuite/btcwallet/wtxmgr"
 	"github.com/p2pderivatives/dlc/internal/rpc"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 )
@@ -20,9 +21,16 @@ import (
 var (
 	waddrmgrNamespaceKey = []byte("waddrmgr")
 	waddrmgrKeyScope     = waddrmgr.KeyScopeBIP0084
+	xpubmgrNamespaceKey  = []byte("xpubmgr")
+	wtxmgrNamespaceKey   = []byte("wtxmgr")
 )
 
-const accountName = "dlc"
+// Account names. Fund keys of contracts are derived on their own account
+// apart from receive and change addresses.
+const (
+	accountName     = "dlc"
+	fundAccountName = "dlcfund"
+)
 
 // Wallet is hierarchical deterministic Wallet
 type Wallet struct {
@@ -30,7 +38,11 @@ type Wallet struct {
 	publicPassphrase []byte
 	rpc              rpc.Client
 	db               walletdb.DB
-	manager          *waddrmgr.Manager
+	manager          keyStore
+	addrmgrNsKey     []byte
+	watchOnly        *xpubManager
+	signer           wallet.Signer
+	txStore          *wtxmgr.Store
 	account          uint32
 }
 
@@ -108,7 +120,15 @@ func createManagers(
 			addrmgrNs, seed, pubPass, privPass, params, nil,
 			birthday,
 		)
-		return e
+		if e != nil {
+			return e
+		}
+
+		txmgrNs, e := tx.CreateTopLevelBucket(wtxmgrNamespaceKey)
+		if e != nil {
+			return e
+		}
+		return wtxmgr.Create(txmgrNs)
 	})
 }
 
@@ -132,7 +152,10 @@ func createAccount(
 			return e
 		}
 
-		_, e = scopedMgr.NewAccount(ns, accountName)
+		if _, e = scopedMgr.NewAccount(ns, accountName); e != nil {
+			return e
+		}
+		_, e = scopedMgr.NewAccount(ns, fundAccountName)
 		return e
 	})
 }
@@ -152,37 +175,52 @@ func open(
 
 	// Open database abstraction instances
 	var (
-		addrMgr *waddrmgr.Manager
-		account uint32
+		manager      keyStore
+		watchOnly    *xpubManager
+		addrmgrNsKey []byte
+		account      uint32
 	)
 	err := walletdb.View(db, func(tx walletdb.ReadTx) error {
-		addrmgrNs := tx.ReadBucket(waddrmgrNamespaceKey)
-		if addrmgrNs == nil {
-			return errors.New("missing address manager namespace")
-		}
-
 		var e error
-		addrMgr, e = waddrmgr.Open(addrmgrNs, pubPass, params)
-		if e != nil {
-			return e
+		if ns := tx.ReadBucket(xpubmgrNamespaceKey); ns != nil {
+			addrmgrNsKey = xpubmgrNamespaceKey
+			watchOnly, e = openXpubManager(ns, params)
+			manager = watchOnly
+		} else if ns := tx.ReadBucket(waddrmgrNamespaceKey); ns != nil {
+			addrmgrNsKey = waddrmgrNamespaceKey
+			var addrMgr *waddrmgr.Manager
+			addrMgr, e = waddrmgr.Open(ns, pubPass, params)
+			if e != nil {
+				return e
+			}
+			manager, e = newScopedManager(addrMgr)
+		} else {
+			return errors.New("missing address manager namespace")
 		}
-		scopedMgr, e := addrMgr.FetchScopedKeyManager(waddrmgrKeyScope)
 		if e != nil {
 			return e
 		}
-		account, e = scopedMgr.LookupAccount(addrmgrNs, accountName)
+		account, e = manager.LookupAccount(tx.ReadBucket(addrmgrNsKey), accountName)
 		return e
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	txStore, err := openTxStore(db, params)
+	if err != nil {
+		return nil, err
+	}
+
 	w := &Wallet{
 		params:           params,
 		publicPassphrase: pubPass,
 		rpc:              rpcclient,
 		db:               db,
-		manager:          addrMgr,
+		manager:          manager,
+		addrmgrNsKey:     addrmgrNsKey,
+		watchOnly:        watchOnly,
+		txStore:          txStore,
 		account:          account,
 	}
 
@@ -194,6 +232,17 @@ func (w *Wallet) SetRPCClient(rpc rpc.Client) {
 	w.rpc = rpc
 }
 
+// SetSigner sets a signer of PSBTs, which a watch-only wallet asks
+// to sign since it has no privkeys
+func (w *Wallet) SetSigner(signer wallet.Signer) {
+	w.signer = signer
+}
+
+// WatchOnly returns true if the wallet is created by CreateWatchOnlyWallet
+func (w *Wallet) WatchOnly() bool {
+	return w.watchOnly != nil
+}
+
 // SendRawTransaction delegates to RPC client
 func (w *Wallet) SendRawTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
 	return w.rpc.SendRawTransaction(tx, false)
@@ -202,7 +251,7 @@ func (w *Wallet) SendRawTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
 // Unlock unlocks address manager with a given private pass phrase
 func (w *Wallet) Unlock(privPass []byte) error {
 	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
-		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		ns := tx.ReadWriteBucket(w.addrmgrNsKey)
 		return w.manager.Unlock(ns, privPass)
 	})
 }
@@ -0,0 +1,468 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// Account numbers of the accounts created by this package.
+// waddrmgr has the default account 0, so the accounts follow it.
+const (
+	accountNum     = uint32(1)
+	fundAccountNum = uint32(2)
+)
+
+// buckets of the address manager namespace of a watch-only wallet
+var (
+	xpubBucketMeta    = []byte("meta")
+	xpubBucketAccts   = []byte("accts")
+	xpubBucketXpubs   = []byte("xpubs")
+	xpubBucketCounter = []byte("counter")
+	xpubBucketAddrs   = []byte("addrs")
+	xpubBucketHashes  = []byte("hashes")
+
+	xpubKeyFingerprint = []byte("fingerprint")
+	xpubKeySynced      = []byte("synced")
+)
+
+// CreateWatchOnlyWallet returns a new wallet holding only extended pubkeys
+// of the receive and fund accounts (m/84'/0'/1' and m/84'/0'/2'), and
+// creates db where wallet resides. Signing is delegated to a signer set by
+// SetSigner, which is given PSBTs with BIP32 derivations from the master key
+// of a given fingerprint.
+func CreateWatchOnlyWallet(
+	params *chaincfg.Params, fingerprint uint32,
+	xpub, fundXpub *hdkeychain.ExtendedKey,
+	walletDir, walletName string,
+) (wallet.Wallet, error) {
+	db, err := createDB(walletDir, walletName+".db")
+	if err != nil {
+		return nil, err
+	}
+
+	return createWatchOnly(db, params, fingerprint, xpub, fundXpub)
+}
+
+func createWatchOnly(
+	db walletdb.DB, params *chaincfg.Params, fingerprint uint32,
+	xpub, fundXpub *hdkeychain.ExtendedKey,
+) (*Wallet, error) {
+	for _, k := range []*hdkeychain.ExtendedKey{xpub, fundXpub} {
+		if k.IsPrivate() {
+			return nil, errors.New("extended key must be public")
+		}
+		if !k.IsForNet(params) {
+			return nil, errors.New("extended key is for another network")
+		}
+	}
+
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns, e := tx.CreateTopLevelBucket(xpubmgrNamespaceKey)
+		if e != nil {
+			return e
+		}
+		for _, b := range [][]byte{
+			xpubBucketMeta, xpubBucketAccts, xpubBucketXpubs,
+			xpubBucketCounter, xpubBucketAddrs, xpubBucketHashes,
+		} {
+			if _, e = ns.CreateBucket(b); e != nil {
+				return e
+			}
+		}
+
+		meta := ns.NestedReadWriteBucket(xpubBucketMeta)
+		if e = meta.Put(xpubKeyFingerprint, uint32Bytes(fingerprint)); e != nil {
+			return e
+		}
+		e = putBlockStamp(meta, &waddrmgr.BlockStamp{
+			Hash:      *params.GenesisHash,
+			Timestamp: params.GenesisBlock.Header.Timestamp,
+		})
+		if e != nil {
+			return e
+		}
+
+		accts := ns.NestedReadWriteBucket(xpubBucketAccts)
+		xpubs := ns.NestedReadWriteBucket(xpubBucketXpubs)
+		for name, acct := range map[string]struct {
+			num  uint32
+			xpub *hdkeychain.ExtendedKey
+		}{
+			accountName:     {accountNum, xpub},
+			fundAccountName: {fundAccountNum, fundXpub},
+		} {
+			if e = accts.Put([]byte(name), uint32Bytes(acct.num)); e != nil {
+				return e
+			}
+			e = xpubs.Put(uint32Bytes(acct.num), []byte(acct.xpub.String()))
+			if e != nil {
+				return e
+			}
+		}
+
+		txmgrNs, e := tx.CreateTopLevelBucket(wtxmgrNamespaceKey)
+		if e != nil {
+			return e
+		}
+		return wtxmgr.Create(txmgrNs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return open(db, nil, params, nil)
+}
+
+// xpubManager is a keyStore deriving addresses from account xpubs
+type xpubManager struct {
+	params      *chaincfg.Params
+	fingerprint uint32
+	xpubs       map[uint32]*hdkeychain.ExtendedKey
+	synced      waddrmgr.BlockStamp
+}
+
+// openXpubManager loads account xpubs and the synced block
+func openXpubManager(
+	ns walletdb.ReadBucket, params *chaincfg.Params) (*xpubManager, error) {
+	meta := ns.NestedReadBucket(xpubBucketMeta)
+	fp := meta.Get(xpubKeyFingerprint)
+	if len(fp) != 4 {
+		return nil, errors.New("missing master key fingerprint")
+	}
+	m := &xpubManager{
+		params:      params,
+		fingerprint: binary.BigEndian.Uint32(fp),
+		xpubs:       make(map[uint32]*hdkeychain.ExtendedKey),
+	}
+
+	err := ns.NestedReadBucket(xpubBucketXpubs).ForEach(func(k, v []byte) error {
+		xpub, e := hdkeychain.NewKeyFromString(string(v))
+		if e != nil {
+			return e
+		}
+		m.xpubs[binary.BigEndian.Uint32(k)] = xpub
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := readBlockStamp(meta)
+	if err != nil {
+		return nil, err
+	}
+	m.synced = *bs
+	return m, nil
+}
+
+// SyncedTo returns the synced block
+func (m *xpubManager) SyncedTo() waddrmgr.BlockStamp {
+	return m.synced
+}
+
+// SetSyncedTo records the synced block
+func (m *xpubManager) SetSyncedTo(
+	ns walletdb.ReadWriteBucket, bs *waddrmgr.BlockStamp) error {
+	hashes := ns.NestedReadWriteBucket(xpubBucketHashes)
+	if err := hashes.Put(uint32Bytes(uint32(bs.Height)), bs.Hash[:]); err != nil {
+		return err
+	}
+	err := putBlockStamp(ns.NestedReadWriteBucket(xpubBucketMeta), bs)
+	if err != nil {
+		return err
+	}
+	m.synced = *bs
+	return nil
+}
+
+// Birthday returns zero time since account xpubs may have been used
+// before the watch-only wallet is created
+func (m *xpubManager) Birthday() time.Time {
+	return time.Time{}
+}
+
+// BlockHash returns the hash of a synced block
+func (m *xpubManager) BlockHash(
+	ns walletdb.ReadBucket, height int32) (*chainhash.Hash, error) {
+	if height == 0 {
+		return m.params.GenesisHash, nil
+	}
+	v := ns.NestedReadBucket(xpubBucketHashes).Get(uint32Bytes(uint32(height)))
+	if v == nil {
+		return nil, fmt.Errorf("block hash of height %d isn't found", height)
+	}
+	return chainhash.NewHash(v)
+}
+
+// LookupAccount returns the number of a named account
+func (m *xpubManager) LookupAccount(
+	ns walletdb.ReadBucket, name string) (uint32, error) {
+	v := ns.NestedReadBucket(xpubBucketAccts).Get([]byte(name))
+	if v == nil {
+		return 0, waddrmgr.ManagerError{
+			ErrorCode:   waddrmgr.ErrAccountNotFound,
+			Description: fmt.Sprintf("account %s isn't found", name),
+		}
+	}
+	return binary.BigEndian.Uint32(v), nil
+}
+
+// NewAccount fails since accounts can't be derived from account xpubs
+func (m *xpubManager) NewAccount(
+	ns walletdb.ReadWriteBucket, name string) (uint32, error) {
+	return 0, errWatchingOnly()
+}
+
+// AccountProperties returns the numbers of derived addresses of an account
+func (m *xpubManager) AccountProperties(
+	ns walletdb.ReadBucket, account uint32) (*waddrmgr.AccountProperties, error) {
+	if _, ok := m.xpubs[account]; !ok {
+		return nil, fmt.Errorf("account %d isn't found", account)
+	}
+	return &waddrmgr.AccountProperties{
+		AccountNumber:    account,
+		ExternalKeyCount: keyCount(ns, account, waddrmgr.ExternalBranch),
+		InternalKeyCount: keyCount(ns, account, waddrmgr.InternalBranch),
+	}, nil
+}
+
+// Address returns a derived address
+func (m *xpubManager) Address(
+	ns walletdb.ReadBucket, addr btcutil.Address) (waddrmgr.ManagedAddress, error) {
+	v := ns.NestedReadBucket(xpubBucketAddrs).Get(addr.ScriptAddress())
+	if len(v) != 12 {
+		return nil, waddrmgr.ManagerError{
+			ErrorCode:   waddrmgr.ErrAddressNotFound,
+			Description: fmt.Sprintf("address %s isn't found", addr),
+		}
+	}
+	return m.DeriveFromKeyPath(ns, waddrmgr.DerivationPath{
+		Account: binary.BigEndian.Uint32(v[0:4]),
+		Branch:  binary.BigEndian.Uint32(v[4:8]),
+		Index:   binary.BigEndian.Uint32(v[8:12]),
+	})
+}
+
+// ForEachActiveAccountAddress calls a func with each derived address
+// of an account
+func (m *xpubManager) ForEachActiveAccountAddress(ns walletdb.ReadBucket,
+	account uint32, fn func(maddr waddrmgr.ManagedAddress) error) error {
+	for _, branch := range branches {
+		n := keyCount(ns, account, branch)
+		for idx := uint32(0); idx < n; idx++ {
+			maddr, err := m.DeriveFromKeyPath(ns, waddrmgr.DerivationPath{
+				Account: account, Branch: branch, Index: idx})
+			if err != nil {
+				return err
+			}
+			if err = fn(maddr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeriveFromKeyPath derives an address without storing it
+func (m *xpubManager) DeriveFromKeyPath(
+	ns walletdb.ReadBucket, path waddrmgr.DerivationPath) (waddrmgr.ManagedAddress, error) {
+	xpub, ok := m.xpubs[path.Account]
+	if !ok {
+		return nil, fmt.Errorf("account %d isn't found", path.Account)
+	}
+	k, err := xpub.Child(path.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if k, err = k.Child(path.Index); err != nil {
+		return nil, err
+	}
+	pub, err := k.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pub.SerializeCompressed()), m.params)
+	if err != nil {
+		return nil, err
+	}
+	return &xpubAddress{path: path, pub: pub, addr: addr}, nil
+}
+
+// NextExternalAddresses derives and stores addresses of the external branch
+func (m *xpubManager) NextExternalAddresses(
+	ns walletdb.ReadWriteBucket, account, num uint32) ([]waddrmgr.ManagedAddress, error) {
+	return m.nextAddresses(ns, account, waddrmgr.ExternalBranch, num)
+}
+
+// NextInternalAddresses derives and stores addresses of the internal branch
+func (m *xpubManager) NextInternalAddresses(
+	ns walletdb.ReadWriteBucket, account, num uint32) ([]waddrmgr.ManagedAddress, error) {
+	return m.nextAddresses(ns, account, waddrmgr.InternalBranch, num)
+}
+
+// ExtendExternalAddresses derives and stores addresses of the external
+// branch up to a given index
+func (m *xpubManager) ExtendExternalAddresses(
+	ns walletdb.ReadWriteBucket, account, lastIdx uint32) error {
+	return m.extendAddresses(ns, account, waddrmgr.ExternalBranch, lastIdx)
+}
+
+// ExtendInternalAddresses derives and stores addresses of the internal
+// branch up to a given index
+func (m *xpubManager) ExtendInternalAddresses(
+	ns walletdb.ReadWriteBucket, account, lastIdx uint32) error {
+	return m.extendAddresses(ns, account, waddrmgr.InternalBranch, lastIdx)
+}
+
+func (m *xpubManager) extendAddresses(
+	ns walletdb.ReadWriteBucket, account, branch, lastIdx uint32) error {
+	n := keyCount(ns, account, branch)
+	if lastIdx < n {
+		return nil
+	}
+	_, err := m.nextAddresses(ns, account, branch, lastIdx+1-n)
+	return err
+}
+
+func (m *xpubManager) nextAddresses(
+	ns walletdb.ReadWriteBucket, account, branch, num uint32,
+) ([]waddrmgr.ManagedAddress, error) {
+	n := keyCount(ns, account, branch)
+	addrs := ns.NestedReadWriteBucket(xpubBucketAddrs)
+	maddrs := []waddrmgr.ManagedAddress{}
+	for idx := n; idx < n+num; idx++ {
+		path := waddrmgr.DerivationPath{Account: account, Branch: branch, Index: idx}
+		maddr, err := m.DeriveFromKeyPath(ns, path)
+		if err != nil {
+			return nil, err
+		}
+		v := append(append(uint32Bytes(account), uint32Bytes(branch)...), uint32Bytes(idx)...)
+		if err = addrs.Put(maddr.AddrHash(), v); err != nil {
+			return nil, err
+		}
+		maddrs = append(maddrs, maddr)
+	}
+
+	counter := ns.NestedReadWriteBucket(xpubBucketCounter)
+	err := counter.Put(counterKey(account, branch), uint32Bytes(n+num))
+	return maddrs, err
+}
+
+// Unlock does nothing since the wallet has no private keys
+func (m *xpubManager) Unlock(ns walletdb.ReadBucket, privPass []byte) error {
+	return nil
+}
+
+// Close does nothing
+func (m *xpubManager) Close() {}
+
+// bip32Derivation returns the derivation of an address from the master key
+func (m *xpubManager) bip32Derivation(
+	maddr waddrmgr.ManagedPubKeyAddress) *psbt.Bip32Derivation {
+	_, path, _ := maddr.DerivationInfo()
+	return &psbt.Bip32Derivation{
+		PubKey:               maddr.PubKey().SerializeCompressed(),
+		MasterKeyFingerprint: m.fingerprint,
+		Bip32Path: []uint32{
+			waddrmgrKeyScope.Purpose + hdkeychain.HardenedKeyStart,
+			waddrmgrKeyScope.Coin + hdkeychain.HardenedKeyStart,
+			path.Account + hdkeychain.HardenedKeyStart,
+			path.Branch,
+			path.Index,
+		},
+	}
+}
+
+func keyCount(ns walletdb.ReadBucket, account, branch uint32) uint32 {
+	v := ns.NestedReadBucket(xpubBucketCounter).Get(counterKey(account, branch))
+	if len(v) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(v)
+}
+
+func counterKey(account, branch uint32) []byte {
+	return append(uint32Bytes(account), uint32Bytes(branch)...)
+}
+
+func uint32Bytes(n uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, n)
+	return b
+}
+
+// putBlockStamp serializes a block stamp as height, hash and timestamp
+func putBlockStamp(b walletdb.ReadWriteBucket, bs *waddrmgr.BlockStamp) error {
+	v := make([]byte, 4+chainhash.HashSize+8)
+	binary.BigEndian.PutUint32(v[0:4], uint32(bs.Height))
+	copy(v[4:4+chainhash.HashSize], bs.Hash[:])
+	binary.BigEndian.PutUint64(v[4+chainhash.HashSize:], uint64(bs.Timestamp.Unix()))
+	return b.Put(xpubKeySynced, v)
+}
+
+func readBlockStamp(b walletdb.ReadBucket) (*waddrmgr.BlockStamp, error) {
+	v := b.Get(xpubKeySynced)
+	if len(v) != 4+chainhash.HashSize+8 {
+		return nil, errors.New("missing synced block")
+	}
+	bs := &waddrmgr.BlockStamp{
+		Height:    int32(binary.BigEndian.Uint32(v[0:4])),
+		Timestamp: time.Unix(int64(binary.BigEndian.Uint64(v[4+chainhash.HashSize:])), 0),
+	}
+	copy(bs.Hash[:], v[4:4+chainhash.HashSize])
+	return bs, nil
+}
+
+func errWatchingOnly() error {
+	return waddrmgr.ManagerError{
+		ErrorCode:   waddrmgr.ErrWatchingOnly,
+		Description: "watch-only wallet has no private keys",
+	}
+}
+
+// xpubAddress is a pubkey address derived from an account xpub
+type xpubAddress struct {
+	path waddrmgr.DerivationPath
+	pub  *btcec.PublicKey
+	addr btcutil.Address
+}
+
+func (a *xpubAddress) Account() uint32                  { return a.path.Account }
+func (a *xpubAddress) Address() btcutil.Address         { return a.addr }
+func (a *xpubAddress) AddrHash() []byte                 { return a.addr.ScriptAddress() }
+func (a *xpubAddress) Imported() bool                   { return false }
+func (a *xpubAddress) Internal() bool                   { return a.path.Branch == waddrmgr.InternalBranch }
+func (a *xpubAddress) Compressed() bool                 { return true }
+func (a *xpubAddress) Used(ns walletdb.ReadBucket) bool { return false }
+func (a *xpubAddress) AddrType() waddrmgr.AddressType   { return waddrmgr.WitnessPubKey }
+func (a *xpubAddress) PubKey() *btcec.PublicKey         { return a.pub }
+
+func (a *xpubAddress) ExportPubKey() string {
+	return fmt.Sprintf("%x", a.pub.SerializeCompressed())
+}
+
+func (a *xpubAddress) PrivKey() (*btcec.PrivateKey, error) {
+	return nil, errWatchingOnly()
+}
+
+func (a *xpubAddress) ExportPrivKey() (*btcutil.WIF, error) {
+	return nil, errWatchingOnly()
+}
+
+func (a *xpubAddress) DerivationInfo() (waddrmgr.KeyScope, waddrmgr.DerivationPath, bool) {
+	return waddrmgrKeyScope, a.path, true
+}
@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupWatchOnlyWallet creates a watch-only wallet and a software signer
+// with a wallet of the same seed
+func setupWatchOnlyWallet(t *testing.T) (
+	watchOnly *Wallet, signer *SoftwareSigner, w *Wallet, tearDownFunc func()) {
+	assert := assert.New(t)
+
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+	assert.NoError(err)
+	signer, err = NewSoftwareSigner(seed, testNetParams)
+	assert.NoError(err)
+	xpub, fundXpub, err := signer.AccountXpubs()
+	assert.NoError(err)
+
+	db, deleteDB := setupDB(t)
+	watchOnly, err = createWatchOnly(
+		db, testNetParams, signer.Fingerprint(), xpub, fundXpub)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	seedDB, deleteSeedDB := setupDB(t)
+	w, err = create(seedDB, testNetParams, seed, testPubPass, testPrivPass)
+	assert.NoError(err)
+
+	tearDownFunc = func() {
+		deleteDB()
+		deleteSeedDB()
+	}
+	return watchOnly, signer, w, tearDownFunc
+}
+
+func TestCreateWatchOnlyWithPrivateKey(t *testing.T) {
+	assert := assert.New(t)
+	db, tearDownFunc := setupDB(t)
+	defer tearDownFunc()
+
+	seed, _ := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+	master, _ := hdkeychain.NewMaster(seed, testNetParams)
+	xpub, _ := master.Neuter()
+
+	_, err := createWatchOnly(db, testNetParams, 0, master, xpub)
+	assert.Error(err)
+}
+
+func TestWatchOnlyAddresses(t *testing.T) {
+	assert := assert.New(t)
+	watchOnly, _, w, tearDownFunc := setupWatchOnlyWallet(t)
+	defer tearDownFunc()
+
+	for i := 0; i < 2; i++ {
+		pub, err := watchOnly.NewPubkey()
+		assert.NoError(err)
+		expected, _ := w.NewPubkey()
+		assert.True(expected.IsEqual(pub))
+	}
+
+	pub, idx, err := watchOnly.NewFundPubkey()
+	assert.NoError(err)
+	expected, expectedIdx, _ := w.NewFundPubkey()
+	assert.True(expected.IsEqual(pub))
+	assert.Equal(expectedIdx, idx)
+
+	// addresses are kept after reopen
+	reopened, err := open(watchOnly.db, nil, testNetParams, nil)
+	assert.NoError(err)
+	_, err = reopened.managedPubKeyAddressFromPubkey(pub)
+	assert.NoError(err)
+	next, err := reopened.NewPubkey()
+	assert.NoError(err)
+	expected, _ = w.NewPubkey()
+	assert.True(expected.IsEqual(next))
+}
+
+func TestWatchOnlyWitnessSignature(t *testing.T) {
+	assert := assert.New(t)
+	watchOnly, signer, _, tearDownFunc := setupWatchOnlyWallet(t)
+	defer tearDownFunc()
+
+	pub, _ := watchOnly.NewPubkey()
+	pkScript, _ := script.P2WPKHpkScript(pub)
+
+	amt := btcutil.Amount(10000)
+	sourceTx := test.NewSourceTx()
+	sourceTx.AddTxOut(wire.NewTxOut(int64(amt), pkScript))
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+
+	// should fail without signer
+	_, err := watchOnly.WitnessSignature(redeemTx, 0, amt, pkScript, pub)
+	assert.Error(err)
+
+	watchOnly.SetSigner(signer)
+	sign, err := watchOnly.WitnessSignature(redeemTx, 0, amt, pkScript, pub)
+	assert.NoError(err)
+
+	redeemTx.TxIn[0].Witness = wire.TxWitness{sign, pub.SerializeCompressed()}
+	err = test.ExecuteScript(pkScript, redeemTx, int64(amt))
+	assert.NoError(err)
+}
+
+func TestWatchOnlyWitnessSignatureOfFundScript(t *testing.T) {
+	assert := assert.New(t)
+	watchOnly, signer, _, tearDownFunc := setupWatchOnlyWallet(t)
+	defer tearDownFunc()
+	watchOnly.SetSigner(signer)
+
+	pub, _, _ := watchOnly.NewFundPubkey()
+	otherPriv, otherPub := test.RandKeys()
+	sc, _ := script.FundScript(pub, otherPub)
+	pkScript, _ := script.P2WSHpkScript(sc)
+
+	amt := btcutil.Amount(10000)
+	sourceTx := test.NewSourceTx()
+	sourceTx.AddTxOut(wire.NewTxOut(int64(amt), pkScript))
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+
+	sign, err := watchOnly.WitnessSignature(redeemTx, 0, amt, sc, pub)
+	assert.NoError(err)
+	otherSign, _ := script.WitnessSignature(redeemTx, 0, int64(amt), sc, otherPriv)
+
+	redeemTx.TxIn[0].Witness = script.WitnessForFundScript(sign, otherSign, sc)
+	err = test.ExecuteScript(pkScript, redeemTx, int64(amt))
+	assert.NoError(err)
+}
+
+func TestWatchOnlyWitnessSignTxByUtxos(t *testing.T) {
+	assert := assert.New(t)
+	watchOnly, signer, _, tearDownFunc := setupWatchOnlyWallet(t)
+	defer tearDownFunc()
+	watchOnly.SetSigner(signer)
+
+	sourceTx := test.NewSourceTx()
+	utxos := []wallet.Utxo{}
+	for i := 0; i < 2; i++ {
+		addr, err := watchOnly.NewAddress()
+		assert.NoError(err)
+		pkScript, _ := script.P2WPKHpkScriptFromAddress(addr)
+		sourceTx.AddTxOut(wire.NewTxOut(int64(10000*(i+1)), pkScript))
+		utxos = append(utxos, wallet.Utxo{
+			Address: addr.EncodeAddress(),
+			Amount:  btcutil.Amount(10000 * (i + 1)).ToBTC(),
+		})
+	}
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+	redeemTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: sourceTx.TxHash(), Index: 1}, nil, nil))
+
+	wits, err := watchOnly.WitnessSignTxByUtxos(redeemTx, []int{0, 1}, utxos)
+	if !assert.NoError(err) {
+		return
+	}
+	for i, wit := range wits {
+		redeemTx.TxIn[i].Witness = wit
+	}
+	for i, txout := range sourceTx.TxOut {
+		vm, err := txscript.NewEngine(txout.PkScript, redeemTx, i,
+			txscript.StandardVerifyFlags, nil, nil, txout.Value)
+		assert.NoError(err)
+		assert.NoError(vm.Execute())
+	}
+}
+
+func TestWatchOnlySchnorrSignature(t *testing.T) {
+	assert := assert.New(t)
+	watchOnly, signer, _, tearDownFunc := setupWatchOnlyWallet(t)
+	defer tearDownFunc()
+	watchOnly.SetSigner(signer)
+
+	pub, _ := watchOnly.NewPubkey()
+
+	// PSBT has no schnorr signatures of arbitrary hashes
+	_, err := watchOnly.SchnorrSignature(chainhash.HashB([]byte("message")), pub)
+	assert.Error(err)
+}
+
+func TestWatchOnlyWitnessSignatureWithCallback(t *testing.T) {
+	assert := assert.New(t)
+	watchOnly, signer, w, tearDownFunc := setupWatchOnlyWallet(t)
+	defer tearDownFunc()
+	watchOnly.SetSigner(signer)
+	assert.True(watchOnly.WatchOnly())
+	assert.False(w.WatchOnly())
+
+	pub, _ := watchOnly.NewPubkey()
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	sc, _ := script.P2WPKHpkScript(pub)
+	converter := func(priv *btcec.PrivateKey) (*btcec.PrivateKey, error) {
+		return priv, nil
+	}
+
+	// the converted privkey can't be passed to the signer
+	_, err := watchOnly.WitnessSignatureWithCallback(tx, 0, 1, sc, pub, converter)
+	assert.Error(err)
+}
@@ -0,0 +1,142 @@
+// Package bip39 implements mnemonic codes for generating deterministic keys
+// with the English wordlist.
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+package bip39
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Entropy sizes in bits. A mnemonic has 3 words per 32 bits of entropy.
+const (
+	MinEntropyBits         = 128 // 12 words
+	MaxEntropyBits         = 256 // 24 words
+	RecommendedEntropyBits = 256
+)
+
+// seed derivation parameters
+const (
+	seedIterations = 2048
+	seedSize       = 64
+	saltPrefix     = "mnemonic"
+)
+
+// InvalidMnemonicError is raised when a mnemonic has unknown words,
+// an invalid number of words or a wrong checksum
+type InvalidMnemonicError struct{ error }
+
+// wordIndexes maps words to their indexes in the wordlist
+var wordIndexes = func() map[string]int {
+	m := make(map[string]int, len(englishWords))
+	for i, w := range englishWords {
+		m[w] = i
+	}
+	return m
+}()
+
+// NewEntropy generates random entropy of a given size in bits
+func NewEntropy(bits int) ([]byte, error) {
+	if err := validateEntropyBits(bits); err != nil {
+		return nil, err
+	}
+	entropy := make([]byte, bits/8)
+	_, err := rand.Read(entropy)
+	return entropy, err
+}
+
+// NewMnemonic encodes entropy into a mnemonic sentence
+func NewMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if err := validateEntropyBits(bits); err != nil {
+		return "", err
+	}
+
+	// entropy followed by the first bits/32 bits of its sha256 as checksum
+	csBits := uint(bits / 32)
+	hash := sha256.Sum256(entropy)
+	n := new(big.Int).SetBytes(entropy)
+	n.Lsh(n, csBits)
+	n.Or(n, big.NewInt(int64(hash[0]>>(8-csBits))))
+
+	nWords := (bits + int(csBits)) / 11
+	words := make([]string, nWords)
+	mask := big.NewInt(2047)
+	for i := nWords - 1; i >= 0; i-- {
+		idx := new(big.Int).And(n, mask).Int64()
+		words[i] = englishWords[idx]
+		n.Rsh(n, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic decodes a mnemonic sentence and verifies its checksum
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	nWords := len(words)
+	if nWords%3 != 0 || nWords < MinEntropyBits/32*3 || nWords > MaxEntropyBits/32*3 {
+		msg := fmt.Sprintf("invalid number of words. %d", nWords)
+		return nil, &InvalidMnemonicError{error: errors.New(msg)}
+	}
+
+	n := new(big.Int)
+	for _, w := range words {
+		idx, ok := wordIndexes[w]
+		if !ok {
+			msg := fmt.Sprintf("unknown word. %s", w)
+			return nil, &InvalidMnemonicError{error: errors.New(msg)}
+		}
+		n.Lsh(n, 11)
+		n.Or(n, big.NewInt(int64(idx)))
+	}
+
+	csBits := uint(nWords / 3)
+	cs := new(big.Int).And(n, big.NewInt(int64(1<<csBits-1))).Int64()
+	n.Rsh(n, csBits)
+
+	entropy := make([]byte, (nWords*11-int(csBits))/8)
+	b := n.Bytes()
+	copy(entropy[len(entropy)-len(b):], b)
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-csBits)) != cs {
+		return nil, &InvalidMnemonicError{error: errors.New("invalid checksum")}
+	}
+	return entropy, nil
+}
+
+// IsMnemonicValid returns true if a mnemonic sentence is valid
+func IsMnemonicValid(mnemonic string) bool {
+	_, err := EntropyFromMnemonic(mnemonic)
+	return err == nil
+}
+
+// NewSeed validates a mnemonic sentence and derives a 64-byte seed
+// with an optional passphrase. The passphrase is used as is, so it
+// should be NFKD normalized if it has non-ASCII characters.
+func NewSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	salt := []byte(saltPrefix + passphrase)
+	return pbkdf2.Key(
+		[]byte(normalized), salt, seedIterations, seedSize, sha512.New), nil
+}
+
+func validateEntropyBits(bits int) error {
+	if bits%32 != 0 || bits < MinEntropyBits || bits > MaxEntropyBits {
+		return fmt.Errorf(
+			"entropy must be a multiple of 32 bits between %d and %d. %d",
+			MinEntropyBits, MaxEntropyBits, bits)
+	}
+	return nil
+}
@@ -29,4 +29,13 @@ func init() {
 
 	// fix deal
 	dealsCmd.AddCommand(initFixDealCmd())
+
+	// subcommand mutual close
+	contractsCmd.AddCommand(mutualCloseCmd)
+	mutualCloseCmd.AddCommand(initSignMutualClosingTxCmd())
+	mutualCloseCmd.AddCommand(initSignedMutualClosingTxCmd())
+
+	// export and import
+	contractsCmd.AddCommand(initExportContractCmd())
+	contractsCmd.AddCommand(initImportContractCmd())
 }
@@ -13,11 +13,14 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcutil"
 	"github.com/p2pderivatives/dlc/internal/dlcmgr"
+	"github.com/p2pderivatives/dlc/internal/fee"
 	"github.com/p2pderivatives/dlc/pkg/dlc"
 	"github.com/p2pderivatives/dlc/pkg/oracle"
+	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/p2pderivatives/dlc/pkg/utils"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 var fund1 int
@@ -28,6 +31,8 @@ var changeAddress1 string
 var changeAddress2 string
 var fundtxFeerate int
 var redeemtxFeerate int
+var fundtxConfTarget int
+var redeemtxConfTarget int
 var refundlc int
 var dealsFile string
 var opubfile string
@@ -40,6 +45,9 @@ var privpass2 string
 var premiumDestAddress string
 var premiumAmount int
 var premiumPayingParty int
+var coinSelect string
+var utxos1 string
+var utxos2 string
 
 // Contractor is contractor
 type Contractor struct {
@@ -88,15 +96,24 @@ func runCreateContract(cmd *cobra.Command, args []string) {
 	err = party1.builder.PreparePubkey()
 	errorHandler(err)
 
+	// release utxos reserved by both parties if the negotiation fails
+	abortOnError := func(err error) {
+		if err != nil {
+			party1.builder.ReleaseUtxos()
+			party2.builder.ReleaseUtxos()
+		}
+		errorHandler(err)
+	}
+
 	// FirstParty prepares utxos
 	err = party1.builder.PrepareFundTx()
-	errorHandler(err)
+	abortOnError(err)
 
 	logger().Debug("First party sending public key and utxos to second party")
 
 	// First Party sends offer to Second Party
 	p1, err := party1.builder.PublicKey()
-	errorHandler(err)
+	abortOnError(err)
 	u1 := party1.builder.Utxos()
 	addr1 := party1.builder.Address()
 	chaddr1 := party1.builder.ChangeAddress()
@@ -105,9 +122,9 @@ func runCreateContract(cmd *cobra.Command, args []string) {
 
 	// Second party accepts pubkey, utxos, addresses
 	err = party2.builder.AcceptPubkey(p1)
-	errorHandler(err)
+	abortOnError(err)
 	err = party2.builder.AcceptUtxos(u1)
-	errorHandler(err)
+	abortOnError(err)
 	party2.builder.AcceptAdderss(addr1)
 	party2.builder.AcceptChangeAdderss(chaddr1)
 
@@ -115,20 +132,20 @@ func runCreateContract(cmd *cobra.Command, args []string) {
 
 	// Second Party signs CETxs and RefundTx
 	err = party2.builder.PreparePubkey()
-	errorHandler(err)
+	abortOnError(err)
 	err = party2.builder.PrepareFundTx()
-	errorHandler(err)
+	abortOnError(err)
 
 	logger().Debug("Second party sigining CETxs and RefundTx")
 
 	ceSigs2, err := party2.builder.SignContractExecutionTxs()
-	errorHandler(err)
+	abortOnError(err)
 	refundSig2, err := party2.builder.SignRefundTx()
-	errorHandler(err)
+	abortOnError(err)
 
 	logger().Debug("Second party sending public key, utxos and change address")
 	p2, err := party2.builder.PublicKey()
-	errorHandler(err)
+	abortOnError(err)
 	u2 := party2.builder.Utxos()
 	addr2 := party2.builder.Address()
 	chaddr2 := party2.builder.ChangeAddress()
@@ -136,9 +153,9 @@ func runCreateContract(cmd *cobra.Command, args []string) {
 	logger().Debug("First party accepting public key, utxoa and change address")
 
 	err = party1.builder.AcceptPubkey(p2)
-	errorHandler(err)
+	abortOnError(err)
 	err = party1.builder.AcceptUtxos(u2)
-	errorHandler(err)
+	abortOnError(err)
 	party1.builder.AcceptAdderss(addr2)
 	party1.builder.AcceptChangeAdderss(chaddr2)
 
@@ -146,33 +163,45 @@ func runCreateContract(cmd *cobra.Command, args []string) {
 
 	// FirstParty accepts sigs
 	err = party1.builder.AcceptRefundTxSignature(refundSig2)
-	errorHandler(err)
+	abortOnError(err)
 	err = party1.builder.AcceptCETxSignatures(ceSigs2)
-	errorHandler(err)
+	abortOnError(err)
+
+	logger().Debug("First party verifying contract")
+
+	err = party1.builder.Verify()
+	abortOnError(err)
 
 	logger().Debug("First party sigining all transactions")
 
 	// FirstParty signs CETxs and RefundTx and FundTx
 	ceSigs1, err := party1.builder.SignContractExecutionTxs()
-	errorHandler(err)
+	abortOnError(err)
 	refundSig1, err := party1.builder.SignRefundTx()
-	errorHandler(err)
+	abortOnError(err)
 	fundWits1, err := party1.builder.SignFundTx()
-	errorHandler(err)
+	abortOnError(err)
 
 	logger().Debug("Second party accepting all signatures")
 
 	// SecondParty accepts sigs
 	err = party2.builder.AcceptCETxSignatures(ceSigs1)
-	errorHandler(err)
+	abortOnError(err)
 	err = party2.builder.AcceptRefundTxSignature(refundSig1)
-	errorHandler(err)
-	party2.builder.AcceptFundWitnesses(fundWits1)
+	abortOnError(err)
+	err = party2.builder.AcceptFundWitnesses(fundWits1)
+	abortOnError(err)
+
+	logger().Debug("Second party verifying contract")
+
+	err = party2.builder.Verify()
+	abortOnError(err)
 
 	// SecondParty sends FundTx signature
 	fundWits2, err := party2.builder.SignFundTx()
-	errorHandler(err)
-	party1.builder.AcceptFundWitnesses(fundWits2)
+	abortOnError(err)
+	err = party1.builder.AcceptFundWitnesses(fundWits2)
+	abortOnError(err)
 
 	logger().Debug("First party persisting contract")
 
@@ -261,10 +290,10 @@ func registerCommonFlags(cmd *cobra.Command) {
 	cmd.MarkFlagRequired("address2")
 	cmd.Flags().StringVar(&changeAddress1, "change_address1", "", "Change address of First party")
 	cmd.Flags().StringVar(&changeAddress2, "change_address2", "", "Change address of Second party")
-	cmd.Flags().IntVar(&fundtxFeerate, "fundtx_feerate", 0, "Fee rate for fund tx (satoshi/byte)")
-	cmd.MarkFlagRequired("fundtx_feerate")
-	cmd.Flags().IntVar(&redeemtxFeerate, "redeemtx_feerate", 0, "Fee rate for refund tx, cetx, closing tx (satoshi/byte)")
-	cmd.MarkFlagRequired("redeemtx_feerate")
+	cmd.Flags().IntVar(&fundtxFeerate, "fundtx_feerate", 0, "Fee rate for fund tx (satoshi/byte). Estimated by bitcoind if not given")
+	cmd.Flags().IntVar(&redeemtxFeerate, "redeemtx_feerate", 0, "Fee rate for refund tx, cetx, closing tx (satoshi/byte). Estimated by bitcoind if not given")
+	cmd.Flags().IntVar(&fundtxConfTarget, "fundtx_conf_target", fee.DefaultFundConfTarget, "Confirmation target of fund tx (blocks) for fee estimation")
+	cmd.Flags().IntVar(&redeemtxConfTarget, "redeemtx_conf_target", fee.DefaultRedeemConfTarget, "Confirmation target of redeem txs (blocks) for fee estimation")
 	cmd.Flags().IntVar(&refundlc, "refund_locktime", 0, "Locktime of refune tx (block height)")
 	cmd.MarkFlagRequired("refund_locktime")
 	cmd.Flags().StringVar(&dealsFile, "deals_file", "", "Path to a csv file that contains deals")
@@ -285,6 +314,9 @@ func registerCommonFlags(cmd *cobra.Command) {
 	cmd.MarkFlagRequired("privpass1")
 	cmd.Flags().StringVar(&privpass2, "privpass2", "", "Privpass phrase of Second party's wallet")
 	cmd.MarkFlagRequired("privpass2")
+	cmd.Flags().StringVar(&coinSelect, "coinselect", "", "Coin selection strategy (firstfit, bnb, largest, smallest, privacy)")
+	cmd.Flags().StringVar(&utxos1, "utxos1", "", "Utxos of First party to fund (comma separated txid:vout)")
+	cmd.Flags().StringVar(&utxos2, "utxos2", "", "Utxos of Second party to fund (comma separated txid:vout)")
 
 }
 
@@ -341,6 +373,8 @@ func initFirstParty(nRpoints int) *Contractor {
 		d.ChangeAddrs[p] = parseAddress(changeAddress1)
 	}
 	b := dlc.NewBuilder(p, w, d)
+	b.SetRPCClient(initRPCClient())
+	b.SetCoinSelector(initCoinSelector(utxos1))
 
 	return &Contractor{
 		wallet:   w,
@@ -365,6 +399,8 @@ func initSecondParty(nRpoints int) *Contractor {
 		d.ChangeAddrs[p] = parseAddress(changeAddress2)
 	}
 	b := dlc.NewBuilder(p, w, d)
+	b.SetRPCClient(initRPCClient())
+	b.SetCoinSelector(initCoinSelector(utxos2))
 
 	return &Contractor{
 		wallet:   w,
@@ -375,9 +411,22 @@ func initSecondParty(nRpoints int) *Contractor {
 	}
 }
 
+// initCoinSelector returns a coin selector by the strategy name,
+// or manual selection if outpoints are given
+func initCoinSelector(outpoints string) wallet.CoinSelector {
+	if outpoints != "" {
+		ops, err := wallet.ParseOutPoints(outpoints)
+		errorHandler(err)
+		return wallet.Manual{OutPoints: ops}
+	}
+	s, err := wallet.CoinSelectorByName(coinSelect)
+	errorHandler(err)
+	return s
+}
+
 func parseAddress(addr string) btcutil.Address {
 	net := loadChainParams(bitcoinConf)
-	address, err := btcutil.DecodeAddress(addr, net)
+	address, err := script.DecodeAddress(addr, net)
 	errorHandler(err)
 	return address
 }
@@ -398,8 +447,7 @@ func loadDLCConditions(nRpoints int) *dlc.Conditions {
 	// cast int to btcutil.Amount
 	famt1 := btcutil.Amount(fund1)
 	famt2 := btcutil.Amount(fund2)
-	ffrate := btcutil.Amount(fundtxFeerate)
-	rfrate := btcutil.Amount(redeemtxFeerate)
+	ffrate, rfrate := loadFeerates()
 	var premiumInfo *dlc.PremiumInfo
 	var err error
 
@@ -421,6 +469,39 @@ func loadDLCConditions(nRpoints int) *dlc.Conditions {
 	return conds
 }
 
+// loadFeerates returns feerates given by flags,
+// or estimates them with bitcoind if they aren't given
+func loadFeerates() (ffrate, rfrate btcutil.Amount) {
+	if fundtxFeerate < 0 || redeemtxFeerate < 0 {
+		errorHandler(fmt.Errorf("feerates must not be negative"))
+	}
+
+	ffrate = btcutil.Amount(fundtxFeerate)
+	rfrate = btcutil.Amount(redeemtxFeerate)
+	if ffrate > 0 && rfrate > 0 {
+		return ffrate, rfrate
+	}
+
+	policy := fee.NewPolicy()
+	policy.FundConfTarget = int64(fundtxConfTarget)
+	policy.RedeemConfTarget = int64(redeemtxConfTarget)
+	rpcclient := initRPCClient()
+
+	var err error
+	if ffrate == 0 {
+		ffrate, err = policy.FundFeerate(rpcclient)
+		errorHandler(err)
+		logger().Debug("Estimated fund tx feerate", zap.Int64("feerate", int64(ffrate)))
+	}
+	if rfrate == 0 {
+		rfrate, err = policy.RedeemFeerate(rpcclient)
+		errorHandler(err)
+		logger().Debug("Estimated redeem tx feerate", zap.Int64("feerate", int64(rfrate)))
+	}
+
+	return ffrate, rfrate
+}
+
 func loadPremiumInfo() (*dlc.PremiumInfo, error) {
 	premiumDestAddressBtc := parseAddress(premiumDestAddress)
 	premiumAmountBtc := btcutil.Amount(premiumAmount)
@@ -0,0 +1,100 @@
+package dlccli
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/p2pderivatives/dlc/internal/dlcmgr"
+	"github.com/spf13/cobra"
+)
+
+func initExportContractCmd() *cobra.Command {
+	var dlcid string
+	var walletName string
+	var pubpass string
+	var passphrase string
+	var output string
+
+	var cmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export a contract into a file encrypted with a passphrase",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, wdb := openWallet(pubpass, walletDir, walletName)
+			defer wdb.Close()
+			mgr, err := dlcmgr.Open(wdb)
+			errorHandler(err)
+
+			h, err := chainhash.NewHashFromStr(dlcid)
+			errorHandler(err)
+			data, err := mgr.ExportContract(h.CloneBytes(), []byte(passphrase))
+			errorHandler(err)
+
+			err = ioutil.WriteFile(output, data, 0600)
+			errorHandler(err)
+			fmt.Printf("Contract exported to %s\n", output)
+		},
+	}
+
+	cmd.Flags().StringVar(&dlcid, "dlcid", "", "Contract ID")
+	cmd.MarkFlagRequired("dlcid")
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "Wallet directory")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "wallet", "", "Wallet name")
+	cmd.MarkFlagRequired("wallet")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&passphrase, "passphrase", "", "passphrase to encrypt the file")
+	cmd.MarkFlagRequired("passphrase")
+	cmd.Flags().StringVar(&output, "output", "", "path of the exported file")
+	cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+func initImportContractCmd() *cobra.Command {
+	var walletName string
+	var pubpass string
+	var passphrase string
+	var input string
+
+	var cmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import an exported contract into a wallet of the same seed",
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := ioutil.ReadFile(input)
+			errorHandler(err)
+
+			w, wdb := openWallet(pubpass, walletDir, walletName)
+			defer wdb.Close()
+			mgr, err := dlcmgr.Open(wdb)
+			errorHandler(err)
+
+			key, fundKey, err := mgr.ImportContract(data, []byte(passphrase))
+			errorHandler(err)
+
+			// the wallet derives the fund pubkey to sign with it
+			if fundKey != nil {
+				err = w.RecoverFundPubkeys(fundKey.Index)
+				errorHandler(err)
+			}
+
+			h, err := chainhash.NewHash(key)
+			errorHandler(err)
+			fmt.Printf("Contract imported\n\nContractID:\n%s\n", h)
+		},
+	}
+
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "Wallet directory")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "wallet", "", "Wallet name")
+	cmd.MarkFlagRequired("wallet")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&passphrase, "passphrase", "", "passphrase of the file")
+	cmd.MarkFlagRequired("passphrase")
+	cmd.Flags().StringVar(&input, "input", "", "path of the exported file")
+	cmd.MarkFlagRequired("input")
+
+	return cmd
+}
@@ -0,0 +1,111 @@
+package dlccli
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var mutualCloseCmd = &cobra.Command{
+	Use:   "mutualclose",
+	Short: "Mutual close commands",
+}
+
+var mutualCloseAmt1 int64
+var mutualCloseAmt2 int64
+
+func initSignMutualClosingTxCmd() *cobra.Command {
+	var dlcid string
+	var contractorType int
+	var walletName string
+	var pubpass string
+	var privpass string
+
+	var cmd = &cobra.Command{
+		Use:   "sign",
+		Short: "Sign mutual closing tx",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := initCotractor(
+				dlcid, walletDir, walletName, pubpass, privpass, contractorType)
+
+			sig, err := c.builder.SignMutualClosingTx(
+				btcutil.Amount(mutualCloseAmt1), btcutil.Amount(mutualCloseAmt2))
+			errorHandler(err)
+
+			fmt.Printf("\nSignature hex:\n%s\n", hex.EncodeToString(sig))
+		},
+	}
+
+	cmd.Flags().StringVar(&dlcid, "dlcid", "", "Contract ID")
+	cmd.MarkFlagRequired("dlcid")
+	registerMutualCloseAmtFlags(cmd)
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "Wallet directory")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "wallet", "", "Wallet name")
+	cmd.MarkFlagRequired("wallet")
+	cmd.Flags().IntVar(&contractorType, "contractor_type", 0, "0: first party, 1:second party")
+	cmd.MarkFlagRequired("contractor_type")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&privpass, "privpass", "", "private passphrase")
+	cmd.MarkFlagRequired("privpass")
+
+	return cmd
+}
+
+func initSignedMutualClosingTxCmd() *cobra.Command {
+	var dlcid string
+	var cpSig string
+	var contractorType int
+	var walletName string
+	var pubpass string
+	var privpass string
+
+	var cmd = &cobra.Command{
+		Use:   "tx",
+		Short: "Create mutual closing tx signed by both parties",
+		Run: func(cmd *cobra.Command, args []string) {
+			c := initCotractor(
+				dlcid, walletDir, walletName, pubpass, privpass, contractorType)
+
+			sig, err := hex.DecodeString(cpSig)
+			errorHandler(err)
+
+			tx, err := c.builder.SignedMutualClosingTx(
+				btcutil.Amount(mutualCloseAmt1), btcutil.Amount(mutualCloseAmt2), sig)
+			errorHandler(err)
+
+			txHex, err := utils.TxToHex(tx)
+			errorHandler(err)
+			fmt.Printf("\nMutualClosingTx hex:\n%s\n", txHex)
+		},
+	}
+
+	cmd.Flags().StringVar(&dlcid, "dlcid", "", "Contract ID")
+	cmd.MarkFlagRequired("dlcid")
+	registerMutualCloseAmtFlags(cmd)
+	cmd.Flags().StringVar(&cpSig, "counterparty_sig", "", "Counterparty's signature hex")
+	cmd.MarkFlagRequired("counterparty_sig")
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "Wallet directory")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "wallet", "", "Wallet name")
+	cmd.MarkFlagRequired("wallet")
+	cmd.Flags().IntVar(&contractorType, "contractor_type", 0, "0: first party, 1:second party")
+	cmd.MarkFlagRequired("contractor_type")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&privpass, "privpass", "", "private passphrase")
+	cmd.MarkFlagRequired("privpass")
+
+	return cmd
+}
+
+func registerMutualCloseAmtFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64Var(&mutualCloseAmt1, "amount1", 0, "Amount distributed to first party (satoshi)")
+	cmd.MarkFlagRequired("amount1")
+	cmd.Flags().Int64Var(&mutualCloseAmt2, "amount2", 0, "Amount distributed to second party (satoshi)")
+	cmd.MarkFlagRequired("amount2")
+}
@@ -3,15 +3,26 @@ package dlccli
 import (
 	"encoding/hex"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/btcsuite/btcwallet/walletdb"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 
 	"github.com/p2pderivatives/dlc/internal/dlcmgr"
+	"github.com/p2pderivatives/dlc/internal/fee"
+	"github.com/p2pderivatives/dlc/internal/history"
 	_wallet "github.com/p2pderivatives/dlc/internal/wallet"
+	"github.com/p2pderivatives/dlc/pkg/bip39"
+	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 )
 
@@ -27,6 +38,8 @@ var walletsCmd = func() *cobra.Command {
 
 var walletsCreateCmd = func() *cobra.Command {
 	var seed string
+	var mnemonic string
+	var passphrase string
 	var pubpass string
 	var privpass string
 	var walletName string
@@ -37,8 +50,7 @@ var walletsCreateCmd = func() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			chainParams := loadChainParams(bitcoinConf)
 
-			seedB, err := hex.DecodeString(seed)
-			errorHandler(err)
+			seedB := loadSeed(seed, mnemonic, passphrase)
 
 			w, err := _wallet.CreateWallet(chainParams,
 				seedB, []byte(pubpass), []byte(privpass),
@@ -55,8 +67,9 @@ var walletsCreateCmd = func() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&seed, "seed", "", "seed of HD wallet")
-	cmd.MarkFlagRequired("seed")
+	cmd.Flags().StringVar(&seed, "seed", "", "seed of HD wallet in hex (either seed or mnemonic is required)")
+	cmd.Flags().StringVar(&mnemonic, "mnemonic", "", "BIP39 mnemonic of HD wallet")
+	cmd.Flags().StringVar(&passphrase, "passphrase", "", "BIP39 passphrase used with mnemonic (optional)")
 	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
 	cmd.MarkFlagRequired("walletdir")
 	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
@@ -84,6 +97,97 @@ var walletsSeedCmd = func() *cobra.Command {
 	return cmd
 }
 
+var walletsMnemonicCmd = func() *cobra.Command {
+	var words int
+
+	cmd := &cobra.Command{
+		Use:   "mnemonic",
+		Short: "Generate BIP39 mnemonic",
+		Run: func(cmd *cobra.Command, args []string) {
+			entropy, err := bip39.NewEntropy(words / 3 * 32)
+			errorHandler(err)
+			mnemonic, err := bip39.NewMnemonic(entropy)
+			errorHandler(err)
+
+			fmt.Println(mnemonic)
+		},
+	}
+
+	cmd.Flags().IntVar(&words, "words", 24, "number of words (12, 15, 18, 21 or 24)")
+
+	return cmd
+}
+
+var walletsRecoverCmd = func() *cobra.Command {
+	var pubpass string
+	var walletName string
+	var gapLimit uint32
+
+	cmd := &cobra.Command{
+		Use:   "recover",
+		Short: "Rescan the chain to restore funds and fund pubkeys of stored contracts",
+		Run: func(cmd *cobra.Command, args []string) {
+			w, wdb := openWallet(pubpass, walletDir, walletName)
+			err := w.Recover(gapLimit)
+			errorHandler(err)
+
+			mgr, err := dlcmgr.Open(wdb)
+			errorHandler(err)
+			contracts, err := mgr.Contracts()
+			errorHandler(err)
+			pubs := []*btcec.PublicKey{}
+			var lastFundKeyIdx *uint32
+			for _, d := range contracts {
+				for _, pub := range d.Pubs {
+					pubs = append(pubs, pub)
+				}
+				if idx := d.FundKeyIdx; idx != nil &&
+					(lastFundKeyIdx == nil || *idx > *lastFundKeyIdx) {
+					lastFundKeyIdx = idx
+				}
+			}
+			// fund pubkeys on the fund account are derived by their indexes,
+			// and older ones on the receive account are searched
+			if lastFundKeyIdx != nil {
+				err = w.RecoverFundPubkeys(*lastFundKeyIdx)
+				errorHandler(err)
+			}
+			recovered, err := w.RecoverPubkeys(pubs, gapLimit)
+			errorHandler(err)
+
+			bal, err := w.Balance()
+			errorHandler(err)
+			fmt.Printf("confirmed: %v\n", bal.Confirmed.ToBTC())
+			fmt.Printf("fund pubkeys: %d\n", len(recovered))
+		},
+	}
+
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
+	cmd.MarkFlagRequired("walletname")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().Uint32Var(&gapLimit, "gaplimit", _wallet.DefaultGapLimit, "number of unused addresses scanned beyond the last used one")
+
+	return cmd
+}
+
+// loadSeed returns a seed given in hex or derived from a BIP39 mnemonic
+func loadSeed(seed, mnemonic, passphrase string) []byte {
+	if (seed == "") == (mnemonic == "") {
+		errorHandler(fmt.Errorf("either seed or mnemonic must be given"))
+	}
+	if seed != "" {
+		seedB, err := hex.DecodeString(seed)
+		errorHandler(err)
+		return seedB
+	}
+	seedB, err := bip39.NewSeed(mnemonic, passphrase)
+	errorHandler(err)
+	return seedB
+}
+
 var addrsCmd = func() *cobra.Command {
 	return &cobra.Command{
 		Use:   "addresses",
@@ -153,20 +257,255 @@ var balanceCmd = func() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "balance",
-		Short: "Check total balance",
+		Short: "Check balance breakdown",
+		Run: func(cmd *cobra.Command, args []string) {
+			w, wdb := openWallet(pubpass, walletDir, walletName)
+			bal, err := w.Balance()
+			errorHandler(err)
+
+			mgr, err := dlcmgr.Open(wdb)
+			errorHandler(err)
+			txs, err := w.Transactions()
+			errorHandler(err)
+			bal.LockedInContracts, err = mgr.LockedInContracts(txs, initRPCClient())
+			errorHandler(err)
+
+			fmt.Printf("confirmed: %v\n", bal.Confirmed.ToBTC())
+			fmt.Printf("unconfirmed: %v\n", bal.Unconfirmed.ToBTC())
+			fmt.Printf("reserved: %v\n", bal.Reserved.ToBTC())
+			fmt.Printf("locked_in_contracts: %v\n", bal.LockedInContracts.ToBTC())
+			fmt.Printf("total: %v\n", bal.Total().ToBTC())
+		},
+	}
+
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
+	cmd.MarkFlagRequired("walletname")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+
+	return cmd
+}
+
+var sendCmd = func() *cobra.Command {
+	var pubpass string
+	var privpass string
+	var walletName string
+	var outputs string
+	var feerate int
+
+	cmd := &cobra.Command{
+		Use:   "send",
+		Short: "Send bitcoin to addresses",
+		Run: func(cmd *cobra.Command, args []string) {
+			w, _ := openWallet(pubpass, walletDir, walletName)
+			err := w.Unlock([]byte(privpass))
+			errorHandler(err)
+
+			txouts := parseTxOuts(outputs)
+			tx, err := w.Send(txouts, loadSendFeerate(feerate))
+			errorHandler(err)
+
+			fmt.Println(tx.TxHash())
+		},
+	}
+
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
+	cmd.MarkFlagRequired("walletname")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&privpass, "privpass", "", "private passphrase")
+	cmd.MarkFlagRequired("privpass")
+	cmd.Flags().StringVar(&outputs, "outputs", "", "destinations (comma separated address:amount in BTC)")
+	cmd.MarkFlagRequired("outputs")
+	cmd.Flags().IntVar(&feerate, "feerate", 0, "Fee rate (satoshi/byte). Estimated by bitcoind if not given")
+
+	return cmd
+}
+
+var sweepCmd = func() *cobra.Command {
+	var pubpass string
+	var privpass string
+	var walletName string
+	var address string
+	var feerate int
+
+	cmd := &cobra.Command{
+		Use:   "sweep",
+		Short: "Send all unlocked utxos to an address",
 		Run: func(cmd *cobra.Command, args []string) {
 			w, _ := openWallet(pubpass, walletDir, walletName)
-			utxos, err := w.ListUnspent()
+			err := w.Unlock([]byte(privpass))
 			errorHandler(err)
 
-			var total btcutil.Amount
-			for _, utxo := range utxos {
-				amt, err := btcutil.NewAmount(utxo.Amount)
+			tx, err := w.Sweep(parseAddress(address), loadSendFeerate(feerate))
+			errorHandler(err)
+
+			fmt.Println(tx.TxHash())
+		},
+	}
+
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
+	cmd.MarkFlagRequired("walletname")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&privpass, "privpass", "", "private passphrase")
+	cmd.MarkFlagRequired("privpass")
+	cmd.Flags().StringVar(&address, "address", "", "destination address")
+	cmd.MarkFlagRequired("address")
+	cmd.Flags().IntVar(&feerate, "feerate", 0, "Fee rate (satoshi/byte). Estimated by bitcoind if not given")
+
+	return cmd
+}
+
+var historyCmd = func() *cobra.Command {
+	var pubpass string
+	var walletName string
+	var format string
+	var perContract bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Export wallet transaction history labeled by contract roles",
+		Run: func(cmd *cobra.Command, args []string) {
+			w, wdb := openWallet(pubpass, walletDir, walletName)
+			mgr, err := dlcmgr.Open(wdb)
+			errorHandler(err)
+
+			txs, err := w.Transactions()
+			errorHandler(err)
+			contracts, err := mgr.Contracts()
+			errorHandler(err)
+			h, err := history.Build(txs, contracts)
+			errorHandler(err)
+
+			out := os.Stdout
+			if output != "" {
+				out, err = os.Create(output)
 				errorHandler(err)
-				total += amt
+				defer out.Close()
+			}
+
+			switch {
+			case format == "json":
+				err = h.WriteJSON(out)
+			case format == "csv" && perContract:
+				err = h.WriteContractsCSV(out)
+			case format == "csv":
+				err = h.WriteCSV(out)
+			default:
+				err = fmt.Errorf("unknown format: %s", format)
+			}
+			errorHandler(err)
+		},
+	}
+
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
+	cmd.MarkFlagRequired("walletname")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&format, "format", "csv", "output format (csv or json)")
+	cmd.Flags().BoolVar(&perContract, "contracts", false, "export profit and loss per contract instead of entries (csv)")
+	cmd.Flags().StringVar(&output, "output", "", "output file path. Printed to stdout if not given")
+
+	return cmd
+}
+
+// parseTxOuts parses comma separated outputs in address:amount format
+func parseTxOuts(outputs string) []*wire.TxOut {
+	txouts := []*wire.TxOut{}
+	for _, str := range strings.Split(outputs, ",") {
+		parts := strings.Split(strings.TrimSpace(str), ":")
+		if len(parts) != 2 {
+			errorHandler(fmt.Errorf("invalid output: %s", str))
+		}
+
+		amtBTC, err := strconv.ParseFloat(parts[1], 64)
+		errorHandler(err)
+		amt, err := btcutil.NewAmount(amtBTC)
+		errorHandler(err)
+
+		sc, err := script.PkScriptFromAddress(parseAddress(parts[0]))
+		errorHandler(err)
+		txouts = append(txouts, wire.NewTxOut(int64(amt), sc))
+	}
+	return txouts
+}
+
+// loadSendFeerate returns a given feerate,
+// or estimates it with bitcoind if it isn't given
+func loadSendFeerate(feerate int) btcutil.Amount {
+	if feerate > 0 {
+		return btcutil.Amount(feerate)
+	}
+	rate, err := fee.NewPolicy().FundFeerate(initRPCClient())
+	errorHandler(err)
+	logger().Debug("Estimated feerate", zap.Int64("feerate", int64(rate)))
+	return rate
+}
+
+var locksCmd = func() *cobra.Command {
+	return &cobra.Command{
+		Use:   "locks",
+		Short: "Utxo lock command",
+	}
+}
+
+var locksListCmd = func() *cobra.Command {
+	var pubpass string
+	var walletName string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List utxos reserved for contracts being negotiated",
+		Run: func(cmd *cobra.Command, args []string) {
+			w, _ := openWallet(pubpass, walletDir, walletName)
+			locks, err := w.LockedUtxos()
+			errorHandler(err)
+
+			for op, expiry := range locks {
+				fmt.Printf("%s\t%s\n", op, expiry.Format(time.RFC3339))
 			}
+		},
+	}
+
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
+	cmd.MarkFlagRequired("walletname")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+	cmd.MarkFlagRequired("pubpass")
 
-			fmt.Println(total.ToBTC())
+	return cmd
+}
+
+var locksReleaseCmd = func() *cobra.Command {
+	var pubpass string
+	var walletName string
+	var outpoints string
+
+	cmd := &cobra.Command{
+		Use:   "release",
+		Short: "Release utxos reserved for an aborted negotiation",
+		Run: func(cmd *cobra.Command, args []string) {
+			w, _ := openWallet(pubpass, walletDir, walletName)
+			ops, err := wallet.ParseOutPoints(outpoints)
+			errorHandler(err)
+
+			utxos := []wallet.Utxo{}
+			for _, op := range ops {
+				utxos = append(utxos, wallet.Utxo{TxID: op.Hash.String(), Vout: op.Index})
+			}
+			err = w.UnlockUtxos(utxos)
+			errorHandler(err)
 		},
 	}
 
@@ -176,6 +515,8 @@ var balanceCmd = func() *cobra.Command {
 	cmd.MarkFlagRequired("walletname")
 	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
 	cmd.MarkFlagRequired("pubpass")
+	cmd.Flags().StringVar(&outpoints, "utxos", "", "releasing utxos (comma separated txid:vout)")
+	cmd.MarkFlagRequired("utxos")
 
 	return cmd
 }
@@ -187,6 +528,8 @@ func openWallet(pubpass string, dir string, name string) (wallet.Wallet, walletd
 
 	w, err := _wallet.Open(wdb, []byte(pubpass), chainParams, rpcclient)
 	errorHandler(err)
+	// asked to sign only by watch-only wallets
+	w.SetSigner(&promptSigner{})
 
 	return w, wdb
 }
@@ -209,9 +552,33 @@ func init() {
 	// seed
 	subRootCmd.AddCommand(walletsSeedCmd())
 
+	// mnemonic
+	subRootCmd.AddCommand(walletsMnemonicCmd())
+
+	// xpubs
+	subRootCmd.AddCommand(walletsXpubsCmd())
+
+	// create watch-only wallet
+	subRootCmd.AddCommand(walletsCreateWatchOnlyCmd())
+
+	// sign psbt
+	subRootCmd.AddCommand(walletsSignPSBTCmd())
+
+	// recover
+	subRootCmd.AddCommand(walletsRecoverCmd())
+
 	// balance
 	subRootCmd.AddCommand(balanceCmd())
 
+	// send
+	subRootCmd.AddCommand(sendCmd())
+
+	// sweep
+	subRootCmd.AddCommand(sweepCmd())
+
+	// history
+	subRootCmd.AddCommand(historyCmd())
+
 	// addresses sub command root
 	addrsRootCmd := addrsCmd()
 	subRootCmd.AddCommand(addrsRootCmd)
@@ -221,4 +588,14 @@ func init() {
 
 	// address import
 	addrsRootCmd.AddCommand(addrsImportCmd())
+
+	// utxo locks sub command root
+	locksRootCmd := locksCmd()
+	subRootCmd.AddCommand(locksRootCmd)
+
+	// list locked utxos
+	locksRootCmd.AddCommand(locksListCmd())
+
+	// release locked utxos
+	locksRootCmd.AddCommand(locksReleaseCmd())
 }
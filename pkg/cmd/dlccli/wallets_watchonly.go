@@ -0,0 +1,166 @@
+package dlccli
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/spf13/cobra"
+
+	"github.com/p2pderivatives/dlc/internal/dlcmgr"
+	_wallet "github.com/p2pderivatives/dlc/internal/wallet"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
+)
+
+var walletsXpubsCmd = func() *cobra.Command {
+	var seed string
+	var mnemonic string
+	var passphrase string
+
+	cmd := &cobra.Command{
+		Use:   "xpubs",
+		Short: "Show master key fingerprint and account xpubs to create a watch-only wallet",
+		Run: func(cmd *cobra.Command, args []string) {
+			chainParams := loadChainParams(bitcoinConf)
+			signer, err := _wallet.NewSoftwareSigner(
+				loadSeed(seed, mnemonic, passphrase), chainParams)
+			errorHandler(err)
+			xpub, fundXpub, err := signer.AccountXpubs()
+			errorHandler(err)
+
+			fmt.Printf("fingerprint: %s\n", formatFingerprint(signer.Fingerprint()))
+			fmt.Printf("xpub: %s\n", xpub)
+			fmt.Printf("fundxpub: %s\n", fundXpub)
+		},
+	}
+
+	cmd.Flags().StringVar(&seed, "seed", "", "seed of HD wallet in hex (either seed or mnemonic is required)")
+	cmd.Flags().StringVar(&mnemonic, "mnemonic", "", "BIP39 mnemonic of HD wallet")
+	cmd.Flags().StringVar(&passphrase, "passphrase", "", "BIP39 passphrase used with mnemonic (optional)")
+
+	return cmd
+}
+
+var walletsCreateWatchOnlyCmd = func() *cobra.Command {
+	var fingerprint string
+	var xpub string
+	var fundXpub string
+	var pubpass string
+	var walletName string
+
+	cmd := &cobra.Command{
+		Use:   "createwatchonly",
+		Short: "Create a new wallet holding only xpubs, which asks an external signer to sign PSBTs",
+		Run: func(cmd *cobra.Command, args []string) {
+			chainParams := loadChainParams(bitcoinConf)
+
+			w, err := _wallet.CreateWatchOnlyWallet(chainParams,
+				parseFingerprint(fingerprint), parseXpub(xpub), parseXpub(fundXpub),
+				walletDir, walletName)
+			errorHandler(err)
+
+			err = w.Close()
+			errorHandler(err)
+
+			_, wdb := openWallet(pubpass, walletDir, walletName)
+			defer wdb.Close()
+			_, err = dlcmgr.Create(wdb)
+			errorHandler(err)
+		},
+	}
+
+	cmd.Flags().StringVar(&fingerprint, "fingerprint", "", "master key fingerprint in hex")
+	cmd.MarkFlagRequired("fingerprint")
+	cmd.Flags().StringVar(&xpub, "xpub", "", "xpub of the receive account (m/84'/0'/1')")
+	cmd.MarkFlagRequired("xpub")
+	cmd.Flags().StringVar(&fundXpub, "fundxpub", "", "xpub of the fund account (m/84'/0'/2')")
+	cmd.MarkFlagRequired("fundxpub")
+	cmd.Flags().StringVar(&walletDir, "walletdir", "", "directory path to store wallets")
+	cmd.MarkFlagRequired("walletdir")
+	cmd.Flags().StringVar(&walletName, "walletname", "", "wallet name")
+	cmd.MarkFlagRequired("walletname")
+	cmd.Flags().StringVar(&pubpass, "pubpass", "", "public passphrase")
+
+	return cmd
+}
+
+var walletsSignPSBTCmd = func() *cobra.Command {
+	var seed string
+	var mnemonic string
+	var passphrase string
+	var p string
+
+	cmd := &cobra.Command{
+		Use:   "signpsbt",
+		Short: "Sign a PSBT with a seed (offline signer of a watch-only wallet)",
+		Run: func(cmd *cobra.Command, args []string) {
+			chainParams := loadChainParams(bitcoinConf)
+			signer, err := _wallet.NewSoftwareSigner(
+				loadSeed(seed, mnemonic, passphrase), chainParams)
+			errorHandler(err)
+
+			packet, err := psbt.B64Decode(p)
+			errorHandler(err)
+			packet, err = signer.SignPSBT(packet)
+			errorHandler(err)
+			signed, err := packet.B64Encode()
+			errorHandler(err)
+
+			fmt.Println(signed)
+		},
+	}
+
+	cmd.Flags().StringVar(&seed, "seed", "", "seed of HD wallet in hex (either seed or mnemonic is required)")
+	cmd.Flags().StringVar(&mnemonic, "mnemonic", "", "BIP39 mnemonic of HD wallet")
+	cmd.Flags().StringVar(&passphrase, "passphrase", "", "BIP39 passphrase used with mnemonic (optional)")
+	cmd.Flags().StringVar(&p, "psbt", "", "PSBT in base64")
+	cmd.MarkFlagRequired("psbt")
+
+	return cmd
+}
+
+// promptSigner is a signer printing PSBTs and reading signed ones
+// from stdin, which are signed by hardware or offline signers
+type promptSigner struct{}
+
+// SignPSBT prompts to sign a PSBT
+func (s *promptSigner) SignPSBT(p *psbt.Packet) (*psbt.Packet, error) {
+	unsigned, err := p.B64Encode()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Sign PSBT:\n%s\nSigned PSBT: ", unsigned)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	return psbt.B64Decode(strings.TrimSpace(line))
+}
+
+// formatFingerprint formats a fingerprint in the byte order of BIP32
+func formatFingerprint(fp uint32) string {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, fp)
+	return hex.EncodeToString(b)
+}
+
+// parseFingerprint parses a fingerprint in the byte order of BIP32
+func parseFingerprint(s string) uint32 {
+	b, err := hex.DecodeString(s)
+	errorHandler(err)
+	if len(b) != 4 {
+		errorHandler(fmt.Errorf("invalid fingerprint: %s", s))
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func parseXpub(s string) *hdkeychain.ExtendedKey {
+	k, err := hdkeychain.NewKeyFromString(s)
+	errorHandler(err)
+	return k
+}
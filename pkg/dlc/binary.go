@@ -0,0 +1,888 @@
+package dlc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/oracle"
+	"github.com/p2pderivatives/dlc/pkg/script"
+)
+
+// binaryVersion is the version of the binary encoding of DLC.
+// Version 2 appends the dust limit of conditions,
+// which is 0 for contracts encoded in version 1.
+const binaryVersion = byte(2)
+
+// tags of signatures in the binary encoding
+const (
+	sigRaw     = byte(0) // bytes as they are
+	sigCompact = byte(1) // DER ECDSA signature with sighash type in 65 bytes
+)
+
+const compactSigSize = 64
+
+// InvalidBinaryError is raised when a binary encoded DLC can't be decoded
+type InvalidBinaryError struct{ error }
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The encoding is deterministic so that it can be used for storage and
+// wire messages. Lists and byte strings are prefixed by their lengths
+// in bitcoin's variable length integers, maps are ordered by contractors,
+// public keys are compressed and DER signatures are compacted to 64 bytes.
+// Empty slices are decoded as nil.
+func (d *DLC) MarshalBinary() ([]byte, error) {
+	if d.Conds == nil {
+		return nil, errors.New("conditions are required")
+	}
+
+	w := &binWriter{buf: &bytes.Buffer{}}
+	w.byte(binaryVersion)
+	w.conditions(d.Conds)
+	w.oracle(d.Oracle)
+	w.pubkeyMap(d.Pubs)
+	w.addrMap(d.Addrs)
+	w.addrMap(d.ChangeAddrs)
+	w.utxos(d.Utxos)
+	w.fundWits(d.FundWits)
+	w.sigMap(d.RefundSigs)
+	w.sigsMap(d.ExecSigs)
+	w.buffer(d.Buffer)
+	w.nonces(d.Nonces)
+	w.optUint32(d.FundKeyIdx)
+	w.amount(d.Conds.DustLimit)
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (d *DLC) UnmarshalBinary(data []byte) error {
+	r := &binReader{r: bytes.NewReader(data)}
+	v := r.byte()
+	if r.err == nil && (v < 1 || v > binaryVersion) {
+		return r.fail("unsupported version. %d", v)
+	}
+
+	conds := r.conditions()
+	if r.err != nil {
+		return r.err
+	}
+	decoded := NewDLC(conds)
+	decoded.Oracle = r.oracle()
+	decoded.Pubs = r.pubkeyMap()
+	decoded.Addrs = r.addrMap(conds)
+	decoded.ChangeAddrs = r.addrMap(conds)
+	decoded.Utxos = r.utxos()
+	decoded.FundWits = r.fundWits()
+	decoded.RefundSigs = r.sigMap()
+	decoded.ExecSigs = r.sigsMap()
+	decoded.Buffer = r.buffer()
+	decoded.Nonces = r.nonces()
+	decoded.FundKeyIdx = r.optUint32()
+	if v >= 2 {
+		conds.DustLimit = r.amount()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if n := r.r.Len(); n != 0 {
+		return r.fail("%d trailing bytes", n)
+	}
+
+	*d = *decoded
+	return nil
+}
+
+// binWriter writes values in the binary encoding,
+// keeping the first error
+type binWriter struct {
+	buf *bytes.Buffer
+	err error
+}
+
+func (w *binWriter) fail(format string, a ...interface{}) {
+	if w.err == nil {
+		w.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (w *binWriter) byte(b byte) {
+	w.buf.WriteByte(b)
+}
+
+func (w *binWriter) bool(b bool) {
+	if b {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+}
+
+func (w *binWriter) uint16(v uint16) {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	w.buf.Write(b)
+}
+
+func (w *binWriter) uint32(v uint32) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	w.buf.Write(b)
+}
+
+func (w *binWriter) uint64(v uint64) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	w.buf.Write(b)
+}
+
+func (w *binWriter) varint(v uint64) {
+	wire.WriteVarInt(w.buf, 0, v)
+}
+
+func (w *binWriter) int(v int) {
+	if v < 0 {
+		w.fail("negative integer. %d", v)
+		return
+	}
+	w.varint(uint64(v))
+}
+
+func (w *binWriter) contractor(c Contractor) {
+	w.int(int(c))
+}
+
+func (w *binWriter) amount(amt btcutil.Amount) {
+	if amt < 0 {
+		w.fail("negative amount. %d", amt)
+		return
+	}
+	w.varint(uint64(amt))
+}
+
+func (w *binWriter) bytes(b []byte) {
+	w.varint(uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *binWriter) str(s string) {
+	w.bytes([]byte(s))
+}
+
+func (w *binWriter) bytesList(bs [][]byte) {
+	w.varint(uint64(len(bs)))
+	for _, b := range bs {
+		w.bytes(b)
+	}
+}
+
+// pubkey writes a compressed public key, or 0x00 if nil
+func (w *binWriter) pubkey(pub *btcec.PublicKey) {
+	if pub == nil {
+		w.byte(0)
+		return
+	}
+	w.buf.Write(pub.SerializeCompressed())
+}
+
+func (w *binWriter) pubkeyList(pubs []*btcec.PublicKey) {
+	w.varint(uint64(len(pubs)))
+	for _, pub := range pubs {
+		w.pubkey(pub)
+	}
+}
+
+// sig writes a DER signature with a sighash type in 64 bytes and the type,
+// or bytes as they are if they aren't a canonical DER signature
+func (w *binWriter) sig(sig []byte) {
+	if compact, ok := compactSig(sig); ok {
+		w.byte(sigCompact)
+		w.buf.Write(compact)
+		return
+	}
+	w.byte(sigRaw)
+	w.bytes(sig)
+}
+
+func (w *binWriter) sigList(sigs [][]byte) {
+	w.varint(uint64(len(sigs)))
+	for _, sig := range sigs {
+		w.sig(sig)
+	}
+}
+
+func (w *binWriter) optUint32(v *uint32) {
+	w.bool(v != nil)
+	if v != nil {
+		w.uint32(*v)
+	}
+}
+
+func (w *binWriter) amtMap(amts map[Contractor]btcutil.Amount) {
+	cs := []Contractor{}
+	for c := range amts {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.amount(amts[c])
+	}
+}
+
+func (w *binWriter) conditions(conds *Conditions) {
+	if conds.NetParams == nil {
+		w.fail("network is required")
+		return
+	}
+	w.str(conds.NetParams.Name)
+	w.uint64(uint64(conds.FixingTime.Unix()))
+	w.amtMap(conds.FundAmts)
+	w.amount(conds.FundFeerate)
+	w.amount(conds.RedeemFeerate)
+	w.uint32(conds.RefundLockTime)
+	w.varint(uint64(len(conds.Deals)))
+	for _, deal := range conds.Deals {
+		if deal == nil {
+			w.fail("deal is nil")
+			return
+		}
+		w.amtMap(deal.Amts)
+		w.bytesList(deal.Msgs)
+	}
+	w.int(int(conds.FundMode))
+
+	p := conds.PremiumInfo
+	w.bool(p != nil)
+	if p == nil {
+		return
+	}
+	if p.PremiumDestAddress == nil {
+		w.fail("premium destination address is required")
+		return
+	}
+	w.str(p.PremiumDestAddress.EncodeAddress())
+	w.amount(p.PremiumAmount)
+	w.contractor(p.PayingParty)
+}
+
+func (w *binWriter) oracle(o *Oracle) {
+	w.bool(o != nil)
+	if o == nil {
+		return
+	}
+
+	w.bool(o.PubkeySet != nil)
+	if o.PubkeySet != nil {
+		w.pubkey(o.PubkeySet.Pubkey)
+		w.pubkeyList(o.PubkeySet.CommittedRpoints)
+	}
+	w.varint(uint64(len(o.RpointIdxs)))
+	for _, idx := range o.RpointIdxs {
+		w.int(idx)
+	}
+	w.pubkeyList(o.Commitments)
+	w.bytes(o.Sig)
+	w.bytesList(o.SignedMsgs)
+}
+
+func (w *binWriter) pubkeyMap(pubs map[Contractor]*btcec.PublicKey) {
+	cs := []Contractor{}
+	for c := range pubs {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.pubkey(pubs[c])
+	}
+}
+
+// addrMap writes addresses except nil ones
+func (w *binWriter) addrMap(addrs map[Contractor]btcutil.Address) {
+	cs := []Contractor{}
+	for c, addr := range addrs {
+		if addr != nil {
+			cs = append(cs, c)
+		}
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.str(addrs[c].EncodeAddress())
+	}
+}
+
+func (w *binWriter) utxos(utxos map[Contractor][]*Utxo) {
+	cs := []Contractor{}
+	for c := range utxos {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.varint(uint64(len(utxos[c])))
+		for _, utxo := range utxos[c] {
+			if utxo == nil {
+				w.fail("utxo is nil")
+				return
+			}
+			w.str(utxo.TxID)
+			w.uint32(utxo.Vout)
+			w.str(utxo.Address)
+			w.str(utxo.Account)
+			w.str(utxo.ScriptPubKey)
+			w.str(utxo.RedeemScript)
+			w.uint64(math.Float64bits(utxo.Amount))
+			w.uint64(uint64(utxo.Confirmations))
+			w.bool(utxo.Spendable)
+		}
+	}
+}
+
+func (w *binWriter) fundWits(wits map[Contractor][]wire.TxWitness) {
+	cs := []Contractor{}
+	for c := range wits {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.varint(uint64(len(wits[c])))
+		for _, wit := range wits[c] {
+			w.sigList(wit)
+		}
+	}
+}
+
+func (w *binWriter) sigMap(sigs map[Contractor][]byte) {
+	cs := []Contractor{}
+	for c := range sigs {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.sig(sigs[c])
+	}
+}
+
+func (w *binWriter) sigsMap(sigs map[Contractor][][]byte) {
+	cs := []Contractor{}
+	for c := range sigs {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.sigList(sigs[c])
+	}
+}
+
+func (w *binWriter) bytesListMap(bs map[Contractor][][]byte) {
+	cs := []Contractor{}
+	for c := range bs {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.bytesList(bs[c])
+	}
+}
+
+func (w *binWriter) buffer(buf *Buffer) {
+	w.bool(buf != nil)
+	if buf == nil {
+		return
+	}
+	w.uint32(buf.Index)
+	w.uint16(buf.Delay)
+	w.pubkeyMap(buf.Points)
+	w.bytes(buf.Secret)
+	w.sig(buf.Sig)
+	w.pubkey(buf.PendingPoint)
+	w.bytesList(buf.RevokedSecrets)
+}
+
+func (w *binWriter) nonces(nonces *MuSigNonces) {
+	w.bool(nonces != nil)
+	if nonces == nil {
+		return
+	}
+	cs := []Contractor{}
+	for c := range nonces.Pub {
+		cs = append(cs, c)
+	}
+	w.varint(uint64(len(cs)))
+	for _, c := range sortContractors(cs) {
+		w.contractor(c)
+		w.bytesListMap(nonces.Pub[c])
+	}
+	w.bytesListMap(nonces.Sec)
+}
+
+func sortContractors(cs []Contractor) []Contractor {
+	sort.Slice(cs, func(i, j int) bool { return cs[i] < cs[j] })
+	return cs
+}
+
+// compactSig converts a canonical DER signature followed by a sighash type
+// to its R and S in 32 bytes each and the sighash type
+func compactSig(sig []byte) ([]byte, bool) {
+	if len(sig) < 2 {
+		return nil, false
+	}
+	der := sig[:len(sig)-1]
+	s, err := btcec.ParseDERSignature(der, btcec.S256())
+	if err != nil || !bytes.Equal(s.Serialize(), der) {
+		return nil, false
+	}
+	compact := make([]byte, compactSigSize+1)
+	rb, sb := s.R.Bytes(), s.S.Bytes()
+	copy(compact[32-len(rb):32], rb)
+	copy(compact[64-len(sb):64], sb)
+	compact[compactSigSize] = sig[len(sig)-1]
+	return compact, true
+}
+
+// binReader reads values in the binary encoding,
+// keeping the first error
+type binReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *binReader) fail(format string, a ...interface{}) error {
+	if r.err == nil {
+		msg := fmt.Sprintf(format, a...)
+		r.err = &InvalidBinaryError{error: errors.New(msg)}
+	}
+	return r.err
+}
+
+func (r *binReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > r.r.Len() {
+		r.fail("unexpected end of data")
+		return nil
+	}
+	b := make([]byte, n)
+	r.r.Read(b)
+	return b
+}
+
+func (r *binReader) byte() byte {
+	b := r.read(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *binReader) bool() bool {
+	switch b := r.byte(); b {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		r.fail("invalid bool. %d", b)
+		return false
+	}
+}
+
+func (r *binReader) uint16() uint16 {
+	b := r.read(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *binReader) uint32() uint32 {
+	b := r.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *binReader) uint64() uint64 {
+	b := r.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *binReader) varint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := wire.ReadVarInt(r.r, 0)
+	if err != nil {
+		r.fail("invalid varint. %v", err)
+		return 0
+	}
+	return v
+}
+
+func (r *binReader) int() int {
+	v := r.varint()
+	if v > math.MaxInt32 {
+		r.fail("integer out of range. %d", v)
+		return 0
+	}
+	return int(v)
+}
+
+// count reads a length of a list, which can't exceed the rest of data
+func (r *binReader) count() int {
+	v := r.varint()
+	if v > uint64(r.r.Len()) {
+		r.fail("length exceeds data. %d", v)
+		return 0
+	}
+	return int(v)
+}
+
+func (r *binReader) contractor() Contractor {
+	return Contractor(r.int())
+}
+
+// nextContractor reads a contractor of a map, which must be
+// greater than the previous one
+func (r *binReader) nextContractor(i int, prev Contractor) Contractor {
+	c := r.contractor()
+	if i > 0 && c <= prev {
+		r.fail("contractors aren't in order")
+	}
+	return c
+}
+
+func (r *binReader) amount() btcutil.Amount {
+	v := r.varint()
+	if v > math.MaxInt64 {
+		r.fail("amount out of range. %d", v)
+		return 0
+	}
+	return btcutil.Amount(v)
+}
+
+func (r *binReader) bytes() []byte {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	return r.read(n)
+}
+
+func (r *binReader) str() string {
+	return string(r.bytes())
+}
+
+func (r *binReader) bytesList() [][]byte {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	bs := make([][]byte, n)
+	for i := range bs {
+		bs[i] = r.bytes()
+	}
+	return bs
+}
+
+func (r *binReader) pubkey() *btcec.PublicKey {
+	prefix := r.byte()
+	if r.err != nil || prefix == 0 {
+		return nil
+	}
+	if prefix != 0x02 && prefix != 0x03 {
+		r.fail("invalid pubkey prefix. %d", prefix)
+		return nil
+	}
+	x := r.read(32)
+	if x == nil {
+		return nil
+	}
+	pub, err := btcec.ParsePubKey(append([]byte{prefix}, x...), btcec.S256())
+	if err != nil {
+		r.fail("invalid pubkey. %v", err)
+		return nil
+	}
+	return pub
+}
+
+func (r *binReader) pubkeyList() []*btcec.PublicKey {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	pubs := make([]*btcec.PublicKey, n)
+	for i := range pubs {
+		pubs[i] = r.pubkey()
+	}
+	return pubs
+}
+
+func (r *binReader) sig() []byte {
+	switch tag := r.byte(); tag {
+	case sigRaw:
+		sig := r.bytes()
+		if _, ok := compactSig(sig); ok {
+			r.fail("compactable signature in raw format")
+			return nil
+		}
+		return sig
+	case sigCompact:
+		compact := r.read(compactSigSize + 1)
+		if compact == nil {
+			return nil
+		}
+		R := new(big.Int).SetBytes(compact[:32])
+		S := new(big.Int).SetBytes(compact[32:64])
+		N := btcec.S256().N
+		halfN := new(big.Int).Rsh(N, 1)
+		if R.Sign() == 0 || R.Cmp(N) >= 0 || S.Sign() == 0 || S.Cmp(halfN) > 0 {
+			r.fail("invalid compact signature")
+			return nil
+		}
+		s := &btcec.Signature{R: R, S: S}
+		return append(s.Serialize(), compact[compactSigSize])
+	default:
+		r.fail("invalid signature tag. %d", tag)
+		return nil
+	}
+}
+
+func (r *binReader) sigList() [][]byte {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	sigs := make([][]byte, n)
+	for i := range sigs {
+		sigs[i] = r.sig()
+	}
+	return sigs
+}
+
+func (r *binReader) optUint32() *uint32 {
+	if !r.bool() {
+		return nil
+	}
+	v := r.uint32()
+	return &v
+}
+
+func (r *binReader) amtMap() map[Contractor]btcutil.Amount {
+	amts := make(map[Contractor]btcutil.Amount)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		amts[c] = r.amount()
+	}
+	return amts
+}
+
+func (r *binReader) conditions() *Conditions {
+	net, err := strToNetParams(r.str())
+	if r.err != nil {
+		return nil
+	}
+	if err != nil {
+		r.fail("%v", err)
+		return nil
+	}
+
+	conds := &Conditions{NetParams: net}
+	conds.FixingTime = time.Unix(int64(r.uint64()), 0).UTC()
+	conds.FundAmts = r.amtMap()
+	conds.FundFeerate = r.amount()
+	conds.RedeemFeerate = r.amount()
+	conds.RefundLockTime = r.uint32()
+	conds.Deals = []*Deal{}
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		deal := &Deal{Amts: r.amtMap(), Msgs: r.bytesList()}
+		conds.Deals = append(conds.Deals, deal)
+	}
+	conds.FundMode = FundMode(r.int())
+
+	if !r.bool() {
+		return conds
+	}
+	addr, err := script.DecodeAddress(r.str(), net)
+	if r.err == nil && err != nil {
+		r.fail("invalid premium destination address. %v", err)
+	}
+	conds.PremiumInfo = &PremiumInfo{
+		PremiumDestAddress: addr,
+		PremiumAmount:      r.amount(),
+		PayingParty:        r.contractor(),
+	}
+	return conds
+}
+
+func (r *binReader) oracle() *Oracle {
+	if !r.bool() {
+		return nil
+	}
+
+	o := &Oracle{}
+	if r.bool() {
+		o.PubkeySet = &oracle.PubkeySet{
+			Pubkey:           r.pubkey(),
+			CommittedRpoints: r.pubkeyList(),
+		}
+	}
+	if n := r.count(); n > 0 {
+		o.RpointIdxs = make([]int, n)
+		for i := range o.RpointIdxs {
+			o.RpointIdxs[i] = r.int()
+		}
+	}
+	// commitments are allocated as NewOracle does
+	o.Commitments = r.pubkeyList()
+	if o.Commitments == nil {
+		o.Commitments = []*btcec.PublicKey{}
+	}
+	o.Sig = r.bytes()
+	o.SignedMsgs = r.bytesList()
+	return o
+}
+
+func (r *binReader) pubkeyMap() map[Contractor]*btcec.PublicKey {
+	pubs := make(map[Contractor]*btcec.PublicKey)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		pubs[c] = r.pubkey()
+	}
+	return pubs
+}
+
+func (r *binReader) addrMap(conds *Conditions) map[Contractor]btcutil.Address {
+	addrs := make(map[Contractor]btcutil.Address)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		addr, err := script.DecodeAddress(r.str(), conds.NetParams)
+		if r.err == nil && err != nil {
+			r.fail("invalid address. %v", err)
+		}
+		addrs[c] = addr
+	}
+	return addrs
+}
+
+func (r *binReader) utxos() map[Contractor][]*Utxo {
+	utxos := make(map[Contractor][]*Utxo)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		var us []*Utxo
+		for j, m := 0, r.count(); j < m && r.err == nil; j++ {
+			us = append(us, &Utxo{
+				TxID:          r.str(),
+				Vout:          r.uint32(),
+				Address:       r.str(),
+				Account:       r.str(),
+				ScriptPubKey:  r.str(),
+				RedeemScript:  r.str(),
+				Amount:        math.Float64frombits(r.uint64()),
+				Confirmations: int64(r.uint64()),
+				Spendable:     r.bool(),
+			})
+		}
+		utxos[c] = us
+	}
+	return utxos
+}
+
+func (r *binReader) fundWits() map[Contractor][]wire.TxWitness {
+	wits := make(map[Contractor][]wire.TxWitness)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		var ws []wire.TxWitness
+		for j, m := 0, r.count(); j < m && r.err == nil; j++ {
+			ws = append(ws, r.sigList())
+		}
+		wits[c] = ws
+	}
+	return wits
+}
+
+func (r *binReader) sigMap() map[Contractor][]byte {
+	sigs := make(map[Contractor][]byte)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		sigs[c] = r.sig()
+	}
+	return sigs
+}
+
+func (r *binReader) sigsMap() map[Contractor][][]byte {
+	sigs := make(map[Contractor][][]byte)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		sigs[c] = r.sigList()
+	}
+	return sigs
+}
+
+func (r *binReader) bytesListMap() map[Contractor][][]byte {
+	bs := make(map[Contractor][][]byte)
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		bs[c] = r.bytesList()
+	}
+	return bs
+}
+
+func (r *binReader) buffer() *Buffer {
+	if !r.bool() {
+		return nil
+	}
+	return &Buffer{
+		Index:          r.uint32(),
+		Delay:          r.uint16(),
+		Points:         r.pubkeyMap(),
+		Secret:         r.bytes(),
+		Sig:            r.sig(),
+		PendingPoint:   r.pubkey(),
+		RevokedSecrets: r.bytesList(),
+	}
+}
+
+func (r *binReader) nonces() *MuSigNonces {
+	if !r.bool() {
+		return nil
+	}
+	nonces := &MuSigNonces{Pub: make(map[Contractor]map[Contractor][][]byte)}
+	var c Contractor
+	for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+		c = r.nextContractor(i, c)
+		nonces.Pub[c] = r.bytesListMap()
+	}
+	nonces.Sec = r.bytesListMap()
+	return nonces
+}
@@ -0,0 +1,432 @@
+package dlc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/oracle"
+	"github.com/stretchr/testify/assert"
+)
+
+// dlcJSON is the JSON form of DLC, which dlcmgr used to store
+type dlcJSON struct {
+	Conds       *Conditions                     `json:"conds"`
+	Oracle      json.RawMessage                 `json:"oracle"`
+	Pubs        PublicKeys                      `json:"pubkeys"`
+	Addrs       Addresses                       `json:"addrs"`
+	ChangeAddrs Addresses                       `json:"chaddrs"`
+	Utxos       map[Contractor][]*Utxo          `json:"utxos"`
+	FundWits    map[Contractor][]wire.TxWitness `json:"fundwits"`
+	RefundSigs  map[Contractor][]byte           `json:"refundsigs"`
+	ExecSigs    map[Contractor][][]byte         `json:"execsigs"`
+	Buffer      *Buffer                         `json:"buffer"`
+	Nonces      *MuSigNonces                    `json:"nonces"`
+	FundKeyIdx  *uint32                         `json:"fundkeyidx"`
+}
+
+func toJSONForm(t *testing.T, d *DLC) []byte {
+	o, err := json.Marshal(d.Oracle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(&dlcJSON{
+		Conds:       d.Conds,
+		Oracle:      o,
+		Pubs:        d.PublicKeys(),
+		Addrs:       d.Addresses(),
+		ChangeAddrs: d.ChangeAddresses(),
+		Utxos:       d.Utxos,
+		FundWits:    d.FundWits,
+		RefundSigs:  d.RefundSigs,
+		ExecSigs:    d.ExecSigs,
+		Buffer:      d.Buffer,
+		Nonces:      d.Nonces,
+		FundKeyIdx:  d.FundKeyIdx,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func fromJSONForm(t *testing.T, data []byte) *DLC {
+	dj := &dlcJSON{}
+	if err := json.Unmarshal(data, dj); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDLC(dj.Conds)
+	if !bytes.Equal(dj.Oracle, []byte("null")) {
+		if err := json.Unmarshal(dj.Oracle, d.Oracle); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		d.Oracle = nil
+	}
+	if err := d.ParsePublicKeys(dj.Pubs); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ParseAddresses(dj.Addrs); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ParseChangeAddresses(dj.ChangeAddrs); err != nil {
+		t.Fatal(err)
+	}
+	d.Utxos = dj.Utxos
+	d.FundWits = dj.FundWits
+	d.RefundSigs = dj.RefundSigs
+	d.ExecSigs = dj.ExecSigs
+	d.Buffer = dj.Buffer
+	d.Nonces = dj.Nonces
+	d.FundKeyIdx = dj.FundKeyIdx
+	return d
+}
+
+// dlcGen generates random contracts
+type dlcGen struct {
+	*rand.Rand
+}
+
+func (g *dlcGen) bytes(n int) []byte {
+	b := make([]byte, n)
+	g.Read(b)
+	return b
+}
+
+func (g *dlcGen) priv() *btcec.PrivateKey {
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), g.bytes(32))
+	return priv
+}
+
+func (g *dlcGen) pub() *btcec.PublicKey {
+	return g.priv().PubKey()
+}
+
+func (g *dlcGen) addr() btcutil.Address {
+	h := btcutil.Hash160(g.pub().SerializeCompressed())
+	addr, _ := btcutil.NewAddressWitnessPubKeyHash(h, &chaincfg.RegressionNetParams)
+	return addr
+}
+
+func (g *dlcGen) amt() btcutil.Amount {
+	return btcutil.Amount(g.Int63n(btcutil.MaxSatoshi))
+}
+
+// sig returns a DER signature with sighash type or a schnorr signature
+func (g *dlcGen) sig() []byte {
+	if g.Intn(4) == 0 {
+		return g.bytes(64)
+	}
+	s, _ := g.priv().Sign(g.bytes(32))
+	return append(s.Serialize(), byte(txscript.SigHashAll))
+}
+
+func (g *dlcGen) sigs(n int) [][]byte {
+	sigs := [][]byte{}
+	for i := 0; i < n; i++ {
+		sigs = append(sigs, g.sig())
+	}
+	return sigs
+}
+
+func (g *dlcGen) bytesList(n, size int) [][]byte {
+	bs := [][]byte{}
+	for i := 0; i < n; i++ {
+		bs = append(bs, g.bytes(1+g.Intn(size)))
+	}
+	return bs
+}
+
+func (g *dlcGen) dlc(nDeals int) *DLC {
+	parties := []Contractor{FirstParty, SecondParty}
+
+	conds := &Conditions{
+		NetParams:      &chaincfg.RegressionNetParams,
+		FixingTime:     time.Unix(g.Int63n(1<<32), 0).UTC(),
+		FundAmts:       map[Contractor]btcutil.Amount{},
+		FundFeerate:    btcutil.Amount(1 + g.Intn(500)),
+		RedeemFeerate:  btcutil.Amount(1 + g.Intn(500)),
+		RefundLockTime: g.Uint32(),
+		Deals:          []*Deal{},
+		FundMode:       FundMode(g.Intn(2)),
+		DustLimit:      btcutil.Amount(g.Intn(1000)),
+	}
+	for _, p := range parties {
+		conds.FundAmts[p] = g.amt()
+	}
+	for i := 0; i < nDeals; i++ {
+		deal := &Deal{
+			Amts: map[Contractor]btcutil.Amount{},
+			Msgs: g.bytesList(1+g.Intn(3), 4),
+		}
+		for _, p := range parties {
+			deal.Amts[p] = g.amt()
+		}
+		conds.Deals = append(conds.Deals, deal)
+	}
+	if g.Intn(2) == 0 {
+		conds.PremiumInfo = &PremiumInfo{
+			PremiumDestAddress: g.addr(),
+			PremiumAmount:      g.amt(),
+			PayingParty:        SecondParty,
+		}
+	}
+
+	d := NewDLC(conds)
+	if g.Intn(2) == 0 {
+		d.Oracle.PubkeySet = &oracle.PubkeySet{
+			Pubkey:           g.pub(),
+			CommittedRpoints: []*btcec.PublicKey{g.pub(), g.pub()},
+		}
+		d.Oracle.RpointIdxs = []int{0, 1}
+	}
+	for i := range d.Oracle.Commitments {
+		d.Oracle.Commitments[i] = g.pub()
+	}
+	if g.Intn(2) == 0 {
+		d.Oracle.Sig = g.bytes(32)
+		d.Oracle.SignedMsgs = g.bytesList(2, 4)
+	}
+
+	for _, p := range parties {
+		d.Pubs[p] = g.pub()
+		d.Addrs[p] = g.addr()
+		d.ChangeAddrs[p] = g.addr()
+		for i := 0; i < 1+g.Intn(3); i++ {
+			d.Utxos[p] = append(d.Utxos[p], &Utxo{
+				TxID:          chainhash.Hash(chainhash.HashH(g.bytes(8))).String(),
+				Vout:          g.Uint32(),
+				Address:       g.addr().EncodeAddress(),
+				ScriptPubKey:  hex.EncodeToString(g.bytes(22)),
+				Amount:        g.amt().ToBTC(),
+				Confirmations: g.Int63n(1000),
+				Spendable:     g.Intn(2) == 0,
+			})
+			d.FundWits[p] = append(d.FundWits[p],
+				wire.TxWitness{g.sig(), g.pub().SerializeCompressed()})
+		}
+		d.RefundSigs[p] = g.sig()
+		d.ExecSigs[p] = g.sigs(nDeals)
+	}
+
+	if g.Intn(2) == 0 {
+		d.Buffer = &Buffer{
+			Index:          g.Uint32(),
+			Delay:          uint16(g.Intn(1 << 16)),
+			Points:         map[Contractor]*btcec.PublicKey{},
+			Secret:         g.bytes(32),
+			Sig:            g.sig(),
+			PendingPoint:   g.pub(),
+			RevokedSecrets: g.bytesList(2, 32),
+		}
+		for _, p := range parties {
+			d.Buffer.Points[p] = g.pub()
+		}
+	}
+	if g.Intn(2) == 0 {
+		d.Nonces = &MuSigNonces{
+			Pub: map[Contractor]map[Contractor][][]byte{},
+			Sec: map[Contractor][][]byte{},
+		}
+		for _, p := range parties {
+			d.Nonces.Pub[p] = map[Contractor][][]byte{}
+			for _, owner := range parties {
+				d.Nonces.Pub[p][owner] = g.bytesList(nDeals, 66)
+			}
+			d.Nonces.Sec[p] = g.bytesList(nDeals, 64)
+		}
+	}
+	if g.Intn(2) == 0 {
+		idx := g.Uint32()
+		d.FundKeyIdx = &idx
+	}
+	return d
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	g := &dlcGen{rand.New(rand.NewSource(1))}
+
+	for i := 0; i < 50; i++ {
+		d := g.dlc(1 + g.Intn(10))
+
+		data, err := d.MarshalBinary()
+		if !assert.NoError(err) {
+			return
+		}
+		decoded := &DLC{}
+		if !assert.NoError(decoded.UnmarshalBinary(data)) {
+			return
+		}
+		assert.Equal(d, decoded)
+		assert.Equal(toJSONForm(t, d), toJSONForm(t, decoded))
+
+		// deterministic
+		again, err := decoded.MarshalBinary()
+		assert.NoError(err)
+		assert.Equal(data, again)
+	}
+}
+
+func TestBinaryAgainstJSON(t *testing.T) {
+	assert := assert.New(t)
+	g := &dlcGen{rand.New(rand.NewSource(2))}
+
+	for i := 0; i < 50; i++ {
+		d := g.dlc(1 + g.Intn(10))
+		data, err := d.MarshalBinary()
+		assert.NoError(err)
+
+		// a contract restored from JSON has the same binary encoding
+		fromJSON := fromJSONForm(t, toJSONForm(t, d))
+		data2, err := fromJSON.MarshalBinary()
+		assert.NoError(err)
+		assert.Equal(data, data2)
+	}
+}
+
+func TestBinaryCompactSigs(t *testing.T) {
+	assert := assert.New(t)
+	g := &dlcGen{rand.New(rand.NewSource(3))}
+
+	s, _ := g.priv().Sign(g.bytes(32))
+	sig := append(s.Serialize(), byte(txscript.SigHashAll))
+	w := &binWriter{buf: &bytes.Buffer{}}
+	w.sig(sig)
+	assert.Equal(1+compactSigSize+1, w.buf.Len())
+	r := &binReader{r: bytes.NewReader(w.buf.Bytes())}
+	assert.Equal(sig, r.sig())
+	assert.NoError(r.err)
+
+	// signature with high S is kept as it is
+	highS := &btcec.Signature{R: s.R, S: new(big.Int).Sub(btcec.S256().N, s.S)}
+	sig = append(highSDER(highS), byte(txscript.SigHashAll))
+	w = &binWriter{buf: &bytes.Buffer{}}
+	w.sig(sig)
+	assert.Equal(sigRaw, w.buf.Bytes()[0])
+	r = &binReader{r: bytes.NewReader(w.buf.Bytes())}
+	assert.Equal(sig, r.sig())
+	assert.NoError(r.err)
+}
+
+// highSDER serializes a signature in DER without making S low
+func highSDER(s *btcec.Signature) []byte {
+	rb := canonicalPad(s.R.Bytes())
+	sb := canonicalPad(s.S.Bytes())
+	b := []byte{0x30, byte(4 + len(rb) + len(sb)), 0x02, byte(len(rb))}
+	b = append(b, rb...)
+	b = append(b, 0x02, byte(len(sb)))
+	return append(b, sb...)
+}
+
+func canonicalPad(b []byte) []byte {
+	if b[0]&0x80 != 0 {
+		return append([]byte{0}, b...)
+	}
+	return b
+}
+
+func TestBinarySize(t *testing.T) {
+	assert := assert.New(t)
+	g := &dlcGen{rand.New(rand.NewSource(4))}
+
+	// a numeric contract with signatures of many CETxs
+	d := g.dlc(1000)
+	d.Nonces = nil
+	data, err := d.MarshalBinary()
+	assert.NoError(err)
+	assert.True(len(data)*3 < len(toJSONForm(t, d))*2,
+		"binary: %d, json: %d", len(data), len(toJSONForm(t, d)))
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	assert := assert.New(t)
+	g := &dlcGen{rand.New(rand.NewSource(5))}
+	data, _ := g.dlc(3).MarshalBinary()
+
+	invalids := map[string][]byte{
+		"empty":          {},
+		"version":        append([]byte{binaryVersion + 1}, data[1:]...),
+		"truncated":      data[:len(data)-1],
+		"trailing bytes": append(append([]byte{}, data...), 0x00),
+	}
+	for name, invalid := range invalids {
+		err := (&DLC{}).UnmarshalBinary(invalid)
+		assert.IsType(&InvalidBinaryError{}, err, name)
+	}
+
+	// contractors of a map must be in order
+	w := &binWriter{buf: &bytes.Buffer{}}
+	w.varint(2)
+	w.contractor(SecondParty)
+	w.amount(1)
+	w.contractor(FirstParty)
+	w.amount(1)
+	r := &binReader{r: bytes.NewReader(w.buf.Bytes())}
+	r.amtMap()
+	assert.IsType(&InvalidBinaryError{}, r.err)
+}
+
+// contracts encoded before the dust limit is added have no dust limit
+func TestUnmarshalBinaryVersion1(t *testing.T) {
+	assert := assert.New(t)
+	g := &dlcGen{rand.New(rand.NewSource(6))}
+	d := g.dlc(3)
+	d.Conds.DustLimit = 546
+	data, err := d.MarshalBinary()
+	assert.NoError(err)
+
+	// version 1 doesn't have the trailing dust limit
+	w := &binWriter{buf: &bytes.Buffer{}}
+	w.amount(d.Conds.DustLimit)
+	v1 := append([]byte{1}, data[1:len(data)-w.buf.Len()]...)
+
+	decoded := &DLC{}
+	if !assert.NoError(decoded.UnmarshalBinary(v1)) {
+		return
+	}
+	assert.Equal(btcutil.Amount(0), decoded.Conds.DustLimit)
+	decoded.Conds.DustLimit = d.Conds.DustLimit
+	assert.Equal(d, decoded)
+}
+
+// TestBinaryFuzz decodes mutated encodings, which must not panic.
+// Decoded ones must be encoded into the same bytes.
+func TestBinaryFuzz(t *testing.T) {
+	g := &dlcGen{rand.New(rand.NewSource(6))}
+
+	for i := 0; i < 20; i++ {
+		data, _ := g.dlc(1 + g.Intn(3)).MarshalBinary()
+		for j := 0; j < 200; j++ {
+			mutated := append([]byte{}, data...)
+			switch g.Intn(3) {
+			case 0:
+				mutated[g.Intn(len(mutated))] ^= byte(1 + g.Intn(255))
+			case 1:
+				mutated = mutated[:g.Intn(len(mutated))]
+			case 2:
+				at := g.Intn(len(mutated))
+				mutated = append(mutated[:at],
+					append(g.bytes(1+g.Intn(4)), mutated[at:]...)...)
+			}
+			d := &DLC{}
+			if d.UnmarshalBinary(mutated) != nil {
+				continue
+			}
+			encoded, err := d.MarshalBinary()
+			if err != nil || !bytes.Equal(mutated, encoded) {
+				t.Fatalf("decoded but encoded differently. %x", mutated)
+			}
+		}
+	}
+}
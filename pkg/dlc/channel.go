@@ -0,0 +1,530 @@
+package dlc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+)
+
+// bufferTxOutAt is a txout index of buffer tx
+const bufferTxOutAt = 0
+
+// Buffer is a revocable state of DLC channel.
+// A contract in a channel state redeems a buffer tx instead of fund tx,
+// and the buffer tx spends fund tx.
+// Each party has its own buffer tx that can be punished by the counterparty
+// after the state is revoked.
+type Buffer struct {
+	Index  uint32                          // state index
+	Delay  uint16                          // delay of buffer script
+	Points map[Contractor]*btcec.PublicKey // revocation points of the state
+	Secret []byte                          // own revocation secret of the state
+	Sig    []byte                          // counterparty's signature for own buffer tx
+
+	// PendingPoint is a counterparty's revocation point of the previous state
+	// whose secret hasn't been revealed yet
+	PendingPoint *btcec.PublicKey
+	// RevokedSecrets are counterparty's revocation secrets of revoked states
+	RevokedSecrets [][]byte
+}
+
+// NewBuffer creates a buffer state with a new revocation secret
+func NewBuffer(idx uint32, delay uint16) (*Buffer, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return &Buffer{
+		Index:  idx,
+		Delay:  delay,
+		Points: make(map[Contractor]*btcec.PublicKey),
+		Secret: secret,
+	}, nil
+}
+
+// RevokedStateError is raised when a revocation secret is invalid
+type RevokedStateError struct{ error }
+
+// OpenChannelError is raised when a contract can't be opened as a channel
+type OpenChannelError struct{ error }
+
+// OpenChannel turns the contract into the opening state of a channel
+// by putting a buffer tx between the fund tx and the CETs,
+// so that the CETs of the opening state are revocable as well.
+// It has to be called before exchanging CETx signatures.
+// Revocation points and buffer tx signatures have to be exchanged
+// as new channel states.
+// Channels aren't supported by watch-only wallets.
+func (b *Builder) OpenChannel() error {
+	d := b.Contract
+	if !d.Conds.isTwoParty() {
+		return &OpenChannelError{
+			error: errors.New("channel is only for two-party contracts")}
+	}
+	if d.Conds.isTaproot() {
+		return &OpenChannelError{
+			error: errors.New("channel isn't supported in taproot fund mode")}
+	}
+	if d.Buffer != nil {
+		return &OpenChannelError{error: errors.New("contract is already in channel")}
+	}
+	// penalty txs are signed by the privkey tweaked with revocation secrets
+	if b.wallet.WatchOnly() {
+		return &OpenChannelError{error: errors.New(
+			"watch-only wallet can't punish revoked channel states")}
+	}
+	// CETs spending fund tx directly can't be revoked
+	for _, sigs := range d.ExecSigs {
+		for _, sig := range sigs {
+			if sig != nil {
+				return &OpenChannelError{error: errors.New(
+					"channel must be opened before exchanging CETx signatures")}
+			}
+		}
+	}
+
+	buf, err := NewBuffer(0, uint16(script.BufferDelay))
+	if err != nil {
+		return err
+	}
+	if err = setBuffer(b.party, d, buf); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewChannelState creates a builder of a new channel state
+// that carries a contract with given conditions on the fund output
+// of the current contract, which must be opened by OpenChannel.
+// The counterparty's revocation secret of the previous state
+// has to be accepted before creating the next one.
+// Revocation points, buffer tx signatures and CETx signatures
+// have to be exchanged using the returned builder.
+// The current state is kept as it is until SwitchChannelState is called.
+func (b *Builder) NewChannelState(conds *Conditions) (*Builder, error) {
+	d := b.Contract
+	if d.Buffer == nil {
+		return nil, errors.New("contract isn't in channel. open channel first")
+	}
+	if !conds.isTwoParty() {
+		return nil, errors.New("channel is only for two-party contracts")
+	}
+	if conds.isTaproot() {
+		return nil, errors.New("channel isn't supported in taproot fund mode")
+	}
+	// the previous state could never be revoked
+	// if its revocation point were overwritten
+	if d.Buffer.PendingPoint != nil {
+		return nil, &RevokedStateError{error: errors.New(
+			"revocation secret of the previous state hasn't been accepted")}
+	}
+
+	nb, err := b.Rollover(conds)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := NewBuffer(d.Buffer.Index+1, d.Buffer.Delay)
+	if err != nil {
+		return nil, err
+	}
+	buf.PendingPoint = d.Buffer.Points[counterparty(b.party)]
+	buf.RevokedSecrets = d.Buffer.RevokedSecrets
+	if err = setBuffer(b.party, nb.Contract, buf); err != nil {
+		return nil, err
+	}
+
+	return nb, nil
+}
+
+// setBuffer sets a buffer state with own revocation point to a contract
+// if the buffer output can pay all deals
+func setBuffer(p Contractor, d *DLC, buf *Buffer) error {
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), buf.Secret)
+	buf.Points[p] = priv.PubKey()
+
+	// buffer tx fee is paid by deals
+	famt, err := d.fundAmount()
+	if err != nil {
+		return err
+	}
+	bufamt := famt - d.bufferTxFee()
+	for _, deal := range d.Conds.Deals {
+		if total := deal.Amts[FirstParty] + deal.Amts[SecondParty]; total > bufamt {
+			msg := fmt.Sprintf(
+				"deal amounts exceed buffer output. deal: %d, buffer: %d", total, bufamt)
+			return errors.New(msg)
+		}
+	}
+
+	d.Buffer = buf
+	return nil
+}
+
+// RevocationPoint returns own revocation point of the state (compressed)
+func (b *Builder) RevocationPoint() ([]byte, error) {
+	buf := b.Contract.Buffer
+	if buf == nil {
+		return nil, errors.New("contract isn't in channel")
+	}
+	return buf.Points[b.party].SerializeCompressed(), nil
+}
+
+// AcceptRevocationPoint accepts counterparty's revocation point of the state
+func (b *Builder) AcceptRevocationPoint(point []byte) error {
+	buf := b.Contract.Buffer
+	if buf == nil {
+		return errors.New("contract isn't in channel")
+	}
+	pub, err := btcec.ParsePubKey(point, btcec.S256())
+	if err != nil {
+		return err
+	}
+	buf.Points[counterparty(b.party)] = pub
+	return nil
+}
+
+// BufferTx constructs a buffer tx of a given party
+//
+// txins:
+//   [0]:fund transaction output[0]
+// txouts:
+//   [0]:buffer script
+func (d *DLC) BufferTx(p Contractor) (*wire.MsgTx, error) {
+	if d.Buffer == nil {
+		return nil, errors.New("contract isn't in channel")
+	}
+
+	sc, err := d.bufferScript(p)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := script.P2WSHpkScript(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	fundtx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	in := btcutil.Amount(fundtx.TxOut[fundTxOutAt].Value)
+	fee := d.bufferTxFee()
+	if in <= fee {
+		return nil, newNotEnoughFeesError(in, fee)
+	}
+
+	tx, err := d.newRedeemTx()
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(in-fee), pkScript))
+
+	return tx, nil
+}
+
+// bufferScript returns a buffer script of a given party.
+// The revocation pubkey is derived from the counterparty's pubkey
+// and the party's revocation point.
+func (d *DLC) bufferScript(p Contractor) ([]byte, error) {
+	point := d.Buffer.Points[p]
+	if point == nil {
+		return nil, fmt.Errorf("missing revocation point of %s", p)
+	}
+	return d.bufferScriptWithPoint(p, point)
+}
+
+func (d *DLC) bufferScriptWithPoint(
+	p Contractor, point *btcec.PublicKey) ([]byte, error) {
+	pub1, pub2 := d.Pubs[FirstParty], d.Pubs[SecondParty]
+	if pub1 == nil || pub2 == nil {
+		return nil, errors.New("missing pubkey")
+	}
+
+	revpub := script.RevocationPubkey(d.Pubs[counterparty(p)], point)
+	return script.BufferScript(revpub, pub1, pub2, d.Buffer.Delay)
+}
+
+// SignBufferTx signs the counterparty's buffer tx
+func (b *Builder) SignBufferTx() ([]byte, error) {
+	tx, err := b.Contract.BufferTx(counterparty(b.party))
+	if err != nil {
+		return nil, err
+	}
+	return b.witsigForFundScript(tx)
+}
+
+// AcceptBufferTxSignature verifies and accepts the counterparty's signature
+// for own buffer tx
+func (b *Builder) AcceptBufferTxSignature(sig []byte) error {
+	d := b.Contract
+	tx, err := d.BufferTx(b.party)
+	if err != nil {
+		return err
+	}
+
+	cparty := counterparty(b.party)
+	err = d.verifyFundScriptSignature(tx, sig, d.Pubs[cparty])
+	if err != nil {
+		return err
+	}
+
+	d.Buffer.Sig = sig
+	return nil
+}
+
+// SignedBufferTx returns own buffer tx signed by both parties
+func (b *Builder) SignedBufferTx() (*wire.MsgTx, error) {
+	d := b.Contract
+	tx, err := d.BufferTx(b.party)
+	if err != nil {
+		return nil, err
+	}
+	if d.Buffer.Sig == nil {
+		return nil, errors.New("missing counterparty's signature for buffer tx")
+	}
+
+	sig, err := b.witsigForFundScript(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := map[Contractor][]byte{
+		b.party: sig, counterparty(b.party): d.Buffer.Sig}
+	wit, err := d.witnessForFundScript(sigs)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[fundTxInAt].Witness = wit
+
+	return tx, nil
+}
+
+// SwitchChannelState switches the contract to a given new channel state
+// and returns own revocation secret of the previous state,
+// which has to be sent to the counterparty.
+// It fails and keeps the current state if the new state doesn't have
+// the valid buffer tx signature and all valid CETx signatures
+// from the counterparty.
+func (b *Builder) SwitchChannelState(next *DLC) ([]byte, error) {
+	if b.Contract.Buffer == nil || next.Buffer == nil {
+		return nil, errors.New("contract isn't in channel")
+	}
+
+	fundtx, err := b.Contract.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	nextFundtx, err := next.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	if fundtx.TxHash() != nextFundtx.TxHash() {
+		return nil, errors.New("channel state must spend the same fund output")
+	}
+
+	if next.Buffer.Sig == nil {
+		return nil, &RolloverIncompleteError{
+			error: errors.New("missing buffer tx signature")}
+	}
+	buftx, err := next.BufferTx(b.party)
+	if err != nil {
+		return nil, err
+	}
+	cparty := counterparty(b.party)
+	err = next.verifyFundScriptSignature(buftx, next.Buffer.Sig, next.Pubs[cparty])
+	if err != nil {
+		return nil, fmt.Errorf("invalid buffer tx signature. %v", err)
+	}
+	if err = next.verifyCETxSignatureSet(b.party); err != nil {
+		return nil, err
+	}
+
+	secret := b.Contract.Buffer.Secret
+	b.Contract = next
+	return secret, nil
+}
+
+// AcceptRevocationSecret accepts the counterparty's revocation secret
+// of the previous state
+func (b *Builder) AcceptRevocationSecret(secret []byte) error {
+	buf := b.Contract.Buffer
+	if buf == nil || buf.PendingPoint == nil {
+		return &RevokedStateError{
+			error: errors.New("no state to be revoked")}
+	}
+
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), secret)
+	if !priv.PubKey().IsEqual(buf.PendingPoint) {
+		return &RevokedStateError{
+			error: errors.New("invalid revocation secret")}
+	}
+
+	buf.RevokedSecrets = append(buf.RevokedSecrets, secret)
+	buf.PendingPoint = nil
+	return nil
+}
+
+// PenaltyTx constructs a tx that takes all fund from a revoked buffer tx
+// broadcasted by the counterparty
+func (b *Builder) PenaltyTx(revoked *wire.MsgTx) (*wire.MsgTx, error) {
+	d := b.Contract
+	if d.Buffer == nil {
+		return nil, errors.New("contract isn't in channel")
+	}
+	if len(revoked.TxOut) <= bufferTxOutAt {
+		return nil, errors.New("invalid buffer tx")
+	}
+	txout := revoked.TxOut[bufferTxOutAt]
+
+	cparty := counterparty(b.party)
+	for _, secret := range d.Buffer.RevokedSecrets {
+		priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), secret)
+		sc, err := d.bufferScriptWithPoint(cparty, priv.PubKey())
+		if err != nil {
+			return nil, err
+		}
+		pkScript, err := script.P2WSHpkScript(sc)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(pkScript, txout.PkScript) {
+			continue
+		}
+
+		tx := wire.NewMsgTx(txVersion)
+		txid := revoked.TxHash()
+		tx.AddTxIn(wire.NewTxIn(
+			wire.NewOutPoint(&txid, bufferTxOutAt), nil, nil))
+
+		in := btcutil.Amount(txout.Value)
+		fee := d.penaltyTxFee()
+		if in <= fee {
+			return nil, newNotEnoughFeesError(in, fee)
+		}
+		out, err := d.distTxOut(b.party, in-fee)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(out)
+
+		sig, err := b.wallet.WitnessSignatureWithCallback(
+			tx, 0, in, sc, d.Pubs[b.party], genRevocationPrivkeyFunc(secret))
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[0].Witness = script.WitnessForBufferScriptRevoked(sig, sc)
+
+		return tx, nil
+	}
+
+	return nil, errors.New("tx isn't a revoked buffer tx")
+}
+
+// genRevocationPrivkeyFunc returns a converter from own private key
+// to the revocation private key of the counterparty's buffer tx
+// revoked by a given secret
+func genRevocationPrivkeyFunc(secret []byte) wallet.PrivateKeyConverter {
+	return func(priv *btcec.PrivateKey) (*btcec.PrivateKey, error) {
+		return script.RevocationPrivkey(priv, secret), nil
+	}
+}
+
+// redeemTxIn is a txin that CETxs of a given party redeem.
+// It's fund tx output for a contract, and the party's buffer tx output
+// for a contract in channel.
+type redeemTxIn struct {
+	outpoint *wire.OutPoint
+	amt      btcutil.Amount
+	script   []byte
+	sequence uint32
+}
+
+func (d *DLC) redeemTxIn(p Contractor) (*redeemTxIn, error) {
+	if d.Buffer == nil {
+		fundtx, err := d.FundTx()
+		if err != nil {
+			return nil, err
+		}
+		// taproot fund output is spent by key path without script
+		var fs []byte
+		if !d.Conds.isTaproot() {
+			fs, err = d.fundScript()
+			if err != nil {
+				return nil, err
+			}
+		}
+		txid := fundtx.TxHash()
+		return &redeemTxIn{
+			outpoint: wire.NewOutPoint(&txid, fundTxOutAt),
+			amt:      btcutil.Amount(fundtx.TxOut[fundTxOutAt].Value),
+			script:   fs,
+			sequence: wire.MaxTxInSequenceNum,
+		}, nil
+	}
+
+	buftx, err := d.BufferTx(p)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := d.bufferScript(p)
+	if err != nil {
+		return nil, err
+	}
+	txid := buftx.TxHash()
+	return &redeemTxIn{
+		outpoint: wire.NewOutPoint(&txid, bufferTxOutAt),
+		amt:      btcutil.Amount(buftx.TxOut[bufferTxOutAt].Value),
+		script:   sc,
+		sequence: uint32(d.Buffer.Delay),
+	}, nil
+}
+
+// newRedeemTxByParty creates a new tx that redeems fund tx,
+// or the party's buffer tx if the contract is in channel
+func (d *DLC) newRedeemTxByParty(p Contractor) (*wire.MsgTx, error) {
+	in, err := d.redeemTxIn(p)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(txVersion)
+	txin := wire.NewTxIn(in.outpoint, nil, nil)
+	txin.Sequence = in.sequence
+	tx.AddTxIn(txin)
+
+	return tx, nil
+}
+
+// witsigForRedeemTx returns signature for a tx that redeems the txin of a given party
+func (b *Builder) witsigForRedeemTx(p Contractor, tx *wire.MsgTx) ([]byte, error) {
+	in, err := b.Contract.redeemTxIn(p)
+	if err != nil {
+		return nil, err
+	}
+	pub := b.Contract.Pubs[b.party]
+	return b.wallet.WitnessSignature(tx, fundTxInAt, in.amt, in.script, pub)
+}
+
+// witnessForRedeemTx constructs a witness for a tx redeeming the txin of a given party
+func (d *DLC) witnessForRedeemTx(
+	p Contractor, sigs map[Contractor][]byte) (wire.TxWitness, error) {
+	if d.Buffer == nil {
+		return d.witnessForFundScript(sigs)
+	}
+
+	sc, err := d.bufferScript(p)
+	if err != nil {
+		return nil, err
+	}
+	sig1, sig2 := sigs[FirstParty], sigs[SecondParty]
+	return script.WitnessForBufferScript(sig1, sig2, sc), nil
+}
@@ -0,0 +1,459 @@
+package dlc
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
+	"github.com/p2pderivatives/dlc/internal/oracle"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testChannelMsgs = [][]byte{{1}}
+
+// mockWitnessSignatureWithAnyCallback mocks WitnessSignatureWithCallback
+// applying a privkey converter given as an argument
+func mockWitnessSignatureWithAnyCallback(
+	w *walletmock.Wallet, pub *btcec.PublicKey, priv *btcec.PrivateKey) *walletmock.Wallet {
+	call := w.On("WitnessSignatureWithCallback",
+		mock.AnythingOfType("*wire.MsgTx"),
+		mock.AnythingOfType("int"),
+		mock.AnythingOfType("btcutil.Amount"),
+		mock.AnythingOfType("[]uint8"),
+		pub,
+		mock.AnythingOfType("wallet.PrivateKeyConverter"),
+	)
+
+	call.Run(func(args mock.Arguments) {
+		tx := args.Get(0).(*wire.MsgTx)
+		idx := args.Get(1).(int)
+		amt := args.Get(2).(btcutil.Amount)
+		sc := args.Get(3).([]uint8)
+		privkeyConverter := args.Get(5).(wallet.PrivateKeyConverter)
+		privplus, _ := privkeyConverter(priv)
+		sign, err := script.WitnessSignature(tx, idx, int64(amt), sc, privplus)
+		call.ReturnArguments = mock.Arguments{sign, err}
+	})
+
+	return w
+}
+
+// setupChannel prepares contractors that have exchanged fund tx requirements
+func setupChannel() (b1, b2 *Builder, err error) {
+	famt := btcutil.Amount(1 * btcutil.SatoshiPerBitcoin)
+
+	setupConds := func() *Conditions {
+		conds := newTestConditions()
+		conds.FundAmts[FirstParty] = famt
+		conds.FundAmts[SecondParty] = famt
+		return conds
+	}
+	setupWallet := func() *walletmock.Wallet {
+		w := &walletmock.Wallet{}
+		w.On("WatchOnly").Return(false)
+		w = mockSelectUnspent(w, 2*famt, 1, nil)
+		priv, pub := test.RandKeys()
+		w.On("NewFundPubkey").Return(pub, uint32(0), nil)
+		w = mockWitnessSignature(w, pub, priv)
+		w = mockWitnessSignatureWithAnyCallback(w, pub, priv)
+		return w
+	}
+
+	b1 = setupBuilder(FirstParty, setupWallet, setupConds)
+	b2 = setupBuilder(SecondParty, setupWallet, setupConds)
+
+	if err = stepPrepare(b1); err != nil {
+		return
+	}
+	if err = stepPrepare(b2); err != nil {
+		return
+	}
+	if err = stepSendRequirments(b1, b2); err != nil {
+		return
+	}
+	if err = stepSendRequirments(b2, b1); err != nil {
+		return
+	}
+	return b1, b2, nil
+}
+
+// stepOpenChannel opens a channel and exchanges
+// revocation points and buffer tx signatures of the opening state
+func stepOpenChannel(b1, b2 *Builder) error {
+	if err := b1.OpenChannel(); err != nil {
+		return err
+	}
+	if err := b2.OpenChannel(); err != nil {
+		return err
+	}
+	return stepExchangeBuffer(b1, b2)
+}
+
+// stepExchangeBuffer exchanges revocation points and buffer tx signatures
+func stepExchangeBuffer(b1, b2 *Builder) error {
+	p1, err := b1.RevocationPoint()
+	if err != nil {
+		return err
+	}
+	p2, err := b2.RevocationPoint()
+	if err != nil {
+		return err
+	}
+	if err = b1.AcceptRevocationPoint(p2); err != nil {
+		return err
+	}
+	if err = b2.AcceptRevocationPoint(p1); err != nil {
+		return err
+	}
+
+	s1, err := b1.SignBufferTx()
+	if err != nil {
+		return err
+	}
+	s2, err := b2.SignBufferTx()
+	if err != nil {
+		return err
+	}
+	if err = b1.AcceptBufferTxSignature(s2); err != nil {
+		return err
+	}
+	return b2.AcceptBufferTxSignature(s1)
+}
+
+// stepChannelState creates a new channel state and exchanges
+// revocation points, buffer tx signatures and CETx signatures
+func stepChannelState(
+	b1, b2 *Builder, C *btcec.PublicKey) (n1, n2 *Builder, err error) {
+	conds := b1.Contract.Conds
+	deal := NewDeal(
+		btcutil.Amount(1*btcutil.SatoshiPerBitcoin),
+		btcutil.Amount(0.5*btcutil.SatoshiPerBitcoin),
+		testChannelMsgs)
+	nextConds, err := RolloverConditions(
+		conds, conds.FixingTime.Add(24*time.Hour), conds.RefundLockTime+144,
+		[]*Deal{deal})
+	if err != nil {
+		return
+	}
+
+	if n1, err = b1.NewChannelState(nextConds); err != nil {
+		return
+	}
+	if n2, err = b2.NewChannelState(nextConds); err != nil {
+		return
+	}
+	n1.Contract.Oracle.Commitments[0] = C
+	n2.Contract.Oracle.Commitments[0] = C
+
+	if err = stepExchangeBuffer(n1, n2); err != nil {
+		return
+	}
+
+	// CETx signatures
+	if err = stepExchangeCETxSig(n1, n2, deal, 0); err != nil {
+		return
+	}
+	if err = stepExchangeCETxSig(n2, n1, deal, 0); err != nil {
+		return
+	}
+
+	return n1, n2, nil
+}
+
+func TestChannelStates(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	opriv, C := test.RandKeys()
+
+	// state 0 opening the channel
+	if !assert.NoError(stepOpenChannel(b1, b2)) {
+		assert.FailNow("failed to open channel")
+	}
+	assert.Equal(uint32(0), b1.Contract.Buffer.Index)
+
+	buftx0, err := b1.SignedBufferTx()
+	assert.NoError(err)
+
+	// state 1
+	n1, n2, err := stepChannelState(b1, b2, C)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	secret1, err := b1.SwitchChannelState(n1.Contract)
+	assert.NoError(err)
+	secret2, err := b2.SwitchChannelState(n2.Contract)
+	assert.NoError(err)
+	assert.Equal(uint32(1), b1.Contract.Buffer.Index)
+
+	// revoke state 0
+	assert.NoError(b2.AcceptRevocationSecret(secret1))
+	assert.NoError(b1.AcceptRevocationSecret(secret2))
+
+	// second party can punish first party's revoked buffer tx
+	penalty, err := b2.PenaltyTx(buftx0)
+	assert.NoError(err)
+	bufout := buftx0.TxOut[bufferTxOutAt]
+	assert.NoError(test.ExecuteScript(bufout.PkScript, penalty, bufout.Value))
+
+	// but can't punish the current one
+	buftx1, err := b1.SignedBufferTx()
+	assert.NoError(err)
+	_, err = b2.PenaltyTx(buftx1)
+	assert.Error(err)
+
+	// buffer tx spends fund tx
+	fundtx, _ := b1.Contract.FundTx()
+	fout := fundtx.TxOut[fundTxOutAt]
+	assert.NoError(test.ExecuteScript(fout.PkScript, buftx1, fout.Value))
+
+	// CETx of the current state spends buffer tx after the delay
+	osig := &oracle.SignedMsg{
+		Msgs: testChannelMsgs, Sigs: [][]byte{opriv.D.Bytes()}}
+	assert.NoError(b1.FixDeal(osig, []int{0}))
+	cetx, err := b1.SignedContractExecutionTx()
+	assert.NoError(err)
+	assert.Equal(buftx1.TxHash(), cetx.TxIn[0].PreviousOutPoint.Hash)
+	bufout = buftx1.TxOut[bufferTxOutAt]
+	assert.NoError(test.ExecuteScript(bufout.PkScript, cetx, bufout.Value))
+}
+
+func TestAcceptRevocationSecretInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	_, C := test.RandKeys()
+	if !assert.NoError(stepOpenChannel(b1, b2)) {
+		assert.FailNow("failed to open channel")
+	}
+
+	n1, n2, err := stepChannelState(b1, b2, C)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	b1.SwitchChannelState(n1.Contract)
+	b2.SwitchChannelState(n2.Contract)
+
+	err = b2.AcceptRevocationSecret([]byte{1})
+	assert.IsType(&RevokedStateError{}, err)
+}
+
+// SwitchChannelState should keep the current state if signatures are missing
+func TestSwitchChannelStateIncomplete(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	if !assert.NoError(stepOpenChannel(b1, b2)) {
+		assert.FailNow("failed to open channel")
+	}
+	current := b1.Contract
+
+	conds := b1.Contract.Conds
+	deal := NewDeal(1, 1, testChannelMsgs)
+	nextConds, _ := RolloverConditions(
+		conds, conds.FixingTime.Add(time.Hour), conds.RefundLockTime+1,
+		[]*Deal{deal})
+	n1, err := b1.NewChannelState(nextConds)
+	assert.NoError(err)
+
+	_, err = b1.SwitchChannelState(n1.Contract)
+	assert.IsType(&RolloverIncompleteError{}, err)
+	assert.Equal(current, b1.Contract)
+}
+
+// SwitchChannelState should keep the current state if signatures are invalid
+func TestSwitchChannelStateInvalidSignatures(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	_, C := test.RandKeys()
+	if !assert.NoError(stepOpenChannel(b1, b2)) {
+		assert.FailNow("failed to open channel")
+	}
+	current := b1.Contract
+
+	n1, _, err := stepChannelState(b1, b2, C)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	next := n1.Contract
+	bufsig, cetsig := next.Buffer.Sig, next.ExecSigs[SecondParty][0]
+
+	// buffer tx signature of the opening state
+	next.Buffer.Sig = current.Buffer.Sig
+	_, err = b1.SwitchChannelState(next)
+	assert.Error(err)
+	assert.Equal(current, b1.Contract)
+
+	// buffer tx signature for CETx
+	next.Buffer.Sig = bufsig
+	next.ExecSigs[SecondParty][0] = bufsig
+	_, err = b1.SwitchChannelState(next)
+	assert.Error(err)
+	assert.Equal(current, b1.Contract)
+
+	next.ExecSigs[SecondParty][0] = cetsig
+	_, err = b1.SwitchChannelState(next)
+	assert.NoError(err)
+}
+
+// NewChannelState should fail until the counterparty's revocation secret
+// of the previous state is accepted
+func TestNewChannelStatePendingRevocation(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	_, C := test.RandKeys()
+	if !assert.NoError(stepOpenChannel(b1, b2)) {
+		assert.FailNow("failed to open channel")
+	}
+
+	n1, n2, err := stepChannelState(b1, b2, C)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	secret1, err := b1.SwitchChannelState(n1.Contract)
+	assert.NoError(err)
+	_, err = b2.SwitchChannelState(n2.Contract)
+	assert.NoError(err)
+
+	_, _, err = stepChannelState(b1, b2, C)
+	assert.IsType(&RevokedStateError{}, err)
+
+	// first party still waits for the second party's secret
+	assert.NoError(b2.AcceptRevocationSecret(secret1))
+	_, err = b1.NewChannelState(b1.Contract.Conds)
+	assert.IsType(&RevokedStateError{}, err)
+}
+
+// a party can't sweep own buffer tx by the revocation branch
+// with a revocation point cancelling the counterparty's pubkey
+func TestRevocationPointCancellingPubkey(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	if !assert.NoError(b1.OpenChannel()) || !assert.NoError(b2.OpenChannel()) {
+		assert.FailNow("failed to open channel")
+	}
+
+	// first party sends point = X - pub2 where it knows the secret of X
+	privx, pubx := test.RandKeys()
+	pub2 := b2.Contract.Pubs[SecondParty]
+	curve := btcec.S256()
+	negy := new(big.Int).Sub(curve.P, pub2.Y)
+	point := &btcec.PublicKey{Curve: curve}
+	point.X, point.Y = curve.Add(pubx.X, pubx.Y, pub2.X, negy)
+	assert.NoError(b2.AcceptRevocationPoint(point.SerializeCompressed()))
+
+	buftx, err := b2.Contract.BufferTx(FirstParty)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	sc, err := b2.Contract.bufferScript(FirstParty)
+	assert.NoError(err)
+
+	bufout := buftx.TxOut[bufferTxOutAt]
+	tx := test.NewRedeemTx(buftx, bufferTxOutAt)
+	sig, err := script.WitnessSignature(tx, 0, bufout.Value, sc, privx)
+	assert.NoError(err)
+	tx.TxIn[0].Witness = script.WitnessForBufferScriptRevoked(sig, sc)
+	assert.Error(test.ExecuteScript(bufout.PkScript, tx, bufout.Value))
+}
+
+// NewChannelState should fail if deals can't pay buffer tx fee
+func TestNewChannelStateDealsExceedBuffer(t *testing.T) {
+	b1, b2, err := setupChannel()
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, err.Error())
+	}
+	if !assert.NoError(t, stepOpenChannel(b1, b2)) {
+		assert.FailNow(t, "failed to open channel")
+	}
+
+	conds := b1.Contract.Conds
+	famt, _ := b1.Contract.fundAmount()
+	deal := NewDeal(famt, 0, testChannelMsgs)
+	nextConds, _ := RolloverConditions(
+		conds, conds.FixingTime.Add(time.Hour), conds.RefundLockTime+1,
+		[]*Deal{deal})
+
+	_, err = b1.NewChannelState(nextConds)
+	assert.Error(t, err)
+}
+
+// a channel state can't be created from a contract without buffer
+// since CETs spending fund tx directly aren't revocable
+func TestNewChannelStateNotOpened(t *testing.T) {
+	b1, _, err := setupChannel()
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, err.Error())
+	}
+
+	conds := b1.Contract.Conds
+	deal := NewDeal(1, 1, testChannelMsgs)
+	nextConds, _ := RolloverConditions(
+		conds, conds.FixingTime.Add(time.Hour), conds.RefundLockTime+1,
+		[]*Deal{deal})
+	_, err = b1.NewChannelState(nextConds)
+	assert.Error(t, err)
+}
+
+// OpenChannel should fail with a watch-only wallet,
+// which can't sign penalty txs
+func TestOpenChannelWatchOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	w := &walletmock.Wallet{}
+	w.On("WatchOnly").Return(true)
+	b1.wallet = w
+
+	err = b1.OpenChannel()
+	assert.IsType(&OpenChannelError{}, err)
+	assert.Nil(b1.Contract.Buffer)
+}
+
+// OpenChannel should fail after CETx signatures of the opening contract
+// have been exchanged
+func TestOpenChannelAfterCETxSignatures(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, err := setupChannel()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	b1.Contract.ExecSigs[SecondParty] = [][]byte{{1}}
+
+	err = b1.OpenChannel()
+	assert.IsType(&OpenChannelError{}, err)
+	assert.Nil(b1.Contract.Buffer)
+}
@@ -13,6 +13,10 @@ import (
 // closingTxOutAt is a txout index of contract execution tx
 const closingTxOutAt = 0
 
+// closingTxInAt is a txin index spending the contract execution script
+// in closing tx and CPFP tx
+const closingTxInAt = 0
+
 // ClosingTx constructs a tx that redeems a given CET
 func (d *DLC) ClosingTx(
 	p Contractor, cetx *wire.MsgTx) (*wire.MsgTx, error) {
@@ -27,7 +31,7 @@ func (d *DLC) ClosingTx(
 	tx.AddTxIn(txin)
 
 	in := btcutil.Amount(cetx.TxOut[closingTxOutAt].Value)
-	fee := d.closignTxFee()
+	fee := d.closingTxFeeByParty(p)
 	out := in - fee
 
 	if out <= 0 {
@@ -45,6 +49,10 @@ func (d *DLC) ClosingTx(
 
 // SignedClosingTx constructs a closing tx with witness
 func (b *Builder) SignedClosingTx(cetx *wire.MsgTx) (*wire.MsgTx, error) {
+	if err := b.checkClosable(); err != nil {
+		return nil, err
+	}
+
 	dID, _, err := b.Contract.FixedDeal()
 	if err != nil {
 		return nil, err
@@ -60,7 +68,7 @@ func (b *Builder) SignedClosingTx(cetx *wire.MsgTx) (*wire.MsgTx, error) {
 	if err != nil {
 		return nil, err
 	}
-	tx.TxIn[0].Witness = wit
+	tx.TxIn[closingTxInAt].Witness = wit
 
 	return tx, nil
 }
@@ -70,11 +78,8 @@ func (b *Builder) witnessForCEScript(
 	cetxout := cetx.TxOut[closingTxOutAt]
 	amt := btcutil.Amount(cetxout.Value)
 
-	cparty := counterparty(b.party)
-	pub1, pub2 := b.Contract.Pubs[b.party], b.Contract.Pubs[cparty]
-
-	sc, err := script.ContractExecutionScript(
-		pub1, pub2, C)
+	pub := b.Contract.Pubs[b.party]
+	sc, err := b.Contract.contractExecutionScript(b.party, C)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +89,7 @@ func (b *Builder) witnessForCEScript(
 	privkeyConverter := genAddSigToPrivkeyFunc(osig)
 
 	sig, err := b.wallet.WitnessSignatureWithCallback(
-		tx, closingTxOutAt, amt, sc, pub1, privkeyConverter)
+		tx, closingTxInAt, amt, sc, pub, privkeyConverter)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +98,17 @@ func (b *Builder) witnessForCEScript(
 	return wit, nil
 }
 
+// checkClosable returns an error if the wallet can't sign closing txs.
+// The output of own CETx is spent by the privkey tweaked with the oracle's
+// signature, which a watch-only wallet can't pass to its signer.
+func (b *Builder) checkClosable() error {
+	if b.wallet.WatchOnly() {
+		return newWatchOnlyError(
+			"watch-only wallet can't claim the output of own CETx")
+	}
+	return nil
+}
+
 func genAddSigToPrivkeyFunc(
 	sig []byte) wallet.PrivateKeyConverter {
 	return func(priv *btcec.PrivateKey) (*btcec.PrivateKey, error) {
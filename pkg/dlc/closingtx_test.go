@@ -8,6 +8,7 @@ import (
 	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
 	"github.com/p2pderivatives/dlc/internal/oracle"
 	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,6 +40,35 @@ func TestClosingTx(t *testing.T) {
 	)
 }
 
+// Fees for payout txouts should depend on the address types
+func TestClosingTxPayoutAddressTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	d := setupDLC()
+	_, pub := test.RandKeys()
+	p2tr, _ := script.NewAddressTaproot(
+		pub.SerializeCompressed()[1:], d.Conds.NetParams)
+	d.Addrs[FirstParty] = p2tr
+
+	// p2tr txout is 12 bytes larger than p2wpkh txout
+	extra := d.redeemTxFee(12)
+	assert.Equal(extra, d.closingTxFeeByParty(FirstParty)-d.closignTxFee())
+	assert.Equal(d.closignTxFee(), d.closingTxFeeByParty(SecondParty))
+
+	// the party pays for own payout txouts in CETx and closing tx
+	assert.Equal(d.redeemTxFee(2*12), d.payoutFeeByParty(FirstParty))
+	assert.Equal(btcutil.Amount(0), d.payoutFeeByParty(SecondParty))
+	assert.Equal(
+		d.redeemTxFee(2*12), d.feeByParty(FirstParty)-d.feeByParty(SecondParty))
+
+	inamt := btcutil.Amount(1 * btcutil.SatoshiPerBitcoin)
+	tx1, err := d.ClosingTx(FirstParty, newTestCETx(inamt))
+	assert.NoError(err)
+	tx2, err := d.ClosingTx(SecondParty, newTestCETx(inamt))
+	assert.NoError(err)
+	assert.Equal(int64(extra), tx2.TxOut[0].Value-tx1.TxOut[0].Value)
+}
+
 func setupDLC() *DLC {
 	d := NewDLC(newTestConditions())
 	_, pub1 := test.RandKeys()
@@ -90,7 +120,36 @@ func TestSignedClosingTx(t *testing.T) {
 	assert.Error(err)
 }
 
+// a watch-only wallet can't get own CETx since it couldn't claim the output
+func TestSignedContractExecutionTxWatchOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupContractorsUntilSignExchange()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	cetx, err := b2.SignedContractExecutionTx()
+	assert.NoError(err)
+
+	w := &walletmock.Wallet{}
+	w.On("WatchOnly").Return(true)
+	b1.wallet = w
+
+	_, err = b1.SignedContractExecutionTx()
+	assert.IsType(&WatchOnlyError{}, err)
+	_, err = b1.SignedClosingTx(cetx)
+	assert.IsType(&WatchOnlyError{}, err)
+}
+
 func setupContractorsUntilSignExchange() (b1, b2 *Builder, err error) {
+	return setupFundedContractorsUntilSignExchange(0, 0)
+}
+
+// setupFundedContractorsUntilSignExchange does the same with
+// setupContractorsUntilSignExchange with given fund amount and wallet balance
+// of each party. Default test values are used if they are 0.
+func setupFundedContractorsUntilSignExchange(
+	famt, balance btcutil.Amount) (b1, b2 *Builder, err error) {
 	// msg
 	msgs := [][]byte{{1}}
 	damt1 := btcutil.Amount(1 * btcutil.SatoshiPerBitcoin)
@@ -106,14 +165,22 @@ func setupContractorsUntilSignExchange() (b1, b2 *Builder, err error) {
 	setupConds := func() *Conditions {
 		conds := newTestConditions()
 		conds.Deals = []*Deal{deal}
+		if famt > 0 {
+			conds.FundAmts[FirstParty] = famt
+			conds.FundAmts[SecondParty] = famt
+		}
 		return conds
 	}
 
 	setupWallet := func() *walletmock.Wallet {
 		w := &walletmock.Wallet{}
+		w.On("WatchOnly").Return(false)
+		if balance > 0 {
+			w = mockSelectUnspent(w, balance, 1, nil)
+		}
 
 		priv, pub := test.RandKeys()
-		w.On("NewPubkey").Return(pub, nil)
+		w.On("NewFundPubkey").Return(pub, uint32(0), nil)
 		w = mockWitnessSignature(w, pub, priv)
 		w = mockWitnessSignatureWithCallback(
 			w, pub, priv, genAddSigToPrivkeyFunc(osig))
@@ -0,0 +1,333 @@
+package dlc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/utils"
+)
+
+// cpfpDustLimit is the minimum value of the txout of CPFP tx
+const cpfpDustLimit = btcutil.Amount(546)
+
+// ownTxOut is a txout of a parent tx owned by the party
+type ownTxOut struct {
+	idx   int
+	value btcutil.Amount
+	addr  btcutil.Address  // set if the txout pays to the party's address
+	C     *btcec.PublicKey // set if the txout is the party's contract execution script
+}
+
+// SignedCPFPTx constructs a child-pays-for-parent tx that bumps fee of
+// a pending fund tx, CET or closing tx.
+//
+// Those txs are pre-signed with fixed feerates and can't be re-signed.
+// Instead of adding anchor outputs, the child tx spends the output
+// owned by the party as an anchor
+// (a change output of fund tx, a settlement or distribution output of CET,
+// and a distribution output of closing tx).
+// Wallet utxos are added if the output isn't enough for the fee.
+// They're locked so that they aren't selected until the child tx is sent,
+// and released if the child tx can't be signed.
+// Note that the child of the party's own CET spends the same output
+// with the closing tx, so it should be sent instead of the closing tx.
+//
+// txins:
+//   [0]: the party's output of the parent tx
+//   [1:]: wallet utxos (option)
+// txouts:
+//   [0]: the party's change address (or address)
+//
+// The package of parent and child pays a given feerate (satoshi/vbyte).
+// Witnesses of fund txins are estimated if the parent is an unsigned fund tx.
+func (b *Builder) SignedCPFPTx(
+	parent *wire.MsgTx, feerate btcutil.Amount) (_ *wire.MsgTx, err error) {
+	d := b.Contract
+
+	out, err := d.ownTxOut(b.party, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	parentFee, err := d.txFee(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := d.ChangeAddrs[b.party]
+	if addr == nil {
+		addr = d.Addrs[b.party]
+	}
+	if addr == nil {
+		return nil, errors.New("missing destination address")
+	}
+	sc, err := script.PkScriptFromAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	txInSize := script.P2WPKHTxInSize
+	if out.C != nil {
+		txInSize = ceTxInSize
+	}
+	childSize := cpfpTxBaseSize + txInSize + script.TxOutSize(sc)
+	parentSize, err := d.expectedVSize(parent)
+	if err != nil {
+		return nil, err
+	}
+	packageFee := feerate.MulF64(float64(parentSize + childSize))
+	fee := packageFee - parentFee
+	if fee <= 0 {
+		return nil, newCPFPNotNeededError(parentFee, packageFee)
+	}
+
+	tx := wire.NewMsgTx(txVersion)
+
+	txid := parent.TxHash()
+	tx.AddTxIn(wire.NewTxIn(
+		wire.NewOutPoint(&txid, uint32(out.idx)), nil, nil))
+
+	// add wallet utxos if the parent's output isn't enough for the fee
+	total := out.value
+	var utxos []Utxo
+	if out.value < fee+cpfpDustLimit {
+		feePerTxIn := feerate.MulF64(float64(script.P2WPKHTxInSize))
+		utxos, _, err = b.wallet.SelectAndLockUnspent(
+			nil, fee+cpfpDustLimit-out.value, feePerTxIn, 0,
+			time.Now().Add(UtxoLockDuration))
+		if err != nil {
+			return nil, err
+		}
+		locked := utxos
+		defer func() {
+			if err != nil {
+				err = b.unlockUtxosOnError(locked, err)
+			}
+		}()
+
+		for i := range utxos {
+			txin, err := utils.UtxoToTxIn(&utxos[i])
+			if err != nil {
+				return nil, err
+			}
+			tx.AddTxIn(txin)
+
+			amt, err := btcutil.NewAmount(utxos[i].Amount)
+			if err != nil {
+				return nil, err
+			}
+			total += amt
+			fee += feePerTxIn
+		}
+	}
+
+	// txout
+	amt := total - fee
+	if amt < cpfpDustLimit {
+		return nil, newNotEnoughFeesError(total, fee)
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(amt), sc))
+
+	// witnesses
+	idxs := []int{}
+	for i := range utxos {
+		idxs = append(idxs, i+1)
+	}
+	if out.C != nil {
+		wit, err := b.witnessForCEScript(tx, parent, out.C)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[closingTxInAt].Witness = wit
+	} else {
+		utxo := Utxo{
+			TxID:         txid.String(),
+			Vout:         uint32(out.idx),
+			Address:      out.addr.EncodeAddress(),
+			Amount:       out.value.ToBTC(),
+			ScriptPubKey: hex.EncodeToString(parent.TxOut[out.idx].PkScript),
+		}
+		idxs = append([]int{0}, idxs...)
+		utxos = append([]Utxo{utxo}, utxos...)
+	}
+
+	if len(idxs) > 0 {
+		wits, err := b.wallet.WitnessSignTxByUtxos(tx, idxs, utxos)
+		if err != nil {
+			return nil, err
+		}
+		for i, idx := range idxs {
+			tx.TxIn[idx].Witness = wits[i]
+		}
+	}
+
+	return tx, nil
+}
+
+// expectedVSize returns virtual size of a given tx
+// adding expected witnesses of unsigned txins spending utxos of the parties
+func (d *DLC) expectedVSize(tx *wire.MsgTx) (int64, error) {
+	prevOuts := make(map[wire.OutPoint]bool)
+	for _, p := range d.Conds.Parties() {
+		for _, utxo := range d.Utxos[p] {
+			txin, err := utils.UtxoToTxIn(utxo)
+			if err != nil {
+				return 0, err
+			}
+			prevOuts[txin.PreviousOutPoint] = true
+		}
+	}
+
+	weight := int64(tx.SerializeSizeStripped())*3 + int64(tx.SerializeSize())
+	unsigned := int64(0)
+	hasWitness := false
+	for _, txin := range tx.TxIn {
+		if len(txin.Witness) > 0 {
+			hasWitness = true
+			continue
+		}
+		if prevOuts[txin.PreviousOutPoint] {
+			unsigned++
+		}
+	}
+	if unsigned > 0 {
+		weight += unsigned * p2wpkhWitnessSize
+		if !hasWitness {
+			// segwit marker, flag and empty witnesses of other txins
+			weight += 2 + int64(len(tx.TxIn)) - unsigned
+		}
+	}
+	return (weight + 3) / 4, nil
+}
+
+// ownTxOut finds a txout owned by a given party in a given tx
+func (d *DLC) ownTxOut(p Contractor, tx *wire.MsgTx) (*ownTxOut, error) {
+	for idx, txout := range tx.TxOut {
+		for _, addr := range []btcutil.Address{d.Addrs[p], d.ChangeAddrs[p]} {
+			if addr == nil {
+				continue
+			}
+			sc, err := script.PkScriptFromAddress(addr)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Equal(txout.PkScript, sc) {
+				return &ownTxOut{
+					idx: idx, value: btcutil.Amount(txout.Value), addr: addr}, nil
+			}
+		}
+	}
+
+	// contract execution script of the fixed deal
+	if d.HasDealFixed() {
+		dID, _, err := d.FixedDeal()
+		if err != nil {
+			return nil, err
+		}
+		C := d.Oracle.Commitments[dID]
+		if C != nil && len(tx.TxOut) > closingTxOutAt {
+			sc, err := d.contractExecutionScript(p, C)
+			if err != nil {
+				return nil, err
+			}
+			pkScript, err := script.P2WSHpkScript(sc)
+			if err != nil {
+				return nil, err
+			}
+			txout := tx.TxOut[closingTxOutAt]
+			if bytes.Equal(txout.PkScript, pkScript) {
+				return &ownTxOut{
+					idx: closingTxOutAt, value: btcutil.Amount(txout.Value), C: C}, nil
+			}
+		}
+	}
+
+	return nil, errors.New("tx doesn't have any txout owned by the party")
+}
+
+// txFee calculates fee of a given tx by looking up its previous outputs
+// in utxos of both parties, fund tx and fixed CETs
+func (d *DLC) txFee(tx *wire.MsgTx) (btcutil.Amount, error) {
+	prevOuts, err := d.knownTxOuts()
+	if err != nil {
+		return 0, err
+	}
+
+	fee := btcutil.Amount(0)
+	for _, txin := range tx.TxIn {
+		amt, ok := prevOuts[txin.PreviousOutPoint]
+		if !ok {
+			return 0, errors.New("unknown txin. " + txin.PreviousOutPoint.String())
+		}
+		fee += amt
+	}
+	for _, txout := range tx.TxOut {
+		fee -= btcutil.Amount(txout.Value)
+	}
+	if fee < 0 {
+		return 0, errors.New("txouts exceed txins")
+	}
+
+	return fee, nil
+}
+
+// knownTxOuts returns values of txouts related to the contract
+func (d *DLC) knownTxOuts() (map[wire.OutPoint]btcutil.Amount, error) {
+	outs := make(map[wire.OutPoint]btcutil.Amount)
+
+	addTxOuts := func(tx *wire.MsgTx) {
+		txid := tx.TxHash()
+		for idx, txout := range tx.TxOut {
+			op := wire.NewOutPoint(&txid, uint32(idx))
+			outs[*op] = btcutil.Amount(txout.Value)
+		}
+	}
+
+	for _, p := range d.Conds.Parties() {
+		for _, utxo := range d.Utxos[p] {
+			txin, err := utils.UtxoToTxIn(utxo)
+			if err != nil {
+				return nil, err
+			}
+			amt, err := btcutil.NewAmount(utxo.Amount)
+			if err != nil {
+				return nil, err
+			}
+			outs[txin.PreviousOutPoint] = amt
+		}
+	}
+
+	fundtx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	addTxOuts(fundtx)
+
+	if d.Buffer != nil {
+		for _, p := range d.Conds.Parties() {
+			buftx, err := d.BufferTx(p)
+			if err != nil {
+				return nil, err
+			}
+			addTxOuts(buftx)
+		}
+	}
+
+	if d.HasDealFixed() {
+		for _, p := range d.Conds.Parties() {
+			cetx, err := d.FixedContractExecutionTx(p)
+			if err != nil {
+				return nil, err
+			}
+			addTxOuts(cetx)
+		}
+	}
+
+	return outs, nil
+}
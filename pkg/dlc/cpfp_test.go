@@ -0,0 +1,223 @@
+package dlc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fund amount that covers deal amounts
+const testCPFPFundAmt = btcutil.Amount(1 * btcutil.SatoshiPerBitcoin)
+const testCPFPBalance = btcutil.Amount(2 * btcutil.SatoshiPerBitcoin)
+
+func TestSignedCPFPTxForCETx(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, err := setupFundedContractorsUntilSignExchange(
+		testCPFPFundAmt, testCPFPBalance)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	cetx, err := b1.SignedContractExecutionTx()
+	assert.NoError(err)
+
+	feerate := btcutil.Amount(10)
+	tx, err := b1.SignedCPFPTx(cetx, feerate)
+	assert.NoError(err)
+	assert.Len(tx.TxIn, 1)
+	assert.Len(tx.TxOut, 1)
+
+	// spends the contract execution script
+	txid := cetx.TxHash()
+	assert.Equal(*wire.NewOutPoint(&txid, closingTxOutAt), tx.TxIn[0].PreviousOutPoint)
+	assert.NoError(runCEScript(cetx, tx))
+
+	// package of CETx and child pays the target feerate
+	cetxFee, err := b1.Contract.txFee(cetx)
+	assert.NoError(err)
+	childFee := btcutil.Amount(cetx.TxOut[closingTxOutAt].Value - tx.TxOut[0].Value)
+	childSize := cpfpTxBaseSize + ceTxInSize + script.TxOutSize(tx.TxOut[0].PkScript)
+	assert.Equal(
+		feerate.MulF64(float64(txVSize(cetx)+childSize)), cetxFee+childFee)
+}
+
+func TestSignedCPFPTxForCounterpartyCETx(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupFundedContractorsUntilSignExchange(
+		testCPFPFundAmt, testCPFPBalance)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	w := b2.wallet.(*walletmock.Wallet)
+	w.On("WitnessSignTxByUtxos",
+		mock.Anything, []int{0}, mock.Anything,
+	).Return([]wire.TxWitness{{{1}}}, nil)
+
+	// first party's CETx distributes fund to the second party at txout[1]
+	cetx, err := b1.SignedContractExecutionTx()
+	assert.NoError(err)
+
+	tx, err := b2.SignedCPFPTx(cetx, 10)
+	assert.NoError(err)
+	assert.Len(tx.TxIn, 1)
+	assert.Equal(uint32(1), tx.TxIn[0].PreviousOutPoint.Index)
+	assert.Equal(wire.TxWitness{{1}}, tx.TxIn[0].Witness)
+
+	utxos := w.Calls[len(w.Calls)-1].Arguments.Get(2).([]wallet.Utxo)
+	assert.Equal(cetx.TxHash().String(), utxos[0].TxID)
+	assert.Equal(b2.Contract.Addrs[b2.party].EncodeAddress(), utxos[0].Address)
+}
+
+func TestSignedCPFPTxNotNeeded(t *testing.T) {
+	b1, _, err := setupFundedContractorsUntilSignExchange(
+		testCPFPFundAmt, testCPFPBalance)
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, err.Error())
+	}
+
+	cetx, _ := b1.SignedContractExecutionTx()
+	cltx, _ := b1.SignedClosingTx(cetx)
+
+	// any fee is enough for zero feerate
+	_, err = b1.SignedCPFPTx(cltx, 0)
+	assert.IsType(t, &CPFPNotNeededError{}, err)
+}
+
+// CPFP tx for fund tx should add wallet utxos if the change isn't enough for fee
+// testCPFPWalletUtxo is a wallet utxo added to a CPFP tx
+var testCPFPWalletUtxo = wallet.Utxo{TxID: testTxID, Vout: 1, Amount: 0.001}
+
+// setupContractorsForCPFPWithWalletUtxos returns a contractor whose change
+// of fund tx isn't enough for CPFP fee, and its wallet signs CPFP tx
+// returning a given error
+func setupContractorsForCPFPWithWalletUtxos(signErr error) *Builder {
+	setupWallet := func() *walletmock.Wallet {
+		w := setupTestWallet()
+		// for fund tx
+		w.On("SelectAndLockUnspent",
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		).Return(
+			[]wallet.Utxo{{
+				TxID: randTxID(), Amount: 0.00002,
+				ScriptPubKey: randP2WPKHScript()}},
+			btcutil.Amount(1), nil,
+		).Once()
+		// for cpfp tx
+		w.On("SelectAndLockUnspent",
+			nil, mock.Anything, mock.Anything, btcutil.Amount(0), mock.Anything,
+		).Return([]wallet.Utxo{testCPFPWalletUtxo}, btcutil.Amount(1), nil)
+		w.On("WitnessSignTxByUtxos",
+			mock.Anything, []int{0, 1}, mock.Anything,
+		).Return([]wire.TxWitness{{{1}}, {{2}}}, signErr)
+		return w
+	}
+
+	b1 := setupBuilder(FirstParty, setupWallet, newTestConditions)
+	b2 := setupBuilder(SecondParty, setupWallet, newTestConditions)
+	stepPrepare(b1)
+	stepPrepare(b2)
+	stepSendRequirments(b2, b1)
+	return b1
+}
+
+func TestSignedCPFPTxForFundTxWithWalletUtxos(t *testing.T) {
+	assert := assert.New(t)
+
+	b1 := setupContractorsForCPFPWithWalletUtxos(nil)
+	walletUtxo := testCPFPWalletUtxo
+
+	fundtx, err := b1.Contract.FundTx()
+	assert.NoError(err)
+
+	feerate := btcutil.Amount(5)
+	tx, err := b1.SignedCPFPTx(fundtx, feerate)
+	assert.NoError(err)
+	assert.Len(tx.TxIn, 2)
+	assert.Len(tx.TxOut, 1)
+
+	// wallet utxos are kept locked for the child tx
+	w := b1.wallet.(*walletmock.Wallet)
+	w.AssertNotCalled(t, "UnlockUtxos", []wallet.Utxo{walletUtxo})
+
+	// change of first party
+	change := fundtx.TxOut[1]
+	assert.Equal(uint32(1), tx.TxIn[0].PreviousOutPoint.Index)
+	assert.Equal(walletUtxo.Vout, tx.TxIn[1].PreviousOutPoint.Index)
+
+	fundFee, err := b1.Contract.txFee(fundtx)
+	assert.NoError(err)
+	in := btcutil.Amount(change.Value) + btcutil.Amount(100000)
+	childFee := in - btcutil.Amount(tx.TxOut[0].Value)
+	childSize := cpfpTxBaseSize + script.P2WPKHTxInSize*2 +
+		script.TxOutSize(tx.TxOut[0].PkScript)
+
+	// the unsigned fund tx is estimated with p2wpkh witnesses
+	signed := fundtx.Copy()
+	for _, txin := range signed.TxIn {
+		txin.Witness = wire.TxWitness{make([]byte, 72), make([]byte, 33)}
+	}
+	assert.True(txVSize(signed) > txVSize(fundtx))
+	assert.Equal(
+		feerate.MulF64(float64(txVSize(signed)+childSize)), fundFee+childFee)
+}
+
+// wallet utxos locked for a CPFP tx should be released if it can't be signed
+func TestSignedCPFPTxUnlocksWalletUtxosOnFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	b1 := setupContractorsForCPFPWithWalletUtxos(errors.New("signing failed"))
+	fundtx, err := b1.Contract.FundTx()
+	assert.NoError(err)
+
+	_, err = b1.SignedCPFPTx(fundtx, 5)
+	assert.Error(err)
+	w := b1.wallet.(*walletmock.Wallet)
+	w.AssertCalled(t, "UnlockUtxos", []wallet.Utxo{testCPFPWalletUtxo})
+}
+
+// the size of the child txout should depend on the destination address type
+func TestSignedCPFPTxTaprootDestination(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, err := setupFundedContractorsUntilSignExchange(
+		testCPFPFundAmt, testCPFPBalance)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	_, pub := test.RandKeys()
+	p2tr, _ := script.NewAddressTaproot(
+		pub.SerializeCompressed()[1:], b1.Contract.Conds.NetParams)
+	b1.Contract.ChangeAddrs[FirstParty] = p2tr
+
+	// witnesses of the parent don't matter
+	cetx, err := b1.Contract.FixedContractExecutionTx(FirstParty)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	feerate := btcutil.Amount(10)
+	tx, err := b1.SignedCPFPTx(cetx, feerate)
+	assert.NoError(err)
+	sc, _ := script.PkScriptFromAddress(p2tr)
+	assert.Equal(sc, tx.TxOut[0].PkScript)
+
+	cetxFee, err := b1.Contract.txFee(cetx)
+	assert.NoError(err)
+	childFee := btcutil.Amount(cetx.TxOut[closingTxOutAt].Value - tx.TxOut[0].Value)
+	childSize := cpfpTxBaseSize + ceTxInSize + script.TxOutSize(sc)
+	assert.True(script.TxOutSize(sc) > script.P2WPKHTxOutSize)
+	assert.Equal(
+		feerate.MulF64(float64(txVSize(cetx)+childSize)), cetxFee+childFee)
+}
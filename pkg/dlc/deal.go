@@ -16,11 +16,18 @@ type Deal struct {
 
 // NewDeal creates a new deal
 func NewDeal(amt1, amt2 btcutil.Amount, msgs [][]byte) *Deal {
-	amts := make(map[Contractor]btcutil.Amount)
-	amts[FirstParty] = amt1
-	amts[SecondParty] = amt2
+	return NewMultiPartyDeal([]btcutil.Amount{amt1, amt2}, msgs)
+}
+
+// NewMultiPartyDeal creates a new deal that distributes amounts
+// to contractors in order
+func NewMultiPartyDeal(amts []btcutil.Amount, msgs [][]byte) *Deal {
+	_amts := make(map[Contractor]btcutil.Amount)
+	for i, amt := range amts {
+		_amts[Contractor(i)] = amt
+	}
 	return &Deal{
-		Amts: amts,
+		Amts: _amts,
 		Msgs: msgs,
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 	validator "gopkg.in/go-playground/validator.v9"
 )
@@ -26,7 +27,10 @@ type DLC struct {
 	Utxos       map[Contractor][]*Utxo
 	FundWits    map[Contractor][]wire.TxWitness // TODO: change to fund signatures
 	RefundSigs  map[Contractor][]byte           // signatures for refund tx
-	ExecSigs    [][]byte                        // counterparty's signatures for CETxs
+	ExecSigs    map[Contractor][][]byte         // signatures for own CETxs by the other parties
+	Buffer      *Buffer                         // channel state (nil if not in channel)
+	Nonces      *MuSigNonces                    // MuSig2 nonces for CETxs (taproot fund mode)
+	FundKeyIdx  *uint32                         // index of own fund pubkey on the wallet's fund account (nil if unknown)
 }
 
 // Utxo is alias of btcjson.ListUnspentResult
@@ -44,7 +48,7 @@ func NewDLC(conds *Conditions) *DLC {
 		Utxos:       make(map[Contractor][]*Utxo),
 		FundWits:    make(map[Contractor][]wire.TxWitness),
 		RefundSigs:  make(map[Contractor][]byte),
-		ExecSigs:    make([][]byte, nDeal),
+		ExecSigs:    make(map[Contractor][][]byte),
 	}
 }
 
@@ -57,13 +61,15 @@ type Conditions struct {
 	RedeemFeerate  btcutil.Amount                `validate:"required,gt=0"` // redeem fee rate (satoshi per byte)
 	RefundLockTime uint32                        `validate:"required,gt=0"` // refund locktime (block height)
 	Deals          []*Deal                       `validate:"required,gt=0,dive,required"`
+	FundMode       FundMode                      `validate:"gte=0,lte=1"` // fund output type (P2WSH by default)
+	DustLimit      btcutil.Amount                `validate:"gte=0"`       // change txouts below this are paid as fee
 	PremiumInfo    *PremiumInfo
 }
 
 type PremiumInfo struct {
-	PremiumDestAddress btcutil.Address			`validate:"required"`
-	PremiumAmount 	   btcutil.Amount			`validate:"required,gt=0"`
-	PayingParty        Contractor				`validate:"oneof= 0 1"`
+	PremiumDestAddress btcutil.Address `validate:"required"`
+	PremiumAmount      btcutil.Amount  `validate:"required,gt=0"`
+	PayingParty        Contractor      `validate:"oneof= 0 1"`
 }
 
 // NewConditions creates a new DLC conditions
@@ -76,19 +82,38 @@ func NewConditions(
 	deals []*Deal,
 	info *PremiumInfo,
 ) (*Conditions, error) {
-	famts := make(map[Contractor]btcutil.Amount)
-	famts[FirstParty] = famt1
-	famts[SecondParty] = famt2
+	return NewMultiPartyConditions(
+		net, ftime, []btcutil.Amount{famt1, famt2},
+		ffeerate, rfeerate, refundLockTime, deals, info)
+}
+
+// NewMultiPartyConditions creates a new DLC conditions for n contractors.
+// Fund amounts are ordered by contractor, and every deal must have
+// the same number of amounts.
+func NewMultiPartyConditions(
+	net *chaincfg.Params,
+	ftime time.Time,
+	famts []btcutil.Amount,
+	ffeerate, rfeerate btcutil.Amount, // fund feerate and redeem feerate
+	refundLockTime uint32, // refund locktime
+	deals []*Deal,
+	info *PremiumInfo,
+) (*Conditions, error) {
+	_famts := make(map[Contractor]btcutil.Amount)
+	for i, famt := range famts {
+		_famts[Contractor(i)] = famt
+	}
 
 	conds := &Conditions{
 		NetParams:      net,
 		FixingTime:     ftime,
-		FundAmts:       famts,
+		FundAmts:       _famts,
 		FundFeerate:    ffeerate,
 		RedeemFeerate:  rfeerate,
 		RefundLockTime: refundLockTime,
 		Deals:          deals,
-		PremiumInfo:   info,
+		DustLimit:      wallet.DustThreshold,
+		PremiumInfo:    info,
 	}
 
 	// validate structure
@@ -99,12 +124,66 @@ func NewConditions(
 			return false
 		}
 
-		return m[0] != 0 || m[1] != 0
+		for _, amt := range m {
+			if amt != 0 {
+				return true
+			}
+		}
+		return false
 	})
 
 	err := validate.Struct(conds)
+	if err != nil {
+		return conds, err
+	}
+
+	return conds, conds.validateParties()
+}
+
+// validateParties checks if the number of contractors is supported
+// and all deals have amounts for the contractors
+func (conds *Conditions) validateParties() error {
+	n := len(conds.FundAmts)
+	if n < 2 || n > MaxContractors {
+		msg := fmt.Sprintf(
+			"number of contractors must be from 2 to %d. %d", MaxContractors, n)
+		return errors.New(msg)
+	}
+
+	for i, deal := range conds.Deals {
+		if len(deal.Amts) != n {
+			msg := fmt.Sprintf(
+				"deal %d must have amounts for %d contractors", i, n)
+			return errors.New(msg)
+		}
+	}
+
+	return nil
+}
+
+// Parties returns all contractors of the contract in order
+func (conds *Conditions) Parties() []Contractor {
+	parties := []Contractor{}
+	for i := 0; i < len(conds.FundAmts); i++ {
+		parties = append(parties, Contractor(i))
+	}
+	return parties
+}
+
+// counterparties returns contractors other than a given party
+func (conds *Conditions) counterparties(p Contractor) []Contractor {
+	cparties := []Contractor{}
+	for _, q := range conds.Parties() {
+		if q != p {
+			cparties = append(cparties, q)
+		}
+	}
+	return cparties
+}
 
-	return conds, err
+// isTwoParty returns true if the contract is made by two contractors
+func (conds *Conditions) isTwoParty() bool {
+	return len(conds.FundAmts) == 2
 }
 
 func NewPremiumInfo(premiumAddress btcutil.Address, premiumAmount btcutil.Amount, payingParty Contractor) (*PremiumInfo, error) {
@@ -115,7 +194,11 @@ func NewPremiumInfo(premiumAddress btcutil.Address, premiumAmount btcutil.Amount
 	}
 
 	err := validator.New().Struct(premiumInfo)
+	if err != nil {
+		return premiumInfo, err
+	}
 
+	_, err = script.PkScriptFromAddress(premiumAddress)
 	return premiumInfo, err
 }
 
@@ -140,7 +223,7 @@ func (d *DLC) distTxOut(
 		return nil, errors.New(msg)
 	}
 
-	sc, err := txscript.PayToAddrScript(addr)
+	sc, err := script.PkScriptFromAddress(addr)
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +234,8 @@ func (d *DLC) distTxOut(
 
 const txVersion = 2
 
-// Contractor represents a contractor type
+// Contractor represents a contractor type.
+// Contractors of a contract are numbered from 0.
 type Contractor int
 
 const (
@@ -161,6 +245,10 @@ const (
 	SecondParty Contractor = 1
 )
 
+// MaxContractors is the maximum number of contractors in a contract,
+// which is limited by OP_CHECKMULTISIG of fund script
+const MaxContractors = txscript.MaxPubKeysPerMultiSig
+
 // String represents contractor in string format
 func (p Contractor) String() string {
 	switch p {
@@ -169,10 +257,10 @@ func (p Contractor) String() string {
 	case SecondParty:
 		return "second party"
 	}
-	return ""
+	return fmt.Sprintf("party %d", int(p)+1)
 }
 
-// counterparty returns the counterparty
+// counterparty returns the counterparty in a two-party contract
 func counterparty(p Contractor) (cp Contractor) {
 	switch p {
 	case FirstParty:
@@ -188,6 +276,10 @@ type Builder struct {
 	Contract *DLC
 	party    Contractor
 	wallet   wallet.Wallet
+	rpc      ChainClient         // used to validate utxos
+	selector wallet.CoinSelector // used to select fund utxos (optional)
+
+	closingNonces *mutualClosingNonces // MuSig2 nonces of mutual closing tx
 }
 
 // NewBuilder createa a builder from DLC
@@ -200,22 +292,27 @@ func NewBuilder(
 	}
 }
 
-// PreparePubkey sets fund pubkey
+// PreparePubkey sets fund pubkey and its index on the wallet's fund account
 func (b *Builder) PreparePubkey() error {
-	pub, err := b.wallet.NewPubkey()
+	pub, idx, err := b.wallet.NewFundPubkey()
 	if err != nil {
 		return err
 	}
 	b.Contract.Pubs[b.party] = pub
+	b.Contract.FundKeyIdx = &idx
 	return nil
 }
 
 // AcceptPubkey accepts counter party's public key
 func (b *Builder) AcceptPubkey(pub []byte) error {
-	p, err := btcec.ParsePubKey(pub, btcec.S256())
-	c := counterparty(b.party)
+	return b.AcceptPubkeyFrom(counterparty(b.party), pub)
+}
+
+// AcceptPubkeyFrom accepts a public key of a given party
+func (b *Builder) AcceptPubkeyFrom(p Contractor, pub []byte) error {
+	pubkey, err := btcec.ParsePubKey(pub, btcec.S256())
 
-	b.Contract.Pubs[c] = p
+	b.Contract.Pubs[p] = pubkey
 
 	return err
 }
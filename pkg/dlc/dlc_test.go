@@ -6,6 +6,8 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
+	"github.com/p2pderivatives/dlc/internal/test"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -125,3 +127,47 @@ func TestNewBuilder(t *testing.T) {
 
 	assert.NotNil(builder.Contract)
 }
+
+func TestPreparePubkey(t *testing.T) {
+	assert := assert.New(t)
+	_, pub := test.RandKeys()
+	w := &walletmock.Wallet{}
+	w.On("WatchOnly").Return(false)
+	w.On("NewFundPubkey").Return(pub, uint32(3), nil)
+
+	b := NewBuilder(FirstParty, w, NewDLC(newTestConditions()))
+	err := b.PreparePubkey()
+	assert.NoError(err)
+	assert.Equal(pub, b.Contract.Pubs[FirstParty])
+	if assert.NotNil(b.Contract.FundKeyIdx) {
+		assert.Equal(uint32(3), *b.Contract.FundKeyIdx)
+	}
+}
+
+func TestMultiPartyConditions(t *testing.T) {
+	assert := assert.New(t)
+
+	net := &chaincfg.RegressionNetParams
+	ftime := time.Now().AddDate(0, 0, 1)
+	famts := []btcutil.Amount{1, 1, 1}
+	var frate, rrate btcutil.Amount = 1, 1
+	var lc uint32 = 1
+	deals := []*Deal{NewMultiPartyDeal(famts, [][]byte{{1}})}
+
+	conds, err := NewMultiPartyConditions(
+		net, ftime, famts, frate, rrate, lc, deals, nil)
+	assert.NoError(err)
+	assert.Equal([]Contractor{0, 1, 2}, conds.Parties())
+
+	// deal must have amounts for all parties
+	_, err = NewMultiPartyConditions(
+		net, ftime, famts, frate, rrate, lc,
+		[]*Deal{NewDeal(1, 1, [][]byte{{1}})}, nil)
+	assert.Error(err)
+
+	// at least two parties
+	_, err = NewMultiPartyConditions(
+		net, ftime, famts[:1], frate, rrate, lc,
+		[]*Deal{NewMultiPartyDeal(famts[:1], [][]byte{{1}})}, nil)
+	assert.Error(err)
+}
@@ -26,3 +26,36 @@ func newNoFixedDealError() *NoFixedDealError {
 	msg := "No deal has been fixed"
 	return &NoFixedDealError{error: errors.New(msg)}
 }
+
+// CPFPNotNeededError is an error for a case when a parent tx
+// already pays enough fee for a target feerate
+type CPFPNotNeededError struct {
+	error
+}
+
+func newCPFPNotNeededError(fee, target btcutil.Amount) *CPFPNotNeededError {
+	msg := fmt.Sprintf(
+		"Parent tx pays enough fee. fee: %d, target fee: %d", fee, target)
+	return &CPFPNotNeededError{error: errors.New(msg)}
+}
+
+// WatchOnlyError is an error for a case when a watch-only wallet
+// is asked for a signature that it can't make
+type WatchOnlyError struct {
+	error
+}
+
+func newWatchOnlyError(msg string) *WatchOnlyError {
+	return &WatchOnlyError{error: errors.New(msg)}
+}
+
+// ExcessiveFeeError is an error for a case when a tx would pay
+// fee far beyond the feerate of the contract
+type ExcessiveFeeError struct {
+	error
+}
+
+func newExcessiveFeeError(fee, max btcutil.Amount) *ExcessiveFeeError {
+	msg := fmt.Sprintf("TxFee is too high. fee: %d, max: %d", fee, max)
+	return &ExcessiveFeeError{error: errors.New(msg)}
+}
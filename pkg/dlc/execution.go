@@ -10,78 +10,103 @@ import (
 )
 
 // ContractExecutionTx constructs a contract execution tx (CET) using pubkeys and given condition.
-// Both parties have different transactions signed by the other side.
+// Every party has different transactions signed by the other parties.
 //
 // txins:
-//   [0]:fund transaction output[0]
+//   [0]:fund transaction output[0] (buffer transaction output[0] in channel)
 // txouts:
 //   [0]:settlement script
 //   [1]:p2wpkh (option)
+//   [2..n-1]:p2wpkh of the other parties (option, multi-party)
+//
+// In a multi-party contract, the settlement script is omitted
+// if the party receives nothing from the deal.
 func (d *DLC) ContractExecutionTx(
 	party Contractor, deal *Deal, dID int) (*wire.MsgTx, error) {
-	cparty := counterparty(party)
-
 	// out values
-	damt1 := deal.Amts[party]
-	damt2 := deal.Amts[cparty]
+	damt := deal.Amts[party]
 
-	if damt1 == 0 {
+	if damt == 0 && d.Conds.isTwoParty() {
 		return d.ContractAbandonmentTx(party)
 	}
 
-	// txout1: contract execution script
-	pub1 := d.Pubs[party]
-	if pub1 == nil {
-		return nil, errors.New("missing pubkey")
-	}
-	pub2 := d.Pubs[cparty]
-	if pub2 == nil {
-		return nil, errors.New("missing pubkey")
-	}
-
-	C := d.Oracle.Commitments[dID]
-	if C == nil {
-		return nil, errors.New("missing oracle's commitment")
-	}
-
-	sc, err := script.ContractExecutionScript(pub1, pub2, C)
-	if err != nil {
-		return nil, err
-	}
-	pkScript, err := script.P2WSHpkScript(sc)
+	tx, err := d.newRedeemTxByParty(party)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := d.newRedeemTx()
-	if err != nil {
-		return nil, err
-	}
+	// txout1: contract execution script
+	if damt > 0 {
+		C := d.Oracle.Commitments[dID]
+		if C == nil {
+			return nil, errors.New("missing oracle's commitment")
+		}
 
-	outAmt1 := damt1 + d.closignTxFee()
-	txout1 := wire.NewTxOut(int64(outAmt1), pkScript)
-	tx.AddTxOut(txout1)
+		sc, err := d.contractExecutionScript(party, C)
+		if err != nil {
+			return nil, err
+		}
+		pkScript, err := script.P2WSHpkScript(sc)
+		if err != nil {
+			return nil, err
+		}
+
+		outAmt := damt + d.closingTxFeeByParty(party)
+		tx.AddTxOut(wire.NewTxOut(int64(outAmt), pkScript))
+	}
 
-	// txout2: counterparty's p2wpkh
-	if damt2 > 0 {
-		txout2, err := d.distTxOut(cparty, damt2)
+	// txouts: the other parties' p2wpkh
+	for _, cparty := range d.Conds.counterparties(party) {
+		amt := deal.Amts[cparty]
+		if amt == 0 {
+			continue
+		}
+		txout, err := d.distTxOut(cparty, amt)
 		if err != nil {
 			return nil, err
 		}
-		tx.AddTxOut(txout2)
+		tx.AddTxOut(txout)
 	}
 	return tx, nil
 }
 
+// contractExecutionScript returns a contract execution script
+// that a given party can unlock with oracle's signature for a commitment C
+func (d *DLC) contractExecutionScript(
+	p Contractor, C *btcec.PublicKey) ([]byte, error) {
+	pub := d.Pubs[p]
+	if pub == nil {
+		return nil, errors.New("missing pubkey")
+	}
+
+	cpubs := []*btcec.PublicKey{}
+	for _, cparty := range d.Conds.counterparties(p) {
+		cpub := d.Pubs[cparty]
+		if cpub == nil {
+			return nil, errors.New("missing pubkey")
+		}
+		cpubs = append(cpubs, cpub)
+	}
+
+	return script.MultiPartyContractExecutionScript(pub, C, cpubs)
+}
+
 // ContractAbandonmentTx creates tx that sends all fund to the counterparty
 // Note: This transaction isn't useful in the realworld, but is necessary for PoC
 func (d *DLC) ContractAbandonmentTx(p Contractor) (*wire.MsgTx, error) {
+	if !d.Conds.isTwoParty() {
+		return nil, errors.New("contract abandonment tx is only for two-party contracts")
+	}
+
 	famt, err := d.fundAmount()
 	if err != nil {
 		return nil, err
 	}
+	if d.Buffer != nil {
+		famt -= d.bufferTxFee()
+	}
 
-	tx, err := d.newRedeemTx()
+	tx, err := d.newRedeemTxByParty(p)
 	if err != nil {
 		return nil, err
 	}
@@ -100,9 +125,14 @@ func (d *DLC) ContractAbandonmentTx(p Contractor) (*wire.MsgTx, error) {
 
 // SignContractExecutionTxs signs contract execution txs for all deals
 func (b *Builder) SignContractExecutionTxs() ([][]byte, error) {
+	return b.SignContractExecutionTxsFor(counterparty(b.party))
+}
+
+// SignContractExecutionTxsFor signs contract execution txs of a given party for all deals
+func (b *Builder) SignContractExecutionTxsFor(p Contractor) ([][]byte, error) {
 	var sigs [][]byte
 	for idx, deal := range b.Contract.Conds.Deals {
-		sign, err := b.SignContractExecutionTx(deal, idx)
+		sign, err := b.signContractExecutionTx(p, deal, idx)
 		if err != nil {
 			return nil, err
 		}
@@ -111,22 +141,34 @@ func (b *Builder) SignContractExecutionTxs() ([][]byte, error) {
 	return sigs, nil
 }
 
-// SignContractExecutionTx signs a contract execution tx for a given party
+// SignContractExecutionTx signs a contract execution tx for the counterparty
 func (b *Builder) SignContractExecutionTx(deal *Deal, idx int) ([]byte, error) {
-	cparty := counterparty(b.party)
+	return b.signContractExecutionTx(counterparty(b.party), deal, idx)
+}
 
-	tx, err := b.Contract.ContractExecutionTx(cparty, deal, idx)
+// signContractExecutionTx signs a contract execution tx for a given party
+func (b *Builder) signContractExecutionTx(
+	p Contractor, deal *Deal, idx int) ([]byte, error) {
+	tx, err := b.Contract.ContractExecutionTx(p, deal, idx)
 	if err != nil {
 		return nil, err
 	}
 
-	return b.witsigForFundScript(tx)
+	if b.Contract.Conds.isTaproot() {
+		return b.partialSignCETx(p, tx, idx)
+	}
+	return b.witsigForRedeemTx(p, tx)
 }
 
 // AcceptCETxSignatures accepts CETx signatures received from the counterparty
 func (b *Builder) AcceptCETxSignatures(sigs [][]byte) error {
+	return b.AcceptCETxSignaturesFrom(counterparty(b.party), sigs)
+}
+
+// AcceptCETxSignaturesFrom accepts CETx signatures received from a given party
+func (b *Builder) AcceptCETxSignaturesFrom(p Contractor, sigs [][]byte) error {
 	for idx, sig := range sigs {
-		err := b.Contract.AcceptCETxSignature(b.party, idx, sig)
+		err := b.Contract.acceptCETxSignature(b.party, p, idx, sig)
 		if err != nil {
 			return err
 		}
@@ -134,8 +176,16 @@ func (b *Builder) AcceptCETxSignatures(sigs [][]byte) error {
 	return nil
 }
 
-// AcceptCETxSignature sets a signature if it's valid for an identified CETx
+// AcceptCETxSignature sets a signature of the counterparty
+// if it's valid for an identified CETx
 func (d *DLC) AcceptCETxSignature(party Contractor, idx int, sig []byte) error {
+	return d.acceptCETxSignature(party, counterparty(party), idx, sig)
+}
+
+// acceptCETxSignature sets a signature by signer
+// if it's valid for an identified CETx of a given party
+func (d *DLC) acceptCETxSignature(
+	party, signer Contractor, idx int, sig []byte) error {
 	deal, err := d.Deal(idx)
 	if err != nil {
 		return err
@@ -146,36 +196,48 @@ func (d *DLC) AcceptCETxSignature(party Contractor, idx int, sig []byte) error {
 		return err
 	}
 
-	err = d.verifyCETxSignature(party, tx, sig)
+	if d.Conds.isTaproot() {
+		err = d.verifyCETxPartialSig(party, signer, tx, idx, sig)
+	} else {
+		err = d.verifyCETxSignature(party, signer, tx, sig)
+	}
 	if err != nil {
 		return err
 	}
 
-	d.ExecSigs[idx] = sig
+	d.setExecSig(signer, idx, sig)
 	return nil
 }
 
-func (d *DLC) verifyCETxSignature(
-	p Contractor, tx *wire.MsgTx, sig []byte) error {
-
-	cparty := counterparty(p)
+// setExecSig sets a signature by signer for own CETx of an identified deal
+func (d *DLC) setExecSig(signer Contractor, idx int, sig []byte) {
+	if d.ExecSigs[signer] == nil {
+		d.ExecSigs[signer] = make([][]byte, len(d.Conds.Deals))
+	}
+	d.ExecSigs[signer][idx] = sig
+}
 
-	fsc, err := d.fundScript()
-	if err != nil {
-		return err
+// execSig returns a signature by signer for own CETx of an identified deal
+func (d *DLC) execSig(signer Contractor, idx int) []byte {
+	sigs := d.ExecSigs[signer]
+	if idx >= len(sigs) {
+		return nil
 	}
+	return sigs[idx]
+}
 
-	sighashes := txscript.NewTxSigHashes(tx)
+func (d *DLC) verifyCETxSignature(
+	p, signer Contractor, tx *wire.MsgTx, sig []byte) error {
 
-	ftx, err := d.FundTx()
+	in, err := d.redeemTxIn(p)
 	if err != nil {
 		return err
 	}
 
-	fout := ftx.TxOut[fundTxOutAt]
+	sighashes := txscript.NewTxSigHashes(tx)
 
 	hash, err := txscript.CalcWitnessSigHash(
-		fsc, sighashes, txscript.SigHashAll, tx, fundTxInAt, fout.Value)
+		in.script, sighashes, txscript.SigHashAll, tx, fundTxInAt, int64(in.amt))
 	if err != nil {
 		return err
 	}
@@ -185,7 +247,7 @@ func (d *DLC) verifyCETxSignature(
 		return err
 	}
 
-	if !s.Verify(hash, d.Pubs[cparty]) {
+	if !s.Verify(hash, d.Pubs[signer]) {
 		return errors.New("failed to verify")
 	}
 
@@ -206,15 +268,15 @@ func (d *DLC) FixedContractExecutionTx(p Contractor) (*wire.MsgTx, error) {
 	return d.ContractExecutionTx(p, deal, dID)
 }
 
-// SignedContractExecutionTx returns a contract execution tx signed by both parties
+// SignedContractExecutionTx returns a contract execution tx signed by all parties.
+// It fails with a watch-only wallet, which couldn't claim the CETx output.
 // TODO: separate SignedContractExecutionTx and SignContractExecutionTx
 func (b *Builder) SignedContractExecutionTx() (*wire.MsgTx, error) {
-	tx, err := b.Contract.FixedContractExecutionTx(b.party)
-	if err != nil {
+	if err := b.checkClosable(); err != nil {
 		return nil, err
 	}
 
-	sig, err := b.witsigForFundScript(tx)
+	tx, err := b.Contract.FixedContractExecutionTx(b.party)
 	if err != nil {
 		return nil, err
 	}
@@ -224,17 +286,26 @@ func (b *Builder) SignedContractExecutionTx() (*wire.MsgTx, error) {
 		return nil, err
 	}
 
-	cpSig := b.Contract.ExecSigs[dID]
+	if b.Contract.Conds.isTaproot() {
+		wit, err := b.witnessForTaprootCETx(tx, dID)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[fundTxInAt].Witness = wit
+		return tx, nil
+	}
+
+	sig, err := b.witsigForRedeemTx(b.party, tx)
+	if err != nil {
+		return nil, err
+	}
 
-	var sig1, sig2 []byte
-	switch b.party {
-	case FirstParty:
-		sig1, sig2 = sig, cpSig
-	case SecondParty:
-		sig1, sig2 = cpSig, sig
+	sigs := map[Contractor][]byte{b.party: sig}
+	for _, cparty := range b.Contract.Conds.counterparties(b.party) {
+		sigs[cparty] = b.Contract.execSig(cparty, dID)
 	}
 
-	wit, err := b.Contract.witnessForFundScript(sig1, sig2)
+	wit, err := b.Contract.witnessForRedeemTx(b.party, sigs)
 	if err != nil {
 		return nil, err
 	}
@@ -2,9 +2,12 @@ package dlc
 
 import (
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
 	"github.com/p2pderivatives/dlc/internal/oracle"
 	"github.com/p2pderivatives/dlc/internal/test"
 	"github.com/stretchr/testify/assert"
@@ -230,3 +233,152 @@ func runFundScript(b *Builder, tx *wire.MsgTx) error {
 	fout := fundtx.TxOut[fundTxOutAt]
 	return test.ExecuteScript(fout.PkScript, tx, fout.Value)
 }
+
+func TestMultiPartyContractExecution(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewMultiPartyDeal([]btcutil.Amount{1000, 2000, 3000}, [][]byte{{1}})
+	bs, err := setupMultiPartyContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	// all parties have the same fund tx with 3-of-3 fund script
+	fundtx, err := bs[0].Contract.FundTx()
+	assert.NoError(err)
+	for _, b := range bs[1:] {
+		tx, _ := b.Contract.FundTx()
+		assert.Equal(fundtx.TxHash(), tx.TxHash())
+	}
+	assert.Len(fundtx.TxIn, 3)
+
+	// oracle's commitment
+	privkey, C := test.RandKeys()
+	for _, b := range bs {
+		b.Contract.Oracle.Commitments[0] = C
+	}
+
+	// exchange CETx signatures and refund tx signatures
+	for _, b := range bs {
+		for _, other := range bs {
+			if b == other {
+				continue
+			}
+			sigs, err := other.SignContractExecutionTxsFor(b.party)
+			assert.NoError(err)
+			assert.NoError(b.AcceptCETxSignaturesFrom(other.party, sigs))
+
+			sig, err := other.SignRefundTx()
+			assert.NoError(err)
+			assert.NoError(b.AcceptRefundTxSignatureFrom(other.party, sig))
+		}
+	}
+
+	// signature by a wrong party is rejected
+	sigs, _ := bs[2].SignContractExecutionTxsFor(bs[0].party)
+	assert.Error(bs[0].AcceptCETxSignaturesFrom(bs[1].party, sigs))
+
+	// CETx of each party redeems fund tx
+	osigs := [][]byte{privkey.D.Bytes()}
+	oFixedMsg := &oracle.SignedMsg{Msgs: deal.Msgs, Sigs: osigs}
+	for _, b := range bs {
+		assert.NoError(b.FixDeal(oFixedMsg, []int{0}))
+
+		cetx, err := b.SignedContractExecutionTx()
+		assert.NoError(err)
+		assert.Len(cetx.TxOut, 3)
+		assert.NoError(runFundScript(b, cetx))
+
+		// closing tx redeems the settlement script
+		tx, err := b.SignedClosingTx(cetx)
+		assert.NoError(err)
+		assert.NoError(runCEScript(cetx, tx))
+	}
+
+	// refund tx
+	refundtx, err := bs[0].Contract.SignedRefundTx()
+	assert.NoError(err)
+	assert.Len(refundtx.TxOut, 3)
+	assert.NoError(runFundScript(bs[0], refundtx))
+}
+
+// A party who receives nothing has no settlement script in own CETx
+func TestMultiPartyContractExecutionTxTakeNothing(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewMultiPartyDeal([]btcutil.Amount{0, 2000, 3000}, [][]byte{{1}})
+	bs, err := setupMultiPartyContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	tx, err := bs[0].Contract.ContractExecutionTx(bs[0].party, deal, 0)
+	assert.NoError(err)
+	assert.Len(tx.TxOut, 2)
+	assert.Equal(int64(2000), tx.TxOut[0].Value)
+	assert.Equal(int64(3000), tx.TxOut[1].Value)
+}
+
+// setupMultiPartyContractors prepares three contractors
+// that have exchanged fund tx requirements
+func setupMultiPartyContractors(deal *Deal) ([]*Builder, error) {
+	famt := btcutil.Amount(10000)
+	n := len(deal.Amts)
+
+	setupConds := func() *Conditions {
+		famts := make([]btcutil.Amount, n)
+		for i := range famts {
+			famts[i] = famt
+		}
+		conds, _ := NewMultiPartyConditions(
+			&chaincfg.RegressionNetParams, time.Now(), famts,
+			1, 1, 1, []*Deal{deal}, nil)
+		return conds
+	}
+	setupWallet := func() *walletmock.Wallet {
+		w := &walletmock.Wallet{}
+		w.On("WatchOnly").Return(false)
+		w = mockSelectUnspent(w, 2*famt, 1, nil)
+		priv, pub := test.RandKeys()
+		w.On("NewFundPubkey").Return(pub, uint32(0), nil)
+		w = mockWitnessSignature(w, pub, priv)
+		w = mockWitnessSignatureWithAnyCallback(w, pub, priv)
+		return w
+	}
+
+	bs := []*Builder{}
+	for i := 0; i < n; i++ {
+		b := setupBuilder(Contractor(i), setupWallet, setupConds)
+		if err := stepPrepare(b); err != nil {
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+
+	for _, b := range bs {
+		pub, err := b.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range bs {
+			if b == other {
+				continue
+			}
+			if err = other.AcceptPubkeyFrom(b.party, pub); err != nil {
+				return nil, err
+			}
+			if err = other.AcceptUtxosFrom(b.party, b.Utxos()); err != nil {
+				return nil, err
+			}
+			if err = other.AcceptAddressFrom(b.party, b.Address()); err != nil {
+				return nil, err
+			}
+			err = other.AcceptChangeAddressFrom(b.party, b.ChangeAddress())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return bs, nil
+}
@@ -1,7 +1,9 @@
 package dlc
 
 import (
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/script"
 )
 
 // Tx sizes for fee estimation
@@ -10,6 +12,17 @@ const fundTxInSize = int64(149)
 const fundTxOutSize = int64(31)
 const cetxSize = int64(345) // context execution tx size
 const closingTxSize = int64(238)
+const mutualClosingTxSize = int64(333)
+const bufferTxSize = int64(323)
+const penaltyTxSize = int64(276)
+
+// Additional tx sizes for each contractor more than two
+const fundScriptSizePerParty = int64(27) // pubkey and signature in fund script witness
+const redeemTxOutSize = int64(31)        // p2wpkh txout
+
+// Reduced CETx size by spending taproot fund output with a single schnorr signature
+// instead of the witness of 2-of-2 fund script
+const taprootKeySpendSaving = int64(39)
 
 func (d *DLC) fundTxFeeBase() btcutil.Amount {
 	return d.Conds.FundFeerate.MulF64(float64(fundTxBaseSize))
@@ -23,17 +36,22 @@ func (d *DLC) fundTxFeePerTxIn() btcutil.Amount {
 	return d.Conds.FundFeerate.MulF64(float64(fundTxInSize))
 }
 
-func (d *DLC) fundTxFeePerTxOut() btcutil.Amount {
-	return d.Conds.FundFeerate.MulF64(float64(fundTxOutSize))
+// fundTxFeePerTxOut returns fee for a change txout of a given party.
+// The size depends on the type of the change address.
+func (d *DLC) fundTxFeePerTxOut(p Contractor) btcutil.Amount {
+	size := fundTxOutSize
+	if addr := d.ChangeAddrs[p]; addr != nil {
+		if sc, err := script.PkScriptFromAddress(addr); err == nil {
+			size = script.TxOutSize(sc)
+		}
+	}
+	return d.Conds.FundFeerate.MulF64(float64(size))
 }
 
-func (d *DLC) fundInOutFeeByParty(p Contractor) btcutil.Amount {
-	feeIns := d.fundTxFeeTxIns(len(d.Utxos[p]))
-	feeOut := btcutil.Amount(0)
-	if d.ChangeAddrs[p] != nil {
-		feeOut = d.fundTxFeePerTxOut()
-	}
-	return feeIns + feeOut
+// fundTxInsFeeByParty returns fee for fund txins of a given party.
+// Fee for a change txout is added to the deposit only if it's needed.
+func (d *DLC) fundTxInsFeeByParty(p Contractor) btcutil.Amount {
+	return d.fundTxFeeTxIns(len(d.Utxos[p]))
 }
 
 func (d *DLC) redeemTxFee(size int64) btcutil.Amount {
@@ -41,23 +59,104 @@ func (d *DLC) redeemTxFee(size int64) btcutil.Amount {
 }
 
 func (d *DLC) execTxFee() btcutil.Amount {
-	return d.redeemTxFee(cetxSize)
+	extra := int64(len(d.Conds.FundAmts) - 2)
+	if d.Conds.isTaproot() {
+		// witness size doesn't depend on the number of parties
+		size := cetxSize - taprootKeySpendSaving + extra*redeemTxOutSize
+		return d.redeemTxFee(size)
+	}
+	size := cetxSize + extra*(fundScriptSizePerParty+redeemTxOutSize)
+	return d.redeemTxFee(size)
 }
 
 func (d *DLC) closignTxFee() btcutil.Amount {
 	return d.redeemTxFee(closingTxSize)
 }
 
+// payoutTxOutExtraSize returns size of a txout paying to the address of
+// a given party in excess of a p2wpkh txout assumed by the redeem tx sizes
+func (d *DLC) payoutTxOutExtraSize(p Contractor) int64 {
+	addr := d.Addrs[p]
+	if addr == nil {
+		return 0
+	}
+	sc, err := script.PkScriptFromAddress(addr)
+	if err != nil {
+		return 0
+	}
+	if extra := script.TxOutSize(sc) - redeemTxOutSize; extra > 0 {
+		return extra
+	}
+	return 0
+}
+
+// closingTxFeeByParty returns fee of closing tx of a given party
+func (d *DLC) closingTxFeeByParty(p Contractor) btcutil.Amount {
+	return d.closignTxFee() + d.redeemTxFee(d.payoutTxOutExtraSize(p))
+}
+
+// payoutFeeByParty returns fee for payout txouts of a given party
+// in CETxs of the other parties and own closing tx in excess of p2wpkh txouts.
+// It's paid by the party since it depends on the type of the party's address.
+func (d *DLC) payoutFeeByParty(p Contractor) btcutil.Amount {
+	return d.redeemTxFee(2 * d.payoutTxOutExtraSize(p))
+}
+
+// payoutFee returns total of payout fees of all parties
+func (d *DLC) payoutFee() btcutil.Amount {
+	total := btcutil.Amount(0)
+	for _, p := range d.Conds.Parties() {
+		total += d.payoutFeeByParty(p)
+	}
+	return total
+}
+
+func (d *DLC) mutualClosingTxFee() btcutil.Amount {
+	size := mutualClosingTxSize
+	if d.Conds.isTaproot() {
+		size -= taprootKeySpendSaving
+	}
+	for _, p := range d.Conds.Parties() {
+		size += d.payoutTxOutExtraSize(p)
+	}
+	return d.redeemTxFee(size)
+}
+
+func (d *DLC) bufferTxFee() btcutil.Amount {
+	return d.redeemTxFee(bufferTxSize)
+}
+
+func (d *DLC) penaltyTxFee() btcutil.Amount {
+	return d.redeemTxFee(penaltyTxSize)
+}
+
 func (d *DLC) feeCommon() btcutil.Amount {
 	ffeeBase := d.fundTxFeeBase()
 	efee := d.execTxFee()
 	clfee := d.closignTxFee()
 
-	return (ffeeBase + efee + clfee) / 2
+	n := btcutil.Amount(len(d.Conds.FundAmts))
+	return (ffeeBase + efee + clfee) / n
 }
 
 func (d *DLC) feeByParty(p Contractor) btcutil.Amount {
 	feeCommon := d.feeCommon()
-	feeFundInOut := d.fundInOutFeeByParty(p)
-	return feeCommon + feeFundInOut
+	feeFundIns := d.fundTxInsFeeByParty(p)
+	return feeCommon + feeFundIns + d.payoutFeeByParty(p)
+}
+
+// Tx sizes (vbytes) for CPFP child tx fee estimation
+const cpfpTxBaseSize = int64(11) // version, locktime, txin/txout counts, segwit marker
+const ceTxInSize = int64(81)     // txin unlocking contract execution script
+
+// p2wpkhWitnessSize is size of a p2wpkh witness
+// (item count, signature and compressed pubkey with their lengths)
+const p2wpkhWitnessSize = int64(1 + 1 + 72 + 1 + 33)
+
+// txVSize returns virtual size of tx
+func txVSize(tx *wire.MsgTx) int64 {
+	base := int64(tx.SerializeSizeStripped())
+	total := int64(tx.SerializeSize())
+	weight := base*3 + total
+	return (weight + 3) / 4
 }
@@ -0,0 +1,148 @@
+package dlc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
+)
+
+// InvalidFundTxPSBTError is raised when a PSBT isn't of the fund tx
+type InvalidFundTxPSBTError struct{ error }
+
+// FundTxPSBT returns the fund tx as a PSBT (BIP174) with utxos of all parties
+// and the witness script of the fund output, so that standard tools can
+// check and sign it. Txins whose witnesses have been accepted are finalized.
+func (d *DLC) FundTxPSBT() (*psbt.Packet, error) {
+	tx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	p, err := psbt.New(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, party := range d.Conds.Parties() {
+		wits := d.FundWits[party]
+		for i, idx := range d.fundTxInsIdxs(party) {
+			utxo := d.Utxos[party][i]
+			pkScript, err := hex.DecodeString(utxo.ScriptPubKey)
+			if err != nil {
+				return nil, err
+			}
+			if len(pkScript) == 0 {
+				msg := fmt.Sprintf(
+					"scriptPubKey is required for fund txin %d of %s", idx, party)
+				return nil, errors.New(msg)
+			}
+			amt, err := btcutil.NewAmount(utxo.Amount)
+			if err != nil {
+				return nil, err
+			}
+			p.Inputs[idx].WitnessUtxo = wire.NewTxOut(int64(amt), pkScript)
+
+			if len(wits) == len(d.Utxos[party]) {
+				fw, err := psbt.SerializeWitness(wits[i])
+				if err != nil {
+					return nil, err
+				}
+				p.Inputs[idx].FinalScriptWitness = fw
+			}
+		}
+	}
+
+	if d.Conds.FundMode == FundModeP2WSH {
+		fs, err := d.fundScript()
+		if err != nil {
+			return nil, err
+		}
+		p.Outputs[fundTxOutAt].WitnessScript = fs
+	}
+
+	return p, nil
+}
+
+// checkFundTxPSBT checks if a PSBT is of the fund tx
+func (d *DLC) checkFundTxPSBT(p *psbt.Packet) error {
+	tx, err := d.FundTx()
+	if err != nil {
+		return err
+	}
+	if p.UnsignedTx.TxHash() != tx.TxHash() {
+		msg := fmt.Sprintf(
+			"PSBT isn't of the fund tx. expected: %s, actual: %s",
+			tx.TxHash(), p.UnsignedTx.TxHash())
+		return &InvalidFundTxPSBTError{error: errors.New(msg)}
+	}
+	return nil
+}
+
+// SignFundTxPSBT signs fund tx and adds partial signatures for the txins
+// owned by the party to a PSBT of the fund tx
+func (b *Builder) SignFundTxPSBT(p *psbt.Packet) (*psbt.Packet, error) {
+	if err := b.Contract.checkFundTxPSBT(p); err != nil {
+		return nil, err
+	}
+
+	wits, err := b.SignFundTx()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, idx := range b.Contract.fundTxInsIdxs(b.party) {
+		pi := &p.Inputs[idx]
+		wit := wits[i]
+		// p2wpkh witness consists of a signature and a pubkey
+		if len(wit) == 2 {
+			err = pi.AddPartialSig(wit[1], wit[0])
+		} else {
+			pi.FinalScriptWitness, err = psbt.SerializeWitness(wit)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// AcceptFundTxPSBT finalizes fund txins in a PSBT of the fund tx, and accepts
+// witnesses of the parties whose txins are all finalized after verifying them.
+// It returns the parties whose witnesses are accepted.
+// The PSBT isn't modified.
+func (b *Builder) AcceptFundTxPSBT(p *psbt.Packet) ([]Contractor, error) {
+	if err := b.Contract.checkFundTxPSBT(p); err != nil {
+		return nil, err
+	}
+	p, err := psbt.Combine(p)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := []Contractor{}
+	for _, party := range b.Contract.Conds.Parties() {
+		wits := []wire.TxWitness{}
+		for _, idx := range b.Contract.fundTxInsIdxs(party) {
+			if p.FinalizeInput(idx) != nil {
+				break
+			}
+			wit, err := psbt.ParseWitness(p.Inputs[idx].FinalScriptWitness)
+			if err != nil {
+				return nil, err
+			}
+			wits = append(wits, wit)
+		}
+		if len(wits) != len(b.Contract.Utxos[party]) {
+			continue
+		}
+
+		if err = b.AcceptFundWitnessesFrom(party, wits); err != nil {
+			return nil, err
+		}
+		accepted = append(accepted, party)
+	}
+	return accepted, nil
+}
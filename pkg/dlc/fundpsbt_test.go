@@ -0,0 +1,141 @@
+package dlc
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupFundSigningWallet returns a wallet with a p2wpkh utxo of 1000 satoshi
+// which signs fund txins spending it
+func setupFundSigningWallet(priv *btcec.PrivateKey) func() *walletmock.Wallet {
+	return func() *walletmock.Wallet {
+		w := setupFundWitnessWallet(priv)()
+		call := w.On("WitnessSignTxByIdxs", mock.Anything, mock.Anything)
+		call.Run(func(args mock.Arguments) {
+			tx := args.Get(0).(*wire.MsgTx)
+			idxs := args.Get(1).([]int)
+			pkScript, _ := script.P2WPKHpkScript(priv.PubKey())
+			wits := []wire.TxWitness{}
+			var err error
+			for _, idx := range idxs {
+				var wit wire.TxWitness
+				wit, err = txscript.WitnessSignature(tx, txscript.NewTxSigHashes(tx),
+					idx, 1000, pkScript, txscript.SigHashAll, priv, true)
+				wits = append(wits, wit)
+			}
+			call.ReturnArguments = mock.Arguments{wits, err}
+		})
+		return w
+	}
+}
+
+func setupFundTxPSBTBuilders(t *testing.T) (b1, b2 *Builder) {
+	assert := assert.New(t)
+	priv1, _ := test.RandKeys()
+	priv2, _ := test.RandKeys()
+	b1 = setupBuilder(FirstParty, setupFundSigningWallet(priv1), newTestConditions)
+	b2 = setupBuilder(SecondParty, setupFundSigningWallet(priv2), newTestConditions)
+	assert.NoError(stepPrepare(b1))
+	assert.NoError(stepPrepare(b2))
+	assert.NoError(stepSendRequirments(b1, b2))
+	assert.NoError(stepSendRequirments(b2, b1))
+	return b1, b2
+}
+
+// exchangePSBT encodes and decodes a PSBT as it's passed to other tools
+func exchangePSBT(t *testing.T, p *psbt.Packet) *psbt.Packet {
+	b64, err := p.B64Encode()
+	assert.NoError(t, err)
+	decoded, err := psbt.B64Decode(b64)
+	assert.NoError(t, err)
+	return decoded
+}
+
+func TestFundTxPSBT(t *testing.T) {
+	assert := assert.New(t)
+	b1, _ := setupFundTxPSBTBuilders(t)
+	d := b1.Contract
+
+	p, err := d.FundTxPSBT()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	tx, _ := d.FundTx()
+	assert.Equal(tx.TxHash(), p.UnsignedTx.TxHash())
+	for _, pi := range p.Inputs {
+		assert.NotNil(pi.WitnessUtxo)
+		assert.Equal(int64(1000), pi.WitnessUtxo.Value)
+		assert.False(pi.IsFinalized())
+	}
+	fs, _ := d.fundScript()
+	assert.Equal(fs, p.Outputs[fundTxOutAt].WitnessScript)
+
+	// scriptPubKey of utxos is required
+	d.Utxos[SecondParty][0].ScriptPubKey = ""
+	_, err = d.FundTxPSBT()
+	assert.Error(err)
+}
+
+func TestSignAndAcceptFundTxPSBT(t *testing.T) {
+	assert := assert.New(t)
+	b1, b2 := setupFundTxPSBTBuilders(t)
+
+	p, err := b1.Contract.FundTxPSBT()
+	assert.NoError(err)
+
+	// each party adds its partial signatures
+	p1, err := b1.SignFundTxPSBT(exchangePSBT(t, p))
+	assert.NoError(err)
+	p2, err := b2.SignFundTxPSBT(exchangePSBT(t, p))
+	assert.NoError(err)
+	idx1 := b1.Contract.fundTxInsIdxs(FirstParty)[0]
+	assert.Len(p1.Inputs[idx1].PartialSigs, 1)
+
+	// witnesses of a party whose txins are all signed are accepted
+	accepted, err := b2.AcceptFundTxPSBT(exchangePSBT(t, p1))
+	assert.NoError(err)
+	assert.Equal([]Contractor{FirstParty}, accepted)
+	assert.False(p1.Inputs[idx1].IsFinalized())
+
+	// combined PSBT completes the fund tx
+	combined, err := psbt.Combine(p1, p2)
+	assert.NoError(err)
+	accepted, err = b1.AcceptFundTxPSBT(exchangePSBT(t, combined))
+	assert.NoError(err)
+	assert.Equal([]Contractor{FirstParty, SecondParty}, accepted)
+
+	signed, err := b1.Contract.SignedFundTx()
+	assert.NoError(err)
+	assert.NoError(combined.Finalize())
+	extracted, err := combined.Extract()
+	assert.NoError(err)
+	assert.Equal(extracted, signed)
+
+	// PSBT of the contract has finalized txins
+	p, err = b1.Contract.FundTxPSBT()
+	assert.NoError(err)
+	assert.True(p.IsComplete())
+}
+
+func TestAcceptFundTxPSBTOfAnotherTx(t *testing.T) {
+	assert := assert.New(t)
+	b1, b2 := setupFundTxPSBTBuilders(t)
+
+	p, _ := b1.Contract.FundTxPSBT()
+	p.UnsignedTx.LockTime = 1
+
+	_, err := b1.SignFundTxPSBT(p)
+	assert.IsType(&InvalidFundTxPSBTError{}, err)
+	_, err = b2.AcceptFundTxPSBT(p)
+	assert.IsType(&InvalidFundTxPSBTError{}, err)
+}
@@ -1,21 +1,32 @@
 package dlc
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/p2pderivatives/dlc/pkg/utils"
+	"github.com/p2pderivatives/dlc/pkg/wallet"
 )
 
 const fundTxOutAt = 0 // fund txout is always at 0 in fund tx
 const fundTxInAt = 0  // fund txin is always at 0 in redeem tx
 
+// UtxoLockDuration is how long utxos are reserved for a contract being negotiated
+const UtxoLockDuration = 24 * time.Hour
+
 // ChangeAddressNotExistsError is raised when change address doesn't exist
 type ChangeAddressNotExistsError struct{ error }
 
+// InvalidFundWitnessError is raised when a witness for fund txin is invalid
+type InvalidFundWitnessError struct{ error }
+
 // FundTx constructs fund tx using prepared fund tx requirements
 func (d *DLC) FundTx() (*wire.MsgTx, error) {
 	tx := wire.NewMsgTx(txVersion)
@@ -26,7 +37,7 @@ func (d *DLC) FundTx() (*wire.MsgTx, error) {
 	}
 	tx.AddTxOut(txout)
 
-	for _, p := range []Contractor{FirstParty, SecondParty} {
+	for _, p := range d.Conds.Parties() {
 		// txins
 		total := btcutil.Amount(0)
 		for _, utxo := range d.Utxos[p] {
@@ -51,13 +62,13 @@ func (d *DLC) FundTx() (*wire.MsgTx, error) {
 			return nil, errors.New(msg)
 		}
 
-		if change > 0 {
+		if d.hasChangeTxOut(p) {
 			addr := d.ChangeAddrs[p]
 			if addr == nil {
 				msg := fmt.Sprintf("change address must be provided by %s", p)
 				return nil, &ChangeAddressNotExistsError{error: errors.New(msg)}
 			}
-			sc, err := script.P2WPKHpkScriptFromAddress(addr)
+			sc, err := script.PkScriptFromAddress(addr)
 			if err != nil {
 				return nil, err
 			}
@@ -66,7 +77,7 @@ func (d *DLC) FundTx() (*wire.MsgTx, error) {
 	}
 
 	if d.Conds.PremiumInfo != nil {
-		sc, err := txscript.PayToAddrScript(d.Conds.PremiumInfo.PremiumDestAddress)
+		sc, err := script.PkScriptFromAddress(d.Conds.PremiumInfo.PremiumDestAddress)
 
 		if err != nil {
 			return nil, err
@@ -81,27 +92,51 @@ func (d *DLC) FundTx() (*wire.MsgTx, error) {
 }
 
 func (d *DLC) fundScript() ([]byte, error) {
-	pub1, ok := d.Pubs[FirstParty]
-	if !ok {
-		return nil, errors.New("First party must provide a pubkey for fund script")
+	pubs, err := d.fundPubkeys()
+	if err != nil {
+		return nil, err
 	}
-	pub2, ok := d.Pubs[SecondParty]
-	if !ok {
-		return nil, errors.New("Second party must provide a pubkey for fund script")
+
+	return script.MultiPartyFundScript(pubs)
+}
+
+// fundPubkeys returns pubkeys of all parties in order
+func (d *DLC) fundPubkeys() ([]*btcec.PublicKey, error) {
+	pubs := []*btcec.PublicKey{}
+	for _, p := range d.Conds.Parties() {
+		pub, ok := d.Pubs[p]
+		if !ok {
+			msg := fmt.Sprintf("%s must provide a pubkey for fund script", p)
+			return nil, errors.New(msg)
+		}
+		pubs = append(pubs, pub)
 	}
+	return pubs, nil
+}
 
-	return script.FundScript(pub1, pub2)
+// fundPkScript returns a pkScript of fund output for the fund mode
+func (d *DLC) fundPkScript() ([]byte, error) {
+	switch d.Conds.FundMode {
+	case FundModeP2WSH:
+		fs, err := d.fundScript()
+		if err != nil {
+			return nil, err
+		}
+		return script.P2WSHpkScript(fs)
+	case FundModeTaproot:
+		ft, err := d.fundTaproot()
+		if err != nil {
+			return nil, err
+		}
+		return script.P2TRpkScript(ft.outputKey())
+	}
+	return nil, fmt.Errorf("unknown fund mode. %s", d.Conds.FundMode)
 }
 
 // fundTxOutForRedeemTx creates a txout for the txin of redeem tx.
 // The value of the txout is calculated by `fund amount + redeem tx fee`
 func (d *DLC) fundTxOutForRedeemTx() (*wire.TxOut, error) {
-	fs, err := d.fundScript()
-	if err != nil {
-		return nil, err
-	}
-
-	pkScript, err := script.P2WSHpkScript(fs)
+	pkScript, err := d.fundPkScript()
 	if err != nil {
 		return nil, err
 	}
@@ -111,7 +146,7 @@ func (d *DLC) fundTxOutForRedeemTx() (*wire.TxOut, error) {
 		return nil, err
 	}
 
-	fee := d.execTxFee() + d.closignTxFee()
+	fee := d.execTxFee() + d.closignTxFee() + d.payoutFee()
 	amt += fee
 
 	txout := wire.NewTxOut(int64(amt), pkScript)
@@ -119,34 +154,74 @@ func (d *DLC) fundTxOutForRedeemTx() (*wire.TxOut, error) {
 	return txout, nil
 }
 
+// witnessForFundScript constructs a witness for fund script
+// with signatures of all parties
 func (d *DLC) witnessForFundScript(
-	sig1, sig2 []byte) (wire.TxWitness, error) {
+	sigs map[Contractor][]byte) (wire.TxWitness, error) {
 
 	sc, err := d.fundScript()
 	if err != nil {
 		return nil, err
 	}
 
-	wit := script.WitnessForFundScript(sig1, sig2, sc)
+	signs := [][]byte{}
+	for _, p := range d.Conds.Parties() {
+		signs = append(signs, sigs[p])
+	}
+
+	wit := script.WitnessForMultiPartyFundScript(signs, sc)
 	return wit, nil
 }
 
 // fundAmount calculates total fund amount
 func (d *DLC) fundAmount() (btcutil.Amount, error) {
-	amt1, ok := d.Conds.FundAmts[FirstParty]
-	if !ok {
-		return 0, errors.New("Fund amount for first party isn't set")
-	}
-	amt2, ok := d.Conds.FundAmts[SecondParty]
-	if !ok {
-		return 0, errors.New("Fund amount for second party isn't set")
+	total := btcutil.Amount(0)
+	for _, p := range d.Conds.Parties() {
+		amt, ok := d.Conds.FundAmts[p]
+		if !ok {
+			msg := fmt.Sprintf("Fund amount for %s isn't set", p)
+			return 0, errors.New(msg)
+		}
+		total += amt
 	}
 
-	return amt1 + amt2, nil
+	return total, nil
 }
 
 // DepositAmt calculates fund amount + fees
 func (d *DLC) DepositAmt(p Contractor) btcutil.Amount {
+	deposit := d.depositAmtWithoutChange(p)
+	if d.hasChangeTxOut(p) {
+		deposit += d.fundTxFeePerTxOut(p)
+	}
+	return deposit
+}
+
+// hasChangeTxOut returns true if fund tx has a change txout of a given party.
+// The change txout is omitted if the excess of the party's utxos
+// doesn't exceed the fee for it or the change is below the dust limit,
+// and the excess is paid as fee instead.
+func (d *DLC) hasChangeTxOut(p Contractor) bool {
+	excess := d.fundUtxosAmount(p) - d.depositAmtWithoutChange(p)
+	change := excess - d.fundTxFeePerTxOut(p)
+	return change > 0 && change >= d.Conds.DustLimit
+}
+
+// fundUtxosAmount returns total amount of fund utxos of a given party
+func (d *DLC) fundUtxosAmount(p Contractor) btcutil.Amount {
+	total := btcutil.Amount(0)
+	for _, utxo := range d.Utxos[p] {
+		amt, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			continue
+		}
+		total += amt
+	}
+	return total
+}
+
+// depositAmtWithoutChange calculates fund amount + fees without a change txout
+func (d *DLC) depositAmtWithoutChange(p Contractor) btcutil.Amount {
 	famt := d.Conds.FundAmts[p]
 	fee := d.feeByParty(p)
 	premium := btcutil.Amount(0)
@@ -163,23 +238,43 @@ func (b *Builder) FundAmt() btcutil.Amount {
 	return b.Contract.Conds.FundAmts[b.party]
 }
 
+// SetCoinSelector sets a coin selection strategy used to prepare fund utxos.
+// The wallet's default strategy is used if it's not set.
+func (b *Builder) SetCoinSelector(s wallet.CoinSelector) {
+	b.selector = s
+}
+
 // PrepareFundTx prepares fundtx ins and out
 func (b *Builder) PrepareFundTx() error {
 	famt := b.FundAmt()
-	feeCommon := b.Contract.feeCommon()
+	feeCommon := b.Contract.feeCommon() + b.Contract.payoutFeeByParty(b.party)
 	premiumAmount := btcutil.Amount(0)
 	if premiumInfo := b.Contract.Conds.PremiumInfo; premiumInfo != nil && premiumInfo.PayingParty == b.party {
 		premiumAmount = premiumInfo.PremiumAmount
 	}
 
-	utxos, change, err := b.wallet.SelectUnspent(
+	// select and reserve utxos atomically
+	utxos, change, err := b.wallet.SelectAndLockUnspent(
+		b.selector,
 		famt+feeCommon+premiumAmount,
 		b.Contract.fundTxFeePerTxIn(),
-		b.Contract.fundTxFeePerTxOut())
+		b.Contract.fundTxFeePerTxOut(b.party),
+		time.Now().Add(UtxoLockDuration))
 	if err != nil {
 		return err
 	}
 
+	if change > 0 && b.Contract.ChangeAddrs[b.party] == nil {
+		msg := fmt.Sprintf("Change address must be provided by %s", b.party)
+		err = b.unlockUtxosOnError(utxos, errors.New(msg))
+		return &ChangeAddressNotExistsError{error: err}
+	}
+
+	// release utxos previously reserved
+	if err = b.ReleaseUtxos(); err != nil {
+		return err
+	}
+
 	// set utxos to DLC
 	_utxos := []*Utxo{}
 	for i, _ := range utxos {
@@ -187,12 +282,25 @@ func (b *Builder) PrepareFundTx() error {
 	}
 	b.Contract.Utxos[b.party] = _utxos
 
-	if change > 0 && b.Contract.ChangeAddrs[b.party] == nil {
-		msg := fmt.Sprintf("Change address must be provided by %s", b.party)
-		return &ChangeAddressNotExistsError{error: errors.New(msg)}
+	return nil
+}
+
+// ReleaseUtxos releases own utxos reserved by PrepareFundTx.
+// It should be called when the negotiation is aborted.
+func (b *Builder) ReleaseUtxos() error {
+	if len(b.Contract.Utxos[b.party]) == 0 {
+		return nil
 	}
+	return b.wallet.UnlockUtxos(b.Utxos())
+}
 
-	return nil
+// unlockUtxosOnError releases utxos reserved for a failed operation.
+// The error of unlocking is added to the message of a given error.
+func (b *Builder) unlockUtxosOnError(utxos []Utxo, err error) error {
+	if uerr := b.wallet.UnlockUtxos(utxos); uerr != nil {
+		return fmt.Errorf("%v. failed to unlock utxos: %v", err, uerr)
+	}
+	return err
 }
 
 // Utxos returns utxos
@@ -206,15 +314,23 @@ func (b *Builder) Utxos() []Utxo {
 
 // AcceptUtxos accepts utxos
 func (b *Builder) AcceptUtxos(utxos []Utxo) error {
-	cp := counterparty(b.party)
-
-	// TODO: validate if total amount is enough
+	return b.AcceptUtxosFrom(counterparty(b.party), utxos)
+}
 
+// AcceptUtxosFrom accepts utxos of a given party
+// after validating them. The utxos aren't accepted if they're invalid.
+func (b *Builder) AcceptUtxosFrom(p Contractor, utxos []Utxo) error {
 	_utxos := []*Utxo{}
 	for i, _ := range utxos {
 		_utxos = append(_utxos, &utxos[i])
 	}
-	b.Contract.Utxos[cp] = _utxos
+
+	prev := b.Contract.Utxos[p]
+	b.Contract.Utxos[p] = _utxos
+	if err := b.validateUtxos(p); err != nil {
+		b.Contract.Utxos[p] = prev
+		return err
+	}
 
 	return nil
 }
@@ -230,8 +346,16 @@ func (b *Builder) Address() btcutil.Address {
 
 // AcceptAdderss accepts address from the counterparty
 func (b *Builder) AcceptAdderss(addr btcutil.Address) error {
-	cp := counterparty(b.party)
-	b.Contract.Addrs[cp] = addr
+	return b.AcceptAddressFrom(counterparty(b.party), addr)
+}
+
+// AcceptAddressFrom accepts address from a given party
+// if its type is supported
+func (b *Builder) AcceptAddressFrom(p Contractor, addr btcutil.Address) error {
+	if _, err := script.PkScriptFromAddress(addr); err != nil {
+		return err
+	}
+	b.Contract.Addrs[p] = addr
 	return nil
 }
 
@@ -246,8 +370,16 @@ func (b *Builder) ChangeAddress() btcutil.Address {
 
 // AcceptChangeAdderss accepts change address from the counterparty
 func (b *Builder) AcceptChangeAdderss(addr btcutil.Address) error {
-	cp := counterparty(b.party)
-	b.Contract.ChangeAddrs[cp] = addr
+	return b.AcceptChangeAddressFrom(counterparty(b.party), addr)
+}
+
+// AcceptChangeAddressFrom accepts change address from a given party
+// if its type is supported
+func (b *Builder) AcceptChangeAddressFrom(p Contractor, addr btcutil.Address) error {
+	if _, err := script.PkScriptFromAddress(addr); err != nil {
+		return err
+	}
+	b.Contract.ChangeAddrs[p] = addr
 	return nil
 }
 
@@ -319,7 +451,7 @@ func (d *DLC) SignedFundTx() (*wire.MsgTx, error) {
 		return nil, err
 	}
 
-	for _, p := range []Contractor{FirstParty, SecondParty} {
+	for _, p := range d.Conds.Parties() {
 		wits := d.FundWits[p]
 
 		idxs := d.fundTxInsIdxs(p)
@@ -351,16 +483,12 @@ func (b *Builder) SendFundTx() error {
 
 // fundTxInAt returns indices of txin in fundtx by the party
 func (d *DLC) fundTxInsIdxs(p Contractor) (idxs []int) {
-	nTxInMe := len(d.Utxos[p])
-	var txinFrom, txinTo int
-	if p == FirstParty {
-		txinFrom = 0
-		txinTo = nTxInMe
-	} else {
-		nTxInCP := len(d.Utxos[FirstParty])
-		txinFrom = nTxInCP
-		txinTo = txinFrom + nTxInMe
+	// txins are ordered by contractor
+	txinFrom := 0
+	for q := Contractor(0); q < p; q++ {
+		txinFrom += len(d.Utxos[q])
 	}
+	txinTo := txinFrom + len(d.Utxos[p])
 	for i := txinFrom; i < txinTo; i++ {
 		idxs = append(idxs, i)
 	}
@@ -368,9 +496,68 @@ func (d *DLC) fundTxInsIdxs(p Contractor) (idxs []int) {
 }
 
 // AcceptFundWitnesses accepts witnesses for fund txins owned by the counerparty
-func (b *Builder) AcceptFundWitnesses(wits []wire.TxWitness) {
-	cparty := counterparty(b.party)
-	b.Contract.FundWits[cparty] = wits
+func (b *Builder) AcceptFundWitnesses(wits []wire.TxWitness) error {
+	return b.AcceptFundWitnessesFrom(counterparty(b.party), wits)
+}
+
+// AcceptFundWitnessesFrom accepts witnesses for fund txins owned by a given party
+// after verifying them. The witnesses aren't accepted if any of them is invalid.
+func (b *Builder) AcceptFundWitnessesFrom(p Contractor, wits []wire.TxWitness) error {
+	if err := b.verifyFundWitnesses(p, wits); err != nil {
+		return err
+	}
+	b.Contract.FundWits[p] = wits
+	return nil
+}
+
+// verifyFundWitnesses verifies witnesses for fund txins owned by a given party
+// by executing scripts of the previous outputs looked up on chain
+func (b *Builder) verifyFundWitnesses(p Contractor, wits []wire.TxWitness) error {
+	d := b.Contract
+	tx, err := d.FundTx()
+	if err != nil {
+		return err
+	}
+
+	idxs := d.fundTxInsIdxs(p)
+	if len(wits) != len(idxs) {
+		msg := fmt.Sprintf(
+			"Expected %d witnesses from %s, but found %d", len(idxs), p, len(wits))
+		return &InvalidFundWitnessError{error: errors.New(msg)}
+	}
+
+	for i, idx := range idxs {
+		tx.TxIn[idx].Witness = wits[i]
+	}
+
+	sighashes := txscript.NewTxSigHashes(tx)
+	for _, idx := range idxs {
+		op := tx.TxIn[idx].PreviousOutPoint
+		// the counterparty's scriptPubKey and amount aren't trusted
+		prevout, err := b.txOutOnChain(&op)
+		if err != nil {
+			return err
+		}
+		pkScript, err := hex.DecodeString(prevout.ScriptPubKey.Hex)
+		if err != nil {
+			return err
+		}
+		amt, err := btcutil.NewAmount(prevout.Value)
+		if err != nil {
+			return err
+		}
+
+		vm, err := txscript.NewEngine(
+			pkScript, tx, idx, txscript.StandardVerifyFlags, nil, sighashes, int64(amt))
+		if err == nil {
+			err = vm.Execute()
+		}
+		if err != nil {
+			msg := fmt.Sprintf(
+				"invalid witness for fund txin %d of %s. %s: %v", idx, p, op, err)
+			return &InvalidFundWitnessError{error: errors.New(msg)}
+		}
+	}
 
-	// TODO: verify
+	return nil
 }
@@ -1,13 +1,17 @@
 package dlc
 
 import (
+	"encoding/hex"
 	"errors"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
 	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -17,8 +21,8 @@ import (
 func TestPrepareFundTxNotEnoughUtxos(t *testing.T) {
 	setupWallet := func() *walletmock.Wallet {
 		w := setupTestWallet()
-		w.On("SelectUnspent",
-			mock.Anything, mock.Anything, mock.Anything,
+		w.On("SelectAndLockUnspent",
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
 		).Return(
 			[]wallet.Utxo{}, btcutil.Amount(0), errors.New("not enough utxos"))
 		return w
@@ -50,9 +54,68 @@ func TestPrepareFundTx(t *testing.T) {
 	utxos := b.Contract.Utxos[b.party]
 	assert.NotNil(utxos, "utxos")
 
+	// utxos are reserved for the contract
+	w := b.wallet.(*walletmock.Wallet)
+	w.AssertNumberOfCalls(t, "SelectAndLockUnspent", 1)
+	w.AssertNotCalled(t, "LockUtxos", mock.Anything, mock.Anything)
+
+	// and released on abort
+	err = b.ReleaseUtxos()
+	assert.NoError(err)
+	w.AssertCalled(t, "UnlockUtxos", b.Utxos())
+
 	// TODO: check if total amount is enough
 }
 
+// PrepareFundTx should release reserved utxos if it fails
+func TestPrepareFundTxReleasesUtxosOnFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	setupWallet := func() *walletmock.Wallet {
+		return mockSelectUnspent(setupTestWallet(), 1, 1, nil)
+	}
+	b := setupBuilder(FirstParty, setupWallet, newTestConditions)
+	b.Contract.ChangeAddrs[FirstParty] = nil
+
+	err := b.PrepareFundTx()
+	assert.IsType(&ChangeAddressNotExistsError{}, err)
+	assert.Empty(b.Contract.Utxos[FirstParty])
+
+	w := b.wallet.(*walletmock.Wallet)
+	w.AssertNumberOfCalls(t, "UnlockUtxos", 1)
+}
+
+// PrepareFundTx should report utxos that couldn't be released
+func TestPrepareFundTxUnlockFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	setupWallet := func() *walletmock.Wallet {
+		w := mockSelectUnspent(setupTestWallet(), 1, 1, nil)
+		w.On("UnlockUtxos", mock.Anything).Return(errors.New("wallet is locked"))
+		return w
+	}
+	b := setupBuilder(FirstParty, setupWallet, newTestConditions)
+	b.Contract.ChangeAddrs[FirstParty] = nil
+
+	err := b.PrepareFundTx()
+	if assert.IsType(&ChangeAddressNotExistsError{}, err) {
+		assert.Contains(err.Error(), "wallet is locked")
+	}
+}
+
+// PrepareFundTx should release utxos reserved by the previous call
+func TestPrepareFundTxAgain(t *testing.T) {
+	assert := assert.New(t)
+
+	b := setupBuilder(FirstParty, setupTestWallet, newTestConditions)
+	assert.NoError(b.PrepareFundTx())
+	prev := b.Utxos()
+
+	assert.NoError(b.PrepareFundTx())
+	w := b.wallet.(*walletmock.Wallet)
+	w.AssertCalled(t, "UnlockUtxos", prev)
+}
+
 // PrepareFundTx shouldn't have txouts if no changes
 func TestPrepareFundTxNoChange(t *testing.T) {
 	assert := assert.New(t)
@@ -141,3 +204,251 @@ func TestRedeemFundTx(t *testing.T) {
 	err = test.ExecuteScript(fout.PkScript, redeemtx, fout.Value)
 	assert.Nil(err)
 }
+
+// setupFundWitnessWallet returns a wallet whose utxo is locked by p2wpkh script
+func setupFundWitnessWallet(priv *btcec.PrivateKey) func() *walletmock.Wallet {
+	return func() *walletmock.Wallet {
+		w := setupTestWallet()
+		pkScript, _ := script.P2WPKHpkScript(priv.PubKey())
+		utxo := wallet.Utxo{
+			TxID:         randTxID(),
+			Amount:       float64(1000) / btcutil.SatoshiPerBitcoin,
+			ScriptPubKey: hex.EncodeToString(pkScript),
+		}
+		w.On("SelectAndLockUnspent",
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		).Return([]wallet.Utxo{utxo}, btcutil.Amount(1), nil)
+		return w
+	}
+}
+
+func signFundTxIn(
+	d *DLC, p Contractor, priv *btcec.PrivateKey) ([]wire.TxWitness, error) {
+	tx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	sighashes := txscript.NewTxSigHashes(tx)
+	wits := []wire.TxWitness{}
+	for i, idx := range d.fundTxInsIdxs(p) {
+		utxo := d.Utxos[p][i]
+		pkScript, _ := hex.DecodeString(utxo.ScriptPubKey)
+		amt, _ := btcutil.NewAmount(utxo.Amount)
+		wit, err := txscript.WitnessSignature(
+			tx, sighashes, idx, int64(amt), pkScript, txscript.SigHashAll, priv, true)
+		if err != nil {
+			return nil, err
+		}
+		wits = append(wits, wit)
+	}
+	return wits, nil
+}
+
+func TestAcceptFundWitnesses(t *testing.T) {
+	assert := assert.New(t)
+
+	priv1, _ := test.RandKeys()
+	priv2, _ := test.RandKeys()
+	b1 := setupBuilder(FirstParty, setupFundWitnessWallet(priv1), newTestConditions)
+	b2 := setupBuilder(SecondParty, setupFundWitnessWallet(priv2), newTestConditions)
+	assert.NoError(stepPrepare(b1))
+	assert.NoError(stepPrepare(b2))
+	assert.NoError(stepSendRequirments(b1, b2))
+	assert.NoError(stepSendRequirments(b2, b1))
+
+	// signed by a wrong key
+	wits, err := signFundTxIn(b1.Contract, FirstParty, priv2)
+	assert.NoError(err)
+	err = b2.AcceptFundWitnesses(wits)
+	assert.IsType(&InvalidFundWitnessError{}, err)
+	assert.Nil(b2.Contract.FundWits[FirstParty])
+
+	// missing witnesses
+	err = b2.AcceptFundWitnesses([]wire.TxWitness{})
+	assert.IsType(&InvalidFundWitnessError{}, err)
+
+	wits, err = signFundTxIn(b1.Contract, FirstParty, priv1)
+	assert.NoError(err)
+	err = b2.AcceptFundWitnesses(wits)
+	assert.NoError(err)
+	assert.Equal(wits, b2.Contract.FundWits[FirstParty])
+
+	// witnesses are verified against the output on chain
+	// rather than the scriptPubKey provided by the counterparty
+	utxo := b2.Contract.Utxos[FirstParty][0]
+	b2.SetRPCClient(mockGetTxOut(testTxOutResult(utxo.Amount, randP2WPKHScript())))
+	err = b2.AcceptFundWitnesses(wits)
+	assert.IsType(&InvalidFundWitnessError{}, err)
+
+	// witnesses can't be verified without the output on chain
+	b2.SetRPCClient(mockGetTxOut(nil))
+	err = b2.AcceptFundWitnesses(wits)
+	assert.IsType(&UtxoNotFoundError{}, err)
+	b2.SetRPCClient(nil)
+	err = b2.AcceptFundWitnesses(wits)
+	assert.IsType(&ChainClientNotSetError{}, err)
+}
+
+// FundTx should create change txouts for any supported address types
+func TestFundTxChangeAddressTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	b1 := setupBuilder(FirstParty, setupTestWallet, newTestConditions)
+	b2 := setupBuilder(SecondParty, setupTestWallet, newTestConditions)
+
+	net := b1.Contract.Conds.NetParams
+	_, pub := test.RandKeys()
+	hash := btcutil.Hash160(pub.SerializeCompressed())
+	p2pkh, _ := btcutil.NewAddressPubKeyHash(hash, net)
+	p2tr, _ := script.NewAddressTaproot(pub.SerializeCompressed()[1:], net)
+	b1.Contract.ChangeAddrs[FirstParty] = p2pkh
+	b2.Contract.ChangeAddrs[SecondParty] = p2tr
+
+	assert.NoError(stepPrepare(b1))
+	assert.NoError(stepPrepare(b2))
+	assert.NoError(stepSendRequirments(b1, b2))
+	assert.NoError(stepSendRequirments(b2, b1))
+
+	tx, err := b1.Contract.FundTx()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	sc1, _ := txscript.PayToAddrScript(p2pkh)
+	sc2, _ := script.PkScriptFromAddress(p2tr)
+	assert.Equal(sc1, tx.TxOut[1].PkScript)
+	assert.Equal(sc2, tx.TxOut[2].PkScript)
+
+	// fee for change txout depends on the address type
+	assert.True(
+		b1.Contract.fundTxFeePerTxOut(FirstParty) < b1.Contract.fundTxFeePerTxOut(SecondParty))
+}
+
+// FundTx shouldn't create a change txout below the dust threshold
+func TestFundTxDustChange(t *testing.T) {
+	assert := assert.New(t)
+
+	setupWallet := func() *walletmock.Wallet {
+		return mockSelectUnspent(setupTestWallet(), 1000, 0, nil)
+	}
+	b1 := setupBuilder(FirstParty, setupWallet, newTestConditions)
+	b2 := setupBuilder(SecondParty, setupTestWallet, newTestConditions)
+
+	assert.NoError(stepPrepare(b1))
+	assert.NoError(stepPrepare(b2))
+	assert.NoError(stepSendRequirments(b1, b2))
+
+	d := b2.Contract
+	excess := d.fundUtxosAmount(FirstParty) - d.depositAmtWithoutChange(FirstParty)
+	assert.True(excess > d.fundTxFeePerTxOut(FirstParty))
+	assert.True(excess-d.fundTxFeePerTxOut(FirstParty) < wallet.DustThreshold)
+
+	tx, err := d.FundTx()
+	assert.NoError(err)
+	assert.Len(tx.TxOut, 2) // 1 for redeemtx and 1 for change of second party
+	sc, _ := script.PkScriptFromAddress(d.ChangeAddrs[SecondParty])
+	assert.Equal(sc, tx.TxOut[1].PkScript)
+
+	// contracts without the dust limit keep any change above the fee
+	d.Conds.DustLimit = 0
+	tx, err = d.FundTx()
+	assert.NoError(err)
+	assert.Len(tx.TxOut, 3)
+}
+
+// Unsupported address types should be rejected
+func TestAcceptUnsupportedAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	b := setupBuilder(FirstParty, setupTestWallet, newTestConditions)
+	_, pub := test.RandKeys()
+	p2pk, _ := btcutil.NewAddressPubKey(
+		pub.SerializeCompressed(), b.Contract.Conds.NetParams)
+
+	err := b.AcceptAdderss(p2pk)
+	assert.IsType(&script.UnsupportedAddressError{}, err)
+	err = b.AcceptChangeAdderss(p2pk)
+	assert.IsType(&script.UnsupportedAddressError{}, err)
+	assert.Nil(b.Contract.Addrs[SecondParty])
+	assert.Nil(b.Contract.ChangeAddrs[SecondParty])
+}
+
+// PrepareFundTx should select utxos with a given coin selector
+func TestPrepareFundTxWithCoinSelector(t *testing.T) {
+	assert := assert.New(t)
+
+	utxo := wallet.Utxo{TxID: randTxID(), Amount: 0.00001}
+	setupWallet := func() *walletmock.Wallet {
+		w := setupTestWallet()
+		w.On("SelectAndLockUnspent",
+			wallet.LargestFirst{},
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		).Return([]wallet.Utxo{utxo}, btcutil.Amount(1), nil)
+		return w
+	}
+
+	b := setupBuilder(FirstParty, setupWallet, newTestConditions)
+	b.SetCoinSelector(wallet.LargestFirst{})
+
+	err := b.PrepareFundTx()
+	assert.NoError(err)
+	assert.Equal(utxo.TxID, b.Contract.Utxos[FirstParty][0].TxID)
+}
+
+// FundTx should omit the change txout and its fee
+// if utxos selected by branch and bound have no change
+func TestFundTxWithBranchAndBound(t *testing.T) {
+	assert := assert.New(t)
+
+	setupWallet := func() *walletmock.Wallet {
+		w := setupTestWallet()
+		call := w.On("SelectAndLockUnspent",
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		call.Run(func(args mock.Arguments) {
+			s := args.Get(0).(wallet.CoinSelector)
+			amt := args.Get(1).(btcutil.Amount)
+			feePerTxIn := args.Get(2).(btcutil.Amount)
+			feePerTxOut := args.Get(3).(btcutil.Amount)
+
+			// the excess of the exact utxo is less than fee for a change txout
+			exact := amt + feePerTxIn + feePerTxOut/2
+			utxos := []wallet.Utxo{
+				{TxID: randTxID(), Amount: (amt * 10).ToBTC(),
+					ScriptPubKey: randP2WPKHScript()},
+				{TxID: randTxID(), Amount: exact.ToBTC(),
+					ScriptPubKey: randP2WPKHScript()},
+			}
+			selected, change, err := s.Select(utxos, amt, feePerTxIn, feePerTxOut)
+			call.ReturnArguments = mock.Arguments{selected, change, err}
+		})
+		return w
+	}
+
+	b1 := setupBuilder(FirstParty, setupWallet, newTestConditions)
+	b2 := setupBuilder(SecondParty, setupTestWallet, newTestConditions)
+	b1.SetCoinSelector(wallet.BranchAndBound{})
+
+	assert.NoError(stepPrepare(b1))
+	assert.NoError(stepPrepare(b2))
+	assert.NoError(stepSendRequirments(b1, b2))
+	assert.NoError(stepSendRequirments(b2, b1))
+	assert.Len(b1.Contract.Utxos[FirstParty], 1)
+	assert.False(b1.Contract.hasChangeTxOut(FirstParty))
+
+	for _, b := range []*Builder{b1, b2} {
+		tx, err := b.Contract.FundTx()
+		if !assert.NoError(err) {
+			continue
+		}
+		// fund txout and change txout of second party
+		assert.Len(tx.TxOut, 2)
+		sc, _ := script.PkScriptFromAddress(b2.Contract.ChangeAddrs[SecondParty])
+		assert.Equal(sc, tx.TxOut[1].PkScript)
+	}
+
+	// the excess is paid as fee
+	total := b1.Contract.fundUtxosAmount(FirstParty)
+	assert.True(total > b1.Contract.DepositAmt(FirstParty))
+	assert.Equal(
+		b1.Contract.depositAmtWithoutChange(FirstParty),
+		b1.Contract.DepositAmt(FirstParty))
+}
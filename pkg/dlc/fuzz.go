@@ -0,0 +1,23 @@
+//go:build gofuzz
+// +build gofuzz
+
+package dlc
+
+import "bytes"
+
+// Fuzz is the entry point of go-fuzz for the binary encoding.
+// A decoded contract must be encoded into the same bytes.
+func Fuzz(data []byte) int {
+	d := &DLC{}
+	if err := d.UnmarshalBinary(data); err != nil {
+		return 0
+	}
+	encoded, err := d.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	if !bytes.Equal(encoded, data) {
+		panic("decoded contract is encoded differently")
+	}
+	return 1
+}
@@ -0,0 +1,347 @@
+package dlc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/musig2"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+	"github.com/p2pderivatives/dlc/pkg/script"
+)
+
+// mutualClosingDustLimit is the minimum value of the txouts of mutual closing tx
+const mutualClosingDustLimit = btcutil.Amount(546)
+
+// maxMutualClosingFeeMultiplier caps fee of mutual closing tx
+// by a multiple of the fee at the redeem feerate of the contract
+const maxMutualClosingFeeMultiplier = 10
+
+// MutualClosingTx creates a tx that closes the contract
+// by agreement of both parties before or after fixing a deal.
+// It redeems fund tx directly without CSV delay and closing tx.
+//
+// input:
+//   [0]:fund transaction output[0]
+// output:
+//   [0]:p2wpkh a (omitted if amt1 is 0)
+//   [1]:p2wpkh b (omitted if amt2 is 0)
+//
+// Amounts below the dust limit are rejected instead of being paid as fee,
+// so that the parties agree on the payouts as they are.
+// The rest of fund output after the txouts is paid as fee,
+// which should be at least redeem feerate of the contract and
+// at most maxMutualClosingFeeMultiplier times of it.
+//
+// In taproot fund mode, the tx spends the key path with a MuSig2 signature.
+// Nonces have to be exchanged by MutualClosingNonce and
+// AcceptMutualClosingNonce before signing.
+func (d *DLC) MutualClosingTx(amt1, amt2 btcutil.Amount) (*wire.MsgTx, error) {
+	if amt1 < 0 || amt2 < 0 {
+		return nil, errors.New("amounts must not be negative")
+	}
+	if !d.Conds.isTwoParty() {
+		return nil, errors.New("mutual closing tx is only for two-party contracts")
+	}
+
+	amts := map[Contractor]btcutil.Amount{
+		FirstParty: amt1, SecondParty: amt2}
+	for _, p := range d.Conds.Parties() {
+		if amt := amts[p]; amt > 0 && amt < mutualClosingDustLimit {
+			msg := fmt.Sprintf(
+				"amount of %s is below dust limit %d. amount: %d",
+				p, mutualClosingDustLimit, amt)
+			return nil, errors.New(msg)
+		}
+	}
+	if amts[FirstParty] == 0 && amts[SecondParty] == 0 {
+		return nil, errors.New("either amount must be positive")
+	}
+
+	fundtx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	in := btcutil.Amount(fundtx.TxOut[fundTxOutAt].Value)
+	fee := d.mutualClosingTxFee()
+	out := amts[FirstParty] + amts[SecondParty]
+	if out+fee > in {
+		return nil, newNotEnoughFeesError(in-out, fee)
+	}
+	if max := fee * maxMutualClosingFeeMultiplier; in-out > max {
+		return nil, newExcessiveFeeError(in-out, max)
+	}
+
+	tx, err := d.newRedeemTx()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range []Contractor{FirstParty, SecondParty} {
+		if amts[p] == 0 {
+			continue
+		}
+		txout, err := d.distTxOut(p, amts[p])
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(txout)
+	}
+
+	return tx, nil
+}
+
+// SignMutualClosingTx creates a signature for a mutual closing tx
+// that distributes amt1 to first party and amt2 to second party.
+// It's a MuSig2 partial signature in taproot fund mode.
+func (b *Builder) SignMutualClosingTx(
+	amt1, amt2 btcutil.Amount) ([]byte, error) {
+	tx, err := b.Contract.MutualClosingTx(amt1, amt2)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Contract.Conds.isTaproot() {
+		return b.partialSignMutualClosingTx(tx)
+	}
+	return b.witsigForFundScript(tx)
+}
+
+// SignedMutualClosingTx verifies a given counterparty's signature
+// and returns a mutual closing tx signed by both parties
+func (b *Builder) SignedMutualClosingTx(
+	amt1, amt2 btcutil.Amount, cpSig []byte) (*wire.MsgTx, error) {
+	tx, err := b.Contract.MutualClosingTx(amt1, amt2)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Contract.Conds.isTaproot() {
+		wit, err := b.witnessForTaprootMutualClosingTx(tx, cpSig)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[fundTxInAt].Witness = wit
+		return tx, nil
+	}
+
+	cparty := counterparty(b.party)
+	err = b.Contract.verifyFundScriptSignature(tx, cpSig, b.Contract.Pubs[cparty])
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := b.witsigForFundScript(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := map[Contractor][]byte{b.party: sig, cparty: cpSig}
+	wit, err := b.Contract.witnessForFundScript(sigs)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[fundTxInAt].Witness = wit
+
+	return tx, nil
+}
+
+// SendMutualClosingTx sends a signed mutual closing tx
+func (b *Builder) SendMutualClosingTx(
+	amt1, amt2 btcutil.Amount, cpSig []byte) error {
+	tx, err := b.SignedMutualClosingTx(amt1, amt2, cpSig)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.wallet.SendRawTransaction(tx)
+	return err
+}
+
+// mutualClosingNonces are MuSig2 nonces of a mutual closing tx
+// in taproot fund mode. They are kept only in memory and generated
+// for each mutual close, so that a secret nonce never signs two txs.
+type mutualClosingNonces struct {
+	sec  []byte                // own secret nonce, erased after signing
+	pub  map[Contractor][]byte // public nonces of both parties
+	psig []byte                // own partial signature
+	msg  []byte                // signature hash signed by psig
+}
+
+// MutualClosingNonce generates a new MuSig2 nonce for a mutual closing tx
+// and returns own public nonce, which has to be sent to the counterparty.
+// It's required only in taproot fund mode.
+func (b *Builder) MutualClosingNonce() ([]byte, error) {
+	d := b.Contract
+	if !d.Conds.isTaproot() {
+		return nil, errors.New("nonces are used only in taproot fund mode")
+	}
+	pub := d.Pubs[b.party]
+	if pub == nil {
+		return nil, &PubkeyNotExistsError{
+			error: errors.New("pubkey must be prepared before nonces")}
+	}
+
+	secnonce, pubnonce, err := musig2.NonceGen(pub)
+	if err != nil {
+		return nil, err
+	}
+	b.closingNonces = &mutualClosingNonces{
+		sec: secnonce,
+		pub: map[Contractor][]byte{b.party: pubnonce},
+	}
+	return pubnonce, nil
+}
+
+// AcceptMutualClosingNonce accepts the counterparty's public nonce
+// for a mutual closing tx. Own nonce has to be generated beforehand.
+func (b *Builder) AcceptMutualClosingNonce(nonce []byte) error {
+	n := b.closingNonces
+	if n == nil {
+		return errors.New("own nonce must be generated first")
+	}
+	if len(nonce) != musig2.PubNonceSize {
+		return errors.New("invalid public nonce")
+	}
+	cparty := counterparty(b.party)
+	if n.pub[cparty] != nil {
+		msg := fmt.Sprintf("nonce of %s has already been accepted", cparty)
+		return errors.New(msg)
+	}
+	n.pub[cparty] = nonce
+	return nil
+}
+
+// mutualClosingSession returns a MuSig2 session to sign a mutual closing tx
+func (b *Builder) mutualClosingSession(
+	tx *wire.MsgTx) (*musig2.Session, error) {
+	d := b.Contract
+	if b.closingNonces == nil {
+		return nil, errors.New("missing nonces of mutual closing tx")
+	}
+	ft, err := d.fundTaproot()
+	if err != nil {
+		return nil, err
+	}
+
+	pubnonces := [][]byte{}
+	for _, p := range d.Conds.Parties() {
+		nonce := b.closingNonces.pub[p]
+		if nonce == nil {
+			msg := fmt.Sprintf("missing nonce of %s", p)
+			return nil, errors.New(msg)
+		}
+		pubnonces = append(pubnonces, nonce)
+	}
+	aggnonce, err := musig2.NonceAgg(pubnonces)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := d.fundTaprootSigHash(tx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &musig2.Session{KeyAgg: ft.keyAgg, AggNonce: aggnonce, Msg: hash}, nil
+}
+
+// partialSignMutualClosingTx creates own partial signature
+// for a mutual closing tx. The secret nonce is erased before signing,
+// and the signature is kept to complete the same tx later.
+func (b *Builder) partialSignMutualClosingTx(tx *wire.MsgTx) ([]byte, error) {
+	s, err := b.mutualClosingSession(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	n := b.closingNonces
+	if n.psig != nil {
+		if !bytes.Equal(n.msg, s.Msg) {
+			return nil, errors.New(
+				"nonce has already signed another mutual closing tx")
+		}
+		return n.psig, nil
+	}
+	if n.sec == nil {
+		return nil, errors.New("missing secret nonce")
+	}
+	secnonce := n.sec
+	n.sec = nil
+
+	psig, err := b.wallet.MuSig2PartialSign(
+		secnonce, s, b.Contract.Pubs[b.party])
+	if err != nil {
+		return nil, err
+	}
+	n.psig, n.msg = psig, s.Msg
+	return psig, nil
+}
+
+// witnessForTaprootMutualClosingTx verifies the counterparty's partial
+// signature and aggregates it with own one into a key path witness
+func (b *Builder) witnessForTaprootMutualClosingTx(
+	tx *wire.MsgTx, cpSig []byte) (wire.TxWitness, error) {
+	s, err := b.mutualClosingSession(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	d := b.Contract
+	cparty := counterparty(b.party)
+	nonce := b.closingNonces.pub[cparty]
+	if !musig2.PartialSigVerify(cpSig, nonce, d.Pubs[cparty], s) {
+		return nil, errors.New("invalid partial signature for mutual closing tx")
+	}
+
+	psig, err := b.partialSignMutualClosingTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := musig2.PartialSigAgg([][]byte{psig, cpSig}, s)
+	if err != nil {
+		return nil, err
+	}
+	if !schnorr.VerifyBIP340(s.KeyAgg.XOnlyPubKey(), s.Msg, sig) {
+		return nil, errors.New("failed to aggregate mutual closing tx signatures")
+	}
+
+	return script.WitnessForTaprootKeySpend(sig), nil
+}
+
+// verifyFundScriptSignature verifies a signature for a tx redeeming fund tx
+func (d *DLC) verifyFundScriptSignature(
+	tx *wire.MsgTx, sig []byte, pub *btcec.PublicKey) error {
+	s, err := btcec.ParseDERSignature(sig, btcec.S256())
+	if err != nil {
+		return err
+	}
+
+	fsc, err := d.fundScript()
+	if err != nil {
+		return err
+	}
+
+	fundtx, err := d.FundTx()
+	if err != nil {
+		return err
+	}
+	amt := fundtx.TxOut[fundTxOutAt].Value
+
+	sighashes := txscript.NewTxSigHashes(tx)
+	hash, err := txscript.CalcWitnessSigHash(
+		fsc, sighashes, txscript.SigHashAll, tx, fundTxInAt, amt)
+	if err != nil {
+		return err
+	}
+
+	if !s.Verify(hash, pub) {
+		return fmt.Errorf("invalid signature for fund script")
+	}
+
+	return nil
+}
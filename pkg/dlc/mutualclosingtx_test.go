@@ -0,0 +1,145 @@
+package dlc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// fund amount of each party in mutual close tests
+const mutualCloseFundAmt = btcutil.Amount(100000)
+
+func newMutualCloseConditions() *Conditions {
+	net := &chaincfg.RegressionNetParams
+	conds, _ := NewConditions(net, time.Now(),
+		mutualCloseFundAmt, mutualCloseFundAmt, 1, 1, 1, []*Deal{}, nil)
+	return conds
+}
+
+func setupMutualCloseWallet() *walletmock.Wallet {
+	w := setupTestWallet()
+	return mockSelectUnspent(w, 2*mutualCloseFundAmt, 1, nil)
+}
+
+func setupContractorsForMutualClose() (b1, b2 *Builder, err error) {
+	b1 = setupBuilder(FirstParty, setupMutualCloseWallet, newMutualCloseConditions)
+	b2 = setupBuilder(SecondParty, setupMutualCloseWallet, newMutualCloseConditions)
+
+	if err = stepPrepare(b1); err != nil {
+		return
+	}
+	if err = stepPrepare(b2); err != nil {
+		return
+	}
+	if err = stepSendRequirments(b1, b2); err != nil {
+		return
+	}
+	if err = stepSendRequirments(b2, b1); err != nil {
+		return
+	}
+	return b1, b2, nil
+}
+
+func TestSignedMutualClosingTx(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupContractorsForMutualClose()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	amt1, amt2 := btcutil.Amount(120000), mutualCloseFundAmt*2-120000-1000
+
+	sig2, err := b2.SignMutualClosingTx(amt1, amt2)
+	assert.NoError(err)
+
+	tx, err := b1.SignedMutualClosingTx(amt1, amt2, sig2)
+	assert.NoError(err)
+	assert.Len(tx.TxIn, 1)
+	assert.Len(tx.TxOut, 2)
+	assert.Equal(int64(amt1), tx.TxOut[0].Value)
+	assert.Equal(int64(amt2), tx.TxOut[1].Value)
+
+	// no CSV delay
+	assert.Equal(uint32(0), tx.LockTime)
+
+	fundtx, _ := b1.Contract.FundTx()
+	fout := fundtx.TxOut[fundTxOutAt]
+	err = test.ExecuteScript(fout.PkScript, tx, fout.Value)
+	assert.NoError(err)
+}
+
+// SignedMutualClosingTx should fail if the counterparty signed other amounts
+func TestSignedMutualClosingTxInvalidSig(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupContractorsForMutualClose()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	sig2, err := b2.SignMutualClosingTx(120000, 79000)
+	assert.NoError(err)
+
+	_, err = b1.SignedMutualClosingTx(79000, 120000, sig2)
+	assert.Error(err)
+}
+
+func TestMutualClosingTxOneSide(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, err := setupContractorsForMutualClose()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	tx, err := b1.Contract.MutualClosingTx(0, mutualCloseFundAmt*2-1000)
+	assert.NoError(err)
+	assert.Len(tx.TxOut, 1)
+
+	// a dust amount isn't dropped silently
+	_, err = b1.Contract.MutualClosingTx(500, mutualCloseFundAmt*2-1000)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "amount: 500")
+	}
+}
+
+func TestMutualClosingTxDust(t *testing.T) {
+	b1, _, err := setupContractorsForMutualClose()
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, err.Error())
+	}
+
+	_, err = b1.Contract.MutualClosingTx(500, 500)
+	assert.Error(t, err)
+	_, err = b1.Contract.MutualClosingTx(0, 0)
+	assert.Error(t, err)
+}
+
+func TestMutualClosingTxExcessiveFee(t *testing.T) {
+	b1, _, err := setupContractorsForMutualClose()
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, err.Error())
+	}
+
+	_, err = b1.Contract.MutualClosingTx(mutualCloseFundAmt, 1000)
+	assert.IsType(t, &ExcessiveFeeError{}, err)
+}
+
+func TestMutualClosingTxNotEnoughFees(t *testing.T) {
+	b1, _, err := setupContractorsForMutualClose()
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, err.Error())
+	}
+
+	fundtx, _ := b1.Contract.FundTx()
+	famt := btcutil.Amount(fundtx.TxOut[fundTxOutAt].Value)
+
+	_, err = b1.Contract.MutualClosingTx(famt, 0)
+	assert.IsType(t, &NotEnoughFeesError{}, err)
+}
@@ -12,6 +12,7 @@ import (
 // Oracle contains pubkeys and commitments and signature received from oracle
 type Oracle struct {
 	PubkeySet   *oracle.PubkeySet  // Oracle's pubkey set
+	RpointIdxs  []int              // Indices of R points committed to deals
 	Commitments []*btcec.PublicKey // Commitments for deals
 	Sig         []byte             // Signature for a fixed deal
 	SignedMsgs  [][]byte           // Messages signed by Oracle
@@ -54,6 +55,7 @@ func (b *Builder) SetOraclePubkeySet(
 	}
 
 	b.Contract.Oracle.PubkeySet = pubset
+	b.Contract.Oracle.RpointIdxs = idxs
 	return nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
 )
 
 // RefundTx creates refund tx
@@ -17,9 +18,14 @@ import (
 // output:
 //   [0]:p2wpkh a
 //   [1]:p2wpkh b
+//   [n-1]:p2wpkh of n-th party (multi-party)
 // locktime:
 //    Value decided by contract.
 func (d *DLC) RefundTx() (*wire.MsgTx, error) {
+	if d.Buffer != nil {
+		return nil, errors.New("refund tx isn't available in channel state")
+	}
+
 	tx, err := d.newRedeemTx()
 	if err != nil {
 		return nil, err
@@ -30,7 +36,7 @@ func (d *DLC) RefundTx() (*wire.MsgTx, error) {
 	tx.LockTime = d.Conds.RefundLockTime
 
 	// txouts
-	for _, p := range []Contractor{FirstParty, SecondParty} {
+	for _, p := range d.Conds.Parties() {
 		txout, err := d.distTxOut(p, d.Conds.FundAmts[p])
 
 		if err != nil {
@@ -50,7 +56,12 @@ func (b *Builder) SignRefundTx() ([]byte, error) {
 		return nil, err
 	}
 
-	sig, err := b.witsigForFundScript(tx)
+	var sig []byte
+	if b.Contract.Conds.isTaproot() {
+		sig, err = b.schnorrSigForRefundTx(tx)
+	} else {
+		sig, err = b.witsigForFundScript(tx)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -77,29 +88,36 @@ func (d *DLC) SignedRefundTx() (*wire.MsgTx, error) {
 }
 
 func (d *DLC) witnessForRefundTx() (wire.TxWitness, error) {
-	sc, err := d.fundScript()
-	if err != nil {
-		return nil, err
+	for _, p := range d.Conds.Parties() {
+		if d.RefundSigs[p] == nil {
+			msg := fmt.Sprintf("%s must sign refund tx", p)
+			return nil, errors.New(msg)
+		}
 	}
 
-	sig1 := d.RefundSigs[FirstParty]
-	if sig1 == nil {
-		return nil, errors.New("First party must sign refund tx")
+	if d.Conds.isTaproot() {
+		return d.witnessForTaprootRefundTx()
 	}
+	return d.witnessForFundScript(d.RefundSigs)
+}
 
-	sig2 := d.RefundSigs[SecondParty]
-	if sig2 == nil {
-		return nil, errors.New("Second party must sign refund tx")
+// schnorrSigForRefundTx returns a schnorr signature for refund tx
+// that spends the refund script leaf of taproot fund output
+func (b *Builder) schnorrSigForRefundTx(tx *wire.MsgTx) ([]byte, error) {
+	hash, err := b.Contract.refundTxSigHash(tx)
+	if err != nil {
+		return nil, err
 	}
-
-	wt := wire.TxWitness{[]byte{}, sig1, sig2, sc}
-	return wt, nil
+	return b.wallet.SchnorrSignature(hash, b.Contract.Pubs[b.party])
 }
 
 // AcceptRefundTxSignature verifies couterparty's given signature is valid and then
 func (b *Builder) AcceptRefundTxSignature(sig []byte) error {
-	p := counterparty(b.party)
+	return b.AcceptRefundTxSignatureFrom(counterparty(b.party), sig)
+}
 
+// AcceptRefundTxSignatureFrom verifies a given party's signature and accepts it
+func (b *Builder) AcceptRefundTxSignatureFrom(p Contractor, sig []byte) error {
 	err := b.Contract.VerifyRefundTx(sig, b.Contract.Pubs[p])
 	if err != nil {
 		return err
@@ -114,6 +132,10 @@ func (b *Builder) AcceptRefundTxSignature(sig []byte) error {
 // Returns nil if the passed signature is valid and corresponds to the passed
 // in public key, and an error if it isnt.
 func (d *DLC) VerifyRefundTx(sig []byte, pub *btcec.PublicKey) error {
+	if d.Conds.isTaproot() {
+		return d.verifyTaprootRefundTx(sig, pub)
+	}
+
 	// parse signature
 	s, err := btcec.ParseDERSignature(sig, btcec.S256())
 	if err != nil {
@@ -154,6 +176,25 @@ func (d *DLC) VerifyRefundTx(sig []byte, pub *btcec.PublicKey) error {
 	return nil
 }
 
+// verifyTaprootRefundTx verifies a schnorr signature for refund tx
+// that spends the refund script leaf of taproot fund output
+func (d *DLC) verifyTaprootRefundTx(sig []byte, pub *btcec.PublicKey) error {
+	tx, err := d.RefundTx()
+	if err != nil {
+		return err
+	}
+
+	hash, err := d.refundTxSigHash(tx)
+	if err != nil {
+		return err
+	}
+
+	if !schnorr.VerifyBIP340(schnorr.XOnly(pub), hash, sig) {
+		return errors.New("failed to verify refund tx signature")
+	}
+	return nil
+}
+
 // SendRefundTx sends refund tx
 func (b *Builder) SendRefundTx() error {
 	tx, err := b.Contract.SignedRefundTx()
@@ -0,0 +1,161 @@
+package dlc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// RolloverIncompleteError is raised when a renewed contract
+// doesn't have all signatures required to switch to it
+type RolloverIncompleteError struct{ error }
+
+// RolloverConditions creates conditions that renew given conditions
+// with a new fixing time, refund locktime and deals.
+// Fund amounts, feerates and premium info are kept
+// so that the renewed contract spends the same fund output.
+func RolloverConditions(
+	conds *Conditions,
+	ftime time.Time,
+	refundLockTime uint32,
+	deals []*Deal,
+) (*Conditions, error) {
+	if !ftime.After(conds.FixingTime) {
+		return nil, errors.New("fixing time must be after the current fixing time")
+	}
+	if refundLockTime <= conds.RefundLockTime {
+		return nil, errors.New("refund locktime must be after the current refund locktime")
+	}
+
+	famts := []btcutil.Amount{}
+	for _, p := range conds.Parties() {
+		famts = append(famts, conds.FundAmts[p])
+	}
+
+	next, err := NewMultiPartyConditions(
+		conds.NetParams, ftime, famts,
+		conds.FundFeerate, conds.RedeemFeerate,
+		refundLockTime, deals, conds.PremiumInfo)
+	if err != nil {
+		return nil, err
+	}
+	next.FundMode = conds.FundMode
+	return next, nil
+}
+
+// Rollover creates a builder of a contract renewed with given conditions.
+// The renewed contract takes over fund tx of the current contract,
+// so oracle's commitments, CETx signatures and refund tx signatures
+// have to be exchanged again using the returned builder.
+// The current contract is kept as it is until SwitchContract is called.
+func (b *Builder) Rollover(conds *Conditions) (*Builder, error) {
+	d := b.Contract
+	if d.HasDealFixed() {
+		return nil, errors.New("contract with a fixed deal can't be rolled over")
+	}
+	// refund script leaf commits to the refund locktime
+	if d.Conds.isTaproot() {
+		return nil, errors.New("contract in taproot fund mode can't be rolled over")
+	}
+
+	next := NewDLC(conds)
+	for _, p := range conds.Parties() {
+		next.Pubs[p] = d.Pubs[p]
+		next.Addrs[p] = d.Addrs[p]
+		next.ChangeAddrs[p] = d.ChangeAddrs[p]
+		next.Utxos[p] = d.Utxos[p]
+		next.FundWits[p] = d.FundWits[p]
+	}
+
+	// fund tx must be same
+	fundtx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	nextFundtx, err := next.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	if fundtx.TxHash() != nextFundtx.TxHash() {
+		return nil, errors.New("renewed contract must spend the same fund output")
+	}
+
+	nb := NewBuilder(b.party, b.wallet, next)
+	nb.rpc = b.rpc
+	nb.selector = b.selector
+	return nb, nil
+}
+
+// SwitchContract switches the contract to a renewed one created by Rollover.
+// It fails and keeps the current contract if the renewed contract doesn't have
+// all CETx signatures and refund tx signatures of all parties.
+func (b *Builder) SwitchContract(next *DLC) error {
+	fundtx, err := b.Contract.FundTx()
+	if err != nil {
+		return err
+	}
+	nextFundtx, err := next.FundTx()
+	if err != nil {
+		return err
+	}
+	if fundtx.TxHash() != nextFundtx.TxHash() {
+		return errors.New("renewed contract must spend the same fund output")
+	}
+
+	if err = next.verifySignatureSet(b.party); err != nil {
+		return err
+	}
+
+	b.Contract = next
+	return nil
+}
+
+// verifySignatureSet checks if the contract has all signatures
+// for CETxs of a given party and refund tx, and verifies them
+func (d *DLC) verifySignatureSet(p Contractor) error {
+	if err := d.verifyCETxSignatureSet(p); err != nil {
+		return err
+	}
+
+	for _, p := range d.Conds.Parties() {
+		sig := d.RefundSigs[p]
+		if sig == nil {
+			msg := "missing refund tx signature of " + p.String()
+			return &RolloverIncompleteError{error: errors.New(msg)}
+		}
+		if err := d.VerifyRefundTx(sig, d.Pubs[p]); err != nil {
+			return fmt.Errorf("invalid refund tx signature of %s. %v", p, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyCETxSignatureSet checks if the contract has all counterparties'
+// signatures for CETxs of a given party, and verifies them
+func (d *DLC) verifyCETxSignatureSet(p Contractor) error {
+	for i, deal := range d.Conds.Deals {
+		if d.Oracle.Commitments[i] == nil {
+			return &RolloverIncompleteError{
+				error: errors.New("missing oracle's commitment")}
+		}
+		tx, err := d.ContractExecutionTx(p, deal, i)
+		if err != nil {
+			return err
+		}
+		for _, cparty := range d.Conds.counterparties(p) {
+			sig := d.execSig(cparty, i)
+			if sig == nil {
+				msg := "missing CETx signature of " + cparty.String()
+				return &RolloverIncompleteError{error: errors.New(msg)}
+			}
+			if err = d.verifyCETxSignature(p, cparty, tx, sig); err != nil {
+				return fmt.Errorf(
+					"invalid CETx signature of %s for deal %d. %v", cparty, i, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package dlc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRollover() (b1, b2, next1, next2 *Builder, err error) {
+	b1, b2, err = setupContractorsForMutualClose()
+	if err != nil {
+		return
+	}
+
+	conds := b1.Contract.Conds
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	nextConds, err := RolloverConditions(
+		conds, time.Now().Add(24*time.Hour), conds.RefundLockTime+144,
+		[]*Deal{deal})
+	if err != nil {
+		return
+	}
+
+	if next1, err = b1.Rollover(nextConds); err != nil {
+		return
+	}
+	if next2, err = b2.Rollover(nextConds); err != nil {
+		return
+	}
+
+	_, C := test.RandKeys()
+	next1.Contract.Oracle.Commitments[0] = C
+	next2.Contract.Oracle.Commitments[0] = C
+
+	return b1, b2, next1, next2, nil
+}
+
+func TestRollover(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, next1, next2, err := setupRollover()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	// exchange signatures for the renewed contract
+	deal := next1.Contract.Conds.Deals[0]
+	assert.NoError(stepExchangeCETxSig(next1, next2, deal, 0))
+	assert.NoError(stepExchangeCETxSig(next2, next1, deal, 0))
+	rs1, err := next1.SignRefundTx()
+	assert.NoError(err)
+	rs2, err := next2.SignRefundTx()
+	assert.NoError(err)
+	assert.NoError(next1.AcceptRefundTxSignature(rs2))
+	assert.NoError(next2.AcceptRefundTxSignature(rs1))
+
+	fundtx, _ := b1.Contract.FundTx()
+
+	assert.NoError(b1.SwitchContract(next1.Contract))
+	assert.NoError(b2.SwitchContract(next2.Contract))
+	assert.Equal(next1.Contract, b1.Contract)
+
+	// spends the same fund output
+	nextFundtx, _ := b1.Contract.FundTx()
+	assert.Equal(fundtx.TxHash(), nextFundtx.TxHash())
+	refundtx, err := b1.Contract.SignedRefundTx()
+	assert.NoError(err)
+	assert.Equal(fundtx.TxHash(), refundtx.TxIn[0].PreviousOutPoint.Hash)
+}
+
+// SwitchContract should keep the current contract if signatures are missing
+func TestSwitchContractIncomplete(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, next1, _, err := setupRollover()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	current := b1.Contract
+
+	err = b1.SwitchContract(next1.Contract)
+	assert.IsType(&RolloverIncompleteError{}, err)
+	assert.Equal(current, b1.Contract)
+}
+
+// SwitchContract should verify signatures set without Accept methods
+func TestSwitchContractInvalidSignatures(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, next1, next2, err := setupRollover()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	current := b1.Contract
+
+	deal := next1.Contract.Conds.Deals[0]
+	assert.NoError(stepExchangeCETxSig(next2, next1, deal, 0))
+	rs1, err := next1.SignRefundTx()
+	assert.NoError(err)
+	rs2, err := next2.SignRefundTx()
+	assert.NoError(err)
+	assert.NoError(next1.AcceptRefundTxSignature(rs2))
+
+	// own signature set as the counterparty's
+	next1.Contract.RefundSigs[SecondParty] = rs1
+	err = b1.SwitchContract(next1.Contract)
+	assert.Error(err)
+	assert.Equal(current, b1.Contract)
+
+	// signature for another CETx
+	next1.Contract.RefundSigs[SecondParty] = rs2
+	sig, err := next2.SignContractExecutionTxsFor(SecondParty)
+	assert.NoError(err)
+	next1.Contract.ExecSigs[SecondParty][0] = sig[0]
+	err = b1.SwitchContract(next1.Contract)
+	assert.Error(err)
+	assert.Equal(current, b1.Contract)
+}
+
+func TestRolloverConditionsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	conds := newTestConditions()
+	deals := []*Deal{NewDeal(1, 1, [][]byte{{1}})}
+
+	_, err := RolloverConditions(
+		conds, conds.FixingTime, conds.RefundLockTime+1, deals)
+	assert.Error(err, "same fixing time")
+
+	_, err = RolloverConditions(
+		conds, conds.FixingTime.Add(time.Hour), conds.RefundLockTime, deals)
+	assert.Error(err, "same refund locktime")
+}
+
+// Rollover should fail if the renewed contract changes fund output
+func TestRolloverFundChanged(t *testing.T) {
+	b1, _, err := setupContractorsForMutualClose()
+	if !assert.NoError(t, err) {
+		assert.FailNow(t, err.Error())
+	}
+
+	conds := *b1.Contract.Conds
+	conds.RedeemFeerate = btcutil.Amount(100)
+
+	_, err = b1.Rollover(&conds)
+	assert.Error(t, err)
+}
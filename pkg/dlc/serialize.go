@@ -5,15 +5,18 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil"
 	"github.com/p2pderivatives/dlc/pkg/oracle"
+	"github.com/p2pderivatives/dlc/pkg/script"
 	"github.com/p2pderivatives/dlc/pkg/utils"
 )
 
 // OracleJSON is oracle information in JSON format
 type OracleJSON struct {
 	PubkeySet   *oracle.PubkeySetJSON `json:"pubkey"`
+	RpointIdxs  []int                 `json:"rpoint_idxs,omitempty"`
 	Commitments []string              `json:"commitments"`
 	Sig         []byte                `json:"sig"`
 	SignedMsgs  [][]byte              `json:"signed_msgs"`
@@ -29,6 +32,8 @@ type ConditionsJSON struct {
 	RefundLockTime uint32             `json:"refund_locktime"`
 	Deals          []*DealJSON        `json:"deals"`
 	PremiumInfo    *PremiumInfoJSON   `json:"premium_info"`
+	FundMode       FundMode           `json:"fund_mode,omitempty"`
+	DustLimit      int                `json:"dust_limit,omitempty"`
 }
 
 // PremiumInfoJSON is PremiumInfo in JSON format
@@ -44,6 +49,17 @@ type DealJSON struct {
 	Msgs [][]byte           `json:"msgs"`
 }
 
+// BufferJSON is channel state in JSON format
+type BufferJSON struct {
+	Index          uint32     `json:"index"`
+	Delay          uint16     `json:"delay"`
+	Points         PublicKeys `json:"points"`
+	Secret         []byte     `json:"secret"`
+	Sig            []byte     `json:"sig"`
+	PendingPoint   string     `json:"pending_point,omitempty"`
+	RevokedSecrets [][]byte   `json:"revoked_secrets"`
+}
+
 // PublicKeys is public keys in hex string format
 type PublicKeys map[Contractor]string
 
@@ -64,6 +80,7 @@ func (o *Oracle) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(&OracleJSON{
 		PubkeySet:   pubkeyJSON,
+		RpointIdxs:  o.RpointIdxs,
 		Commitments: Cs,
 		Sig:         o.Sig,
 		SignedMsgs:  o.SignedMsgs,
@@ -81,6 +98,8 @@ func (conds *Conditions) MarshalJSON() ([]byte, error) {
 		RefundLockTime: conds.RefundLockTime,
 		Deals:          dealsToJSON(conds.Deals),
 		PremiumInfo:    premiumInfoToJSON(conds.PremiumInfo),
+		FundMode:       conds.FundMode,
+		DustLimit:      int(conds.DustLimit),
 	})
 }
 
@@ -135,6 +154,7 @@ func (o *Oracle) UnmarshalJSON(data []byte) error {
 		}
 		o.PubkeySet = pubset
 	}
+	o.RpointIdxs = oJSON.RpointIdxs
 
 	for k, cstr := range oJSON.Commitments {
 		c, err := utils.ParsePublicKey(cstr)
@@ -171,6 +191,8 @@ func (conds *Conditions) UnmarshalJSON(data []byte) error {
 	conds.RedeemFeerate = btcutil.Amount(condsJSON.RedeemFeerate)
 	conds.RefundLockTime = condsJSON.RefundLockTime
 	conds.Deals = jsonToDeals(condsJSON.Deals)
+	conds.FundMode = condsJSON.FundMode
+	conds.DustLimit = btcutil.Amount(condsJSON.DustLimit)
 
 	conds.PremiumInfo, err = jsonToPremiumInfo(condsJSON.PremiumInfo, net)
 
@@ -182,7 +204,7 @@ func jsonToPremiumInfo(premiumInfoJSON *PremiumInfoJSON, net *chaincfg.Params) (
 		return nil, nil
 	}
 
-	address, err := btcutil.DecodeAddress(premiumInfoJSON.PremiumDestAddress, net)
+	address, err := script.DecodeAddress(premiumInfoJSON.PremiumDestAddress, net)
 
 	if err != nil {
 		return nil, err
@@ -242,6 +264,62 @@ func jsonToDeals(dealsJSON []*DealJSON) []*Deal {
 	return deals
 }
 
+// MarshalJSON implements json.Marshaler
+func (buf *Buffer) MarshalJSON() ([]byte, error) {
+	points := make(PublicKeys)
+	for c, p := range buf.Points {
+		points[c] = utils.PubkeyToStr(p)
+	}
+
+	var pending string
+	if buf.PendingPoint != nil {
+		pending = utils.PubkeyToStr(buf.PendingPoint)
+	}
+
+	return json.Marshal(&BufferJSON{
+		Index:          buf.Index,
+		Delay:          buf.Delay,
+		Points:         points,
+		Secret:         buf.Secret,
+		Sig:            buf.Sig,
+		PendingPoint:   pending,
+		RevokedSecrets: buf.RevokedSecrets,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (buf *Buffer) UnmarshalJSON(data []byte) error {
+	bufJSON := &BufferJSON{}
+	err := json.Unmarshal(data, bufJSON)
+	if err != nil {
+		return err
+	}
+
+	buf.Points = make(map[Contractor]*btcec.PublicKey)
+	for c, pstr := range bufJSON.Points {
+		p, err := utils.ParsePublicKey(pstr)
+		if err != nil {
+			return err
+		}
+		buf.Points[c] = p
+	}
+
+	if bufJSON.PendingPoint != "" {
+		buf.PendingPoint, err = utils.ParsePublicKey(bufJSON.PendingPoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	buf.Index = bufJSON.Index
+	buf.Delay = bufJSON.Delay
+	buf.Secret = bufJSON.Secret
+	buf.Sig = bufJSON.Sig
+	buf.RevokedSecrets = bufJSON.RevokedSecrets
+
+	return nil
+}
+
 // PublicKeys converts btcec.PublicKey to hex string
 func (d *DLC) PublicKeys() PublicKeys {
 	pubs := make(PublicKeys)
@@ -277,7 +355,7 @@ func (d *DLC) Addresses() Addresses {
 // ParseAddresses parses address string
 func (d *DLC) ParseAddresses(addrs Addresses) error {
 	for c, addrStr := range addrs {
-		addr, err := btcutil.DecodeAddress(addrStr, d.Conds.NetParams)
+		addr, err := script.DecodeAddress(addrStr, d.Conds.NetParams)
 		if err != nil {
 			return err
 		}
@@ -300,7 +378,7 @@ func (d *DLC) ChangeAddresses() Addresses {
 // ParseChangeAddresses parses address string
 func (d *DLC) ParseChangeAddresses(addrs Addresses) error {
 	for c, addrStr := range addrs {
-		addr, err := btcutil.DecodeAddress(addrStr, d.Conds.NetParams)
+		addr, err := script.DecodeAddress(addrStr, d.Conds.NetParams)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,336 @@
+package dlc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/p2pderivatives/dlc/pkg/musig2"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+	"github.com/p2pderivatives/dlc/pkg/script"
+)
+
+// FundMode is a type of fund output
+type FundMode int
+
+const (
+	// FundModeP2WSH locks fund in a P2WSH n-of-n multisig script
+	FundModeP2WSH FundMode = iota
+	// FundModeTaproot locks fund in a taproot output whose internal key
+	// is a MuSig2 aggregated key of all parties.
+	// CETxs spend the key path, and refund tx spends a script leaf
+	// locked until the refund locktime.
+	FundModeTaproot
+)
+
+// String represents fund mode in string format
+func (m FundMode) String() string {
+	switch m {
+	case FundModeP2WSH:
+		return "p2wsh"
+	case FundModeTaproot:
+		return "taproot"
+	}
+	return fmt.Sprintf("unknown(%d)", int(m))
+}
+
+// isTaproot returns true if the contract is funded by a taproot output
+func (conds *Conditions) isTaproot() bool {
+	return conds.FundMode == FundModeTaproot
+}
+
+// MuSigNonces are MuSig2 nonces used to sign CETxs in taproot fund mode.
+// Every party has a nonce for each CETx of all parties including own CETxs.
+type MuSigNonces struct {
+	Pub map[Contractor]map[Contractor][][]byte // public nonces by signer and CETx owner
+	Sec map[Contractor][][]byte                // own secret nonces by CETx owner
+}
+
+// fundTaproot is a taproot fund output
+type fundTaproot struct {
+	keyAgg   *musig2.KeyAggContext // key aggregation tweaked to output key
+	internal *btcec.PublicKey      // aggregated key of all parties
+	refund   []byte                // refund script leaf
+}
+
+func (d *DLC) fundTaproot() (*fundTaproot, error) {
+	pubs, err := d.fundPubkeys()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := musig2.KeyAgg(pubs)
+	if err != nil {
+		return nil, err
+	}
+
+	refund, err := script.TaprootRefundScript(d.Conds.RefundLockTime, pubs)
+	if err != nil {
+		return nil, err
+	}
+
+	internal := ctx.PubKey()
+	tweak := script.TapTweak(internal, script.TapLeafHash(refund))
+	tctx, err := ctx.ApplyTweak(tweak, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fundTaproot{keyAgg: tctx, internal: internal, refund: refund}, nil
+}
+
+func (f *fundTaproot) outputKey() *btcec.PublicKey {
+	return f.keyAgg.PubKey()
+}
+
+func (f *fundTaproot) controlBlock() []byte {
+	return script.ControlBlock(f.internal, f.outputKey())
+}
+
+// fundTaprootSigHash returns a signature hash of a tx spending taproot fund output.
+// leafHash is nil for the key path.
+func (d *DLC) fundTaprootSigHash(
+	tx *wire.MsgTx, leafHash []byte) ([]byte, error) {
+	fundtx, err := d.FundTx()
+	if err != nil {
+		return nil, err
+	}
+	prevOuts := []*wire.TxOut{fundtx.TxOut[fundTxOutAt]}
+	return script.TaprootSigHash(tx, fundTxInAt, prevOuts, leafHash)
+}
+
+// PrepareNonces generates own MuSig2 nonces for CETxs of all parties.
+// It's required only in taproot fund mode.
+func (b *Builder) PrepareNonces() error {
+	d := b.Contract
+	if !d.Conds.isTaproot() {
+		return errors.New("nonces are used only in taproot fund mode")
+	}
+
+	pub := d.Pubs[b.party]
+	if pub == nil {
+		return &PubkeyNotExistsError{
+			error: errors.New("pubkey must be prepared before nonces")}
+	}
+
+	// regenerating nonces would let a counterparty
+	// make us sign different messages with a secret nonce
+	if d.Nonces != nil && (d.Nonces.Pub[b.party] != nil || d.Nonces.Sec != nil) {
+		return errors.New("nonces have already been prepared")
+	}
+
+	pubnonces := make(map[Contractor][][]byte)
+	secnonces := make(map[Contractor][][]byte)
+	for _, p := range d.Conds.Parties() {
+		for range d.Conds.Deals {
+			secnonce, pubnonce, err := musig2.NonceGen(pub)
+			if err != nil {
+				return err
+			}
+			secnonces[p] = append(secnonces[p], secnonce)
+			pubnonces[p] = append(pubnonces[p], pubnonce)
+		}
+	}
+
+	if d.Nonces == nil {
+		d.Nonces = &MuSigNonces{
+			Pub: make(map[Contractor]map[Contractor][][]byte)}
+	}
+	d.Nonces.Pub[b.party] = pubnonces
+	d.Nonces.Sec = secnonces
+	return nil
+}
+
+// PublicNonces returns own public nonces by CETx owner
+func (b *Builder) PublicNonces() map[Contractor][][]byte {
+	if b.Contract.Nonces == nil {
+		return nil
+	}
+	return b.Contract.Nonces.Pub[b.party]
+}
+
+// AcceptNonces accepts public nonces of the counterparty
+func (b *Builder) AcceptNonces(nonces map[Contractor][][]byte) error {
+	return b.AcceptNoncesFrom(counterparty(b.party), nonces)
+}
+
+// AcceptNoncesFrom accepts public nonces of a given party
+func (b *Builder) AcceptNoncesFrom(
+	p Contractor, nonces map[Contractor][][]byte) error {
+	d := b.Contract
+	if !d.Conds.isTaproot() {
+		return errors.New("nonces are used only in taproot fund mode")
+	}
+
+	for _, owner := range d.Conds.Parties() {
+		if len(nonces[owner]) != len(d.Conds.Deals) {
+			msg := fmt.Sprintf("%s must provide nonces for all CETxs of %s", p, owner)
+			return errors.New(msg)
+		}
+		for _, nonce := range nonces[owner] {
+			if len(nonce) != musig2.PubNonceSize {
+				return errors.New("invalid public nonce")
+			}
+		}
+	}
+
+	if d.Nonces == nil {
+		d.Nonces = &MuSigNonces{
+			Pub: make(map[Contractor]map[Contractor][][]byte)}
+	}
+	if d.Nonces.Pub[p] != nil {
+		msg := fmt.Sprintf("nonces of %s have already been accepted", p)
+		return errors.New(msg)
+	}
+	d.Nonces.Pub[p] = nonces
+	return nil
+}
+
+// pubNonce returns a public nonce by signer for a CETx of owner
+func (d *DLC) pubNonce(signer, owner Contractor, idx int) []byte {
+	if d.Nonces == nil {
+		return nil
+	}
+	nonces := d.Nonces.Pub[signer][owner]
+	if idx >= len(nonces) {
+		return nil
+	}
+	return nonces[idx]
+}
+
+// cetxSession returns a MuSig2 session to sign a CETx of a given party
+func (d *DLC) cetxSession(
+	p Contractor, tx *wire.MsgTx, idx int) (*musig2.Session, error) {
+	ft, err := d.fundTaproot()
+	if err != nil {
+		return nil, err
+	}
+
+	pubnonces := [][]byte{}
+	for _, signer := range d.Conds.Parties() {
+		nonce := d.pubNonce(signer, p, idx)
+		if nonce == nil {
+			msg := fmt.Sprintf("missing nonce of %s", signer)
+			return nil, errors.New(msg)
+		}
+		pubnonces = append(pubnonces, nonce)
+	}
+	aggnonce, err := musig2.NonceAgg(pubnonces)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := d.fundTaprootSigHash(tx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &musig2.Session{KeyAgg: ft.keyAgg, AggNonce: aggnonce, Msg: hash}, nil
+}
+
+// partialSignCETx creates own partial signature for a CETx of a given party.
+// The secret nonce is erased before signing
+// so that it's never reused for another message even if signing fails.
+func (b *Builder) partialSignCETx(
+	p Contractor, tx *wire.MsgTx, idx int) ([]byte, error) {
+	d := b.Contract
+	s, err := d.cetxSession(p, tx, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Nonces == nil || idx >= len(d.Nonces.Sec[p]) || d.Nonces.Sec[p][idx] == nil {
+		return nil, errors.New("missing secret nonce")
+	}
+	secnonce := d.Nonces.Sec[p][idx]
+	d.Nonces.Sec[p][idx] = nil
+
+	return b.wallet.MuSig2PartialSign(secnonce, s, d.Pubs[b.party])
+}
+
+// verifyCETxPartialSig verifies a partial signature by signer
+// for a CETx of a given party
+func (d *DLC) verifyCETxPartialSig(
+	p, signer Contractor, tx *wire.MsgTx, idx int, psig []byte) error {
+	s, err := d.cetxSession(p, tx, idx)
+	if err != nil {
+		return err
+	}
+
+	nonce := d.pubNonce(signer, p, idx)
+	if !musig2.PartialSigVerify(psig, nonce, d.Pubs[signer], s) {
+		return errors.New("failed to verify")
+	}
+	return nil
+}
+
+// witnessForTaprootCETx aggregates partial signatures of all parties
+// and constructs a key path witness for own CETx.
+// Own partial signature is kept in ExecSigs since the secret nonce
+// is erased after signing.
+func (b *Builder) witnessForTaprootCETx(
+	tx *wire.MsgTx, idx int) (wire.TxWitness, error) {
+	d := b.Contract
+	cpsigs := [][]byte{}
+	for _, cparty := range d.Conds.counterparties(b.party) {
+		sig := d.execSig(cparty, idx)
+		if sig == nil {
+			msg := "missing CETx signature of " + cparty.String()
+			return nil, errors.New(msg)
+		}
+		cpsigs = append(cpsigs, sig)
+	}
+
+	psig := d.execSig(b.party, idx)
+	if psig == nil {
+		var err error
+		psig, err = b.partialSignCETx(b.party, tx, idx)
+		if err != nil {
+			return nil, err
+		}
+		d.setExecSig(b.party, idx, psig)
+	}
+	psigs := append([][]byte{psig}, cpsigs...)
+
+	s, err := d.cetxSession(b.party, tx, idx)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := musig2.PartialSigAgg(psigs, s)
+	if err != nil {
+		return nil, err
+	}
+	if !schnorr.VerifyBIP340(s.KeyAgg.XOnlyPubKey(), s.Msg, sig) {
+		return nil, errors.New("failed to aggregate CETx signatures")
+	}
+
+	return script.WitnessForTaprootKeySpend(sig), nil
+}
+
+// refundTxSigHash returns a signature hash of refund tx
+// spending the refund script leaf
+func (d *DLC) refundTxSigHash(tx *wire.MsgTx) ([]byte, error) {
+	ft, err := d.fundTaproot()
+	if err != nil {
+		return nil, err
+	}
+	return d.fundTaprootSigHash(tx, script.TapLeafHash(ft.refund))
+}
+
+// witnessForTaprootRefundTx constructs a witness for refund tx
+// that unlocks the refund script leaf
+func (d *DLC) witnessForTaprootRefundTx() (wire.TxWitness, error) {
+	ft, err := d.fundTaproot()
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := [][]byte{}
+	for _, p := range d.Conds.Parties() {
+		sigs = append(sigs, d.RefundSigs[p])
+	}
+
+	return script.WitnessForTaprootRefundScript(
+		sigs, ft.refund, ft.controlBlock()), nil
+}
@@ -0,0 +1,414 @@
+package dlc
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
+	"github.com/p2pderivatives/dlc/internal/oracle"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/musig2"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+	"github.com/p2pderivatives/dlc/pkg/script"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockSchnorrSignature(
+	w *walletmock.Wallet, pub *btcec.PublicKey, priv *btcec.PrivateKey) *walletmock.Wallet {
+	call := w.On("SchnorrSignature", mock.AnythingOfType("[]uint8"), pub)
+
+	call.Run(func(args mock.Arguments) {
+		hash := args.Get(0).([]uint8)
+		sign, err := schnorr.SignBIP340(priv, hash, make([]byte, 32))
+		call.ReturnArguments = mock.Arguments{sign, err}
+	})
+
+	return w
+}
+
+func mockMuSig2PartialSign(
+	w *walletmock.Wallet, pub *btcec.PublicKey, priv *btcec.PrivateKey) *walletmock.Wallet {
+	call := w.On("MuSig2PartialSign",
+		mock.AnythingOfType("[]uint8"),
+		mock.AnythingOfType("*musig2.Session"),
+		pub,
+	)
+
+	call.Run(func(args mock.Arguments) {
+		secnonce := args.Get(0).([]uint8)
+		s := args.Get(1).(*musig2.Session)
+		psig, err := musig2.PartialSign(secnonce, priv, s)
+		call.ReturnArguments = mock.Arguments{psig, err}
+	})
+
+	return w
+}
+
+func setupTaprootWallet() *walletmock.Wallet {
+	w := &walletmock.Wallet{}
+	w.On("WatchOnly").Return(false)
+	priv, pub := test.RandKeys()
+	w.On("NewFundPubkey").Return(pub, uint32(0), nil)
+	w = mockSchnorrSignature(w, pub, priv)
+	w = mockMuSig2PartialSign(w, pub, priv)
+	return w
+}
+
+// setupTaprootContractors prepares two contractors in taproot fund mode
+// that have exchanged fund tx requirements and nonces
+func setupTaprootContractors(deal *Deal) (b1, b2 *Builder, err error) {
+	setupConds := func() *Conditions {
+		conds := newTestConditions()
+		conds.Deals = []*Deal{deal}
+		conds.FundMode = FundModeTaproot
+		return conds
+	}
+
+	b1 = setupBuilder(FirstParty, setupTaprootWallet, setupConds)
+	if err = stepPrepare(b1); err != nil {
+		return
+	}
+	b2 = setupBuilder(SecondParty, setupTaprootWallet, setupConds)
+	if err = stepPrepare(b2); err != nil {
+		return
+	}
+
+	if err = stepSendRequirments(b1, b2); err != nil {
+		return
+	}
+	if err = stepSendRequirments(b2, b1); err != nil {
+		return
+	}
+
+	if err = b1.PrepareNonces(); err != nil {
+		return
+	}
+	if err = b2.PrepareNonces(); err != nil {
+		return
+	}
+	if err = b2.AcceptNonces(b1.PublicNonces()); err != nil {
+		return
+	}
+	err = b1.AcceptNonces(b2.PublicNonces())
+	return
+}
+
+func TestTaprootFundTx(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	b1, b2, err := setupTaprootContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	tx1, err := b1.Contract.FundTx()
+	assert.NoError(err)
+	tx2, err := b2.Contract.FundTx()
+	assert.NoError(err)
+	assert.Equal(tx1.TxHash(), tx2.TxHash())
+
+	// fund output is locked by taproot output key
+	// tweaked with the refund script leaf
+	ft, err := b1.Contract.fundTaproot()
+	assert.NoError(err)
+	outputKey, err := script.TaprootOutputKey(
+		ft.internal, script.TapLeafHash(ft.refund))
+	assert.NoError(err)
+	assert.Equal(schnorr.XOnly(outputKey), ft.keyAgg.XOnlyPubKey())
+
+	pkScript, _ := script.P2TRpkScript(outputKey)
+	assert.Equal(pkScript, tx1.TxOut[fundTxOutAt].PkScript)
+
+	// CETx fee is reduced by the key path spending
+	conds := *b1.Contract.Conds
+	conds.FundMode = FundModeP2WSH
+	p2wsh := NewDLC(&conds)
+	assert.Equal(
+		p2wsh.execTxFee()-b1.Contract.execTxFee(),
+		btcutil.Amount(taprootKeySpendSaving))
+}
+
+func TestTaprootContractExecution(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	b1, b2, err := setupTaprootContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	dID := 0
+
+	privkey, C := test.RandKeys()
+	b1.Contract.Oracle.Commitments[dID] = C
+	b2.Contract.Oracle.Commitments[dID] = C
+
+	// exchange partial signatures
+	sig1, err := b1.SignContractExecutionTx(deal, dID)
+	assert.NoError(err)
+	sig2, err := b2.SignContractExecutionTx(deal, dID)
+	assert.NoError(err)
+	assert.Len(sig1, musig2.PartialSigSize)
+
+	// a partial signature for the other CETx is invalid
+	err = b1.AcceptCETxSignatures([][]byte{sig1})
+	assert.Error(err)
+
+	err = b1.AcceptCETxSignatures([][]byte{sig2})
+	assert.NoError(err)
+	err = b2.AcceptCETxSignatures([][]byte{sig1})
+	assert.NoError(err)
+
+	// secret nonce for the counterparty's CETx isn't reused
+	_, err = b1.SignContractExecutionTx(deal, dID)
+	assert.Error(err)
+
+	osigs := [][]byte{privkey.D.Bytes()}
+	oFixedMsg := &oracle.SignedMsg{Msgs: deal.Msgs, Sigs: osigs}
+	err = b1.FixDeal(oFixedMsg, []int{0})
+	assert.NoError(err)
+	err = b2.FixDeal(oFixedMsg, []int{0})
+	assert.NoError(err)
+
+	// both parties are able to spend the key path
+	fundtx, _ := b1.Contract.FundTx()
+	fout := fundtx.TxOut[fundTxOutAt]
+	for _, b := range []*Builder{b1, b2} {
+		tx, err := b.SignedContractExecutionTx()
+		if !assert.NoError(err) {
+			continue
+		}
+		wit := tx.TxIn[fundTxInAt].Witness
+		assert.Len(wit, 1)
+
+		hash, err := script.TaprootSigHash(tx, fundTxInAt, []*wire.TxOut{fout}, nil)
+		assert.NoError(err)
+		assert.True(schnorr.VerifyBIP340(fout.PkScript[2:], hash, wit[0]))
+
+		// all secret nonces are erased after use
+		for _, secnonces := range b.Contract.Nonces.Sec {
+			for _, secnonce := range secnonces {
+				assert.Nil(secnonce)
+			}
+		}
+
+		// own partial signature is reused without a secret nonce
+		tx2, err := b.SignedContractExecutionTx()
+		assert.NoError(err)
+		assert.Equal(tx, tx2)
+	}
+}
+
+func TestTaprootNoncesNotRegenerated(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	b1, b2, err := setupTaprootContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	nonces := b1.PublicNonces()
+	secnonces := b1.Contract.Nonces.Sec
+
+	// nonces are never replaced by a re-run or a replayed message
+	assert.Error(b1.PrepareNonces())
+	assert.Error(b1.AcceptNonces(b1.PublicNonces()))
+	assert.Error(b2.AcceptNonces(b1.PublicNonces()))
+	assert.Equal(nonces, b1.PublicNonces())
+	assert.Equal(secnonces, b1.Contract.Nonces.Sec)
+}
+
+func TestTaprootContractExecutionWithoutNonces(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	b1, _, err := setupTaprootContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	_, C := test.RandKeys()
+	b1.Contract.Oracle.Commitments[0] = C
+
+	// nonces for all CETxs are required
+	err = b1.AcceptNonces(map[Contractor][][]byte{FirstParty: {{1}}})
+	assert.Error(err)
+
+	delete(b1.Contract.Nonces.Pub, SecondParty)
+	_, err = b1.SignContractExecutionTx(deal, 0)
+	assert.Error(err)
+}
+
+func TestTaprootRefundTx(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	b1, b2, err := setupTaprootContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	sig1, err := b1.SignRefundTx()
+	assert.NoError(err)
+	sig2, err := b2.SignRefundTx()
+	assert.NoError(err)
+
+	// a signature by the other party is invalid
+	err = b1.AcceptRefundTxSignature(sig1)
+	assert.Error(err)
+
+	err = b1.AcceptRefundTxSignature(sig2)
+	assert.NoError(err)
+
+	tx, err := b1.Contract.SignedRefundTx()
+	assert.NoError(err)
+	assert.Equal(b1.Contract.Conds.RefundLockTime, tx.LockTime)
+
+	// signatures, refund script and control block
+	ft, _ := b1.Contract.fundTaproot()
+	wit := tx.TxIn[fundTxInAt].Witness
+	assert.Len(wit, 4)
+	assert.Equal(sig2, wit[0])
+	assert.Equal(sig1, wit[1])
+	assert.Equal(ft.refund, wit[2])
+	assert.Equal(ft.controlBlock(), wit[3])
+}
+
+// setupTaprootContractorsForMutualClose prepares two contractors
+// in taproot fund mode that have exchanged mutual closing tx nonces
+func setupTaprootContractorsForMutualClose() (b1, b2 *Builder, err error) {
+	setupConds := func() *Conditions {
+		conds := newMutualCloseConditions()
+		conds.FundMode = FundModeTaproot
+		return conds
+	}
+	setupWallet := func() *walletmock.Wallet {
+		return mockSelectUnspent(
+			setupTaprootWallet(), 2*mutualCloseFundAmt, 1, nil)
+	}
+
+	b1 = setupBuilder(FirstParty, setupWallet, setupConds)
+	b2 = setupBuilder(SecondParty, setupWallet, setupConds)
+	for _, b := range []*Builder{b1, b2} {
+		if err = stepPrepare(b); err != nil {
+			return
+		}
+	}
+	if err = stepSendRequirments(b1, b2); err != nil {
+		return
+	}
+	if err = stepSendRequirments(b2, b1); err != nil {
+		return
+	}
+
+	n1, err := b1.MutualClosingNonce()
+	if err != nil {
+		return
+	}
+	n2, err := b2.MutualClosingNonce()
+	if err != nil {
+		return
+	}
+	if err = b1.AcceptMutualClosingNonce(n2); err != nil {
+		return
+	}
+	err = b2.AcceptMutualClosingNonce(n1)
+	return
+}
+
+func TestTaprootMutualClosingTx(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupTaprootContractorsForMutualClose()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	amt1, amt2 := btcutil.Amount(120000), mutualCloseFundAmt*2-120000-1000
+
+	sig2, err := b2.SignMutualClosingTx(amt1, amt2)
+	assert.NoError(err)
+	assert.Len(sig2, musig2.PartialSigSize)
+
+	// a partial signature for other amounts is invalid
+	_, err = b1.SignedMutualClosingTx(amt2, amt1, sig2)
+	assert.Error(err)
+
+	tx, err := b1.SignedMutualClosingTx(amt1, amt2, sig2)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	assert.Equal(int64(amt1), tx.TxOut[0].Value)
+	assert.Equal(int64(amt2), tx.TxOut[1].Value)
+
+	// key path spending with a single schnorr signature
+	fundtx, _ := b1.Contract.FundTx()
+	fout := fundtx.TxOut[fundTxOutAt]
+	wit := tx.TxIn[fundTxInAt].Witness
+	assert.Len(wit, 1)
+	hash, err := script.TaprootSigHash(tx, fundTxInAt, []*wire.TxOut{fout}, nil)
+	assert.NoError(err)
+	assert.True(schnorr.VerifyBIP340(fout.PkScript[2:], hash, wit[0]))
+
+	// fee is reduced by the key path spending
+	conds := *b1.Contract.Conds
+	conds.FundMode = FundModeP2WSH
+	p2wsh := NewDLC(&conds)
+	assert.Equal(
+		p2wsh.mutualClosingTxFee()-b1.Contract.mutualClosingTxFee(),
+		btcutil.Amount(taprootKeySpendSaving))
+}
+
+// a secret nonce of mutual closing tx never signs another tx
+func TestTaprootMutualClosingNonceNotReused(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupTaprootContractorsForMutualClose()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	amt1, amt2 := btcutil.Amount(120000), mutualCloseFundAmt*2-120000-1000
+
+	sig1, err := b1.SignMutualClosingTx(amt1, amt2)
+	assert.NoError(err)
+	assert.Nil(b1.closingNonces.sec)
+
+	// the same signature for the same tx
+	sig, err := b1.SignMutualClosingTx(amt1, amt2)
+	assert.NoError(err)
+	assert.Equal(sig1, sig)
+
+	_, err = b1.SignMutualClosingTx(amt2, amt1)
+	assert.Error(err)
+
+	// counterparty's nonce is never replaced
+	n, _ := b1.MutualClosingNonce()
+	assert.Error(b2.AcceptMutualClosingNonce(n))
+
+	// signing requires nonces of both parties
+	b3, _, err := setupTaprootContractorsForMutualClose()
+	assert.NoError(err)
+	_, err = b3.MutualClosingNonce()
+	assert.NoError(err)
+	_, err = b3.SignMutualClosingTx(amt1, amt2)
+	assert.Error(err)
+}
+
+func TestTaprootUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	b1, _, err := setupTaprootContractors(deal)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	_, err = b1.Rollover(b1.Contract.Conds)
+	assert.Error(err)
+
+	// nonces aren't used in P2WSH fund mode
+	b, _, _, _, err := setupContractorsUntilPubkeyExchange(1, 1)
+	assert.NoError(err)
+	assert.Error(b.PrepareNonces())
+}
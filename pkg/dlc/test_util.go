@@ -1,10 +1,14 @@
 package dlc
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/p2pderivatives/dlc/internal/mocks/walletmock"
@@ -17,9 +21,10 @@ import (
 // setup mocke wallet
 func setupTestWallet() *walletmock.Wallet {
 	w := &walletmock.Wallet{}
+	w.On("WatchOnly").Return(false)
 	// pubkey for fund script
 	priv, pub := test.RandKeys()
-	w.On("NewPubkey").Return(pub, nil)
+	w.On("NewFundPubkey").Return(pub, uint32(0), nil)
 	w = mockWitnessSignature(w, pub, priv)
 
 	return w
@@ -82,19 +87,71 @@ func mockWitnessSignatureWithCallback(
 // Hash of block 234439
 var testTxID = "14a0810ac680a3eb3f82edc878cea25ec41d6b790744e5daeef"
 
+// randTxID returns a random txid so that utxos of parties don't conflict
+func randTxID() string {
+	b := make([]byte, chainhash.HashSize)
+	rand.Read(b)
+	h, _ := chainhash.NewHash(b)
+	return h.String()
+}
+
+// randP2WPKHScript returns a random p2wpkh script in hex
+func randP2WPKHScript() string {
+	_, pub := test.RandKeys()
+	pkScript, _ := script.P2WPKHpkScript(pub)
+	return hex.EncodeToString(pkScript)
+}
+
 func mockSelectUnspent(
 	w *walletmock.Wallet, balance, change btcutil.Amount, err error) *walletmock.Wallet {
 	utxo := wallet.Utxo{
-		TxID:   testTxID,
-		Amount: float64(balance) / btcutil.SatoshiPerBitcoin,
+		TxID:         randTxID(),
+		Amount:       float64(balance) / btcutil.SatoshiPerBitcoin,
+		ScriptPubKey: randP2WPKHScript(),
 	}
-	w.On("SelectUnspent",
-		mock.Anything, mock.Anything, mock.Anything,
+	w.On("SelectAndLockUnspent",
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
 	).Return([]wallet.Utxo{utxo}, change, err)
 
 	return w
 }
 
+func mockLockUtxos(w *walletmock.Wallet) *walletmock.Wallet {
+	w.On("LockUtxos", mock.Anything, mock.Anything).Return(nil)
+	w.On("UnlockUtxos", mock.Anything).Return(nil)
+	return w
+}
+
+// testChain is a chain client on which utxos of a contract are confirmed
+type testChain struct {
+	d      *DLC
+	height int64
+}
+
+// GetBlockCount returns the height of the chain
+func (c *testChain) GetBlockCount() (int64, error) {
+	return c.height, nil
+}
+
+// GetTxOut finds a utxo of the contract
+func (c *testChain) GetTxOut(
+	txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error) {
+	for _, utxos := range c.d.Utxos {
+		for _, utxo := range utxos {
+			if utxo.TxID != txHash.String() || utxo.Vout != index {
+				continue
+			}
+			return &btcjson.GetTxOutResult{
+				Confirmations: MinUtxoConfirmations,
+				Value:         utxo.Amount,
+				ScriptPubKey: btcjson.ScriptPubKeyResult{
+					Hex: utxo.ScriptPubKey, Type: scriptTypeP2WPKH},
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
 func newTestConditions() *Conditions {
 	net := &chaincfg.RegressionNetParams
 	conds, _ := NewConditions(net, time.Now(), 1, 1, 1, 1, 1, []*Deal{}, nil)
@@ -123,12 +180,14 @@ func setupBuilder(
 	walletFunc func() *walletmock.Wallet,
 	condsFunc func() *Conditions) *Builder {
 	w := walletFunc()
-	w = mockSelectUnspent(w, 1000, 1, nil)
+	w = mockSelectUnspent(w, 10000, 1, nil)
+	w = mockLockUtxos(w)
 	conds := condsFunc()
 	d := NewDLC(conds)
 	d.Addrs[p] = test.RandAddress()
 	d.ChangeAddrs[p] = test.RandAddress()
 	b := NewBuilder(p, w, d)
+	b.SetRPCClient(&testChain{d: d})
 	return b
 }
 
@@ -0,0 +1,189 @@
+package dlc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// MinUtxoConfirmations is the minimum number of confirmations
+// required for utxos provided by the other parties
+const MinUtxoConfirmations = 1
+
+// script type of p2wpkh in gettxout result
+const scriptTypeP2WPKH = "witness_v0_keyhash"
+
+// UtxoNotFoundError is raised when a utxo doesn't exist or has been spent
+type UtxoNotFoundError struct{ error }
+
+// UtxoNotConfirmedError is raised when a utxo doesn't have enough confirmations
+type UtxoNotConfirmedError struct{ error }
+
+// UtxoScriptTypeError is raised when a utxo isn't locked by p2wpkh script
+type UtxoScriptTypeError struct{ error }
+
+// UtxoAmountError is raised when a utxo amount is invalid
+// or doesn't match the amount of the actual output
+type UtxoAmountError struct{ error }
+
+// DuplicateUtxoError is raised when a utxo is provided more than once
+type DuplicateUtxoError struct{ error }
+
+// InsufficientUtxosError is raised when utxos don't cover deposit amount
+type InsufficientUtxosError struct{ error }
+
+// ChainClientNotSetError is raised when utxos or the block height
+// can't be looked up because no chain client is set
+type ChainClientNotSetError struct{ error }
+
+// ChainClient looks up outputs and the block height on the blockchain.
+// A bitcoind rpc client satisfies it.
+type ChainClient interface {
+	// GetTxOut returns an unspent output, or nil if it doesn't exist
+	GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error)
+	// GetBlockCount returns the current block height
+	GetBlockCount() (int64, error)
+}
+
+// SetRPCClient sets a client used to look up utxos of the other parties.
+// It's required to accept utxos and fund tx witnesses of the other parties,
+// and to verify a contract whose refund locktime is a block height.
+func (b *Builder) SetRPCClient(c ChainClient) {
+	b.rpc = c
+}
+
+// txOutOnChain looks up an unspent output by gettxout
+func (b *Builder) txOutOnChain(op *wire.OutPoint) (*btcjson.GetTxOutResult, error) {
+	if b.rpc == nil {
+		msg := fmt.Sprintf("chain client is required to look up utxo. %s", op)
+		return nil, &ChainClientNotSetError{error: errors.New(msg)}
+	}
+
+	// outputs spent in mempool aren't found either
+	res, err := b.rpc.GetTxOut(&op.Hash, op.Index, true)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		msg := fmt.Sprintf("utxo not found. %s", op)
+		return nil, &UtxoNotFoundError{error: errors.New(msg)}
+	}
+	return res, nil
+}
+
+// validateUtxos validates utxos of a given party
+func (b *Builder) validateUtxos(p Contractor) error {
+	d := b.Contract
+
+	// outpoints of the other parties
+	used := make(map[wire.OutPoint]Contractor)
+	for _, q := range d.Conds.Parties() {
+		if q == p {
+			continue
+		}
+		for _, utxo := range d.Utxos[q] {
+			op, err := utxoOutPoint(utxo)
+			if err != nil {
+				return err
+			}
+			used[*op] = q
+		}
+	}
+
+	seen := make(map[wire.OutPoint]bool)
+	total := btcutil.Amount(0)
+	for _, utxo := range d.Utxos[p] {
+		op, err := utxoOutPoint(utxo)
+		if err != nil {
+			return err
+		}
+		if seen[*op] {
+			msg := fmt.Sprintf("utxo is duplicated. %s", op)
+			return &DuplicateUtxoError{error: errors.New(msg)}
+		}
+		if q, ok := used[*op]; ok {
+			msg := fmt.Sprintf("utxo is already used by %s. %s", q, op)
+			return &DuplicateUtxoError{error: errors.New(msg)}
+		}
+		seen[*op] = true
+
+		amt, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return err
+		}
+		if amt <= 0 {
+			msg := fmt.Sprintf("utxo amount must be positive. %s", op)
+			return &UtxoAmountError{error: errors.New(msg)}
+		}
+
+		if err = b.validateUtxoOnChain(utxo, op, amt); err != nil {
+			return err
+		}
+		total += amt
+	}
+
+	if deposit := d.DepositAmt(p); total < deposit {
+		msg := fmt.Sprintf(
+			"utxos of %s don't cover deposit amount. total: %d, deposit: %d",
+			p, total, deposit)
+		return &InsufficientUtxosError{error: errors.New(msg)}
+	}
+
+	return nil
+}
+
+// validateUtxoOnChain looks up a utxo by gettxout
+// and validates confirmations, script type and amount
+func (b *Builder) validateUtxoOnChain(
+	utxo *Utxo, op *wire.OutPoint, amt btcutil.Amount) error {
+	res, err := b.txOutOnChain(op)
+	if err != nil {
+		return err
+	}
+
+	if res.Confirmations < MinUtxoConfirmations {
+		msg := fmt.Sprintf(
+			"utxo must have at least %d confirmations. %s, confirmations: %d",
+			MinUtxoConfirmations, op, res.Confirmations)
+		return &UtxoNotConfirmedError{error: errors.New(msg)}
+	}
+
+	if res.ScriptPubKey.Type != scriptTypeP2WPKH {
+		msg := fmt.Sprintf(
+			"utxo must be p2wpkh. %s, type: %s", op, res.ScriptPubKey.Type)
+		return &UtxoScriptTypeError{error: errors.New(msg)}
+	}
+	if utxo.ScriptPubKey == "" {
+		msg := fmt.Sprintf("utxo script is required. %s", op)
+		return &UtxoScriptTypeError{error: errors.New(msg)}
+	}
+	if utxo.ScriptPubKey != res.ScriptPubKey.Hex {
+		msg := fmt.Sprintf("utxo script doesn't match. %s", op)
+		return &UtxoScriptTypeError{error: errors.New(msg)}
+	}
+
+	actual, err := btcutil.NewAmount(res.Value)
+	if err != nil {
+		return err
+	}
+	if actual != amt {
+		msg := fmt.Sprintf(
+			"utxo amount doesn't match. %s, amount: %d, actual: %d", op, amt, actual)
+		return &UtxoAmountError{error: errors.New(msg)}
+	}
+
+	return nil
+}
+
+// utxoOutPoint returns an outpoint of a utxo
+func utxoOutPoint(utxo *Utxo) (*wire.OutPoint, error) {
+	txid, err := chainhash.NewHashFromStr(utxo.TxID)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewOutPoint(txid, utxo.Vout), nil
+}
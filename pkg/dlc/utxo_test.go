@@ -0,0 +1,137 @@
+package dlc
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/p2pderivatives/dlc/internal/mocks/rpcmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockGetTxOut(res *btcjson.GetTxOutResult) *rpcmock.Client {
+	rpcc := &rpcmock.Client{}
+	rpcc.On("GetTxOut", mock.Anything, mock.Anything, true).Return(res, nil)
+	return rpcc
+}
+
+func testTxOutResult(amt float64, pkScript string) *btcjson.GetTxOutResult {
+	return &btcjson.GetTxOutResult{
+		Confirmations: 1,
+		Value:         amt,
+		ScriptPubKey: btcjson.ScriptPubKeyResult{
+			Hex: pkScript, Type: scriptTypeP2WPKH},
+	}
+}
+
+// setupUtxoValidation prepares first party's utxos to be accepted by second party
+func setupUtxoValidation() (b1, b2 *Builder, err error) {
+	b1 = setupBuilder(FirstParty, setupTestWallet, newTestConditions)
+	if err = stepPrepare(b1); err != nil {
+		return
+	}
+	b2 = setupBuilder(SecondParty, setupTestWallet, newTestConditions)
+	err = stepPrepare(b2)
+	return
+}
+
+func TestAcceptUtxosWithRPC(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupUtxoValidation()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	utxos := b1.Utxos()
+	amt := utxos[0].Amount
+	pkScript := utxos[0].ScriptPubKey
+
+	valid := testTxOutResult(amt, pkScript)
+	unconfirmed := testTxOutResult(amt, pkScript)
+	unconfirmed.Confirmations = 0
+	p2pkh := testTxOutResult(amt, pkScript)
+	p2pkh.ScriptPubKey.Type = "pubkeyhash"
+	wrongScript := testTxOutResult(amt, randP2WPKHScript())
+	wrongAmt := testTxOutResult(amt*2, pkScript)
+
+	tests := []struct {
+		res      *btcjson.GetTxOutResult
+		expected interface{}
+	}{
+		{nil, &UtxoNotFoundError{}},
+		{unconfirmed, &UtxoNotConfirmedError{}},
+		{p2pkh, &UtxoScriptTypeError{}},
+		{wrongScript, &UtxoScriptTypeError{}},
+		{wrongAmt, &UtxoAmountError{}},
+	}
+	for _, test := range tests {
+		b2.SetRPCClient(mockGetTxOut(test.res))
+		err = b2.AcceptUtxos(utxos)
+		assert.IsType(test.expected, err)
+		assert.Nil(b2.Contract.Utxos[FirstParty])
+	}
+
+	b2.SetRPCClient(mockGetTxOut(valid))
+	err = b2.AcceptUtxos(utxos)
+	assert.NoError(err)
+	assert.Len(b2.Contract.Utxos[FirstParty], 1)
+}
+
+func TestAcceptUtxosWithoutOnChainCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupUtxoValidation()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	utxos := b1.Utxos()
+
+	// script is required to be compared with the actual output
+	noScript := append([]Utxo{}, utxos...)
+	noScript[0].ScriptPubKey = ""
+	err = b2.AcceptUtxos(noScript)
+	assert.IsType(&UtxoScriptTypeError{}, err)
+
+	// utxos can't be validated without chain client
+	b2.SetRPCClient(nil)
+	err = b2.AcceptUtxos(utxos)
+	assert.IsType(&ChainClientNotSetError{}, err)
+	assert.Nil(b2.Contract.Utxos[FirstParty])
+}
+
+func TestAcceptUtxosDuplicated(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupUtxoValidation()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	// same utxo twice
+	utxo := b1.Utxos()[0]
+	err = b2.AcceptUtxos([]Utxo{utxo, utxo})
+	assert.IsType(&DuplicateUtxoError{}, err)
+
+	// utxo of own
+	err = b2.AcceptUtxos(b2.Utxos())
+	assert.IsType(&DuplicateUtxoError{}, err)
+	assert.Nil(b2.Contract.Utxos[FirstParty])
+}
+
+func TestAcceptUtxosInsufficient(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupUtxoValidation()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	utxos := b1.Utxos()
+	utxos[0].Amount = 0.00000001
+	err = b2.AcceptUtxos(utxos)
+	assert.IsType(&InsufficientUtxosError{}, err)
+
+	utxos[0].Amount = 0
+	err = b2.AcceptUtxos(utxos)
+	assert.IsType(&UtxoAmountError{}, err)
+}
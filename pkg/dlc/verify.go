@@ -0,0 +1,234 @@
+package dlc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+)
+
+// ContractVerificationError is raised when a contract is inconsistent.
+// It contains all errors found by the verification.
+type ContractVerificationError struct {
+	Errs []error
+}
+
+func (e *ContractVerificationError) Error() string {
+	msgs := []string{}
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid contract: " + strings.Join(msgs, "; ")
+}
+
+// blockInterval is the expected interval of blocks
+const blockInterval = 10 * time.Minute
+
+// Verify checks if all information held by a given party is consistent.
+// It's supposed to be called before signing fund tx.
+// All errors found are aggregated into ContractVerificationError.
+//
+// It checks deal amounts, refund locktime, networks of addresses,
+// oracle's commitments, CETx signatures and refund tx signatures.
+// Signatures of the other parties for all the party's CETxs and refund tx
+// are required.
+// The refund locktime in block height is compared with the height
+// expected at the fixing time from a given current height.
+func (d *DLC) Verify(p Contractor, height int64) error {
+	errs := []error{}
+	errs = append(errs, d.verifyDealAmts()...)
+	errs = append(errs, d.verifyRefundLockTime(height)...)
+	errs = append(errs, d.verifyAddrsNet()...)
+	errs = append(errs, d.verifyCommitments()...)
+	errs = append(errs, d.verifyExecSigs(p)...)
+	errs = append(errs, d.verifyRefundSigs(p)...)
+
+	if len(errs) > 0 {
+		return &ContractVerificationError{Errs: errs}
+	}
+	return nil
+}
+
+// Verify verifies the contract held by the builder.
+// The current height is looked up by the chain client
+// if the refund locktime is a block height.
+func (b *Builder) Verify() error {
+	height := int64(0)
+	if b.Contract.Conds.RefundLockTime < txscript.LockTimeThreshold {
+		if b.rpc == nil {
+			msg := "chain client is required to verify refund locktime in block height"
+			return &ChainClientNotSetError{error: errors.New(msg)}
+		}
+		var err error
+		height, err = b.rpc.GetBlockCount()
+		if err != nil {
+			return err
+		}
+	}
+	return b.Contract.Verify(b.party, height)
+}
+
+// verifyDealAmts checks if amounts of each deal sum up to the fund amount
+func (d *DLC) verifyDealAmts() (errs []error) {
+	famt, err := d.fundAmount()
+	if err != nil {
+		return []error{err}
+	}
+
+	for idx, deal := range d.Conds.Deals {
+		total := btcutil.Amount(0)
+		for _, amt := range deal.Amts {
+			total += amt
+		}
+		if total != famt {
+			msg := fmt.Sprintf(
+				"amounts of deal %d don't match fund amount. total: %d, fund: %d",
+				idx, total, famt)
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	return errs
+}
+
+// verifyRefundLockTime checks if the refund locktime is after the fixing time.
+// The locktime in block height is compared with the height at the fixing time,
+// which is estimated from the current height by the block interval.
+func (d *DLC) verifyRefundLockTime(height int64) (errs []error) {
+	lt := d.Conds.RefundLockTime
+	if lt < txscript.LockTimeThreshold {
+		fixingHeight := height
+		if wait := time.Until(d.Conds.FixingTime); wait > 0 {
+			fixingHeight += int64((wait + blockInterval - 1) / blockInterval)
+		}
+		if int64(lt) <= fixingHeight {
+			msg := fmt.Sprintf(
+				"refund locktime must be after the height at fixing time. "+
+					"locktime: %d, expected height: %d", lt, fixingHeight)
+			errs = append(errs, errors.New(msg))
+		}
+		return errs
+	}
+	if int64(lt) <= d.Conds.FixingTime.Unix() {
+		msg := fmt.Sprintf(
+			"refund locktime must be after fixing time. locktime: %d, fixing time: %d",
+			lt, d.Conds.FixingTime.Unix())
+		errs = append(errs, errors.New(msg))
+	}
+	return errs
+}
+
+// verifyAddrsNet checks if all addresses are for the network of the contract
+func (d *DLC) verifyAddrsNet() (errs []error) {
+	net := d.Conds.NetParams
+	check := func(kind string, p Contractor, addr btcutil.Address) {
+		if addr != nil && !addr.IsForNet(net) {
+			msg := fmt.Sprintf(
+				"%s of %s isn't for %s. %s", kind, p, net.Name, addr.EncodeAddress())
+			errs = append(errs, errors.New(msg))
+		}
+	}
+
+	for _, p := range d.Conds.Parties() {
+		check("address", p, d.Addrs[p])
+		check("change address", p, d.ChangeAddrs[p])
+	}
+	if info := d.Conds.PremiumInfo; info != nil {
+		check("premium address", info.PayingParty, info.PremiumDestAddress)
+	}
+	return errs
+}
+
+// verifyCommitments checks if oracle's commitments match the oracle's pubkey set.
+// It's skipped if the pubkey set isn't given.
+func (d *DLC) verifyCommitments() (errs []error) {
+	o := d.Oracle
+	if o.PubkeySet == nil || o.RpointIdxs == nil {
+		return nil
+	}
+
+	Rs := []*btcec.PublicKey{}
+	for _, idx := range o.RpointIdxs {
+		if idx < 0 || idx >= len(o.PubkeySet.CommittedRpoints) {
+			msg := fmt.Sprintf("R point index out of range. %d", idx)
+			return []error{errors.New(msg)}
+		}
+		Rs = append(Rs, o.PubkeySet.CommittedRpoints[idx])
+	}
+
+	for idx, deal := range d.Conds.Deals {
+		if idx >= len(o.Commitments) || o.Commitments[idx] == nil {
+			msg := fmt.Sprintf("missing oracle's commitment for deal %d", idx)
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if len(deal.Msgs) != len(Rs) {
+			msg := fmt.Sprintf(
+				"deal %d has %d messages, but %d R points are committed",
+				idx, len(deal.Msgs), len(Rs))
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		C := schnorr.CommitMulti(o.PubkeySet.Pubkey, Rs, deal.Msgs)
+		if !C.IsEqual(o.Commitments[idx]) {
+			msg := fmt.Sprintf(
+				"oracle's commitment for deal %d doesn't match pubkey set", idx)
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	return errs
+}
+
+// verifyExecSigs verifies CETx signatures by the other parties
+// for CETxs of a given party
+func (d *DLC) verifyExecSigs(owner Contractor) (errs []error) {
+	for _, signer := range d.Conds.counterparties(owner) {
+		sigs := d.ExecSigs[signer]
+		for idx, deal := range d.Conds.Deals {
+			if idx >= len(sigs) || sigs[idx] == nil {
+				msg := fmt.Sprintf("missing CETx signature of %s for deal %d", signer, idx)
+				errs = append(errs, errors.New(msg))
+				continue
+			}
+
+			tx, err := d.ContractExecutionTx(owner, deal, idx)
+			if err == nil {
+				if d.Conds.isTaproot() {
+					err = d.verifyCETxPartialSig(owner, signer, tx, idx, sigs[idx])
+				} else {
+					err = d.verifyCETxSignature(owner, signer, tx, sigs[idx])
+				}
+			}
+			if err != nil {
+				msg := fmt.Sprintf(
+					"invalid CETx signature of %s for deal %d: %v", signer, idx, err)
+				errs = append(errs, errors.New(msg))
+			}
+		}
+	}
+	return errs
+}
+
+// verifyRefundSigs verifies refund tx signatures of all parties.
+// Own signature may not be created yet, but the others are required.
+func (d *DLC) verifyRefundSigs(owner Contractor) (errs []error) {
+	for _, p := range d.Conds.Parties() {
+		sig := d.RefundSigs[p]
+		if sig == nil {
+			if p != owner {
+				msg := fmt.Sprintf("missing refund tx signature of %s", p)
+				errs = append(errs, errors.New(msg))
+			}
+			continue
+		}
+		if err := d.VerifyRefundTx(sig, d.Pubs[p]); err != nil {
+			msg := fmt.Sprintf("invalid refund tx signature of %s: %v", p, err)
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	return errs
+}
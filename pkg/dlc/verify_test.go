@@ -0,0 +1,188 @@
+package dlc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/oracle"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupContractorsForVerify prepares two contractors that have exchanged
+// oracle's pubkey set, CETx signatures and refund tx signatures
+func setupContractorsForVerify() (b1, b2 *Builder, err error) {
+	deal := NewDeal(1, 1, [][]byte{{1}})
+	setupConds := func() *Conditions {
+		conds := newTestConditions()
+		conds.Deals = []*Deal{deal}
+		return conds
+	}
+
+	b1 = setupBuilder(FirstParty, setupTestWallet, setupConds)
+	b2 = setupBuilder(SecondParty, setupTestWallet, setupConds)
+
+	_, V := test.RandKeys()
+	_, R := test.RandKeys()
+	pubset := &oracle.PubkeySet{
+		Pubkey: V, CommittedRpoints: []*btcec.PublicKey{R}}
+
+	for _, b := range []*Builder{b1, b2} {
+		if err = b.SetOraclePubkeySet(pubset, []int{0}); err != nil {
+			return
+		}
+		if err = stepPrepare(b); err != nil {
+			return
+		}
+	}
+
+	if err = stepSendRequirments(b1, b2); err != nil {
+		return
+	}
+	if err = stepSendRequirments(b2, b1); err != nil {
+		return
+	}
+
+	if err = stepExchangeCETxSig(b1, b2, deal, 0); err != nil {
+		return
+	}
+	if err = stepExchangeCETxSig(b2, b1, deal, 0); err != nil {
+		return
+	}
+
+	rs1, err := b1.SignRefundTx()
+	if err != nil {
+		return
+	}
+	rs2, err := b2.SignRefundTx()
+	if err != nil {
+		return
+	}
+	if err = b1.AcceptRefundTxSignature(rs2); err != nil {
+		return
+	}
+	err = b2.AcceptRefundTxSignature(rs1)
+	return
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, b2, err := setupContractorsForVerify()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	assert.NoError(b1.Verify())
+	assert.NoError(b2.Verify())
+
+	// a stored contract can be verified without builder
+	assert.NoError(b1.Contract.Verify(FirstParty, 0))
+	err = b1.Contract.Verify(SecondParty, 0)
+	assert.IsType(&ContractVerificationError{}, err) // no own signatures
+}
+
+// refund locktime in block height should be after the height at fixing time
+func TestVerifyRefundLockTimeHeight(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, err := setupContractorsForVerify()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	d := b1.Contract
+	d.Conds.FixingTime = time.Now().Add(24 * time.Hour) // 144 blocks later
+
+	d.Conds.RefundLockTime = 100
+	assert.Len(d.verifyRefundLockTime(0), 1)
+	d.Conds.RefundLockTime = 145
+	assert.Empty(d.verifyRefundLockTime(0))
+	assert.Len(d.verifyRefundLockTime(1), 1)
+
+	// current height is looked up by the chain client
+	b1.SetRPCClient(&testChain{d: d, height: 1})
+	err = b1.Verify()
+	if assert.IsType(&ContractVerificationError{}, err) {
+		assert.Contains(err.Error(), "expected height: 145")
+	}
+	b1.SetRPCClient(nil)
+	assert.IsType(&ChainClientNotSetError{}, b1.Verify())
+}
+
+func TestVerifyMissingSignatures(t *testing.T) {
+	assert := assert.New(t)
+
+	b1, _, err := setupContractorsForVerify()
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+
+	// own refund tx signature isn't required
+	delete(b1.Contract.RefundSigs, FirstParty)
+	assert.NoError(b1.Verify())
+
+	// no signature of the counterparty at all
+	b1.Contract.ExecSigs = make(map[Contractor][][]byte)
+	delete(b1.Contract.RefundSigs, SecondParty)
+	err = b1.Verify()
+	if !assert.IsType(&ContractVerificationError{}, err) {
+		return
+	}
+	assert.Len(err.(*ContractVerificationError).Errs, 2)
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	mainnetAddr, _ := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), &chaincfg.MainNetParams)
+
+	tests := []struct {
+		name    string
+		tamper  func(d *DLC)
+		minErrs int
+	}{
+		{"deal amounts", func(d *DLC) {
+			d.Conds.Deals[0].Amts[FirstParty] = 2
+		}, 2}, // deal amounts and CETx signature
+		{"refund locktime", func(d *DLC) {
+			d.Conds.RefundLockTime = uint32(d.Conds.FixingTime.Unix() - 1)
+		}, 3}, // locktime and refund signatures of both parties
+		{"address network", func(d *DLC) {
+			d.Addrs[SecondParty] = mainnetAddr
+		}, 1},
+		{"commitment", func(d *DLC) {
+			_, C := test.RandKeys()
+			d.Oracle.Commitments[0] = C
+		}, 2}, // commitment and CETx signature
+		{"CETx signature", func(d *DLC) {
+			d.ExecSigs[SecondParty][0] = []byte{1}
+		}, 1},
+		{"missing CETx signature", func(d *DLC) {
+			d.ExecSigs[SecondParty] = [][]byte{}
+		}, 1},
+		{"refund signature", func(d *DLC) {
+			d.RefundSigs[SecondParty] = d.RefundSigs[FirstParty]
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			b1, _, err := setupContractorsForVerify()
+			if !assert.NoError(err) {
+				assert.FailNow(err.Error())
+			}
+
+			tt.tamper(b1.Contract)
+			err = b1.Verify()
+			if !assert.IsType(&ContractVerificationError{}, err) {
+				return
+			}
+			verr := err.(*ContractVerificationError)
+			assert.True(len(verr.Errs) >= tt.minErrs, verr.Error())
+		})
+	}
+}
@@ -0,0 +1,434 @@
+// Package musig2 implements MuSig2 multi-signatures for BIP340 schnorr signatures.
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0327.mediawiki
+package musig2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+)
+
+// Sizes of serialized nonces and partial signatures
+const (
+	PubNonceSize   = 66
+	SecNonceSize   = 97
+	PartialSigSize = 32
+)
+
+// KeyAggContext is a context of aggregated public key
+type KeyAggContext struct {
+	pubs [][]byte // compressed public keys in order
+	Q    *btcec.PublicKey
+	gacc *big.Int
+	tacc *big.Int
+}
+
+// KeyAgg aggregates public keys in a given order
+func KeyAgg(pubs []*btcec.PublicKey) (*KeyAggContext, error) {
+	if len(pubs) == 0 {
+		return nil, errors.New("no public keys to aggregate")
+	}
+
+	ctx := &KeyAggContext{
+		gacc: big.NewInt(1),
+		tacc: big.NewInt(0),
+	}
+	for _, pub := range pubs {
+		ctx.pubs = append(ctx.pubs, pub.SerializeCompressed())
+	}
+
+	curve := btcec.S256()
+	var Qx, Qy *big.Int
+	for i, pub := range pubs {
+		a := ctx.coeff(ctx.pubs[i])
+		x, y := curve.ScalarMult(pub.X, pub.Y, schnorr.Bytes32(a))
+		if Qx == nil {
+			Qx, Qy = x, y
+		} else {
+			Qx, Qy = curve.Add(Qx, Qy, x, y)
+		}
+	}
+	if isInfinity(Qx, Qy) {
+		return nil, errors.New("aggregated public key is infinite")
+	}
+	ctx.Q = newPoint(Qx, Qy)
+
+	return ctx, nil
+}
+
+// coeff calculates KeyAggCoeff of a public key
+func (ctx *KeyAggContext) coeff(pub []byte) *big.Int {
+	// the second distinct key gets coefficient 1
+	for _, p := range ctx.pubs[1:] {
+		if !bytes.Equal(p, ctx.pubs[0]) {
+			if bytes.Equal(p, pub) {
+				return big.NewInt(1)
+			}
+			break
+		}
+	}
+
+	L := schnorr.TaggedHash("KeyAgg list", ctx.pubs...)
+	h := schnorr.TaggedHash("KeyAgg coefficient", L, pub)
+	a := new(big.Int).SetBytes(h)
+	return a.Mod(a, btcec.S256().N)
+}
+
+// PubKey returns the aggregated public key
+func (ctx *KeyAggContext) PubKey() *btcec.PublicKey {
+	return ctx.Q
+}
+
+// XOnlyPubKey returns the aggregated public key in x-only format
+func (ctx *KeyAggContext) XOnlyPubKey() []byte {
+	return schnorr.XOnly(ctx.Q)
+}
+
+// ApplyTweak returns a new context with a tweak added to the aggregated key.
+// A x-only tweak is used for taproot output key.
+func (ctx *KeyAggContext) ApplyTweak(
+	tweak []byte, xonly bool) (*KeyAggContext, error) {
+	curve := btcec.S256()
+	if len(tweak) != 32 {
+		return nil, errors.New("tweak must be 32 bytes")
+	}
+	t := new(big.Int).SetBytes(tweak)
+	if t.Cmp(curve.N) >= 0 {
+		return nil, errors.New("tweak is out of range")
+	}
+
+	g := big.NewInt(1)
+	if xonly && !schnorr.HasEvenY(ctx.Q) {
+		g = new(big.Int).Sub(curve.N, big.NewInt(1))
+	}
+
+	// Q' = g*Q + t*G
+	gQx, gQy := curve.ScalarMult(ctx.Q.X, ctx.Q.Y, schnorr.Bytes32(g))
+	tGx, tGy := curve.ScalarBaseMult(schnorr.Bytes32(t))
+	Qx, Qy := curve.Add(gQx, gQy, tGx, tGy)
+	if isInfinity(Qx, Qy) {
+		return nil, errors.New("tweaked public key is infinite")
+	}
+
+	gacc := new(big.Int).Mul(g, ctx.gacc)
+	gacc.Mod(gacc, curve.N)
+	tacc := new(big.Int).Mul(g, ctx.tacc)
+	tacc.Add(tacc, t)
+	tacc.Mod(tacc, curve.N)
+
+	return &KeyAggContext{
+		pubs: ctx.pubs,
+		Q:    newPoint(Qx, Qy),
+		gacc: gacc,
+		tacc: tacc,
+	}, nil
+}
+
+// NonceGen generates a secret nonce and a public nonce for a signer's public key.
+// A secret nonce must be used only once.
+func NonceGen(pub *btcec.PublicKey) (secnonce, pubnonce []byte, err error) {
+	r := make([]byte, 32)
+	if _, err = rand.Read(r); err != nil {
+		return nil, nil, err
+	}
+
+	pk := pub.SerializeCompressed()
+	secnonce = make([]byte, 0, SecNonceSize)
+	pubnonce = make([]byte, 0, PubNonceSize)
+	for i := byte(0); i < 2; i++ {
+		buf := new(bytes.Buffer)
+		buf.Write(r)
+		buf.WriteByte(byte(len(pk)))
+		buf.Write(pk)
+		buf.WriteByte(0) // no aggregated public key
+		buf.WriteByte(0) // no message
+		binary.Write(buf, binary.BigEndian, uint32(0))
+		buf.WriteByte(i)
+
+		h := schnorr.TaggedHash("MuSig/nonce", buf.Bytes())
+		k := new(big.Int).SetBytes(h)
+		k.Mod(k, btcec.S256().N)
+		if k.Sign() == 0 {
+			return nil, nil, errors.New("invalid nonce")
+		}
+
+		R := newPoint(btcec.S256().ScalarBaseMult(schnorr.Bytes32(k)))
+		secnonce = append(secnonce, schnorr.Bytes32(k)...)
+		pubnonce = append(pubnonce, R.SerializeCompressed()...)
+	}
+	secnonce = append(secnonce, pk...)
+
+	return secnonce, pubnonce, nil
+}
+
+// NonceAgg aggregates public nonces of all signers
+func NonceAgg(pubnonces [][]byte) ([]byte, error) {
+	curve := btcec.S256()
+	aggnonce := []byte{}
+	for j := 0; j < 2; j++ {
+		var Rx, Ry *big.Int
+		for _, pubnonce := range pubnonces {
+			if len(pubnonce) != PubNonceSize {
+				return nil, errors.New("invalid public nonce")
+			}
+			R, err := btcec.ParsePubKey(pubnonce[33*j:33*(j+1)], curve)
+			if err != nil {
+				return nil, err
+			}
+			if Rx == nil {
+				Rx, Ry = R.X, R.Y
+			} else {
+				Rx, Ry = curve.Add(Rx, Ry, R.X, R.Y)
+			}
+		}
+		aggnonce = append(aggnonce, serializeExt(Rx, Ry)...)
+	}
+	return aggnonce, nil
+}
+
+// Session is a signing session of a message
+type Session struct {
+	KeyAgg   *KeyAggContext
+	AggNonce []byte
+	Msg      []byte
+}
+
+// values calculates session values b, R and e
+func (s *Session) values() (b *big.Int, R *btcec.PublicKey, e *big.Int, err error) {
+	curve := btcec.S256()
+	if len(s.AggNonce) != PubNonceSize {
+		return nil, nil, nil, errors.New("invalid aggregated nonce")
+	}
+
+	Qx := s.KeyAgg.XOnlyPubKey()
+	h := schnorr.TaggedHash("MuSig/noncecoef", s.AggNonce, Qx, s.Msg)
+	b = new(big.Int).SetBytes(h)
+	b.Mod(b, curve.N)
+
+	R1x, R1y, err := parseExt(s.AggNonce[:33])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	R2x, R2y, err := parseExt(s.AggNonce[33:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// R = R1 + b*R2
+	var Rx, Ry *big.Int
+	if isInfinity(R2x, R2y) {
+		Rx, Ry = R1x, R1y
+	} else {
+		bR2x, bR2y := curve.ScalarMult(R2x, R2y, schnorr.Bytes32(b))
+		Rx, Ry = addPoints(R1x, R1y, bR2x, bR2y)
+	}
+	if isInfinity(Rx, Ry) {
+		Rx, Ry = curve.Gx, curve.Gy
+	}
+	R = newPoint(Rx, Ry)
+
+	h = schnorr.TaggedHash("BIP0340/challenge", schnorr.XOnly(R), Qx, s.Msg)
+	e = new(big.Int).SetBytes(h)
+	e.Mod(e, curve.N)
+
+	return b, R, e, nil
+}
+
+// PartialSign creates a partial signature with a secret nonce and a private key
+func PartialSign(
+	secnonce []byte, priv *btcec.PrivateKey, s *Session) ([]byte, error) {
+	curve := btcec.S256()
+	if len(secnonce) != SecNonceSize {
+		return nil, errors.New("invalid secret nonce")
+	}
+
+	b, R, e, err := s.values()
+	if err != nil {
+		return nil, err
+	}
+
+	k1 := new(big.Int).SetBytes(secnonce[:32])
+	k2 := new(big.Int).SetBytes(secnonce[32:64])
+	if k1.Sign() == 0 || k1.Cmp(curve.N) >= 0 ||
+		k2.Sign() == 0 || k2.Cmp(curve.N) >= 0 {
+		return nil, errors.New("invalid secret nonce")
+	}
+	if !schnorr.HasEvenY(R) {
+		k1 = new(big.Int).Sub(curve.N, k1)
+		k2 = new(big.Int).Sub(curve.N, k2)
+	}
+
+	pk := priv.PubKey().SerializeCompressed()
+	if !bytes.Equal(pk, secnonce[64:]) {
+		return nil, errors.New("secret nonce doesn't match the private key")
+	}
+	if !s.KeyAgg.contains(pk) {
+		return nil, errors.New("signer isn't included in the aggregated key")
+	}
+
+	// d = g*gacc*d'
+	a := s.KeyAgg.coeff(pk)
+	d := new(big.Int).Mul(s.KeyAgg.g(), s.KeyAgg.gacc)
+	d.Mul(d, priv.D)
+	d.Mod(d, curve.N)
+
+	// s = k1 + b*k2 + e*a*d
+	sig := new(big.Int).Mul(e, a)
+	sig.Mul(sig, d)
+	sig.Add(sig, new(big.Int).Mul(b, k2))
+	sig.Add(sig, k1)
+	sig.Mod(sig, curve.N)
+
+	psig := schnorr.Bytes32(sig)
+	pubnonce := append(
+		newPoint(curve.ScalarBaseMult(secnonce[:32])).SerializeCompressed(),
+		newPoint(curve.ScalarBaseMult(secnonce[32:64])).SerializeCompressed()...)
+	if !PartialSigVerify(psig, pubnonce, priv.PubKey(), s) {
+		return nil, errors.New("failed to create a valid partial signature")
+	}
+	return psig, nil
+}
+
+// PartialSigVerify verifies a partial signature of a signer
+func PartialSigVerify(
+	psig, pubnonce []byte, pub *btcec.PublicKey, s *Session) bool {
+	curve := btcec.S256()
+	if len(psig) != PartialSigSize || len(pubnonce) != PubNonceSize {
+		return false
+	}
+	sig := new(big.Int).SetBytes(psig)
+	if sig.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	b, R, e, err := s.values()
+	if err != nil {
+		return false
+	}
+
+	pk := pub.SerializeCompressed()
+	if !s.KeyAgg.contains(pk) {
+		return false
+	}
+
+	R1, err := btcec.ParsePubKey(pubnonce[:33], curve)
+	if err != nil {
+		return false
+	}
+	R2, err := btcec.ParsePubKey(pubnonce[33:], curve)
+	if err != nil {
+		return false
+	}
+
+	// Re = R1 + b*R2 (negated if R has odd y)
+	bR2x, bR2y := curve.ScalarMult(R2.X, R2.Y, schnorr.Bytes32(b))
+	Rex, Rey := addPoints(R1.X, R1.Y, bR2x, bR2y)
+	if !schnorr.HasEvenY(R) && !isInfinity(Rex, Rey) {
+		Rey = new(big.Int).Sub(curve.P, Rey)
+	}
+
+	// s*G == Re + e*a*g*gacc*P
+	a := s.KeyAgg.coeff(pk)
+	c := new(big.Int).Mul(e, a)
+	c.Mul(c, s.KeyAgg.g())
+	c.Mul(c, s.KeyAgg.gacc)
+	c.Mod(c, curve.N)
+	cPx, cPy := curve.ScalarMult(pub.X, pub.Y, schnorr.Bytes32(c))
+	Rx, Ry := addPoints(Rex, Rey, cPx, cPy)
+
+	sGx, sGy := curve.ScalarBaseMult(psig)
+	return sGx.Cmp(Rx) == 0 && sGy.Cmp(Ry) == 0
+}
+
+// PartialSigAgg aggregates partial signatures to a BIP340 signature
+func PartialSigAgg(psigs [][]byte, s *Session) ([]byte, error) {
+	curve := btcec.S256()
+	_, R, e, err := s.values()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := new(big.Int)
+	for _, psig := range psigs {
+		if len(psig) != PartialSigSize {
+			return nil, errors.New("invalid partial signature")
+		}
+		si := new(big.Int).SetBytes(psig)
+		if si.Cmp(curve.N) >= 0 {
+			return nil, errors.New("invalid partial signature")
+		}
+		sum.Add(sum, si)
+	}
+
+	// s = sum + e*g*tacc
+	et := new(big.Int).Mul(e, s.KeyAgg.g())
+	et.Mul(et, s.KeyAgg.tacc)
+	sum.Add(sum, et)
+	sum.Mod(sum, curve.N)
+
+	return append(schnorr.XOnly(R), schnorr.Bytes32(sum)...), nil
+}
+
+// g returns 1 if the aggregated key has even y, otherwise n-1
+func (ctx *KeyAggContext) g() *big.Int {
+	if schnorr.HasEvenY(ctx.Q) {
+		return big.NewInt(1)
+	}
+	return new(big.Int).Sub(btcec.S256().N, big.NewInt(1))
+}
+
+// contains returns true if a given public key is aggregated
+func (ctx *KeyAggContext) contains(pk []byte) bool {
+	for _, p := range ctx.pubs {
+		if bytes.Equal(p, pk) {
+			return true
+		}
+	}
+	return false
+}
+
+func newPoint(x, y *big.Int) *btcec.PublicKey {
+	return &btcec.PublicKey{Curve: btcec.S256(), X: x, Y: y}
+}
+
+func isInfinity(x, y *big.Int) bool {
+	return x.Sign() == 0 && y.Sign() == 0
+}
+
+// addPoints adds points handling the point at infinity
+func addPoints(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	if isInfinity(x1, y1) {
+		return x2, y2
+	}
+	if isInfinity(x2, y2) {
+		return x1, y1
+	}
+	return btcec.S256().Add(x1, y1, x2, y2)
+}
+
+// serializeExt serializes a point in compressed format,
+// and the point at infinity in 33 zero bytes
+func serializeExt(x, y *big.Int) []byte {
+	if isInfinity(x, y) {
+		return make([]byte, 33)
+	}
+	return newPoint(x, y).SerializeCompressed()
+}
+
+// parseExt parses a point serialized by serializeExt
+func parseExt(b []byte) (*big.Int, *big.Int, error) {
+	if bytes.Equal(b, make([]byte, 33)) {
+		return new(big.Int), new(big.Int), nil
+	}
+	p, err := btcec.ParsePubKey(b, btcec.S256())
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.X, p.Y, nil
+}
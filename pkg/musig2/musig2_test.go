@@ -0,0 +1,125 @@
+package musig2
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+	"github.com/stretchr/testify/assert"
+)
+
+// public keys of KeyAgg test vectors from BIP327
+var testPubs = []string{
+	"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+	"03DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+	"023590A94E768F8E1815C2F24B4D80A8E3149316C3518CE7B7AD338368D038CA66",
+}
+
+func TestKeyAgg(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		idxs     []int
+		expected string
+	}{
+		{[]int{0, 1, 2}, "90539EEDE565F5D054F32CC0C220126889ED1E5D193BAF15AEF344FE59D4610C"},
+		{[]int{2, 1, 0}, "6204DE8B083426DC6EAF9502D27024D53FC826BF7D2012148A0575435DF54B2B"},
+		{[]int{0, 0, 0}, "B436E3BAD62B8CD409969A224731C193D051162D8C5AE8B109306127DA3AA935"},
+		{[]int{0, 0, 1, 1}, "69BC22BFA5D106306E48A20679DE1D7389386124D07571D0D872686028C26A3E"},
+	}
+
+	for _, c := range cases {
+		pubs := []*btcec.PublicKey{}
+		for _, i := range c.idxs {
+			b, _ := hex.DecodeString(testPubs[i])
+			pub, err := btcec.ParsePubKey(b, btcec.S256())
+			assert.NoError(err)
+			pubs = append(pubs, pub)
+		}
+
+		ctx, err := KeyAgg(pubs)
+		assert.NoError(err)
+		expected, _ := hex.DecodeString(c.expected)
+		assert.Equal(expected, ctx.XOnlyPubKey())
+	}
+}
+
+func TestSignAndAggregate(t *testing.T) {
+	assert := assert.New(t)
+
+	privs, pubs := testKeys(3)
+	ctx, err := KeyAgg(pubs)
+	assert.NoError(err)
+
+	for _, tweaked := range []bool{false, true} {
+		sctx := ctx
+		if tweaked {
+			tweak := schnorr.TaggedHash("TapTweak", ctx.XOnlyPubKey())
+			sctx, err = ctx.ApplyTweak(tweak, true)
+			assert.NoError(err)
+		}
+
+		secnonces := [][]byte{}
+		pubnonces := [][]byte{}
+		for _, pub := range pubs {
+			secnonce, pubnonce, err := NonceGen(pub)
+			assert.NoError(err)
+			secnonces = append(secnonces, secnonce)
+			pubnonces = append(pubnonces, pubnonce)
+		}
+		aggnonce, err := NonceAgg(pubnonces)
+		assert.NoError(err)
+
+		msg := schnorr.TaggedHash("test", []byte("message"))
+		s := &Session{KeyAgg: sctx, AggNonce: aggnonce, Msg: msg}
+
+		psigs := [][]byte{}
+		for i, priv := range privs {
+			psig, err := PartialSign(secnonces[i], priv, s)
+			assert.NoError(err)
+			assert.True(PartialSigVerify(psig, pubnonces[i], pubs[i], s))
+			psigs = append(psigs, psig)
+		}
+
+		// a partial signature can't be verified with another nonce
+		assert.False(PartialSigVerify(psigs[0], pubnonces[1], pubs[0], s))
+
+		sig, err := PartialSigAgg(psigs, s)
+		assert.NoError(err)
+		assert.True(schnorr.VerifyBIP340(sctx.XOnlyPubKey(), msg, sig))
+	}
+}
+
+func TestPartialSignInvalidSecNonce(t *testing.T) {
+	assert := assert.New(t)
+
+	privs, pubs := testKeys(2)
+	ctx, _ := KeyAgg(pubs)
+
+	secnonce, pubnonce, _ := NonceGen(pubs[0])
+	_, pubnonce2, _ := NonceGen(pubs[1])
+	aggnonce, _ := NonceAgg([][]byte{pubnonce, pubnonce2})
+	s := &Session{KeyAgg: ctx, AggNonce: aggnonce, Msg: make([]byte, 32)}
+
+	// secret nonce of another signer
+	_, err := PartialSign(secnonce, privs[1], s)
+	assert.Error(err)
+
+	// signer not in the aggregated key
+	other, _ := btcec.NewPrivateKey(btcec.S256())
+	othernonce, _, _ := NonceGen(other.PubKey())
+	_, err = PartialSign(othernonce, other, s)
+	assert.Error(err)
+}
+
+func testKeys(n int) ([]*btcec.PrivateKey, []*btcec.PublicKey) {
+	privs := []*btcec.PrivateKey{}
+	pubs := []*btcec.PublicKey{}
+	for i := 0; i < n; i++ {
+		priv, _ := btcec.NewPrivateKey(btcec.S256())
+		privs = append(privs, priv)
+		pubs = append(pubs, priv.PubKey())
+	}
+	return privs, pubs
+}
@@ -0,0 +1,299 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// NotFinalizableError is raised when an input lacks data to finalize
+type NotFinalizableError struct{ error }
+
+// IncompleteError is raised when a PSBT has inputs not finalized yet
+type IncompleteError struct{ error }
+
+// Combine merges PSBTs of the same tx into a new PSBT.
+// It fails if they have conflicting signatures.
+func Combine(p *Packet, others ...*Packet) (*Packet, error) {
+	combined, err := p.copy()
+	if err != nil {
+		return nil, err
+	}
+
+	txid := p.UnsignedTx.TxHash()
+	for _, o := range others {
+		if o.UnsignedTx.TxHash() != txid {
+			return nil, errors.New("PSBTs of different txs can't be combined")
+		}
+		for i := range combined.Inputs {
+			if err = combined.Inputs[i].merge(&o.Inputs[i]); err != nil {
+				return nil, err
+			}
+		}
+		for i := range combined.Outputs {
+			combined.Outputs[i].merge(&o.Outputs[i])
+		}
+		combined.Unknowns = mergeUnknowns(combined.Unknowns, o.Unknowns)
+	}
+	return combined, nil
+}
+
+// copy returns a deep copy of a PSBT
+func (p *Packet) copy() (*Packet, error) {
+	buf := &bytes.Buffer{}
+	if err := p.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return NewFromRawBytes(buf, false)
+}
+
+func (pi *PInput) merge(o *PInput) error {
+	if pi.NonWitnessUtxo == nil {
+		pi.NonWitnessUtxo = o.NonWitnessUtxo
+	}
+	if pi.WitnessUtxo == nil {
+		pi.WitnessUtxo = o.WitnessUtxo
+	}
+	for _, s := range o.PartialSigs {
+		if err := pi.AddPartialSig(s.PubKey, s.Signature); err != nil {
+			return err
+		}
+	}
+	if pi.SighashType == 0 {
+		pi.SighashType = o.SighashType
+	}
+	if pi.RedeemScript == nil {
+		pi.RedeemScript = o.RedeemScript
+	}
+	if pi.WitnessScript == nil {
+		pi.WitnessScript = o.WitnessScript
+	}
+	pi.Bip32Derivation = mergeBip32Derivations(pi.Bip32Derivation, o.Bip32Derivation)
+	if pi.FinalScriptSig == nil {
+		pi.FinalScriptSig = o.FinalScriptSig
+	}
+	if pi.FinalScriptWitness == nil {
+		pi.FinalScriptWitness = o.FinalScriptWitness
+	}
+	pi.Unknowns = mergeUnknowns(pi.Unknowns, o.Unknowns)
+	return nil
+}
+
+func (po *POutput) merge(o *POutput) {
+	if po.RedeemScript == nil {
+		po.RedeemScript = o.RedeemScript
+	}
+	if po.WitnessScript == nil {
+		po.WitnessScript = o.WitnessScript
+	}
+	po.Bip32Derivation = mergeBip32Derivations(po.Bip32Derivation, o.Bip32Derivation)
+	po.Unknowns = mergeUnknowns(po.Unknowns, o.Unknowns)
+}
+
+func mergeBip32Derivations(ds, others []*Bip32Derivation) []*Bip32Derivation {
+	for _, o := range others {
+		found := false
+		for _, d := range ds {
+			if bytes.Equal(d.PubKey, o.PubKey) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ds = append(ds, o)
+		}
+	}
+	return ds
+}
+
+func mergeUnknowns(us, others []*Unknown) []*Unknown {
+	for _, o := range others {
+		found := false
+		for _, u := range us {
+			if bytes.Equal(u.Key, o.Key) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			us = append(us, o)
+		}
+	}
+	return us
+}
+
+// IsFinalized returns true if an input has its final witness or scriptSig
+func (pi *PInput) IsFinalized() bool {
+	return pi.FinalScriptWitness != nil || pi.FinalScriptSig != nil
+}
+
+// IsComplete returns true if all inputs are finalized
+func (p *Packet) IsComplete() bool {
+	for i := range p.Inputs {
+		if !p.Inputs[i].IsFinalized() {
+			return false
+		}
+	}
+	return true
+}
+
+// FinalizeInput constructs the final witness of a P2WPKH or P2WSH multisig
+// input from its partial signatures. Data used only to sign are removed.
+// A finalized input is left as it is.
+func (p *Packet) FinalizeInput(idx int) error {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return fmt.Errorf("input index out of range. %d", idx)
+	}
+	pi := &p.Inputs[idx]
+	if pi.IsFinalized() {
+		return nil
+	}
+	if pi.WitnessUtxo == nil {
+		msg := fmt.Sprintf("input %d has no witness utxo", idx)
+		return &NotFinalizableError{error: errors.New(msg)}
+	}
+
+	var wit wire.TxWitness
+	var err error
+	pkScript := pi.WitnessUtxo.PkScript
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		wit, err = pi.p2wpkhWitness()
+	case txscript.IsPayToWitnessScriptHash(pkScript):
+		wit, err = pi.p2wshMultiSigWitness()
+	default:
+		err = errors.New("unsupported script type")
+	}
+	if err != nil {
+		msg := fmt.Sprintf("input %d can't be finalized. %v", idx, err)
+		return &NotFinalizableError{error: errors.New(msg)}
+	}
+
+	if pi.FinalScriptWitness, err = SerializeWitness(wit); err != nil {
+		return err
+	}
+	pi.PartialSigs = nil
+	pi.SighashType = 0
+	pi.RedeemScript = nil
+	pi.WitnessScript = nil
+	pi.Bip32Derivation = nil
+	return nil
+}
+
+// Finalize finalizes all inputs
+func (p *Packet) Finalize() error {
+	for i := range p.Inputs {
+		if err := p.FinalizeInput(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pi *PInput) p2wpkhWitness() (wire.TxWitness, error) {
+	hash := pi.WitnessUtxo.PkScript[2:]
+	for _, s := range pi.PartialSigs {
+		if bytes.Equal(btcutil.Hash160(s.PubKey), hash) {
+			return wire.TxWitness{s.Signature, s.PubKey}, nil
+		}
+	}
+	return nil, errors.New("missing signature")
+}
+
+func (pi *PInput) p2wshMultiSigWitness() (wire.TxWitness, error) {
+	ws := pi.WitnessScript
+	if ws == nil {
+		return nil, errors.New("missing witness script")
+	}
+	if !bytes.Equal(chainhash.HashB(ws), pi.WitnessUtxo.PkScript[2:]) {
+		return nil, errors.New("witness script doesn't match witness utxo")
+	}
+	if txscript.GetScriptClass(ws) != txscript.MultiSigTy {
+		return nil, errors.New("witness script isn't multisig")
+	}
+	_, nSigs, err := txscript.CalcMultiSigStats(ws)
+	if err != nil {
+		return nil, err
+	}
+	pubs, err := txscript.PushedData(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	// signatures in the order of pubkeys following a dummy element
+	wit := wire.TxWitness{[]byte{}}
+	for _, pub := range pubs {
+		if sig, ok := pi.PartialSigOf(pub); ok && len(wit) <= nSigs {
+			wit = append(wit, sig)
+		}
+	}
+	if len(wit) <= nSigs {
+		return nil, fmt.Errorf("%d of %d signatures", len(wit)-1, nSigs)
+	}
+	return append(wit, ws), nil
+}
+
+// Extract returns the signed tx of a complete PSBT
+func (p *Packet) Extract() (*wire.MsgTx, error) {
+	tx := p.UnsignedTx.Copy()
+	for i := range p.Inputs {
+		pi := &p.Inputs[i]
+		if !pi.IsFinalized() {
+			msg := fmt.Sprintf("input %d isn't finalized", i)
+			return nil, &IncompleteError{error: errors.New(msg)}
+		}
+		tx.TxIn[i].SignatureScript = pi.FinalScriptSig
+		if pi.FinalScriptWitness != nil {
+			wit, err := ParseWitness(pi.FinalScriptWitness)
+			if err != nil {
+				return nil, err
+			}
+			tx.TxIn[i].Witness = wit
+		}
+	}
+	return tx, nil
+}
+
+// SerializeWitness serializes a witness stack in the format of
+// final script witnesses
+func SerializeWitness(wit wire.TxWitness) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := wire.WriteVarInt(buf, 0, uint64(len(wit))); err != nil {
+		return nil, err
+	}
+	for _, item := range wit {
+		if err := wire.WriteVarBytes(buf, 0, item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseWitness parses a serialized witness stack
+func ParseWitness(b []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(b)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, invalidFormat("invalid witness. %v", err)
+	}
+	if n > uint64(len(b)) {
+		return nil, invalidFormat("invalid witness size. %d", n)
+	}
+	wit := wire.TxWitness{}
+	for i := uint64(0); i < n; i++ {
+		item, err := wire.ReadVarBytes(r, 0, maxPsbtSize, "witness item")
+		if err != nil {
+			return nil, invalidFormat("invalid witness. %v", err)
+		}
+		wit = append(wit, item)
+	}
+	if r.Len() != 0 {
+		return nil, invalidFormat("witness has trailing bytes")
+	}
+	return wit, nil
+}
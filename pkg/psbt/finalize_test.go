@@ -0,0 +1,125 @@
+package psbt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPacket creates a PSBT spending a P2WPKH output of priv1
+// and a 2-of-2 P2WSH output of priv2 and priv3
+func newTestPacket(priv1, priv2, priv3 *btcec.PrivateKey) *Packet {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}}, nil, nil))
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{2}}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(19000, []byte{txscript.OP_TRUE}))
+	p, _ := New(tx)
+
+	pkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(priv1.PubKey().SerializeCompressed())).Script()
+	p.Inputs[0].WitnessUtxo = wire.NewTxOut(10000, pkScript)
+
+	ws, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_2).
+		AddData(priv2.PubKey().SerializeCompressed()).
+		AddData(priv3.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_2).AddOp(txscript.OP_CHECKMULTISIG).Script()
+	pkScript, _ = txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(chainhash.HashB(ws)).Script()
+	p.Inputs[1].WitnessUtxo = wire.NewTxOut(10000, pkScript)
+	p.Inputs[1].WitnessScript = ws
+
+	return p
+}
+
+func TestCombineFinalizeAndExtract(t *testing.T) {
+	assert := assert.New(t)
+	priv1, _ := btcec.NewPrivateKey(btcec.S256())
+	priv2, _ := btcec.NewPrivateKey(btcec.S256())
+	priv3, _ := btcec.NewPrivateKey(btcec.S256())
+	p := newTestPacket(priv1, priv2, priv3)
+
+	// each party signs its own copy
+	p1, _ := p.copy()
+	assert.NoError(p1.SignWitnessInput(0, priv1))
+	assert.NoError(p1.SignWitnessInput(1, priv2))
+	p2, _ := p.copy()
+	assert.NoError(p2.SignWitnessInput(1, priv3))
+
+	// a signature is missing
+	assert.IsType(&NotFinalizableError{}, p2.FinalizeInput(1))
+	_, err := p1.Extract()
+	assert.IsType(&IncompleteError{}, err)
+
+	combined, err := Combine(p1, p2)
+	assert.NoError(err)
+	assert.Len(combined.Inputs[1].PartialSigs, 2)
+	assert.Len(p1.Inputs[1].PartialSigs, 1)
+	assert.NoError(combined.Finalize())
+	assert.True(combined.IsComplete())
+	assert.Nil(combined.Inputs[1].PartialSigs)
+	assert.Nil(combined.Inputs[1].WitnessScript)
+
+	// finalized inputs are kept after serialization
+	b64, err := combined.B64Encode()
+	assert.NoError(err)
+	decoded, err := B64Decode(b64)
+	assert.NoError(err)
+
+	tx, err := decoded.Extract()
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Len(tx.TxIn[1].Witness, 4)
+	sighashes := txscript.NewTxSigHashes(tx)
+	for i, pi := range p.Inputs {
+		vm, err := txscript.NewEngine(pi.WitnessUtxo.PkScript, tx, i,
+			txscript.StandardVerifyFlags, nil, sighashes, pi.WitnessUtxo.Value)
+		assert.NoError(err)
+		assert.NoError(vm.Execute())
+	}
+}
+
+func TestCombineConflicts(t *testing.T) {
+	assert := assert.New(t)
+	priv1, _ := btcec.NewPrivateKey(btcec.S256())
+	priv2, _ := btcec.NewPrivateKey(btcec.S256())
+	priv3, _ := btcec.NewPrivateKey(btcec.S256())
+	p := newTestPacket(priv1, priv2, priv3)
+
+	// another tx
+	other := newTestPacket(priv1, priv2, priv3)
+	other.UnsignedTx.LockTime = 1
+	_, err := Combine(p, other)
+	assert.Error(err)
+
+	// conflicting signatures of the same pubkey
+	p1, _ := p.copy()
+	p2, _ := p.copy()
+	assert.NoError(p1.SignWitnessInput(0, priv1))
+	p2.Inputs[0].PartialSigs = []*PartialSig{{
+		PubKey: priv1.PubKey().SerializeCompressed(), Signature: []byte{0x01}}}
+	_, err = Combine(p1, p2)
+	assert.Error(err)
+}
+
+func TestParseWitness(t *testing.T) {
+	assert := assert.New(t)
+
+	wit := wire.TxWitness{{}, {0x01, 0x02}, bytes.Repeat([]byte{0x03}, 300)}
+	b, err := SerializeWitness(wit)
+	assert.NoError(err)
+	parsed, err := ParseWitness(b)
+	assert.NoError(err)
+	assert.Equal(wit, parsed)
+
+	_, err = ParseWitness(append(b, 0x00))
+	assert.Error(err)
+	_, err = ParseWitness(b[:len(b)-1])
+	assert.Error(err)
+}
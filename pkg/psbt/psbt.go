@@ -0,0 +1,479 @@
+// Package psbt implements partially signed bitcoin transactions (BIP174)
+// for segwit inputs, which are used to delegate signing to external signers.
+//
+// https://github.com/bitcoin/bips/blob/master/bip-0174.mediawiki
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// magic bytes of a PSBT
+var magic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// global key types
+const globalUnsignedTx = 0x00
+
+// input key types
+const (
+	inNonWitnessUtxo     = 0x00
+	inWitnessUtxo        = 0x01
+	inPartialSig         = 0x02
+	inSighashType        = 0x03
+	inRedeemScript       = 0x04
+	inWitnessScript      = 0x05
+	inBip32Derivation    = 0x06
+	inFinalScriptSig     = 0x07
+	inFinalScriptWitness = 0x08
+)
+
+// output key types
+const (
+	outRedeemScript    = 0x00
+	outWitnessScript   = 0x01
+	outBip32Derivation = 0x02
+)
+
+// maxPsbtSize is the maximum size of a PSBT to decode
+const maxPsbtSize = 100000000
+
+// InvalidFormatError is raised when a PSBT isn't well-formed
+type InvalidFormatError struct{ error }
+
+func invalidFormat(format string, a ...interface{}) error {
+	return &InvalidFormatError{error: fmt.Errorf(format, a...)}
+}
+
+// Packet is a PSBT
+type Packet struct {
+	UnsignedTx *wire.MsgTx
+	Inputs     []PInput
+	Outputs    []POutput
+	Unknowns   []*Unknown
+}
+
+// PInput is a set of data to sign a txin
+type PInput struct {
+	NonWitnessUtxo     *wire.MsgTx
+	WitnessUtxo        *wire.TxOut
+	PartialSigs        []*PartialSig
+	SighashType        txscript.SigHashType // 0 if not specified
+	RedeemScript       []byte
+	WitnessScript      []byte
+	Bip32Derivation    []*Bip32Derivation
+	FinalScriptSig     []byte
+	FinalScriptWitness []byte // serialized witness stack
+	Unknowns           []*Unknown
+}
+
+// POutput is a set of data of a txout
+type POutput struct {
+	RedeemScript    []byte
+	WitnessScript   []byte
+	Bip32Derivation []*Bip32Derivation
+	Unknowns        []*Unknown
+}
+
+// PartialSig is a signature of a pubkey with sighash type
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// Bip32Derivation is a derivation path of a pubkey from a master key
+type Bip32Derivation struct {
+	PubKey               []byte
+	MasterKeyFingerprint uint32
+	Bip32Path            []uint32
+}
+
+// Unknown is a key-value pair of unknown type, which is kept as is
+type Unknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// New creates a PSBT of an unsigned tx
+func New(tx *wire.MsgTx) (*Packet, error) {
+	if err := checkUnsigned(tx); err != nil {
+		return nil, err
+	}
+	return &Packet{
+		UnsignedTx: tx.Copy(),
+		Inputs:     make([]PInput, len(tx.TxIn)),
+		Outputs:    make([]POutput, len(tx.TxOut)),
+		Unknowns:   []*Unknown{},
+	}, nil
+}
+
+func checkUnsigned(tx *wire.MsgTx) error {
+	for _, txin := range tx.TxIn {
+		if len(txin.SignatureScript) > 0 || len(txin.Witness) > 0 {
+			return invalidFormat("unsigned tx has a signature script or witness")
+		}
+	}
+	return nil
+}
+
+// NewFromRawBytes decodes a PSBT in binary or base64
+func NewFromRawBytes(r io.Reader, b64 bool) (*Packet, error) {
+	if b64 {
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxPsbtSize))
+	if err != nil {
+		return nil, invalidFormat("%v", err)
+	}
+	return parse(bytes.NewReader(data))
+}
+
+// B64Decode decodes a PSBT in base64
+func B64Decode(s string) (*Packet, error) {
+	return NewFromRawBytes(bytes.NewReader([]byte(s)), true)
+}
+
+func parse(r *bytes.Reader) (*Packet, error) {
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, m); err != nil || !bytes.Equal(m, magic) {
+		return nil, invalidFormat("invalid magic bytes")
+	}
+
+	p := &Packet{Unknowns: []*Unknown{}}
+	seen := make(map[string]bool)
+	err := readMap(r, func(k, v []byte) error {
+		if seen[string(k)] {
+			return invalidFormat("duplicate global key %x", k)
+		}
+		seen[string(k)] = true
+
+		if k[0] != globalUnsignedTx {
+			p.Unknowns = append(p.Unknowns, &Unknown{Key: k, Value: v})
+			return nil
+		}
+		if len(k) != 1 {
+			return invalidFormat("invalid unsigned tx key %x", k)
+		}
+		tx := wire.NewMsgTx(wire.TxVersion)
+		if e := tx.DeserializeNoWitness(bytes.NewReader(v)); e != nil {
+			return invalidFormat("invalid unsigned tx. %v", e)
+		}
+		if e := checkUnsigned(tx); e != nil {
+			return e
+		}
+		p.UnsignedTx = tx
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.UnsignedTx == nil {
+		return nil, invalidFormat("missing unsigned tx")
+	}
+
+	p.Inputs = make([]PInput, len(p.UnsignedTx.TxIn))
+	for i := range p.Inputs {
+		if err = p.Inputs[i].parse(r); err != nil {
+			return nil, err
+		}
+	}
+	p.Outputs = make([]POutput, len(p.UnsignedTx.TxOut))
+	for i := range p.Outputs {
+		if err = p.Outputs[i].parse(r); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (pi *PInput) parse(r io.Reader) error {
+	seen := make(map[string]bool)
+	return readMap(r, func(k, v []byte) error {
+		if seen[string(k)] {
+			return invalidFormat("duplicate input key %x", k)
+		}
+		seen[string(k)] = true
+
+		switch k[0] {
+		case inNonWitnessUtxo:
+			if len(k) != 1 {
+				return invalidFormat("invalid non-witness utxo key %x", k)
+			}
+			tx := wire.NewMsgTx(wire.TxVersion)
+			if e := tx.Deserialize(bytes.NewReader(v)); e != nil {
+				return invalidFormat("invalid non-witness utxo. %v", e)
+			}
+			pi.NonWitnessUtxo = tx
+		case inWitnessUtxo:
+			if len(k) != 1 {
+				return invalidFormat("invalid witness utxo key %x", k)
+			}
+			txout, e := readTxOut(v)
+			if e != nil {
+				return e
+			}
+			pi.WitnessUtxo = txout
+		case inPartialSig:
+			pub, e := keyPubKey(k)
+			if e != nil {
+				return e
+			}
+			pi.PartialSigs = append(pi.PartialSigs, &PartialSig{PubKey: pub, Signature: v})
+		case inSighashType:
+			if len(k) != 1 || len(v) != 4 {
+				return invalidFormat("invalid sighash type key %x", k)
+			}
+			pi.SighashType = txscript.SigHashType(binary.LittleEndian.Uint32(v))
+		case inRedeemScript:
+			if len(k) != 1 {
+				return invalidFormat("invalid redeem script key %x", k)
+			}
+			pi.RedeemScript = v
+		case inWitnessScript:
+			if len(k) != 1 {
+				return invalidFormat("invalid witness script key %x", k)
+			}
+			pi.WitnessScript = v
+		case inBip32Derivation:
+			d, e := readBip32Derivation(k, v)
+			if e != nil {
+				return e
+			}
+			pi.Bip32Derivation = append(pi.Bip32Derivation, d)
+		case inFinalScriptSig:
+			if len(k) != 1 {
+				return invalidFormat("invalid final script sig key %x", k)
+			}
+			pi.FinalScriptSig = v
+		case inFinalScriptWitness:
+			if len(k) != 1 {
+				return invalidFormat("invalid final script witness key %x", k)
+			}
+			pi.FinalScriptWitness = v
+		default:
+			pi.Unknowns = append(pi.Unknowns, &Unknown{Key: k, Value: v})
+		}
+		return nil
+	})
+}
+
+func (po *POutput) parse(r io.Reader) error {
+	seen := make(map[string]bool)
+	return readMap(r, func(k, v []byte) error {
+		if seen[string(k)] {
+			return invalidFormat("duplicate output key %x", k)
+		}
+		seen[string(k)] = true
+
+		switch k[0] {
+		case outRedeemScript:
+			if len(k) != 1 {
+				return invalidFormat("invalid redeem script key %x", k)
+			}
+			po.RedeemScript = v
+		case outWitnessScript:
+			if len(k) != 1 {
+				return invalidFormat("invalid witness script key %x", k)
+			}
+			po.WitnessScript = v
+		case outBip32Derivation:
+			d, e := readBip32Derivation(k, v)
+			if e != nil {
+				return e
+			}
+			po.Bip32Derivation = append(po.Bip32Derivation, d)
+		default:
+			po.Unknowns = append(po.Unknowns, &Unknown{Key: k, Value: v})
+		}
+		return nil
+	})
+}
+
+// readMap reads key-value pairs until a separator
+func readMap(r io.Reader, fn func(k, v []byte) error) error {
+	for {
+		k, err := wire.ReadVarBytes(r, 0, maxPsbtSize, "key")
+		if err != nil {
+			return invalidFormat("failed to read key. %v", err)
+		}
+		if len(k) == 0 {
+			return nil
+		}
+		v, err := wire.ReadVarBytes(r, 0, maxPsbtSize, "value")
+		if err != nil {
+			return invalidFormat("failed to read value. %v", err)
+		}
+		if err = fn(k, v); err != nil {
+			return err
+		}
+	}
+}
+
+func readTxOut(v []byte) (*wire.TxOut, error) {
+	if len(v) < 9 {
+		return nil, invalidFormat("invalid witness utxo")
+	}
+	r := bytes.NewReader(v[8:])
+	sc, err := wire.ReadVarBytes(r, 0, maxPsbtSize, "pkScript")
+	if err != nil || r.Len() != 0 {
+		return nil, invalidFormat("invalid witness utxo")
+	}
+	amt := int64(binary.LittleEndian.Uint64(v[:8]))
+	return wire.NewTxOut(amt, sc), nil
+}
+
+// keyPubKey returns a pubkey in a key of a partial sig or a derivation
+func keyPubKey(k []byte) ([]byte, error) {
+	pub := k[1:]
+	if len(pub) != btcec.PubKeyBytesLenCompressed &&
+		len(pub) != btcec.PubKeyBytesLenUncompressed {
+		return nil, invalidFormat("invalid pubkey in key %x", k)
+	}
+	if _, err := btcec.ParsePubKey(pub, btcec.S256()); err != nil {
+		return nil, invalidFormat("invalid pubkey in key %x", k)
+	}
+	return pub, nil
+}
+
+func readBip32Derivation(k, v []byte) (*Bip32Derivation, error) {
+	pub, err := keyPubKey(k)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) < 4 || len(v)%4 != 0 {
+		return nil, invalidFormat("invalid bip32 derivation of %x", pub)
+	}
+	d := &Bip32Derivation{
+		PubKey:               pub,
+		MasterKeyFingerprint: binary.LittleEndian.Uint32(v[:4]),
+	}
+	for i := 4; i < len(v); i += 4 {
+		d.Bip32Path = append(d.Bip32Path, binary.LittleEndian.Uint32(v[i:i+4]))
+	}
+	return d, nil
+}
+
+// Serialize writes a PSBT in binary
+func (p *Packet) Serialize(w io.Writer) error {
+	if len(p.Inputs) != len(p.UnsignedTx.TxIn) ||
+		len(p.Outputs) != len(p.UnsignedTx.TxOut) {
+		return errors.New("number of inputs or outputs doesn't match the tx")
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(magic)
+
+	txbuf := &bytes.Buffer{}
+	if err := p.UnsignedTx.SerializeNoWitness(txbuf); err != nil {
+		return err
+	}
+	writeKV(buf, []byte{globalUnsignedTx}, txbuf.Bytes())
+	writeUnknowns(buf, p.Unknowns)
+	buf.WriteByte(0x00)
+
+	for i := range p.Inputs {
+		if err := p.Inputs[i].serialize(buf); err != nil {
+			return err
+		}
+	}
+	for i := range p.Outputs {
+		p.Outputs[i].serialize(buf)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// B64Encode returns a PSBT in base64
+func (p *Packet) B64Encode() (string, error) {
+	buf := &bytes.Buffer{}
+	if err := p.Serialize(buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (pi *PInput) serialize(buf *bytes.Buffer) error {
+	if pi.NonWitnessUtxo != nil {
+		txbuf := &bytes.Buffer{}
+		if err := pi.NonWitnessUtxo.Serialize(txbuf); err != nil {
+			return err
+		}
+		writeKV(buf, []byte{inNonWitnessUtxo}, txbuf.Bytes())
+	}
+	if pi.WitnessUtxo != nil {
+		txoutbuf := &bytes.Buffer{}
+		if err := wire.WriteTxOut(txoutbuf, 0, 0, pi.WitnessUtxo); err != nil {
+			return err
+		}
+		writeKV(buf, []byte{inWitnessUtxo}, txoutbuf.Bytes())
+	}
+	for _, s := range pi.PartialSigs {
+		writeKV(buf, append([]byte{inPartialSig}, s.PubKey...), s.Signature)
+	}
+	if pi.SighashType != 0 {
+		v := make([]byte, 4)
+		binary.LittleEndian.PutUint32(v, uint32(pi.SighashType))
+		writeKV(buf, []byte{inSighashType}, v)
+	}
+	if pi.RedeemScript != nil {
+		writeKV(buf, []byte{inRedeemScript}, pi.RedeemScript)
+	}
+	if pi.WitnessScript != nil {
+		writeKV(buf, []byte{inWitnessScript}, pi.WitnessScript)
+	}
+	writeBip32Derivations(buf, inBip32Derivation, pi.Bip32Derivation)
+	if pi.FinalScriptSig != nil {
+		writeKV(buf, []byte{inFinalScriptSig}, pi.FinalScriptSig)
+	}
+	if pi.FinalScriptWitness != nil {
+		writeKV(buf, []byte{inFinalScriptWitness}, pi.FinalScriptWitness)
+	}
+	writeUnknowns(buf, pi.Unknowns)
+	buf.WriteByte(0x00)
+	return nil
+}
+
+func (po *POutput) serialize(buf *bytes.Buffer) {
+	if po.RedeemScript != nil {
+		writeKV(buf, []byte{outRedeemScript}, po.RedeemScript)
+	}
+	if po.WitnessScript != nil {
+		writeKV(buf, []byte{outWitnessScript}, po.WitnessScript)
+	}
+	writeBip32Derivations(buf, outBip32Derivation, po.Bip32Derivation)
+	writeUnknowns(buf, po.Unknowns)
+	buf.WriteByte(0x00)
+}
+
+func writeKV(buf *bytes.Buffer, k, v []byte) {
+	// writes to bytes.Buffer never fail
+	_ = wire.WriteVarBytes(buf, 0, k)
+	_ = wire.WriteVarBytes(buf, 0, v)
+}
+
+func writeBip32Derivations(buf *bytes.Buffer, keyType byte, ds []*Bip32Derivation) {
+	for _, d := range ds {
+		v := make([]byte, 4+4*len(d.Bip32Path))
+		binary.LittleEndian.PutUint32(v, d.MasterKeyFingerprint)
+		for i, idx := range d.Bip32Path {
+			binary.LittleEndian.PutUint32(v[4+4*i:], idx)
+		}
+		writeKV(buf, append([]byte{keyType}, d.PubKey...), v)
+	}
+}
+
+func writeUnknowns(buf *bytes.Buffer, us []*Unknown) {
+	for _, u := range us {
+		writeKV(buf, u.Key, u.Value)
+	}
+}
@@ -0,0 +1,77 @@
+package psbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// PartialSigOf returns a partial signature of a given pubkey
+func (pi *PInput) PartialSigOf(pub []byte) ([]byte, bool) {
+	for _, s := range pi.PartialSigs {
+		if bytes.Equal(s.PubKey, pub) {
+			return s.Signature, true
+		}
+	}
+	return nil, false
+}
+
+// AddPartialSig adds a partial signature of a pubkey.
+// It fails if the pubkey already has a different signature.
+func (pi *PInput) AddPartialSig(pub, sig []byte) error {
+	if s, ok := pi.PartialSigOf(pub); ok {
+		if bytes.Equal(s, sig) {
+			return nil
+		}
+		return fmt.Errorf("conflicting partial signatures of %x", pub)
+	}
+	pi.PartialSigs = append(pi.PartialSigs, &PartialSig{PubKey: pub, Signature: sig})
+	return nil
+}
+
+// sighashType returns the sighash type to sign with
+func (pi *PInput) sighashType() txscript.SigHashType {
+	if pi.SighashType == 0 {
+		return txscript.SigHashAll
+	}
+	return pi.SighashType
+}
+
+// scriptCode returns a script to calculate witness sighash,
+// which is the witness script of P2WSH or the pkScript of P2WPKH
+func (pi *PInput) scriptCode() ([]byte, error) {
+	if pi.WitnessUtxo == nil {
+		return nil, errors.New("missing witness utxo")
+	}
+	if pi.WitnessScript != nil {
+		return pi.WitnessScript, nil
+	}
+	if !txscript.IsPayToWitnessPubKeyHash(pi.WitnessUtxo.PkScript) {
+		return nil, errors.New("missing witness script")
+	}
+	return pi.WitnessUtxo.PkScript, nil
+}
+
+// SignWitnessInput adds a partial signature of a segwit v0 input
+// by a given privkey
+func (p *Packet) SignWitnessInput(idx int, priv *btcec.PrivateKey) error {
+	if idx < 0 || idx >= len(p.Inputs) {
+		return fmt.Errorf("input index out of range. %d", idx)
+	}
+	pi := &p.Inputs[idx]
+	sc, err := pi.scriptCode()
+	if err != nil {
+		return err
+	}
+
+	sig, err := txscript.RawTxInWitnessSignature(
+		p.UnsignedTx, txscript.NewTxSigHashes(p.UnsignedTx), idx,
+		pi.WitnessUtxo.Value, sc, pi.sighashType(), priv)
+	if err != nil {
+		return err
+	}
+	return pi.AddPartialSig(priv.PubKey().SerializeCompressed(), sig)
+}
@@ -0,0 +1,144 @@
+package schnorr
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// BIP340 schnorr signatures used in taproot
+// https://github.com/bitcoin/bips/blob/master/bip-0340.mediawiki
+
+// TaggedHash calculates hash_tag(x) = SHA256(SHA256(tag) || SHA256(tag) || x)
+func TaggedHash(tag string, msgs ...[]byte) []byte {
+	th := sha256.Sum256([]byte(tag))
+	s := sha256.New()
+	s.Write(th[:])
+	s.Write(th[:])
+	for _, m := range msgs {
+		s.Write(m)
+	}
+	return s.Sum(nil)
+}
+
+// XOnly serializes a public key to 32 bytes x coordinate
+func XOnly(pub *btcec.PublicKey) []byte {
+	return Bytes32(pub.X)
+}
+
+// ParseXOnlyPubKey parses 32 bytes x coordinate
+// and returns a public key whose y coordinate is even
+func ParseXOnlyPubKey(x []byte) (*btcec.PublicKey, error) {
+	if len(x) != 32 {
+		return nil, errors.New("x-only public key must be 32 bytes")
+	}
+	return btcec.ParsePubKey(append([]byte{0x02}, x...), btcec.S256())
+}
+
+// HasEvenY returns true if y coordinate of a point is even
+func HasEvenY(pub *btcec.PublicKey) bool {
+	return pub.Y.Bit(0) == 0
+}
+
+// Bytes32 serializes an integer to 32 bytes in big endian
+func Bytes32(n *big.Int) []byte {
+	b := make([]byte, 32)
+	nb := n.Bytes()
+	copy(b[32-len(nb):], nb)
+	return b
+}
+
+// SignBIP340 creates a BIP340 signature of a message with auxiliary random data
+func SignBIP340(priv *btcec.PrivateKey, msg, aux []byte) ([]byte, error) {
+	curve := btcec.S256()
+	if len(aux) != 32 {
+		return nil, errors.New("auxiliary random data must be 32 bytes")
+	}
+
+	d := new(big.Int).Set(priv.D)
+	if d.Sign() == 0 || d.Cmp(curve.N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	P := priv.PubKey()
+	if !HasEvenY(P) {
+		d = new(big.Int).Sub(curve.N, d)
+	}
+
+	// t = bytes(d) xor hash_BIP0340/aux(a)
+	t := Bytes32(d)
+	auxHash := TaggedHash("BIP0340/aux", aux)
+	for i := range t {
+		t[i] ^= auxHash[i]
+	}
+
+	// k' = int(hash_BIP0340/nonce(t || bytes(P) || m)) mod n
+	rand := TaggedHash("BIP0340/nonce", t, XOnly(P), msg)
+	k := new(big.Int).Mod(new(big.Int).SetBytes(rand), curve.N)
+	if k.Sign() == 0 {
+		return nil, errors.New("invalid nonce")
+	}
+	R := new(btcec.PublicKey)
+	R.Curve = curve
+	R.X, R.Y = curve.ScalarBaseMult(Bytes32(k))
+	if !HasEvenY(R) {
+		k = new(big.Int).Sub(curve.N, k)
+	}
+
+	// sig = bytes(R) || bytes((k + ed) mod n)
+	e := challenge(XOnly(R), XOnly(P), msg)
+	s := new(big.Int).Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, curve.N)
+
+	sig := append(XOnly(R), Bytes32(s)...)
+	if !VerifyBIP340(XOnly(P), msg, sig) {
+		return nil, errors.New("failed to create a valid signature")
+	}
+	return sig, nil
+}
+
+// VerifyBIP340 verifies a BIP340 signature for a x-only public key
+func VerifyBIP340(pubx, msg, sig []byte) bool {
+	curve := btcec.S256()
+	if len(sig) != 64 {
+		return false
+	}
+
+	P, err := ParseXOnlyPubKey(pubx)
+	if err != nil {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	if r.Cmp(curve.P) >= 0 {
+		return false
+	}
+	s := new(big.Int).SetBytes(sig[32:])
+	if s.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	// R = sG - eP
+	e := challenge(sig[:32], pubx, msg)
+	negE := new(big.Int).Sub(curve.N, e)
+	sGx, sGy := curve.ScalarBaseMult(Bytes32(s))
+	ePx, ePy := curve.ScalarMult(P.X, P.Y, Bytes32(negE))
+	Rx, Ry := curve.Add(sGx, sGy, ePx, ePy)
+
+	if Rx.Sign() == 0 && Ry.Sign() == 0 {
+		return false
+	}
+	if Ry.Bit(0) != 0 {
+		return false
+	}
+	return Rx.Cmp(r) == 0
+}
+
+// challenge calculates int(hash_BIP0340/challenge(R || P || m)) mod n
+func challenge(R, P, msg []byte) *big.Int {
+	h := TaggedHash("BIP0340/challenge", R, P, msg)
+	e := new(big.Int).SetBytes(h)
+	return e.Mod(e, btcec.S256().N)
+}
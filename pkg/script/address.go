@@ -0,0 +1,191 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+// UnsupportedAddressError is raised when an address type isn't supported
+type UnsupportedAddressError struct{ error }
+
+// AddressTaproot is a pay-to-taproot address (BIP341).
+// btcutil doesn't support witness v1 addresses encoded in bech32m (BIP350).
+type AddressTaproot struct {
+	hrp       string
+	outputKey [32]byte
+}
+
+// NewAddressTaproot returns a taproot address for a given x-only output key
+func NewAddressTaproot(
+	outputKey []byte, net *chaincfg.Params) (*AddressTaproot, error) {
+	return newAddressTaproot(net.Bech32HRPSegwit, outputKey)
+}
+
+func newAddressTaproot(hrp string, outputKey []byte) (*AddressTaproot, error) {
+	if len(outputKey) != 32 {
+		return nil, errors.New("taproot output key must be 32 bytes")
+	}
+	addr := &AddressTaproot{hrp: strings.ToLower(hrp)}
+	copy(addr.outputKey[:], outputKey)
+	return addr, nil
+}
+
+// EncodeAddress returns the address in bech32m format
+func (a *AddressTaproot) EncodeAddress() string {
+	prog, _ := bech32.ConvertBits(a.outputKey[:], 8, 5, true)
+	data := append([]byte{1}, prog...)
+	return bech32mEncode(a.hrp, data)
+}
+
+// ScriptAddress returns the x-only output key
+func (a *AddressTaproot) ScriptAddress() []byte {
+	return a.outputKey[:]
+}
+
+// IsForNet returns true if the address is for a given network
+func (a *AddressTaproot) IsForNet(net *chaincfg.Params) bool {
+	return a.hrp == net.Bech32HRPSegwit
+}
+
+// String returns the address in bech32m format
+func (a *AddressTaproot) String() string {
+	return a.EncodeAddress()
+}
+
+// DecodeAddress decodes an address string.
+// It supports taproot addresses in addition to btcutil.DecodeAddress.
+func DecodeAddress(addr string, net *chaincfg.Params) (btcutil.Address, error) {
+	a, err := btcutil.DecodeAddress(addr, net)
+	if err == nil {
+		return a, nil
+	}
+
+	hrp, data, terr := bech32mDecode(addr)
+	if terr != nil {
+		return nil, err
+	}
+	if len(data) == 0 || data[0] != 1 {
+		return nil, err
+	}
+	if hrp != net.Bech32HRPSegwit {
+		return nil, errors.New("address is for another network")
+	}
+	prog, terr := bech32.ConvertBits(data[1:], 5, 8, false)
+	if terr != nil {
+		return nil, terr
+	}
+	if len(prog) != 32 {
+		msg := fmt.Sprintf(
+			"unsupported witness v1 program length %d", len(prog))
+		return nil, &UnsupportedAddressError{error: errors.New(msg)}
+	}
+	return newAddressTaproot(hrp, prog)
+}
+
+// PkScriptFromAddress creates a pkScript paying to a given address.
+// P2PKH, P2SH, P2WPKH, P2WSH and P2TR addresses are supported.
+func PkScriptFromAddress(addr btcutil.Address) ([]byte, error) {
+	switch a := addr.(type) {
+	case *btcutil.AddressPubKeyHash, *btcutil.AddressScriptHash,
+		*btcutil.AddressWitnessPubKeyHash, *btcutil.AddressWitnessScriptHash:
+		return txscript.PayToAddrScript(a)
+	case *AddressTaproot:
+		builder := txscript.NewScriptBuilder()
+		builder.AddOp(txscript.OP_1)
+		builder.AddData(a.ScriptAddress())
+		return builder.Script()
+	}
+
+	msg := fmt.Sprintf("unsupported address type %T", addr)
+	return nil, &UnsupportedAddressError{error: errors.New(msg)}
+}
+
+// bech32m (BIP350)
+
+const bech32mCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+const bech32mConst = 0x2bc830a3
+const bech32mMaxLen = 90
+
+func bech32mPolymod(values []byte) uint32 {
+	gen := []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32mHrpExpand(hrp string) []byte {
+	v := []byte{}
+	for _, c := range []byte(hrp) {
+		v = append(v, c>>5)
+	}
+	v = append(v, 0)
+	for _, c := range []byte(hrp) {
+		v = append(v, c&31)
+	}
+	return v
+}
+
+func bech32mEncode(hrp string, data []byte) string {
+	values := append(bech32mHrpExpand(hrp), data...)
+	polymod := bech32mPolymod(append(values, 0, 0, 0, 0, 0, 0)) ^ bech32mConst
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range data {
+		sb.WriteByte(bech32mCharset[d])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32mCharset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return sb.String()
+}
+
+func bech32mDecode(s string) (string, []byte, error) {
+	if len(s) > bech32mMaxLen {
+		return "", nil, errors.New("bech32m string is too long")
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("mixed case in bech32m string")
+	}
+	s = strings.ToLower(s)
+
+	one := strings.LastIndexByte(s, '1')
+	if one < 1 || one+7 > len(s) {
+		return "", nil, errors.New("invalid bech32m string")
+	}
+
+	hrp := s[:one]
+	for _, c := range []byte(hrp) {
+		if c < 33 || c > 126 {
+			return "", nil, errors.New("invalid character in bech32m hrp")
+		}
+	}
+	data := []byte{}
+	for _, c := range s[one+1:] {
+		d := strings.IndexRune(bech32mCharset, c)
+		if d < 0 {
+			return "", nil, errors.New("invalid character in bech32m string")
+		}
+		data = append(data, byte(d))
+	}
+
+	if bech32mPolymod(append(bech32mHrpExpand(hrp), data...)) != bech32mConst {
+		return "", nil, errors.New("invalid bech32m checksum")
+	}
+	return hrp, data[:len(data)-6], nil
+}
@@ -0,0 +1,199 @@
+package script
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// test vector from BIP350
+const testTaprootAddr = "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"
+const testTaprootPkScript = "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+
+func TestDecodeTaprootAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	addr, err := DecodeAddress(testTaprootAddr, &chaincfg.MainNetParams)
+	if !assert.NoError(err) {
+		assert.FailNow(err.Error())
+	}
+	assert.IsType(&AddressTaproot{}, addr)
+	assert.Equal(testTaprootAddr, addr.EncodeAddress())
+	assert.True(addr.IsForNet(&chaincfg.MainNetParams))
+	assert.False(addr.IsForNet(&chaincfg.RegressionNetParams))
+
+	sc, err := PkScriptFromAddress(addr)
+	assert.NoError(err)
+	assert.Equal(testTaprootPkScript, hex.EncodeToString(sc))
+
+	// invalid checksum
+	invalid := testTaprootAddr[:len(testTaprootAddr)-1] + "1"
+	_, err = DecodeAddress(invalid, &chaincfg.MainNetParams)
+	assert.Error(err)
+}
+
+func TestNewAddressTaproot(t *testing.T) {
+	assert := assert.New(t)
+
+	_, pub := test.RandKeys()
+	net := &chaincfg.RegressionNetParams
+	addr, err := NewAddressTaproot(pub.SerializeCompressed()[1:], net)
+	assert.NoError(err)
+
+	decoded, err := DecodeAddress(addr.EncodeAddress(), net)
+	assert.NoError(err)
+	assert.Equal(addr, decoded)
+
+	_, err = NewAddressTaproot([]byte{1}, net)
+	assert.Error(err)
+}
+
+func TestPkScriptFromAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	net := &chaincfg.RegressionNetParams
+	_, pub := test.RandKeys()
+	hash := btcutil.Hash160(pub.SerializeCompressed())
+
+	p2pkh, _ := btcutil.NewAddressPubKeyHash(hash, net)
+	p2sh, _ := btcutil.NewAddressScriptHashFromHash(hash, net)
+	p2wpkh, _ := btcutil.NewAddressWitnessPubKeyHash(hash, net)
+	p2wsh, _ := btcutil.NewAddressWitnessScriptHash(make([]byte, 32), net)
+
+	for _, addr := range []btcutil.Address{p2pkh, p2sh, p2wpkh, p2wsh} {
+		sc, err := PkScriptFromAddress(addr)
+		assert.NoError(err)
+		expected, _ := txscript.PayToAddrScript(addr)
+		assert.Equal(expected, sc)
+	}
+
+	// p2pk isn't supported
+	p2pk, _ := btcutil.NewAddressPubKey(pub.SerializeCompressed(), net)
+	_, err := PkScriptFromAddress(p2pk)
+	assert.IsType(&UnsupportedAddressError{}, err)
+
+	// p2wpkh script can't be created from the other types
+	_, err = P2WPKHpkScriptFromAddress(p2pkh)
+	assert.IsType(&UnsupportedAddressError{}, err)
+}
+
+// test vectors from BIP350
+var bip350ValidBech32m = []string{
+	"A1LQFN3A",
+	"a1lqfn3a",
+	"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6",
+	"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+	"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8",
+	"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+	"?1v759aa",
+}
+
+var bip350InvalidBech32m = []string{
+	"\x201xj0phk",
+	"\x7f1g6xzxy",
+	"\x801vctc34",
+	"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4",
+	"qyrz8wqd2c9m",
+	"1qyrz8wqd2c9m",
+	"y1b0jsk6g",
+	"lt1igcx5c0",
+	"in1muywd",
+	"mm1crxm3i",
+	"au1s5cgom",
+	"M1VUXWEZ",
+	"16plkw9",
+	"1p2gdwpf",
+}
+
+func TestBech32mBIP350(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, s := range bip350ValidBech32m {
+		_, _, err := bech32mDecode(s)
+		assert.NoError(err, s)
+	}
+	for _, s := range bip350InvalidBech32m {
+		_, _, err := bech32mDecode(s)
+		assert.Error(err, s)
+	}
+}
+
+func TestDecodeAddressBIP350(t *testing.T) {
+	assert := assert.New(t)
+
+	mainnet := &chaincfg.MainNetParams
+	testnet := &chaincfg.TestNet3Params
+
+	valid := []struct {
+		addr     string
+		net      *chaincfg.Params
+		pkScript string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", mainnet,
+			"0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", testnet,
+			"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", testnet,
+			"0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", testnet,
+			"5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{testTaprootAddr, mainnet, testTaprootPkScript},
+	}
+	for _, v := range valid {
+		addr, err := DecodeAddress(v.addr, v.net)
+		if !assert.NoError(err, v.addr) {
+			continue
+		}
+		sc, err := PkScriptFromAddress(addr)
+		assert.NoError(err)
+		assert.Equal(v.pkScript, hex.EncodeToString(sc), v.addr)
+	}
+
+	// valid bech32m addresses of the other witness versions and lengths
+	unsupported := []string{
+		"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y",
+		"BC1SW50QGDZ25J",
+		"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs",
+	}
+	for _, addr := range unsupported {
+		_, _, err := bech32mDecode(addr)
+		assert.NoError(err, addr)
+		_, err = DecodeAddress(addr, mainnet)
+		assert.Error(err, addr)
+	}
+
+	invalid := []string{
+		"tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd",
+		"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf",
+		"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",
+		"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47",
+		"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4",
+		"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R",
+		"bc1pw5dgrnzv",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav",
+		"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P",
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf",
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j",
+		"bc1gmk9yu",
+	}
+	for _, addr := range invalid {
+		for _, net := range []*chaincfg.Params{mainnet, testnet} {
+			_, err := DecodeAddress(addr, net)
+			assert.Error(err, addr)
+		}
+	}
+
+	// mainnet taproot address isn't accepted on the other networks
+	for _, net := range []*chaincfg.Params{testnet, &chaincfg.RegressionNetParams} {
+		_, err := DecodeAddress(testTaprootAddr, net)
+		assert.Error(err)
+	}
+}
@@ -0,0 +1,103 @@
+package script
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BufferDelay is a default delay used in BufferScript
+const BufferDelay = 144
+
+// BufferScript returns a script of buffer tx output in DLC channel.
+// Each party has its own buffer tx whose revocation pubkey is derived
+// from the counterparty's pubkey and a revocation point of the state.
+//
+// Script Code:
+//  OP_IF
+//    <revocation public key>
+//    OP_CHECKSIG
+//  OP_ELSE
+//    delay
+//    OP_CHECKSEQUENCEVERIFY
+//    OP_DROP
+//    OP_2
+//      <public key first party>
+//      <public key second party>
+//    OP_2
+//    OP_CHECKMULTISIG
+//  OP_ENDIF
+//
+// The if block can be passed by the counterparty after the owner of buffer tx
+// revokes the state by revealing the secret of the revocation point.
+// The else block is used by CETs of the state after the delay.
+func BufferScript(
+	revpub, pub1, pub2 *btcec.PublicKey, delay uint16) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	builder.AddOp(txscript.OP_IF)
+	builder.AddData(revpub.SerializeCompressed())
+	builder.AddOp(txscript.OP_CHECKSIG)
+	builder.AddOp(txscript.OP_ELSE)
+	builder.AddInt64(int64(delay))
+	builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	builder.AddOp(txscript.OP_2)
+	builder.AddData(pub1.SerializeCompressed())
+	builder.AddData(pub2.SerializeCompressed())
+	builder.AddOp(txscript.OP_2)
+	builder.AddOp(txscript.OP_CHECKMULTISIG)
+	builder.AddOp(txscript.OP_ENDIF)
+	return builder.Script()
+}
+
+// RevocationPubkey returns a revocation pubkey of a given pubkey
+// and a revocation point.
+// Both keys are tweaked by hashes of them, so that the owner of the point
+// can't choose a point cancelling the pubkey:
+//  revpub = pub*sha256(pub||point) + point*sha256(point||pub)
+func RevocationPubkey(pub, point *btcec.PublicKey) *btcec.PublicKey {
+	curve := btcec.S256()
+	t1, t2 := revocationTweaks(pub, point)
+	x1, y1 := curve.ScalarMult(pub.X, pub.Y, t1)
+	x2, y2 := curve.ScalarMult(point.X, point.Y, t2)
+	revpub := &btcec.PublicKey{Curve: curve}
+	revpub.X, revpub.Y = curve.Add(x1, y1, x2, y2)
+	return revpub
+}
+
+// RevocationPrivkey returns the private key of a revocation pubkey
+// from the private key of the pubkey and the secret of the revocation point
+func RevocationPrivkey(
+	priv *btcec.PrivateKey, secret []byte) *btcec.PrivateKey {
+	curve := btcec.S256()
+	spriv, point := btcec.PrivKeyFromBytes(curve, secret)
+	t1, t2 := revocationTweaks(priv.PubKey(), point)
+	n := new(big.Int).Mul(priv.D, new(big.Int).SetBytes(t1))
+	n.Add(n, new(big.Int).Mul(spriv.D, new(big.Int).SetBytes(t2)))
+	n.Mod(n, curve.N)
+	revpriv, _ := btcec.PrivKeyFromBytes(curve, n.Bytes())
+	return revpriv
+}
+
+// revocationTweaks returns sha256(pub||point) and sha256(point||pub)
+func revocationTweaks(pub, point *btcec.PublicKey) ([]byte, []byte) {
+	p, r := pub.SerializeCompressed(), point.SerializeCompressed()
+	t1 := sha256.Sum256(append(append([]byte{}, p...), r...))
+	t2 := sha256.Sum256(append(append([]byte{}, r...), p...))
+	return t1[:], t2[:]
+}
+
+// WitnessForBufferScript constructs a witness that unlocks a buffer script
+// with signatures of both parties. This function use the OP_ELSE block
+func WitnessForBufferScript(sign1, sign2, sc []byte) wire.TxWitness {
+	return wire.TxWitness{[]byte{}, sign1, sign2, []byte{}, sc}
+}
+
+// WitnessForBufferScriptRevoked constructs a witness that unlocks a revoked buffer script.
+// This function use the OP_IF block
+func WitnessForBufferScriptRevoked(sign, sc []byte) wire.TxWitness {
+	return wire.TxWitness{sign, []byte{1}, sc}
+}
@@ -0,0 +1,85 @@
+package script
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferScript(t *testing.T) {
+	assert := assert.New(t)
+
+	priv1, pub1 := test.RandKeys()
+	priv2, pub2 := test.RandKeys()
+	privr, pubr := test.RandKeys() // revocation secret and point
+	amt := int64(10000)
+
+	// buffer tx of first party
+	revpub := RevocationPubkey(pub2, pubr)
+	script, err := BufferScript(revpub, pub1, pub2, BufferDelay)
+	assert.Nil(err)
+	pkScript, err := P2WSHpkScript(script)
+	assert.Nil(err)
+
+	sourceTx := test.NewSourceTx()
+	sourceTx.AddTxOut(wire.NewTxOut(amt, pkScript))
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+
+	// both parties can't unlock before the delay
+	sign1, _ := WitnessSignature(redeemTx, 0, amt, script, priv1)
+	sign2, _ := WitnessSignature(redeemTx, 0, amt, script, priv2)
+	redeemTx.TxIn[0].Witness = WitnessForBufferScript(sign1, sign2, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.NotNil(err)
+
+	// unlock with signatures of both parties after the delay
+	redeemTx.TxIn[0].Sequence = BufferDelay
+	sign1, _ = WitnessSignature(redeemTx, 0, amt, script, priv1)
+	sign2, _ = WitnessSignature(redeemTx, 0, amt, script, priv2)
+	redeemTx.TxIn[0].Witness = WitnessForBufferScript(sign1, sign2, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.Nil(err)
+
+	// second party can't unlock with own key before revocation
+	redeemTx.TxIn[0].Sequence = wire.MaxTxInSequenceNum
+	sign2, _ = WitnessSignature(redeemTx, 0, amt, script, priv2)
+	redeemTx.TxIn[0].Witness = WitnessForBufferScriptRevoked(sign2, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.NotNil(err)
+
+	// second party can unlock with the revocation secret
+	privrev := RevocationPrivkey(priv2, privr.Serialize())
+	signrev, err := WitnessSignature(redeemTx, 0, amt, script, privrev)
+	assert.Nil(err)
+	redeemTx.TxIn[0].Witness = WitnessForBufferScriptRevoked(signrev, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.Nil(err)
+}
+
+func TestRevocationPrivkey(t *testing.T) {
+	priv, pub := test.RandKeys()
+	privr, pubr := test.RandKeys()
+
+	revpriv := RevocationPrivkey(priv, privr.Serialize())
+	assert.True(t, revpriv.PubKey().IsEqual(RevocationPubkey(pub, pubr)))
+}
+
+// the owner of a revocation point shouldn't know the revocation private key
+// by choosing a point that cancels the counterparty's pubkey
+func TestRevocationPubkeyRoguePoint(t *testing.T) {
+	_, pub := test.RandKeys()
+	_, pubx := test.RandKeys() // key the owner of the point knows
+
+	// point = pubx - pub
+	curve := btcec.S256()
+	negy := new(big.Int).Sub(curve.P, pub.Y)
+	point := &btcec.PublicKey{Curve: curve}
+	point.X, point.Y = curve.Add(pubx.X, pubx.Y, pub.X, negy)
+
+	revpub := RevocationPubkey(pub, point)
+	assert.False(t, revpub.IsEqual(pubx))
+}
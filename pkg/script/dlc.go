@@ -1,6 +1,9 @@
 package script
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
@@ -15,18 +18,56 @@ import (
 //  OP_2
 //  OP_CHECKMULTISIG
 func FundScript(pub1, pub2 *btcec.PublicKey) (script []byte, err error) {
+	return MultiPartyFundScript([]*btcec.PublicKey{pub1, pub2})
+}
+
+// MultiPartyFundScript is a n-of-n multisig script for n contractors
+//
+// ScriptCode:
+//  OP_n
+//    <public key party 1>
+//    ...
+//    <public key party n>
+//  OP_n
+//  OP_CHECKMULTISIG
+func MultiPartyFundScript(pubs []*btcec.PublicKey) (script []byte, err error) {
+	builder, err := multiSigScriptBuilder(pubs)
+	if err != nil {
+		return nil, err
+	}
+	return builder.Script()
+}
+
+// multiSigScriptBuilder adds n-of-n CHECKMULTISIG ops for given pubkeys
+func multiSigScriptBuilder(
+	pubs []*btcec.PublicKey) (*txscript.ScriptBuilder, error) {
+	n := len(pubs)
+	if n == 0 || n > txscript.MaxPubKeysPerMultiSig {
+		msg := fmt.Sprintf("invalid number of pubkeys for multisig. %d", n)
+		return nil, errors.New(msg)
+	}
+
 	builder := txscript.NewScriptBuilder()
-	builder.AddOp(txscript.OP_2)
-	builder.AddData(pub1.SerializeCompressed())
-	builder.AddData(pub2.SerializeCompressed())
-	builder.AddOp(txscript.OP_2)
+	builder.AddInt64(int64(n))
+	for _, pub := range pubs {
+		builder.AddData(pub.SerializeCompressed())
+	}
+	builder.AddInt64(int64(n))
 	builder.AddOp(txscript.OP_CHECKMULTISIG)
-	return builder.Script()
+	return builder, nil
 }
 
 // WitnessForFundScript constructs a witness for fund script
 func WitnessForFundScript(sign1, sign2, sc []byte) wire.TxWitness {
-	return wire.TxWitness{[]byte{}, sign1, sign2, sc}
+	return WitnessForMultiPartyFundScript([][]byte{sign1, sign2}, sc)
+}
+
+// WitnessForMultiPartyFundScript constructs a witness for multi-party fund script.
+// Signatures must be in the same order as pubkeys in the script
+func WitnessForMultiPartyFundScript(signs [][]byte, sc []byte) wire.TxWitness {
+	wit := wire.TxWitness{[]byte{}}
+	wit = append(wit, signs...)
+	return append(wit, sc)
 }
 
 // ContractExecutionDelay is a delay used in ContractExecutionScript
@@ -84,3 +125,66 @@ func WitnessForCEScriptAfterDelay(
 	sign []byte, script []byte) wire.TxWitness {
 	return wire.TxWitness{sign, []byte{}, script}
 }
+
+// MultiPartyContractExecutionScript returns a contract execution script
+// for a contract with more than two parties.
+// The else block requires signatures of all the other parties after the delay.
+// With only one other party, it's same as ContractExecutionScript.
+//
+// Script Code:
+//  OP_IF
+//    <public key a + message public key>
+//    OP_CHECKSIG
+//  OP_ELSE
+//    delay(fix 144)
+//    OP_CHECKSEQUENCEVERIFY
+//    OP_DROP
+//    OP_m
+//      <public key of other party 1>
+//      ...
+//      <public key of other party m>
+//    OP_m
+//    OP_CHECKMULTISIG
+//  OP_ENDIF
+func MultiPartyContractExecutionScript(
+	puba, pubm *btcec.PublicKey, pubs []*btcec.PublicKey) ([]byte, error) {
+	if len(pubs) == 1 {
+		return ContractExecutionScript(puba, pubs[0], pubm)
+	}
+
+	// pub key a + message pub key
+	pubam := &btcec.PublicKey{}
+	pubam.X, pubam.Y = btcec.S256().Add(puba.X, puba.Y, pubm.X, pubm.Y)
+
+	builder, err := multiSigScriptBuilder(pubs)
+	if err != nil {
+		return nil, err
+	}
+	multisig, err := builder.Script()
+	if err != nil {
+		return nil, err
+	}
+
+	delay := uint16(ContractExecutionDelay)
+	builder = txscript.NewScriptBuilder()
+	builder.AddOp(txscript.OP_IF)
+	builder.AddData(pubam.SerializeCompressed())
+	builder.AddOp(txscript.OP_CHECKSIG)
+	builder.AddOp(txscript.OP_ELSE)
+	builder.AddInt64(int64(delay))
+	builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	builder.AddOps(multisig)
+	builder.AddOp(txscript.OP_ENDIF)
+	return builder.Script()
+}
+
+// WitnessForMultiPartyCEScriptAfterDelay constructs a witness that unlocks
+// a multi-party contract execution script with signatures of the other parties.
+// This function use the OP_ELSE block that can be valid after the delay
+func WitnessForMultiPartyCEScriptAfterDelay(
+	signs [][]byte, script []byte) wire.TxWitness {
+	wit := wire.TxWitness{[]byte{}}
+	wit = append(wit, signs...)
+	return append(wit, []byte{}, script)
+}
@@ -48,3 +48,94 @@ func TestCEScript(t *testing.T) {
 	err = test.ExecuteScript(pkScript, redeemTx, amt)
 	assert.Nil(err)
 }
+
+func TestMultiPartyFundScript(t *testing.T) {
+	assert := assert.New(t)
+
+	n := 3
+	privs := []*btcec.PrivateKey{}
+	pubs := []*btcec.PublicKey{}
+	for i := 0; i < n; i++ {
+		priv, pub := test.RandKeys()
+		privs = append(privs, priv)
+		pubs = append(pubs, pub)
+	}
+	amt := int64(10000)
+
+	script, err := MultiPartyFundScript(pubs)
+	assert.Nil(err)
+	pkScript, err := P2WSHpkScript(script)
+	assert.Nil(err)
+
+	sourceTx := test.NewSourceTx()
+	sourceTx.AddTxOut(wire.NewTxOut(amt, pkScript))
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+
+	signs := [][]byte{}
+	for _, priv := range privs {
+		sign, _ := WitnessSignature(redeemTx, 0, amt, script, priv)
+		signs = append(signs, sign)
+	}
+
+	// fails without a signature of any party
+	redeemTx.TxIn[0].Witness = WitnessForMultiPartyFundScript(signs[:n-1], script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.NotNil(err)
+
+	// unlock with signatures of all parties
+	redeemTx.TxIn[0].Witness = WitnessForMultiPartyFundScript(signs, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.Nil(err)
+
+	// 2-of-2 is same as FundScript
+	script2, _ := MultiPartyFundScript(pubs[:2])
+	fs, _ := FundScript(pubs[0], pubs[1])
+	assert.Equal(fs, script2)
+}
+
+func TestMultiPartyCEScript(t *testing.T) {
+	assert := assert.New(t)
+
+	priva, puba := test.RandKeys()
+	privm, pubm := test.RandKeys()
+	privb, pubb := test.RandKeys()
+	privc, pubc := test.RandKeys()
+	amt := int64(10000)
+
+	script, err := MultiPartyContractExecutionScript(
+		puba, pubm, []*btcec.PublicKey{pubb, pubc})
+	assert.Nil(err)
+	pkScript, err := P2WSHpkScript(script)
+	assert.Nil(err)
+
+	sourceTx := test.NewSourceTx()
+	sourceTx.AddTxOut(wire.NewTxOut(amt, pkScript))
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+
+	// unlock with message sign
+	privam, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(),
+		utils.AddBigInts(priva.D, privm.D).Bytes())
+	signam, err := WitnessSignature(redeemTx, 0, amt, script, privam)
+	assert.Nil(err)
+	redeemTx.TxIn[0].Witness = WitnessForCEScript(signam, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.Nil(err)
+
+	// other parties can't unlock before the delay
+	signb, _ := WitnessSignature(redeemTx, 0, amt, script, privb)
+	signc, _ := WitnessSignature(redeemTx, 0, amt, script, privc)
+	redeemTx.TxIn[0].Witness = WitnessForMultiPartyCEScriptAfterDelay(
+		[][]byte{signb, signc}, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.NotNil(err)
+
+	// unlock with signatures of other parties after delay
+	redeemTx.TxIn[0].Sequence = ContractExecutionDelay
+	signb, _ = WitnessSignature(redeemTx, 0, amt, script, privb)
+	signc, _ = WitnessSignature(redeemTx, 0, amt, script, privc)
+	redeemTx.TxIn[0].Witness = WitnessForMultiPartyCEScriptAfterDelay(
+		[][]byte{signb, signc}, script)
+	err = test.ExecuteScript(pkScript, redeemTx, amt)
+	assert.Nil(err)
+}
@@ -0,0 +1,15 @@
+package script
+
+import "github.com/btcsuite/btcd/wire"
+
+// Sizes (vbytes) for fee estimation
+const (
+	P2WPKHTxInSize  = int64(68) // txin spending a p2wpkh output with its witness
+	P2WPKHTxOutSize = int64(31)
+)
+
+// TxOutSize returns size of a txout with a given pkScript
+// (value, script length and script)
+func TxOutSize(pkScript []byte) int64 {
+	return int64(8 + wire.VarIntSerializeSize(uint64(len(pkScript))) + len(pkScript))
+}
@@ -0,0 +1,25 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxOutSize(t *testing.T) {
+	assert := assert.New(t)
+
+	_, pub := test.RandKeys()
+	p2wpkh, _ := P2WPKHpkScript(pub)
+	assert.Equal(P2WPKHTxOutSize, TxOutSize(p2wpkh))
+
+	p2wsh, _ := P2WSHpkScript([]byte{1})
+	assert.Equal(int64(43), TxOutSize(p2wsh))
+
+	p2tr, _ := P2TRpkScript(pub)
+	assert.Equal(int64(43), TxOutSize(p2tr))
+
+	// script length over 252 bytes takes 3 bytes
+	assert.Equal(int64(8+3+253), TxOutSize(make([]byte, 253)))
+}
@@ -0,0 +1,178 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+)
+
+// taproot (BIP341, BIP342)
+// https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki
+
+// TapLeafVersion is a leaf version of tapscript
+const TapLeafVersion = 0xc0
+
+// sighash type SIGHASH_DEFAULT
+const sigHashDefault = 0x00
+
+// TapLeafHash calculates a leaf hash of a tapscript
+func TapLeafHash(sc []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(TapLeafVersion)
+	wire.WriteVarBytes(&buf, 0, sc)
+	return schnorr.TaggedHash("TapLeaf", buf.Bytes())
+}
+
+// TapTweak calculates a tweak of an internal key with a merkle root.
+// The merkle root of a single leaf tree is the leaf hash.
+func TapTweak(internal *btcec.PublicKey, root []byte) []byte {
+	return schnorr.TaggedHash("TapTweak", schnorr.XOnly(internal), root)
+}
+
+// TaprootOutputKey returns an output key tweaked with a merkle root
+func TaprootOutputKey(
+	internal *btcec.PublicKey, root []byte) (*btcec.PublicKey, error) {
+	curve := btcec.S256()
+
+	// lift internal key to even y
+	P, err := schnorr.ParseXOnlyPubKey(schnorr.XOnly(internal))
+	if err != nil {
+		return nil, err
+	}
+
+	t := TapTweak(internal, root)
+	Q := &btcec.PublicKey{Curve: curve}
+	tGx, tGy := curve.ScalarBaseMult(t)
+	Q.X, Q.Y = curve.Add(P.X, P.Y, tGx, tGy)
+	return Q, nil
+}
+
+// P2TRpkScript creates a taproot pkScript for given output key.
+//
+// ScriptCode:
+//  OP_1 + <x-only output key>
+func P2TRpkScript(outputKey *btcec.PublicKey) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	builder.AddOp(txscript.OP_1)
+	builder.AddData(schnorr.XOnly(outputKey))
+	return builder.Script()
+}
+
+// TaprootRefundScript returns a tapscript leaf that all parties can unlock
+// after a given locktime.
+//
+// Script Code:
+//  locktime
+//  OP_CHECKLOCKTIMEVERIFY
+//  OP_DROP
+//  <x-only public key first party>
+//  OP_CHECKSIGVERIFY
+//  ...
+//  <x-only public key last party>
+//  OP_CHECKSIG
+func TaprootRefundScript(
+	locktime uint32, pubs []*btcec.PublicKey) ([]byte, error) {
+	if len(pubs) == 0 {
+		return nil, errors.New("no public keys for refund script")
+	}
+
+	builder := txscript.NewScriptBuilder()
+	builder.AddInt64(int64(locktime))
+	builder.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	for i, pub := range pubs {
+		builder.AddData(schnorr.XOnly(pub))
+		if i < len(pubs)-1 {
+			builder.AddOp(txscript.OP_CHECKSIGVERIFY)
+		} else {
+			builder.AddOp(txscript.OP_CHECKSIG)
+		}
+	}
+	return builder.Script()
+}
+
+// ControlBlock returns a control block for a single leaf script tree
+func ControlBlock(internal, outputKey *btcec.PublicKey) []byte {
+	leaf := byte(TapLeafVersion)
+	if !schnorr.HasEvenY(outputKey) {
+		leaf |= 0x01
+	}
+	return append([]byte{leaf}, schnorr.XOnly(internal)...)
+}
+
+// TaprootSigHash calculates a signature hash of SIGHASH_DEFAULT
+// for a txin spending a taproot output.
+// prevOuts are the outputs spent by all txins in order.
+// leafHash is nil for the key path spending.
+func TaprootSigHash(
+	tx *wire.MsgTx, idx int, prevOuts []*wire.TxOut, leafHash []byte,
+) ([]byte, error) {
+	if idx >= len(tx.TxIn) {
+		return nil, errors.New("txin index out of range")
+	}
+	if len(prevOuts) != len(tx.TxIn) {
+		return nil, errors.New("number of prevouts doesn't match txins")
+	}
+
+	var prevouts, amts, pkScripts, seqs, outs bytes.Buffer
+	for i, txin := range tx.TxIn {
+		prevouts.Write(txin.PreviousOutPoint.Hash[:])
+		binary.Write(&prevouts, binary.LittleEndian, txin.PreviousOutPoint.Index)
+		binary.Write(&amts, binary.LittleEndian, prevOuts[i].Value)
+		wire.WriteVarBytes(&pkScripts, 0, prevOuts[i].PkScript)
+		binary.Write(&seqs, binary.LittleEndian, txin.Sequence)
+	}
+	for _, txout := range tx.TxOut {
+		binary.Write(&outs, binary.LittleEndian, txout.Value)
+		wire.WriteVarBytes(&outs, 0, txout.PkScript)
+	}
+
+	var msg bytes.Buffer
+	msg.WriteByte(0x00) // epoch
+	msg.WriteByte(sigHashDefault)
+	binary.Write(&msg, binary.LittleEndian, tx.Version)
+	binary.Write(&msg, binary.LittleEndian, tx.LockTime)
+	for _, b := range []bytes.Buffer{prevouts, amts, pkScripts, seqs, outs} {
+		h := sha256.Sum256(b.Bytes())
+		msg.Write(h[:])
+	}
+
+	// spend type: ext_flag * 2 + annex_present
+	if leafHash == nil {
+		msg.WriteByte(0x00)
+	} else {
+		msg.WriteByte(0x02)
+	}
+	binary.Write(&msg, binary.LittleEndian, uint32(idx))
+
+	if leafHash != nil {
+		msg.Write(leafHash)
+		msg.WriteByte(0x00) // key version
+		binary.Write(&msg, binary.LittleEndian, uint32(0xffffffff))
+	}
+
+	return schnorr.TaggedHash("TapSighash", msg.Bytes()), nil
+}
+
+// WitnessForTaprootKeySpend constructs a witness for the key path spending
+func WitnessForTaprootKeySpend(sig []byte) wire.TxWitness {
+	return wire.TxWitness{sig}
+}
+
+// WitnessForTaprootRefundScript constructs a witness that unlocks a refund script
+// with signatures of all parties in the same order as public keys in the script
+func WitnessForTaprootRefundScript(
+	signs [][]byte, sc, control []byte) wire.TxWitness {
+	wt := wire.TxWitness{}
+	// the first signature has to be on the top of the stack
+	for i := len(signs) - 1; i >= 0; i-- {
+		wt = append(wt, signs[i])
+	}
+	return append(wt, sc, control)
+}
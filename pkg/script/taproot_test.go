@@ -0,0 +1,237 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/p2pderivatives/dlc/internal/test"
+	"github.com/p2pderivatives/dlc/pkg/schnorr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaprootOutputKey(t *testing.T) {
+	assert := assert.New(t)
+
+	// test vector from BIP86 (m/86'/0'/0'/0/0)
+	internalx, _ := hex.DecodeString(
+		"cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115")
+	outputx, _ := hex.DecodeString(
+		"a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c")
+
+	internal, err := schnorr.ParseXOnlyPubKey(internalx)
+	assert.Nil(err)
+	output, err := TaprootOutputKey(internal, nil)
+	assert.Nil(err)
+	assert.Equal(outputx, schnorr.XOnly(output))
+
+	pkScript, err := P2TRpkScript(output)
+	assert.Nil(err)
+	assert.Equal(append([]byte{txscript.OP_1, txscript.OP_DATA_32}, outputx...), pkScript)
+}
+
+func TestTaprootKeySpend(t *testing.T) {
+	assert := assert.New(t)
+
+	priv, internal := test.RandKeys()
+	refund, err := TaprootRefundScript(100, []*btcec.PublicKey{internal})
+	assert.Nil(err)
+	leaf := TapLeafHash(refund)
+
+	output, err := TaprootOutputKey(internal, leaf)
+	assert.Nil(err)
+	pkScript, _ := P2TRpkScript(output)
+
+	tweaked := tweakPrivKey(priv, leaf)
+	assert.Equal(schnorr.XOnly(output), schnorr.XOnly(tweaked.PubKey()))
+
+	// prepare source tx and redeem tx
+	amt := int64(10000)
+	sourceTx := test.NewSourceTx()
+	sourceTx.AddTxOut(wire.NewTxOut(amt, pkScript))
+	redeemTx := test.NewRedeemTx(sourceTx, 0)
+	redeemTx.AddTxOut(wire.NewTxOut(amt-1000, pkScript))
+	prevOuts := []*wire.TxOut{sourceTx.TxOut[0]}
+
+	hash, err := TaprootSigHash(redeemTx, 0, prevOuts, nil)
+	assert.Nil(err)
+	sig, err := schnorr.SignBIP340(tweaked, hash, make([]byte, 32))
+	assert.Nil(err)
+	assert.True(schnorr.VerifyBIP340(schnorr.XOnly(output), hash, sig))
+	assert.Equal(wire.TxWitness{sig}, WitnessForTaprootKeySpend(sig))
+
+	// sighash commits to outputs
+	redeemTx.TxOut[0].Value--
+	hash2, _ := TaprootSigHash(redeemTx, 0, prevOuts, nil)
+	assert.NotEqual(hash, hash2)
+
+	// script path has a different sighash
+	hash3, _ := TaprootSigHash(redeemTx, 0, prevOuts, leaf)
+	assert.NotEqual(hash2, hash3)
+
+	// prevouts must match txins
+	_, err = TaprootSigHash(redeemTx, 0, nil, nil)
+	assert.NotNil(err)
+}
+
+// tweakPrivKey tweaks a private key of an internal key with a merkle root
+func tweakPrivKey(priv *btcec.PrivateKey, root []byte) *btcec.PrivateKey {
+	internal := priv.PubKey()
+	n := btcec.S256().N
+	d := new(big.Int).Set(priv.D)
+	if !schnorr.HasEvenY(internal) {
+		d.Sub(n, d)
+	}
+	d.Add(d, new(big.Int).SetBytes(TapTweak(internal, root)))
+	d.Mod(d, n)
+	tweaked, _ := btcec.PrivKeyFromBytes(btcec.S256(), schnorr.Bytes32(d))
+	return tweaked
+}
+
+// test vectors from BIP341 (wallet-test-vectors.json scriptPubKey)
+var bip341ScriptPubKeyVectors = []struct {
+	internal, leaf, leafHash, tweak, tweaked, control string
+}{
+	{
+		internal: "d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d",
+		tweak:    "b86e7be8f39bab32a6f2c0443abbc210f0edac0e2c53d501b36b64437d9c6c70",
+		tweaked:  "53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343",
+	},
+	{
+		internal: "187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+		leaf:     "20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac",
+		leafHash: "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21",
+		tweak:    "cbd8679ba636c1110ea247542cfbd964131a6be84f873f7f3b62a777528ed001",
+		tweaked:  "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+		control:  "c1187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+	},
+	{
+		internal: "93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820",
+		leaf:     "20b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007ac",
+		leafHash: "c525714a7f49c28aedbbba78c005931a81c234b2f6c99a73e4d06082adc8bf2b",
+		tweak:    "6af9e28dbf9d6aaf027696e2598a5b3d056f5fd2355a7fd5a37a0e5008132d30",
+		tweaked:  "e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e",
+		control:  "c093478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820",
+	},
+}
+
+func TestTaprootBIP341ScriptPubKey(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, v := range bip341ScriptPubKeyVectors {
+		x, _ := hex.DecodeString(v.internal)
+		internal, err := schnorr.ParseXOnlyPubKey(x)
+		assert.Nil(err)
+
+		var root []byte
+		if v.leaf != "" {
+			leaf, _ := hex.DecodeString(v.leaf)
+			root = TapLeafHash(leaf)
+			assert.Equal(v.leafHash, hex.EncodeToString(root), "vector %d", i)
+		}
+		assert.Equal(v.tweak, hex.EncodeToString(TapTweak(internal, root)), "vector %d", i)
+
+		output, err := TaprootOutputKey(internal, root)
+		assert.Nil(err)
+		assert.Equal(v.tweaked, hex.EncodeToString(schnorr.XOnly(output)), "vector %d", i)
+		pkScript, _ := P2TRpkScript(output)
+		assert.Equal("5120"+v.tweaked, hex.EncodeToString(pkScript), "vector %d", i)
+
+		if v.control != "" {
+			control := ControlBlock(internal, output)
+			assert.Equal(v.control, hex.EncodeToString(control), "vector %d", i)
+		}
+	}
+}
+
+// bip341KeyPathTx returns the unsigned tx and the spent outputs
+// of the BIP341 key path spending test vector
+func bip341KeyPathTx(t *testing.T) (*wire.MsgTx, []*wire.TxOut) {
+	raw, _ := hex.DecodeString("02000000097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a418420000000000fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffffaa5202bdf6d8ccd2ee0f0202afbbb7461d9264a25e5bfd3c5a52ee1239e0ba6c0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd050000000000000000000e664b9773b88c09c32cb70a2a3e4da0ced63b7ba3b22f848531bbb1d5d5f4c94010000000000000000e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf0000000000ffffffffa778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af10100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0065cd1d")
+	tx := wire.NewMsgTx(2)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	spent := []struct {
+		pkScript string
+		amt      int64
+	}{
+		{"512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343", 420000000},
+		{"5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3", 462000000},
+		{"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", 294000000},
+		{"5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e", 504000000},
+		{"512091b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605", 630000000},
+		{"00147dd65592d0ab2fe0d0257d571abf032cd9db93dc", 378000000},
+		{"512075169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831", 672000000},
+		{"5120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5", 546000000},
+		{"512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220", 588000000},
+	}
+	prevOuts := []*wire.TxOut{}
+	for _, s := range spent {
+		pkScript, _ := hex.DecodeString(s.pkScript)
+		prevOuts = append(prevOuts, wire.NewTxOut(s.amt, pkScript))
+	}
+	return tx, prevOuts
+}
+
+func TestTaprootBIP341KeyPathSpending(t *testing.T) {
+	assert := assert.New(t)
+
+	tx, prevOuts := bip341KeyPathTx(t)
+
+	// txin 4 is signed with SIGHASH_DEFAULT
+	hash, err := TaprootSigHash(tx, 4, prevOuts, nil)
+	assert.Nil(err)
+	assert.Equal(
+		"4f900a0bae3f1446fd48490c2958b5a023228f01661cda3496a11da502a7f7ef",
+		hex.EncodeToString(hash))
+
+	// txin 0 is spent by the key path of an output without scripts
+	privb, _ := hex.DecodeString(
+		"6b973d88838f27366ed61c9ad6367663045cb456e28335c109e30717ae0c6baa")
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), privb)
+	tweaked := tweakPrivKey(priv, nil)
+	outputx := prevOuts[0].PkScript[2:]
+	assert.Equal(outputx, schnorr.XOnly(tweaked.PubKey()))
+
+	hash, err = TaprootSigHash(tx, 0, prevOuts, nil)
+	assert.Nil(err)
+	sig, err := schnorr.SignBIP340(tweaked, hash, make([]byte, 32))
+	assert.Nil(err)
+	assert.True(schnorr.VerifyBIP340(outputx, hash, sig))
+}
+
+func TestTaprootRefundScript(t *testing.T) {
+	assert := assert.New(t)
+
+	_, pub1 := test.RandKeys()
+	_, pub2 := test.RandKeys()
+	sc, err := TaprootRefundScript(100, []*btcec.PublicKey{pub1, pub2})
+	assert.Nil(err)
+
+	disasm, _ := txscript.DisasmString(sc)
+	expected := "64 OP_CHECKLOCKTIMEVERIFY OP_DROP " +
+		hex.EncodeToString(schnorr.XOnly(pub1)) + " OP_CHECKSIGVERIFY " +
+		hex.EncodeToString(schnorr.XOnly(pub2)) + " OP_CHECKSIG"
+	assert.Equal(expected, disasm)
+
+	_, err = TaprootRefundScript(100, nil)
+	assert.NotNil(err)
+
+	// control block commits to the internal key and the output key parity
+	output, _ := TaprootOutputKey(pub1, TapLeafHash(sc))
+	control := ControlBlock(pub1, output)
+	assert.Len(control, 33)
+	assert.Equal(schnorr.XOnly(pub1), control[1:])
+	assert.Equal(byte(TapLeafVersion), control[0]&0xfe)
+	assert.Equal(!schnorr.HasEvenY(output), control[0]&0x01 == 1)
+
+	// signatures are put in reverse order
+	wt := WitnessForTaprootRefundScript([][]byte{{1}, {2}}, sc, control)
+	assert.Equal(wire.TxWitness{{2}, {1}, sc, control}, wt)
+}
@@ -1,6 +1,9 @@
 package script
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
@@ -19,8 +22,13 @@ func P2WPKHpkScript(pub *btcec.PublicKey) ([]byte, error) {
 	return builder.Script()
 }
 
-// P2WPKHpkScriptFromAddress creates a witness script for given address
+// P2WPKHpkScriptFromAddress creates a witness script for given p2wpkh address.
+// Use PkScriptFromAddress for the other address types.
 func P2WPKHpkScriptFromAddress(addr btcutil.Address) ([]byte, error) {
+	if _, ok := addr.(*btcutil.AddressWitnessPubKeyHash); !ok {
+		msg := fmt.Sprintf("address isn't p2wpkh. %T", addr)
+		return nil, &UnsupportedAddressError{error: errors.New(msg)}
+	}
 	sc := addr.ScriptAddress()
 
 	builder := txscript.NewScriptBuilder()
@@ -0,0 +1,324 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// DustThreshold is the minimum amount of a change txout.
+// A change less than this is paid as fee.
+const DustThreshold = btcutil.Amount(546)
+
+// NotEnoughUtxosError is raised when utxos don't cover requested amount
+type NotEnoughUtxosError struct{ error }
+
+func newNotEnoughUtxosError() *NotEnoughUtxosError {
+	return &NotEnoughUtxosError{error: errors.New("Not enough utxos")}
+}
+
+// CoinSelector selects utxos for requested amount
+// by considering additional fee per txin and txout.
+// The change excludes the fee for the change txout,
+// and it's 0 if no change txout is required.
+type CoinSelector interface {
+	Select(
+		utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+	) (selected []Utxo, change btcutil.Amount, err error)
+}
+
+// FirstFit selects utxos in the given order until the amount is covered
+type FirstFit struct{}
+
+// Select implements CoinSelector
+func (FirstFit) Select(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) ([]Utxo, btcutil.Amount, error) {
+	return selectInOrder(utxos, amt, feePerTxIn, feePerTxOut)
+}
+
+// LargestFirst selects utxos from the largest one.
+// It minimizes the number of txins.
+type LargestFirst struct{}
+
+// Select implements CoinSelector
+func (LargestFirst) Select(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) ([]Utxo, btcutil.Amount, error) {
+	sorted := sortUtxos(utxos, true)
+	return selectInOrder(sorted, amt, feePerTxIn, feePerTxOut)
+}
+
+// SmallestFirst selects utxos from the smallest one.
+// It consolidates small utxos at the cost of fee.
+type SmallestFirst struct{}
+
+// Select implements CoinSelector
+func (SmallestFirst) Select(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) ([]Utxo, btcutil.Amount, error) {
+	sorted := sortUtxos(utxos, false)
+	return selectInOrder(sorted, amt, feePerTxIn, feePerTxOut)
+}
+
+// bnbMaxTries is the max number of nodes explored by branch and bound
+const bnbMaxTries = 100000
+
+// BranchAndBound searches utxos that cover the amount without change.
+// The excess up to the fee for a change txout is paid as fee
+// since creating change costs more.
+// Fallback is used if no such utxos are found.
+type BranchAndBound struct {
+	Fallback CoinSelector
+}
+
+// Select implements CoinSelector
+func (s BranchAndBound) Select(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) ([]Utxo, btcutil.Amount, error) {
+	// effective values in descending order
+	sorted := []Utxo{}
+	values := []btcutil.Amount{}
+	for _, utxo := range sortUtxos(utxos, true) {
+		v, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return nil, 0, err
+		}
+		if v-feePerTxIn <= 0 {
+			continue
+		}
+		sorted = append(sorted, utxo)
+		values = append(values, v-feePerTxIn)
+	}
+
+	// remaining[i] is sum of values from i
+	remaining := make([]btcutil.Amount, len(values)+1)
+	for i := len(values) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + values[i]
+	}
+
+	upper := amt + feePerTxOut
+	var best []int
+	bestExcess := btcutil.Amount(-1)
+	tries := 0
+
+	var search func(i int, total btcutil.Amount, picked []int)
+	search = func(i int, total btcutil.Amount, picked []int) {
+		tries++
+		if tries > bnbMaxTries || bestExcess == 0 {
+			return
+		}
+		if total > upper || total+remaining[i] < amt {
+			return
+		}
+		if total >= amt {
+			if excess := total - amt; bestExcess < 0 || excess < bestExcess {
+				bestExcess = excess
+				best = append([]int{}, picked...)
+			}
+			return
+		}
+		if i >= len(values) {
+			return
+		}
+		search(i+1, total+values[i], append(picked, i))
+		search(i+1, total, picked)
+	}
+	search(0, 0, []int{})
+
+	if best == nil {
+		if s.Fallback != nil {
+			return s.Fallback.Select(utxos, amt, feePerTxIn, feePerTxOut)
+		}
+		return nil, 0, newNotEnoughUtxosError()
+	}
+
+	selected := []Utxo{}
+	for _, i := range best {
+		selected = append(selected, sorted[i])
+	}
+	return selected, 0, nil
+}
+
+// Privacy avoids linking addresses in a transaction.
+// Utxos are grouped by address and spent together so that
+// the remaining utxos don't reveal the link later.
+// It selects the smallest group covering the amount,
+// and combines the largest groups only if no single group does.
+type Privacy struct{}
+
+// Select implements CoinSelector
+func (Privacy) Select(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) ([]Utxo, btcutil.Amount, error) {
+	type group struct {
+		utxos []Utxo
+		total btcutil.Amount
+	}
+
+	groups := []*group{}
+	byAddr := make(map[string]*group)
+	for _, utxo := range utxos {
+		v, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return nil, 0, err
+		}
+		g, ok := byAddr[utxo.Address]
+		if !ok || utxo.Address == "" {
+			g = &group{}
+			groups = append(groups, g)
+			byAddr[utxo.Address] = g
+		}
+		g.utxos = append(g.utxos, utxo)
+		g.total += v - feePerTxIn
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].total < groups[j].total
+	})
+
+	for _, g := range groups {
+		if change, ok := changeFor(g.utxos, amt, feePerTxIn, feePerTxOut); ok {
+			return g.utxos, change, nil
+		}
+	}
+
+	selected := []Utxo{}
+	for i := len(groups) - 1; i >= 0; i-- {
+		selected = append(selected, groups[i].utxos...)
+		if change, ok := changeFor(selected, amt, feePerTxIn, feePerTxOut); ok {
+			return selected, change, nil
+		}
+	}
+	return nil, 0, newNotEnoughUtxosError()
+}
+
+// Manual selects utxos of given outpoints
+type Manual struct {
+	OutPoints []wire.OutPoint
+}
+
+// Select implements CoinSelector
+func (s Manual) Select(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) ([]Utxo, btcutil.Amount, error) {
+	selected := []Utxo{}
+	for _, op := range s.OutPoints {
+		found := false
+		for _, utxo := range utxos {
+			if utxo.TxID == op.Hash.String() && utxo.Vout == op.Index {
+				selected = append(selected, utxo)
+				found = true
+				break
+			}
+		}
+		if !found {
+			msg := fmt.Sprintf("utxo isn't found. %s", op)
+			return nil, 0, errors.New(msg)
+		}
+	}
+
+	change, ok := changeFor(selected, amt, feePerTxIn, feePerTxOut)
+	if !ok {
+		return nil, 0, newNotEnoughUtxosError()
+	}
+	return selected, change, nil
+}
+
+// CoinSelectorByName returns a coin selector by name.
+// Branch and bound falls back to largest first.
+func CoinSelectorByName(name string) (CoinSelector, error) {
+	switch name {
+	case "", "firstfit":
+		return FirstFit{}, nil
+	case "largest":
+		return LargestFirst{}, nil
+	case "smallest":
+		return SmallestFirst{}, nil
+	case "bnb":
+		return BranchAndBound{Fallback: LargestFirst{}}, nil
+	case "privacy":
+		return Privacy{}, nil
+	}
+	return nil, fmt.Errorf("unknown coin selection strategy: %s", name)
+}
+
+// ParseOutPoints parses comma separated outpoints in txid:vout format
+func ParseOutPoints(s string) ([]wire.OutPoint, error) {
+	ops := []wire.OutPoint{}
+	for _, str := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(str), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid outpoint: %s", str)
+		}
+		txid, err := chainhash.NewHashFromStr(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		vout, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, *wire.NewOutPoint(txid, uint32(vout)))
+	}
+	return ops, nil
+}
+
+// selectInOrder selects utxos in the given order until the amount is covered
+func selectInOrder(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) ([]Utxo, btcutil.Amount, error) {
+	selected := []Utxo{}
+	for _, utxo := range utxos {
+		selected = append(selected, utxo)
+		change, ok := changeFor(selected, amt, feePerTxIn, feePerTxOut)
+		if ok {
+			return selected, change, nil
+		}
+	}
+	return nil, 0, newNotEnoughUtxosError()
+}
+
+// changeFor calculates change of given utxos.
+// It returns false if the utxos can't pay the amount.
+// The change is 0 if it would be below DustThreshold
+// after paying the fee for a change txout.
+func changeFor(
+	utxos []Utxo, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+) (btcutil.Amount, bool) {
+	total := btcutil.Amount(0)
+	for _, utxo := range utxos {
+		v, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return 0, false
+		}
+		total += v
+	}
+
+	required := amt + feePerTxIn*btcutil.Amount(len(utxos))
+	if total < required {
+		return 0, false
+	}
+	if change := total - required - feePerTxOut; change >= DustThreshold {
+		return change, true
+	}
+	return 0, true
+}
+
+// sortUtxos returns utxos sorted by amount
+func sortUtxos(utxos []Utxo, desc bool) []Utxo {
+	sorted := append([]Utxo{}, utxos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if desc {
+			return sorted[i].Amount > sorted[j].Amount
+		}
+		return sorted[i].Amount < sorted[j].Amount
+	})
+	return sorted
+}
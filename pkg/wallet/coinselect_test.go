@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUtxo(vout uint32, amt btcutil.Amount, addr string) Utxo {
+	return Utxo{
+		TxID:    chainhash.Hash{1}.String(),
+		Vout:    vout,
+		Address: addr,
+		Amount:  amt.ToBTC(),
+	}
+}
+
+func testUtxos() []Utxo {
+	return []Utxo{
+		newTestUtxo(0, 3000, "a"),
+		newTestUtxo(1, 1000, "b"),
+		newTestUtxo(2, 5000, "a"),
+		newTestUtxo(3, 2000, "c"),
+	}
+}
+
+func vouts(utxos []Utxo) []uint32 {
+	vs := []uint32{}
+	for _, utxo := range utxos {
+		vs = append(vs, utxo.Vout)
+	}
+	return vs
+}
+
+func TestFirstFit(t *testing.T) {
+	assert := assert.New(t)
+
+	utxos, change, err := FirstFit{}.Select(testUtxos(), 3000, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{0, 1}, vouts(utxos))
+	assert.Equal(btcutil.Amount(4000-3000-20-30), change)
+
+	// change below the dust threshold is paid as fee
+	utxos, change, err = FirstFit{}.Select(testUtxos(), 3500, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{0, 1}, vouts(utxos))
+	assert.Equal(btcutil.Amount(0), change)
+
+	_, _, err = FirstFit{}.Select(testUtxos(), 11000, 10, 30)
+	assert.IsType(&NotEnoughUtxosError{}, err)
+}
+
+func TestLargestFirst(t *testing.T) {
+	assert := assert.New(t)
+
+	utxos, change, err := LargestFirst{}.Select(testUtxos(), 3500, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{2}, vouts(utxos))
+	assert.Equal(btcutil.Amount(5000-3500-10-30), change)
+}
+
+func TestSmallestFirst(t *testing.T) {
+	assert := assert.New(t)
+
+	utxos, change, err := SmallestFirst{}.Select(testUtxos(), 2000, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{1, 3}, vouts(utxos))
+	assert.Equal(btcutil.Amount(3000-2000-20-30), change)
+}
+
+func TestBranchAndBound(t *testing.T) {
+	assert := assert.New(t)
+
+	// 3000 + 2000 covers the amount without change
+	utxos, change, err := BranchAndBound{}.Select(testUtxos(), 4980, 10, 30)
+	assert.NoError(err)
+	assert.ElementsMatch([]uint32{0, 3}, vouts(utxos))
+	assert.Equal(btcutil.Amount(0), change)
+
+	// excess less than the fee for change txout is acceptable
+	utxos, change, err = BranchAndBound{}.Select(testUtxos(), 4960, 10, 30)
+	assert.NoError(err)
+	assert.ElementsMatch([]uint32{0, 3}, vouts(utxos))
+	assert.Equal(btcutil.Amount(0), change)
+
+	// no changeless solution
+	_, _, err = BranchAndBound{}.Select(testUtxos(), 4500, 10, 30)
+	assert.IsType(&NotEnoughUtxosError{}, err)
+
+	utxos, _, err = BranchAndBound{Fallback: LargestFirst{}}.Select(
+		testUtxos(), 4500, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{2}, vouts(utxos))
+}
+
+func TestPrivacy(t *testing.T) {
+	assert := assert.New(t)
+
+	// the smallest single address covering the amount
+	utxos, _, err := Privacy{}.Select(testUtxos(), 1500, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{3}, vouts(utxos))
+
+	// all utxos of address a are spent together
+	utxos, change, err := Privacy{}.Select(testUtxos(), 6000, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{0, 2}, vouts(utxos))
+	assert.Equal(btcutil.Amount(8000-6000-20-30), change)
+
+	// addresses are combined only if no single address covers the amount
+	utxos, _, err = Privacy{}.Select(testUtxos(), 9000, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{0, 2, 3}, vouts(utxos))
+}
+
+func TestManual(t *testing.T) {
+	assert := assert.New(t)
+
+	hash := chainhash.Hash{1}
+	s := Manual{OutPoints: []wire.OutPoint{
+		*wire.NewOutPoint(&hash, 1), *wire.NewOutPoint(&hash, 3)}}
+	utxos, change, err := s.Select(testUtxos(), 2000, 10, 30)
+	assert.NoError(err)
+	assert.Equal([]uint32{1, 3}, vouts(utxos))
+	assert.Equal(btcutil.Amount(3000-2000-20-30), change)
+
+	_, _, err = s.Select(testUtxos(), 5000, 10, 30)
+	assert.IsType(&NotEnoughUtxosError{}, err)
+
+	s = Manual{OutPoints: []wire.OutPoint{*wire.NewOutPoint(&hash, 9)}}
+	_, _, err = s.Select(testUtxos(), 100, 10, 30)
+	assert.Error(err)
+}
+
+func TestParseOutPoints(t *testing.T) {
+	assert := assert.New(t)
+
+	txid := chainhash.Hash{1}.String()
+	ops, err := ParseOutPoints(txid + ":1, " + txid + ":3")
+	assert.NoError(err)
+	assert.Len(ops, 2)
+	assert.Equal(uint32(3), ops[1].Index)
+
+	_, err = ParseOutPoints(txid)
+	assert.Error(err)
+}
+
+func TestCoinSelectorByName(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"", "firstfit", "bnb", "largest", "smallest", "privacy"} {
+		s, err := CoinSelectorByName(name)
+		assert.NoError(err)
+		assert.NotNil(s)
+	}
+	_, err := CoinSelectorByName("unknown")
+	assert.Error(err)
+}
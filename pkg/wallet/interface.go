@@ -1,12 +1,16 @@
 package wallet
 
 import (
+	"time"
+
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/p2pderivatives/dlc/internal/rpc"
+	"github.com/p2pderivatives/dlc/pkg/musig2"
+	"github.com/p2pderivatives/dlc/pkg/psbt"
 )
 
 // Wallet is an interface that provides access to manage pubkey addresses and
@@ -14,6 +18,10 @@ import (
 type Wallet interface {
 	NewPubkey() (*btcec.PublicKey, error)
 
+	// NewFundPubkey returns a new pubkey for a fund tx and its index
+	// on the fund account
+	NewFundPubkey() (pub *btcec.PublicKey, idx uint32, err error)
+
 	// NewAddress creates a new address
 	NewAddress() (btcutil.Address, error)
 
@@ -32,14 +40,78 @@ type Wallet interface {
 		privkeyConverter PrivateKeyConverter,
 	) (sign []byte, err error)
 
+	// SchnorrSignature returns BIP340 schnorr signature of a given hash for pubkey
+	SchnorrSignature(hash []byte, pub *btcec.PublicKey) (sign []byte, err error)
+
+	// MuSig2PartialSign returns MuSig2 partial signature of a given session
+	// using a secret nonce and the privkey of pubkey
+	MuSig2PartialSign(
+		secnonce []byte, session *musig2.Session, pub *btcec.PublicKey,
+	) (psig []byte, err error)
+
 	// WitnessSignTxByIdxs returns witness signatures for txins specified by idxs
 	WitnessSignTxByIdxs(tx *wire.MsgTx, idxs []int) ([]wire.TxWitness, error)
 
+	// WitnessSignTxByUtxos returns witness signatures for txins specified by idxs
+	// that spend given utxos. It can sign txins spending unconfirmed outputs.
+	WitnessSignTxByUtxos(
+		tx *wire.MsgTx, idxs []int, utxos []Utxo) ([]wire.TxWitness, error)
+
 	// SelectUtxos selects utxos for requested amount
 	// by considering additional fee per txin and txout
 	SelectUnspent(
 		amt, feePerTxIn, feePerTxOut btcutil.Amount,
 	) (utxos []Utxo, change btcutil.Amount, err error)
+
+	// SelectUnspentWith selects utxos for requested amount
+	// using a given coin selection strategy
+	SelectUnspentWith(
+		s CoinSelector, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+	) (utxos []Utxo, change btcutil.Amount, err error)
+
+	// SelectAndLockUnspent selects unlocked utxos for requested amount
+	// and locks them until expiry atomically.
+	// The wallet's default strategy is used if s is nil.
+	SelectAndLockUnspent(
+		s CoinSelector, amt, feePerTxIn, feePerTxOut btcutil.Amount,
+		expiry time.Time,
+	) (utxos []Utxo, change btcutil.Amount, err error)
+
+	// Send builds, signs and broadcasts a tx paying to given outputs
+	// with coin selection and change. Feerate is in satoshi/byte.
+	Send(outputs []*wire.TxOut, feerate btcutil.Amount) (*wire.MsgTx, error)
+
+	// Sweep sends all unlocked utxos to a given address
+	Sweep(addr btcutil.Address, feerate btcutil.Amount) (*wire.MsgTx, error)
+
+	// LockUtxos locks utxos until expiry so that they aren't selected
+	LockUtxos(utxos []Utxo, expiry time.Time) error
+
+	// UnlockUtxos releases locks of utxos
+	UnlockUtxos(utxos []Utxo) error
+
+	// LockedUtxos returns outpoints of locked utxos with their expiries
+	LockedUtxos() (map[wire.OutPoint]time.Time, error)
+
+	// Transactions returns confirmed txs relevant to the wallet in block order
+	Transactions() ([]Transaction, error)
+
+	// Balance returns a breakdown of the wallet balance
+	Balance() (*Balance, error)
+
+	// Recover rescans the chain to restore funds of a wallet created from
+	// an existing seed, watching addresses up to gapLimit beyond the last
+	// derived address of external and internal branches
+	Recover(gapLimit uint32) error
+
+	// RecoverPubkeys derives addresses of given pubkeys found within gapLimit
+	// and returns the recovered pubkeys
+	RecoverPubkeys(
+		pubs []*btcec.PublicKey, gapLimit uint32) ([]*btcec.PublicKey, error)
+
+	// RecoverFundPubkeys derives fund pubkeys up to a given index
+	RecoverFundPubkeys(lastIdx uint32) error
+
 	// Unlock unlocks address manager
 	Unlock(privPass []byte) error
 
@@ -47,6 +119,13 @@ type Wallet interface {
 	// SetRPCClient sets rpcclient
 	SetRPCClient(rpc.Client)
 
+	// SetSigner sets a signer to delegate signing to
+	SetSigner(Signer)
+
+	// WatchOnly returns true if the wallet has no private keys.
+	// It can't sign with keys tweaked by WitnessSignatureWithCallback.
+	WatchOnly() bool
+
 	// methods delegating to RPC Client
 	ListUnspent() (utxos []Utxo, err error)
 	SendRawTransaction(tx *wire.MsgTx) (*chainhash.Hash, error)
@@ -57,5 +136,42 @@ type Wallet interface {
 // Utxo is an unspent transaction output
 type Utxo = btcjson.ListUnspentResult
 
+// Balance is a breakdown of the wallet balance
+type Balance struct {
+	Confirmed         btcutil.Amount // spendable
+	Unconfirmed       btcutil.Amount // unmined or immature
+	Reserved          btcutil.Amount // locked for contracts being negotiated
+	LockedInContracts btcutil.Amount // collateral in fund outputs of open contracts
+}
+
+// Total returns the sum of all balances
+func (b *Balance) Total() btcutil.Amount {
+	return b.Confirmed + b.Unconfirmed + b.Reserved + b.LockedInContracts
+}
+
+// Transaction is a confirmed tx relevant to the wallet
+type Transaction struct {
+	MsgTx     *wire.MsgTx
+	Height    int32
+	BlockTime time.Time
+	Credits   []TxCredit     // txouts paying to the wallet
+	Debit     btcutil.Amount // total amount of wallet utxos spent by the tx
+}
+
+// TxCredit is a txout paying to the wallet
+type TxCredit struct {
+	Index  uint32
+	Amount btcutil.Amount
+	Change bool // paid to an internal address
+}
+
 // PrivateKeyConverter is a callback func applied to private key before creating witness signature
 type PrivateKeyConverter func(*btcec.PrivateKey) (*btcec.PrivateKey, error)
+
+// Signer signs PSBTs with keys kept outside the wallet
+// (e.g. hardware or offline signers)
+type Signer interface {
+	// SignPSBT adds partial signatures of inputs it has keys of,
+	// which are identified by BIP32 derivations of the inputs
+	SignPSBT(p *psbt.Packet) (*psbt.Packet, error)
+}
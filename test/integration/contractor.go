@@ -2,6 +2,7 @@ package integration
 
 import (
 	"github.com/btcsuite/btcutil"
+	"github.com/p2pderivatives/dlc/internal/rpc"
 	"github.com/p2pderivatives/dlc/pkg/dlc"
 	"github.com/p2pderivatives/dlc/pkg/wallet"
 )
@@ -36,6 +37,10 @@ func (c *Contractor) createDLCBuilder(
 	d.Addrs[p], _ = c.Wallet.NewAddress()
 	d.ChangeAddrs[p], _ = c.Wallet.NewAddress()
 	c.DLCBuilder = dlc.NewBuilder(p, c.Wallet, d)
+
+	// utxos of the counterparty are looked up on chain
+	rpcclient, _ := rpc.NewTestRPCClient()
+	c.DLCBuilder.SetRPCClient(rpcclient)
 }
 
 func (c *Contractor) unlockWallet() {
@@ -107,7 +107,8 @@ func contractorSignAllTxs(t *testing.T, c1, c2 *Contractor) {
 	assert.NoError(t, err)
 	err = c2.DLCBuilder.AcceptRefundTxSignature(rfSig)
 	assert.NoError(t, err)
-	c2.DLCBuilder.AcceptFundWitnesses(fundWits)
+	err = c2.DLCBuilder.AcceptFundWitnesses(fundWits)
+	assert.NoError(t, err)
 }
 
 // A contractor signs CETxs
@@ -138,6 +139,10 @@ func contractorSignFundTx(t *testing.T, c *Contractor) []wire.TxWitness {
 	// unlocks to sign txs
 	c.unlockWallet()
 
+	// verify contract before signing fund tx
+	err := c.DLCBuilder.Verify()
+	assert.NoError(t, err)
+
 	// create fund tx witnesses
 	wits, err := c.DLCBuilder.SignFundTx()
 	assert.NoError(t, err)
@@ -146,7 +151,9 @@ func contractorSignFundTx(t *testing.T, c *Contractor) []wire.TxWitness {
 }
 
 func contractorSendFundTx(t *testing.T, c *Contractor) {
-	_, err := c.DLCBuilder.SignFundTx()
+	err := c.DLCBuilder.Verify()
+	assert.NoError(t, err)
+	_, err = c.DLCBuilder.SignFundTx()
 	assert.NoError(t, err)
 	err = c.DLCBuilder.SendFundTx()
 	assert.NoError(t, err)